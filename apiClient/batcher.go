@@ -0,0 +1,220 @@
+package apiClient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/turbot/steampipe-plugin-turbot/errors"
+)
+
+const (
+	// how long a batcher waits for more lookups before sending the batch
+	defaultBatchWait = 5 * time.Millisecond
+	// a batch is sent as soon as it reaches this size
+	defaultBatchSize = 100
+)
+
+// Batcher collects single item lookups of a query field, e.g. notification(id: $id), made concurrently
+// by separate hydrate calls. Lookups made within a few milliseconds of each other are sent as one GraphQL
+// document, with an aliased field per item, and the response is demultiplexed back to each caller.
+type Batcher struct {
+	client    *Client
+	field     string
+	selection string
+	wait      time.Duration
+	size      int
+
+	mutex   sync.Mutex
+	pending []*batchItem
+	timer   *time.Timer
+}
+
+type batchItem struct {
+	id     string
+	result chan batchResult
+}
+
+type batchResult struct {
+	data json.RawMessage
+	err  error
+}
+
+// Batcher returns the batcher for lookups of the given query field and selection set, creating it if needed.
+// Batchers are shared by all callers using the client.
+func (client *Client) Batcher(field, selection string) *Batcher {
+	client.batchersMutex.Lock()
+	defer client.batchersMutex.Unlock()
+
+	key := field + selection
+	if batcher, ok := client.batchers[key]; ok {
+		return batcher
+	}
+	if client.batchers == nil {
+		client.batchers = map[string]*Batcher{}
+	}
+	batcher := &Batcher{
+		client:    client,
+		field:     field,
+		selection: selection,
+		wait:      defaultBatchWait,
+		size:      defaultBatchSize,
+	}
+	client.batchers[key] = batcher
+	return batcher
+}
+
+// Get queues a lookup of the item with the given id and waits for the batch containing it to be executed.
+// It returns the raw JSON of the item, or nil if the item does not exist.
+func (b *Batcher) Get(ctx context.Context, id string) (json.RawMessage, error) {
+	item := &batchItem{id: id, result: make(chan batchResult, 1)}
+
+	b.mutex.Lock()
+	b.pending = append(b.pending, item)
+	if len(b.pending) >= b.size {
+		b.flushLocked()
+	} else if b.timer == nil {
+		b.timer = time.AfterFunc(b.wait, b.flush)
+	}
+	b.mutex.Unlock()
+
+	select {
+	case result := <-item.result:
+		return result.data, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// GetAll reads the items with the given ids directly, in batches of the batcher size, without waiting for
+// other lookups. It returns the raw JSON of each item which exists, keyed by id.
+func (b *Batcher) GetAll(ids []string) (map[string]json.RawMessage, error) {
+	items := map[string]json.RawMessage{}
+	for start := 0; start < len(ids); start += b.size {
+		end := start + b.size
+		if end > len(ids) {
+			end = len(ids)
+		}
+		for id, result := range b.client.readBatch(b.field, ids[start:end], b.selection) {
+			if result.err != nil {
+				if errors.NotFoundError(result.err) {
+					continue
+				}
+				return nil, result.err
+			}
+			if result.data != nil {
+				items[id] = result.data
+			}
+		}
+	}
+	return items, nil
+}
+
+func (b *Batcher) flush() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.flushLocked()
+}
+
+// take the pending items and execute them in the background - the caller must hold the mutex
+func (b *Batcher) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+	items := b.pending
+	b.pending = nil
+	go b.execute(items)
+}
+
+func (b *Batcher) execute(items []*batchItem) {
+	// the same item may be requested more than once - only request it once
+	var ids []string
+	waiting := map[string][]*batchItem{}
+	for _, item := range items {
+		if _, ok := waiting[item.id]; !ok {
+			ids = append(ids, item.id)
+		}
+		waiting[item.id] = append(waiting[item.id], item)
+	}
+
+	results := b.client.readBatch(b.field, ids, b.selection)
+	for id, result := range results {
+		for _, item := range waiting[id] {
+			item.result <- result
+		}
+	}
+}
+
+// read a batch of items of the given query field in a single request, returning a result for each id.
+// If the batch fails with a GraphQL error other than not found, which may be caused by any of its items, it
+// is split in halves which are read in turn, so an error is only returned for the items which caused it, in
+// a few requests per failing item. Other errors, e.g. of the network or credentials, are not caused by the
+// items, so the error of the batch is returned for each of them.
+func (client *Client) readBatch(field string, ids []string, selection string) map[string]batchResult {
+	results := map[string]batchResult{}
+	query, variables := batchQuery(field, ids, selection)
+	var responseData = map[string]json.RawMessage{}
+
+	// execute api call
+	// NOTE: the response data is still populated for the items which were found when the api returns an error
+	// for one of the aliases
+	err := client.doRequest(query, variables, &responseData)
+	if err != nil && !errors.NotFoundError(err) {
+		if len(ids) == 1 || ErrorClass(err) != "graphql" {
+			for _, id := range ids {
+				results[id] = batchResult{err: err}
+			}
+			return results
+		}
+		half := len(ids) / 2
+		for _, part := range [][]string{ids[:half], ids[half:]} {
+			for k, v := range client.readBatch(field, part, selection) {
+				results[k] = v
+			}
+		}
+		return results
+	}
+
+	for i, id := range ids {
+		data, ok := responseData[batchAlias(i)]
+		switch {
+		case ok && string(data) != "null":
+			results[id] = batchResult{data: data}
+		case err != nil:
+			results[id] = batchResult{err: fmt.Errorf("error reading %s: not found: %s", field, id)}
+		default:
+			results[id] = batchResult{}
+		}
+	}
+	return results
+}
+
+// build a query reading a batch of items of the given query field in a single request. Each item is aliased
+// by its position, i.e. item0, item1..., and its id passed as a variable, to avoid escaping the id in the query
+func batchQuery(field string, ids []string, selection string) (string, map[string]interface{}) {
+	var args, fields bytes.Buffer
+	variables := map[string]interface{}{}
+	for i, id := range ids {
+		variable := fmt.Sprintf("id%d", i)
+		if i > 0 {
+			args.WriteString(", ")
+		}
+		args.WriteString(fmt.Sprintf("$%s: ID!", variable))
+		fields.WriteString(fmt.Sprintf("\t%s: %s(id: $%s) {\n%s\n\t}\n", batchAlias(i), field, variable, selection))
+		variables[variable] = id
+	}
+	query := fmt.Sprintf(`query %sBatch(%s) {
+%s}`, field, args.String(), fields.String())
+	return query, variables
+}
+
+func batchAlias(index int) string {
+	return fmt.Sprintf("item%d", index)
+}
@@ -0,0 +1,165 @@
+package apiClient
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/machinebox/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/errors"
+)
+
+func TestBatchQuery(t *testing.T) {
+	type test struct {
+		name              string
+		field             string
+		ids               []string
+		expectedQuery     string
+		expectedVariables map[string]interface{}
+	}
+	tests := []test{
+		{
+			"Single aka",
+			"resource",
+			[]string{"arn:aws:s3:::my-bucket"},
+			`query resourceBatch($id0: ID!) {
+	item0: resource(id: $id0) {
+turbot { id }
+	}
+}`,
+			map[string]interface{}{"id0": "arn:aws:s3:::my-bucket"},
+		},
+		{
+			"Multiple ids",
+			"policyType",
+			[]string{"123456789012345", "tmod:@turbot/aws-s3#/policy/types/bucketVersioning"},
+			`query policyTypeBatch($id0: ID!, $id1: ID!) {
+	item0: policyType(id: $id0) {
+turbot { id }
+	}
+	item1: policyType(id: $id1) {
+turbot { id }
+	}
+}`,
+			map[string]interface{}{"id0": "123456789012345", "id1": "tmod:@turbot/aws-s3#/policy/types/bucketVersioning"},
+		},
+	}
+	for _, test := range tests {
+		log.Println(test.name)
+		query, variables := batchQuery(test.field, test.ids, "turbot { id }")
+		assert.Equal(t, test.expectedQuery, query)
+		assert.Equal(t, test.expectedVariables, variables)
+	}
+}
+
+func TestBatcherGet(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		var body struct {
+			Variables map[string]string
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		data := map[string]interface{}{}
+		for variable, id := range body.Variables {
+			alias := "item" + strings.TrimPrefix(variable, "id")
+			if id == "missing" {
+				data[alias] = nil
+			} else {
+				data[alias] = map[string]interface{}{"turbot": map[string]string{"id": id}}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":   data,
+			"errors": []map[string]string{{"message": "Not Found: missing"}},
+		})
+	}))
+	defer server.Close()
+
+	client := &Client{Graphql: graphql.NewClient(server.URL)}
+	batcher := client.Batcher("resource", "turbot { id }")
+
+	ids := []string{"1", "2", "missing", "2"}
+	results := make([]json.RawMessage, len(ids))
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			results[i], errs[i] = batcher.Get(context.Background(), id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	assert.JSONEq(t, `{"turbot":{"id":"1"}}`, string(results[0]))
+	assert.JSONEq(t, `{"turbot":{"id":"2"}}`, string(results[1]))
+	assert.JSONEq(t, `{"turbot":{"id":"2"}}`, string(results[3]))
+	assert.Nil(t, results[2])
+	assert.True(t, errors.NotFoundError(errs[2]))
+	assert.NoError(t, errs[0])
+}
+
+func TestReadBatchFailure(t *testing.T) {
+	var requests int32
+	var status int32 = http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if code := int(atomic.LoadInt32(&status)); code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+		var body struct {
+			Variables map[string]string
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		data := map[string]interface{}{}
+		for variable, id := range body.Variables {
+			// an item which fails the whole batch
+			if id == "bad" {
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []map[string]string{{"message": "Internal error: bad"}}})
+				return
+			}
+			data["item"+strings.TrimPrefix(variable, "id")] = map[string]interface{}{"turbot": map[string]string{"id": id}}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+	client := &Client{Graphql: graphql.NewClient(server.URL)}
+
+	var ids []string
+	for i := 0; i < 100; i++ {
+		ids = append(ids, strconv.Itoa(i))
+	}
+	ids[37] = "bad"
+
+	// the failing item is found by splitting the batch, rather than reading each item
+	results := client.readBatch("resource", ids, "turbot { id }")
+	assert.LessOrEqual(t, atomic.LoadInt32(&requests), int32(15))
+	assert.Len(t, results, 100)
+	assert.ErrorContains(t, results["bad"].err, "Internal error: bad")
+	for _, id := range ids {
+		if id != "bad" {
+			assert.NoError(t, results[id].err, id)
+			assert.JSONEq(t, `{"turbot":{"id":"`+id+`"}}`, string(results[id].data))
+		}
+	}
+
+	// an error which is not caused by the items is returned for each of them, from a single request
+	atomic.StoreInt32(&requests, 0)
+	atomic.StoreInt32(&status, http.StatusInternalServerError)
+	results = client.readBatch("resource", ids, "turbot { id }")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	for _, id := range ids {
+		assert.Equal(t, "server", ErrorClass(results[id].err), id)
+	}
+}
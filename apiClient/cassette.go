@@ -0,0 +1,367 @@
+package apiClient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cassetteVersion is the version of the cassette file format
+const cassetteVersion = 1
+
+// redacted replaces the value of credential headers in a cassette
+const redacted = "REDACTED"
+
+// headers which hold credentials, and are redacted when recorded
+var credentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+// fields of request and response bodies which hold secrets, e.g. of directories and secret policy values, and
+// are redacted when recorded
+var sensitiveFields = map[string]bool{
+	"clientSecret":        true,
+	"password":            true,
+	"privateKey":          true,
+	"secretAccessKey":     true,
+	"secretKey":           true,
+	"secretValue":         true,
+	"secretValueSource":   true,
+	"signaturePrivateKey": true,
+}
+
+// Cassette is a recording of the GraphQL requests made by a client and the responses to them. Recordings
+// let a query be reproduced without access to the workspace it was run against.
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+type CassetteRequest struct {
+	Method  string          `json:"method"`
+	URL     string          `json:"url"`
+	Headers http.Header     `json:"headers,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+}
+
+type CassetteResponse struct {
+	StatusCode int             `json:"status_code"`
+	Headers    http.Header     `json:"headers,omitempty"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	// the body of a response which is not JSON, e.g. an error page from a proxy
+	Text string `json:"text,omitempty"`
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette %s: %s", path, err.Error())
+	}
+	cassette := &Cassette{}
+	if err = json.Unmarshal(data, cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %s", path, err.Error())
+	}
+	if cassette.Version != cassetteVersion {
+		return nil, fmt.Errorf("unsupported cassette version %d in %s", cassette.Version, path)
+	}
+	return cassette, nil
+}
+
+// Save writes the cassette to a file. The file is written in full and then renamed, so a failed write
+// never leaves a partial cassette.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cassette %s: %s", path, err.Error())
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cassette %s: %s", path, err.Error())
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cassette %s: %s", path, err.Error())
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// RecordingTransport is an http.RoundTripper which records each request and its response to a cassette
+// file, with credentials redacted. The file is rewritten after each request, so it is complete even if
+// the plugin is stopped.
+type RecordingTransport struct {
+	path      string
+	transport http.RoundTripper
+	recording *recording
+}
+
+// recording is a cassette being recorded, shared by the transports recording to its file
+type recording struct {
+	mutex    sync.Mutex
+	cassette *Cassette
+}
+
+var (
+	// the cassettes being recorded, by path, so a new client appends to the recording of the clients before it
+	recordings      = map[string]*recording{}
+	recordingsMutex sync.Mutex
+)
+
+// NewRecordingTransport creates a transport recording the requests made with the given transport to the
+// cassette file at path. The requests are appended to the cassette if the file exists, e.g. recorded by
+// another client of the connection.
+func NewRecordingTransport(path string, transport http.RoundTripper) (*RecordingTransport, error) {
+	if absolute, err := filepath.Abs(path); err == nil {
+		path = absolute
+	}
+	recordingsMutex.Lock()
+	defer recordingsMutex.Unlock()
+	if r, ok := recordings[path]; ok {
+		return &RecordingTransport{path: path, transport: transport, recording: r}, nil
+	}
+
+	cassette := &Cassette{Version: cassetteVersion, Interactions: []Interaction{}}
+	if _, err := os.Stat(path); err == nil {
+		// a file which is not a cassette is not overwritten
+		if cassette, err = LoadCassette(path); err != nil {
+			return nil, err
+		}
+	} else if err = cassette.Save(path); err != nil {
+		// the file is created now, so an invalid path is reported when the client is created
+		return nil, err
+	}
+	r := &recording{cassette: cassette}
+	recordings[path] = r
+	return &RecordingTransport{path: path, transport: transport, recording: r}, nil
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Request: CassetteRequest{
+			Method:  req.Method,
+			URL:     redactURL(req.URL),
+			Headers: redactHeaders(req.Header),
+			Body:    redactBody(jsonOrNil(requestBody)),
+		},
+		Response: CassetteResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    redactHeaders(resp.Header),
+		},
+	}
+	if body := jsonOrNil(responseBody); body != nil {
+		interaction.Response.Body = redactBody(body)
+	} else {
+		interaction.Response.Text = string(responseBody)
+	}
+
+	t.recording.mutex.Lock()
+	defer t.recording.mutex.Unlock()
+	t.recording.cassette.Interactions = append(t.recording.cassette.Interactions, interaction)
+	if err = t.recording.cassette.Save(t.path); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ReplayTransport is an http.RoundTripper serving the responses recorded in a cassette, with no network
+// access. Requests are matched by their body, i.e. the query and its variables. A request made more times
+// than it was recorded is served its last recorded response.
+type ReplayTransport struct {
+	cassette *Cassette
+
+	mutex     sync.Mutex
+	responses map[string][]CassetteResponse
+	served    map[string]int
+}
+
+// NewReplayTransport creates a transport serving the responses in the cassette file at path
+func NewReplayTransport(path string) (*ReplayTransport, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &ReplayTransport{
+		cassette:  cassette,
+		responses: map[string][]CassetteResponse{},
+		served:    map[string]int{},
+	}
+	for _, interaction := range cassette.Interactions {
+		key := requestKey(interaction.Request.Body)
+		t.responses[key] = append(t.responses[key], interaction.Response)
+	}
+	return t, nil
+}
+
+// Endpoint returns the URL of the first recorded request, or an empty string if there are none
+func (t *ReplayTransport) Endpoint() string {
+	if len(t.cassette.Interactions) == 0 {
+		return ""
+	}
+	return t.cassette.Interactions[0].Request.URL
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	key := requestKey(body)
+
+	t.mutex.Lock()
+	responses := t.responses[key]
+	if len(responses) == 0 {
+		t.mutex.Unlock()
+		return nil, fmt.Errorf("no recorded response for request: %s", string(body))
+	}
+	i := t.served[key]
+	if i >= len(responses) {
+		i = len(responses) - 1
+	}
+	t.served[key]++
+	t.mutex.Unlock()
+
+	recorded := responses[i]
+	responseBody := []byte(recorded.Body)
+	if recorded.Body == nil {
+		responseBody = []byte(recorded.Text)
+	}
+	header := recorded.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(responseBody)),
+		ContentLength: int64(len(responseBody)),
+		Request:       req,
+	}, nil
+}
+
+// read a request or response body, replacing it with a reader of the same bytes
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// requestKey returns the canonical form of a request body, so requests with the same query and variables
+// match whatever the order of the variables. Sensitive fields are redacted, as they are in the cassette.
+func requestKey(body []byte) string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return string(body)
+	}
+	redactValue(value)
+	canonical, _ := json.Marshal(value)
+	return string(canonical)
+}
+
+// jsonOrNil returns the data as raw JSON if it is valid JSON, so it is readable in the cassette, or nil
+func jsonOrNil(data []byte) json.RawMessage {
+	if len(data) == 0 || !json.Valid(data) {
+		return nil
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return nil
+	}
+	return compact.Bytes()
+}
+
+func redactHeaders(header http.Header) http.Header {
+	result := header.Clone()
+	for _, name := range credentialHeaders {
+		if _, ok := result[name]; ok {
+			result.Set(name, redacted)
+		}
+	}
+	return result
+}
+
+func redactURL(u *url.URL) string {
+	redactedURL := *u
+	if redactedURL.User != nil {
+		redactedURL.User = url.User(redacted)
+	}
+	return redactedURL.String()
+}
+
+// redactBody replaces the values of the sensitive fields of a JSON body
+func redactBody(body json.RawMessage) json.RawMessage {
+	if body == nil {
+		return nil
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil || !redactValue(value) {
+		return body
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return data
+}
+
+// redactValue replaces the sensitive fields of a decoded JSON value, returning whether any were set
+func redactValue(value interface{}) bool {
+	changed := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if sensitiveFields[key] && field != nil {
+				v[key] = redacted
+				changed = true
+			} else if redactValue(field) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if redactValue(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,147 @@
+package apiClient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/machinebox/graphql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordReplay(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		if strings.Contains(string(body), "missing") {
+			_, _ = w.Write([]byte(`{"data": {"resource": null}, "errors": [{"message": "Not Found: resource missing"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"resource": {"turbot": {"id": "` + strconv.Itoa(int(n)) + `"}}}}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	query := `query resource($id: ID!) { resource(id: $id) { turbot { id } } }`
+	type response struct {
+		Resource *struct {
+			Turbot TurbotResourceMetadata
+		}
+	}
+
+	// record
+	client, err := CreateClient(ClientConfig{
+		Credentials: ClientCredentials{AccessKey: "access", SecretKey: "secret", Workspace: strings.TrimPrefix(server.URL, "http://")},
+		RecordPath:  path,
+	})
+	assert.NoError(t, err)
+	// GetCredentials forces https, so point the recording client at the test server
+	transport, err := NewRecordingTransport(path, http.DefaultTransport)
+	assert.NoError(t, err)
+	client.Graphql = graphql.NewClient(server.URL, graphql.WithHTTPClient(&http.Client{Transport: transport}))
+
+	var recorded []string
+	for _, id := range []string{"a", "a", "b"} {
+		result := response{}
+		assert.NoError(t, client.DoRequest(query, map[string]interface{}{"id": id}, &result))
+		recorded = append(recorded, result.Resource.Turbot.Id)
+	}
+	assert.Error(t, client.DoRequest(query, map[string]interface{}{"id": "missing"}, &response{}))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), basicAuthHeader("access", "secret"))
+	assert.NotContains(t, string(data), "session=secret")
+	assert.Contains(t, string(data), redacted)
+
+	// replay, with no requests reaching the server
+	client, err = CreateClient(ClientConfig{ReplayPath: path})
+	assert.NoError(t, err)
+	var replayed []string
+	for _, id := range []string{"a", "a", "b"} {
+		result := response{}
+		assert.NoError(t, client.DoRequest(query, map[string]interface{}{"id": id}, &result))
+		replayed = append(replayed, result.Resource.Turbot.Id)
+	}
+	assert.Equal(t, recorded, replayed)
+
+	// a request made more times than recorded gets the last response
+	result := response{}
+	assert.NoError(t, client.DoRequest(query, map[string]interface{}{"id": "a"}, &result))
+	assert.Equal(t, recorded[1], result.Resource.Turbot.Id)
+
+	err = client.DoRequest(query, map[string]interface{}{"id": "missing"}, &response{})
+	assert.ErrorContains(t, err, "Not Found")
+	err = client.DoRequest(query, map[string]interface{}{"id": "c"}, &response{})
+	assert.ErrorContains(t, err, "no recorded response")
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+}
+
+func TestCreateClientRecordAndReplay(t *testing.T) {
+	_, err := CreateClient(ClientConfig{RecordPath: "a.json", ReplayPath: "b.json"})
+	assert.Error(t, err)
+	_, err = CreateClient(ClientConfig{ReplayPath: filepath.Join(t.TempDir(), "missing.json")})
+	assert.ErrorContains(t, err, "failed to read cassette")
+}
+
+func TestRecordingAppendsAndRedactsBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"directory": {"password": "hunter2", "clientSecret": null, "title": "LDAP"}}}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	query := `query directory($id: ID!) { directory: resource(id: $id) { password clientSecret title } }`
+	request := func(transport *RecordingTransport, id string) {
+		client := &Client{Graphql: graphql.NewClient(server.URL, graphql.WithHTTPClient(&http.Client{Transport: transport}))}
+		assert.NoError(t, client.DoRequest(query, map[string]interface{}{"id": id}, &map[string]interface{}{}))
+	}
+
+	// each client appends to the cassette of the path
+	for _, id := range []string{"a", "b"} {
+		transport, err := NewRecordingTransport(path, http.DefaultTransport)
+		if !assert.NoError(t, err) {
+			return
+		}
+		request(transport, id)
+	}
+	cassette, err := LoadCassette(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, cassette.Interactions, 2)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+	assert.JSONEq(t, `{"data": {"directory": {"password": "REDACTED", "clientSecret": null, "title": "LDAP"}}}`, string(cassette.Interactions[0].Response.Body))
+
+	// a recording started by another process is appended to
+	recordingsMutex.Lock()
+	recordings = map[string]*recording{}
+	recordingsMutex.Unlock()
+	transport, err := NewRecordingTransport(path, http.DefaultTransport)
+	if !assert.NoError(t, err) {
+		return
+	}
+	request(transport, "c")
+	cassette, err = LoadCassette(path)
+	assert.NoError(t, err)
+	assert.Len(t, cassette.Interactions, 3)
+
+	// a file which is not a cassette is not overwritten
+	other := filepath.Join(t.TempDir(), "notes.txt")
+	assert.NoError(t, os.WriteFile(other, []byte("notes"), 0600))
+	_, err = NewRecordingTransport(other, http.DefaultTransport)
+	assert.ErrorContains(t, err, "failed to parse cassette")
+}
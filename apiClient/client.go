@@ -5,95 +5,162 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/go-yaml/yaml"
 	"github.com/machinebox/graphql"
 	"github.com/mitchellh/go-homedir"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient/snapshot"
 	errorsHandler "github.com/turbot/steampipe-plugin-turbot/errors"
 	"github.com/turbot/steampipe-plugin-turbot/helpers"
 )
 
+// snapshotEndpoint is the URL of the API of a client serving a snapshot, which is never requested
+const snapshotEndpoint = "https://snapshot/api/latest/graphql"
+
 // Turbot API Client
 type Client struct {
 	AccessKey string
 	SecretKey string
 	Graphql   *graphql.Client
+
+	// batchers for single item lookups, keyed by query field and selection
+	batchers      map[string]*Batcher
+	batchersMutex sync.Mutex
+
+	// the provider of the credentials, if they expire
+	credentials      ExpiringCredentialProvider
+	credentialsMutex sync.Mutex
+
+	// OnAuthFailure is called when the workspace rejects the credentials of a request, e.g. after the access key
+	// is rotated, so the owner of the client can replace it
+	OnAuthFailure func()
+	// OnRequest is called after each request, with its stats, see RequestStats
+	OnRequest func(RequestStats)
+	// DebugQueries adds the GraphQL document and variables of each request to its stats, and logs them
+	DebugQueries bool
+
+	// the log of the client's requests, if the config sets a trace file
+	requestLog *requestLog
+	// the page numbers of the next pages of paged requests
+	pages pageTracker
 }
 
 func CreateClient(config ClientConfig) (*Client, error) {
 	// if accessKeyId and secretAccessKey were not directly specified (either via provider parameters or environment variables)
 	// look for a credentials file
 
-	credentials, err := GetCredentials(config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get credentials, error: %s", err.Error())
+	if config.ReplayPath != "" && (config.RecordPath != "" || config.SnapshotPath != "") {
+		return nil, errors.New("a client replaying a cassette cannot also record a cassette or use a snapshot")
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+	var credentials ClientCredentials
+	var provider CredentialProvider
+	var endpoint string
+	switch {
+	case config.ReplayPath != "":
+		// a replayed client makes no network requests, so needs no credentials
+		replay, err := NewReplayTransport(config.ReplayPath)
+		if err != nil {
+			return nil, err
+		}
+		transport = replay
+		endpoint = replay.Endpoint()
+	case config.SnapshotPath != "":
+		// as does a client serving a snapshot
+		store, err := snapshot.LoadSnapshot(config.SnapshotPath)
+		if err != nil {
+			return nil, err
+		}
+		transport = snapshot.NewServer(store)
+		endpoint = snapshotEndpoint
+	default:
+		var err error
+		credentials, provider, err = getCredentials(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credentials, error: %s", err.Error())
+		}
+		endpoint = credentials.Workspace
 	}
-	return &Client{
+
+	if config.RecordPath != "" {
+		recording, err := NewRecordingTransport(config.RecordPath, transport)
+		if err != nil {
+			return nil, err
+		}
+		transport = recording
+	}
+	// the bytes of each response are counted for its stats
+	transport = &countingTransport{transport: transport}
+	client := &Client{
 		AccessKey: credentials.AccessKey,
 		SecretKey: credentials.SecretKey,
-		Graphql:   graphql.NewClient(credentials.Workspace),
-	}, nil
+		Graphql:   graphql.NewClient(endpoint, graphql.WithHTTPClient(&http.Client{Transport: transport})),
+
+		DebugQueries: config.DebugQueries,
+	}
+	if config.TracePath != "" {
+		requestLog, err := newRequestLog(config.TracePath)
+		if err != nil {
+			return nil, err
+		}
+		client.requestLog = requestLog
+	}
+	if expiring, ok := provider.(ExpiringCredentialProvider); ok {
+		client.credentials = expiring
+	}
+	return client, nil
 }
 
 func GetCredentials(config ClientConfig) (ClientCredentials, error) {
-	credentials, err := getCredentialsByPrecedence(config)
+	credentials, _, err := getCredentials(config)
+	return credentials, err
+}
+
+// getCredentials returns the credentials of the config, and the provider they are from
+func getCredentials(config ClientConfig) (ClientCredentials, CredentialProvider, error) {
+	credentials, provider, err := getCredentialsByPrecedence(config)
 	if err != nil {
-		return ClientCredentials{}, err
+		return ClientCredentials{}, nil, err
 	}
 	if !CredentialsSet(credentials) {
-		return ClientCredentials{}, errors.New("failed to get credentials")
+		return ClientCredentials{}, nil, errors.New("failed to get credentials")
 	}
 	// update workspace url
 	credentials.Workspace, err = BuildApiUrl(credentials.Workspace)
 	if err != nil {
-		return ClientCredentials{}, err
+		return ClientCredentials{}, nil, err
 	}
-	return credentials, nil
+	return credentials, provider, nil
 }
 
-/*
-	precedence of credentials:
-	- Credentials set in config
-	- profile set in config
-	- ENV vars {TURBOT_ACCESS_KEY, TURBOT_SECRET_KEY, TURBOT_WORKSPACE}
-	- TURBOT_PROFILE env var
-*/
-func getCredentialsByPrecedence(config ClientConfig) (ClientCredentials, error) {
-	credentials := config.Credentials
-	if !CredentialsSet(credentials) {
-		var err error
-		credentialsPath, err := getCredentialsPath(config)
+// getCredentialsByPrecedence returns the credentials of the first provider of the config which has them, see
+// credentialProviders for their precedence
+func getCredentialsByPrecedence(config ClientConfig) (ClientCredentials, CredentialProvider, error) {
+	providers, err := credentialProviders(config)
+	if err != nil {
+		return ClientCredentials{}, nil, err
+	}
+	for _, provider := range providers {
+		credentials, err := provider.Retrieve()
 		if err != nil {
-			return ClientCredentials{}, err
+			return ClientCredentials{}, nil, err
 		}
-		if len(config.Profile) != 0 {
-			credentials, err = getProfileCredentials(config)
-			if err != nil {
-				return ClientCredentials{}, err
-			}
-		} else {
-			var credentialsOk bool
-			credentials, credentialsOk = getCredentialsFromEnv()
-			// if credentials were not passed in, get from the credentials file
-			if !credentialsOk {
-				config.Profile = os.Getenv("TURBOT_PROFILE")
-				credentials, err = loadProfile(credentialsPath, config.Profile)
-				if err != nil {
-					return ClientCredentials{}, err
-				}
-			}
+		if CredentialsSet(credentials) {
+			return credentials, provider, nil
 		}
 	}
-	return credentials, nil
+	return ClientCredentials{}, nil, nil
 }
 
 func getCredentialsFromEnv() (ClientCredentials, bool) {
@@ -105,18 +172,6 @@ func getCredentialsFromEnv() (ClientCredentials, bool) {
 	return credentials, CredentialsSet(credentials)
 }
 
-func getProfileCredentials(config ClientConfig) (ClientCredentials, error) {
-	credentialsPath, err := getCredentialsPath(config)
-	if err != nil {
-		return ClientCredentials{}, err
-	}
-	credentials, err := loadProfile(credentialsPath, config.Profile)
-	if err != nil {
-		return ClientCredentials{}, err
-	}
-	return credentials, nil
-}
-
 func getCredentialsPath(config ClientConfig) (string, error) {
 	var err error
 	credentialsPath := config.CredentialsPath
@@ -199,35 +254,38 @@ func userHomeDir() string {
 	return os.Getenv("HOME")
 }
 
-func loadProfile(credentialsPath, profile string) (ClientCredentials, error) {
-	// if no profile specified, use default
-	if len(profile) == 0 {
-		profile = "default"
-	}
-	yamlFile, err := os.ReadFile(credentialsPath)
-	if err != nil {
-		return ClientCredentials{}, err
-	}
+func basicAuthHeader(username, password string) string {
+	auth := username + ":" + password
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
+}
 
-	var credentialsMap = map[string]ClientCredentials{}
-	err = yaml.Unmarshal(yamlFile, &credentialsMap)
-	if err != nil {
-		log.Fatalf("Unmarshal: %v", err)
+func (client *Client) BuildPropertiesFromUpdateSchema(resourceId string, properties []interface{}) ([]interface{}, error) {
+	m, err := client.readUpdateSchema(resourceId, properties)
+	if err != nil || m == nil {
+		return nil, err
 	}
-	credentials := credentialsMap[profile]
-	if !CredentialsSet(credentials) {
-		return ClientCredentials{}, fmt.Errorf("failed to load all credentials for profile %s from credentials file %s", profile, credentialsPath)
+	var excluded []interface{}
+	if value, ok := m["allOf"]; ok {
+		for _, schema := range value.([]interface{}) {
+			if res, ok := schema.(map[string]interface{}); ok {
+				if res["type"] == "object" {
+					// loop to flatten interface, so we will not get this structure - [[id1,id2],[id3,id4]]
+					for _, element := range helpers.GetNullProperties(res) {
+						excluded = append(excluded, element)
+					}
+				}
+			}
+		}
 	}
-
-	return credentials, nil
+	return excluded, nil
 }
 
-func basicAuthHeader(username, password string) string {
-	auth := username + ":" + password
-	return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
+// ReadUpdateSchema returns the update schema of the resource type of a resource, nil if it has none
+func (client *Client) ReadUpdateSchema(resourceId string) (map[string]interface{}, error) {
+	return client.readUpdateSchema(resourceId, []interface{}{map[string]string{"updateSchema": "updateSchema"}})
 }
 
-func (client *Client) BuildPropertiesFromUpdateSchema(resourceId string, properties []interface{}) ([]interface{}, error) {
+func (client *Client) readUpdateSchema(resourceId string, properties []interface{}) (map[string]interface{}, error) {
 	getResourceQuery := getResourceTypeIdQuery(resourceId)
 	responseData := &ResourceResponse{}
 	// execute api call
@@ -247,22 +305,7 @@ func (client *Client) BuildPropertiesFromUpdateSchema(resourceId string, propert
 	if response.Resource.UpdateSchema == nil {
 		return nil, nil
 	}
-
-	var m = response.Resource.UpdateSchema.(map[string]interface{})
-	var excluded []interface{}
-	if value, ok := m["allOf"]; ok {
-		for _, schema := range value.([]interface{}) {
-			if res, ok := schema.(map[string]interface{}); ok {
-				if res["type"] == "object" {
-					// loop to flatten interface, so we will not get this structure - [[id1,id2],[id3,id4]]
-					for _, element := range helpers.GetNullProperties(res) {
-						excluded = append(excluded, element)
-					}
-				}
-			}
-		}
-	}
-	return excluded, nil
+	return response.Resource.UpdateSchema.(map[string]interface{}), nil
 }
 
 func (client *Client) doRequest(query string, vars map[string]interface{}, responseData interface{}) error {
@@ -271,6 +314,12 @@ func (client *Client) doRequest(query string, vars map[string]interface{}, respo
 
 // execute graphql request
 func (client *Client) DoRequest(query string, vars map[string]interface{}, responseData interface{}) error {
+	return client.DoRequestContext(context.Background(), query, vars, responseData)
+}
+
+// DoRequestContext executes a graphql request, in the span of the context, and tagged with its table, see
+// WithTable
+func (client *Client) DoRequestContext(ctx context.Context, query string, vars map[string]interface{}, responseData interface{}) error {
 	// make a request
 	req := graphql.NewRequest(query)
 
@@ -281,21 +330,55 @@ func (client *Client) DoRequest(query string, vars map[string]interface{}, respo
 
 	// set header fields
 	req.Header.Set("Cache-Control", "no-cache")
-	req.Header.Set("Authorization", basicAuthHeader(client.AccessKey, client.SecretKey))
+	accessKey, secretKey, err := client.keys()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", basicAuthHeader(accessKey, secretKey))
 
-	// define a Context for the request
-	ctx := context.Background()
+	stats := RequestStats{Operation: operationName(query), Filter: requestFilter(vars), Start: time.Now()}
+	stats.Table, _ = ctx.Value(tableContextKey{}).(string)
+	stats.Page = client.pages.page(requestToken(vars))
+	if client.DebugQueries {
+		stats.Query, stats.Variables = query, vars
+	}
+	ctx, span := startRequestSpan(ctx, stats)
+	ctx = context.WithValue(ctx, byteCountContextKey{}, &stats.BytesReceived)
 
 	// run it and capture the response
-	start := time.Now()
-	if err := client.Graphql.Run(ctx, req, &responseData); err != nil {
-		err = errorsHandler.BuildErrorMessage(err)
-		return err
+	err = client.Graphql.Run(ctx, req, &responseData)
+	stats.Latency = time.Since(stats.Start)
+	stats.ErrorClass = ErrorClass(err)
+	if err == nil {
+		var next string
+		stats.Rows, next = inspectResponse(reflect.ValueOf(responseData), 0)
+		client.pages.next(next, stats.Page)
+	}
+	client.observeRequest(ctx, span, stats, err)
+
+	if err != nil {
+		if stats.ErrorClass == "auth" && client.OnAuthFailure != nil {
+			client.OnAuthFailure()
+		}
+		return &requestError{error: errorsHandler.BuildErrorMessage(err), class: stats.ErrorClass}
 	}
-	log.Println("graphql.time", time.Since(start).Milliseconds())
 	return nil
 }
 
+// keys returns the access and secret keys of the client, retrieving them again if they have expired
+func (client *Client) keys() (string, string, error) {
+	client.credentialsMutex.Lock()
+	defer client.credentialsMutex.Unlock()
+	if client.credentials != nil && client.credentials.Expired() {
+		credentials, err := client.credentials.Retrieve()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to refresh credentials: %s", err.Error())
+		}
+		client.AccessKey, client.SecretKey = credentials.AccessKey, credentials.SecretKey
+	}
+	return client.AccessKey, client.SecretKey, nil
+}
+
 func (client *Client) handleCreateError(err error, input map[string]interface{}, resourceType string) error {
 	parent := input["parent"]
 	if errorsHandler.NotFoundError(err) {
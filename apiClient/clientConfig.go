@@ -4,6 +4,20 @@ type ClientConfig struct {
 	Credentials     ClientCredentials
 	CredentialsPath string
 	Profile         string
+	// RecordPath is the path of a cassette file to record the client's requests and responses to
+	RecordPath string
+	// ReplayPath is the path of a cassette file to serve the client's responses from, with no network access
+	ReplayPath string
+	// SnapshotPath is the path of a workspace snapshot to serve the client's responses from, with no network access
+	SnapshotPath string
+	// CredentialProcess is a command which writes credentials as JSON to stdout, see ExecProvider
+	CredentialProcess string
+	// TracePath is the path of a file to append a JSON line to for each request of the client, see RequestStats
+	TracePath string
+	// DebugQueries records the GraphQL document and variables of each request in its stats, see Client.DebugQueries
+	DebugQueries bool
+	// CredentialProviders are the sources of credentials, in order of precedence, instead of the default sources
+	CredentialProviders []CredentialProvider
 }
 
 type ClientCredentials struct {
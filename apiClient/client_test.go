@@ -28,6 +28,13 @@ func TestCredentialsPrecedence(t *testing.T) {
 				},
 				"",
 				"",
+				"",
+				"",
+				"",
+				"",
+				"",
+				false,
+				nil,
 			},
 			expected{
 				true,
@@ -48,6 +55,13 @@ func TestCredentialsPrecedence(t *testing.T) {
 				},
 				"",
 				"test",
+				"",
+				"",
+				"",
+				"",
+				"",
+				false,
+				nil,
 			},
 			expected{
 				true,
@@ -68,6 +82,13 @@ func TestCredentialsPrecedence(t *testing.T) {
 				},
 				"",
 				"test",
+				"",
+				"",
+				"",
+				"",
+				"",
+				false,
+				nil,
 			},
 			expected{
 				true,
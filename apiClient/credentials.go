@@ -0,0 +1,207 @@
+package apiClient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CredentialProvider is a source of client credentials, e.g. the Turbot CLI credentials file. Clients take their
+// credentials from the first provider of the config which has them.
+type CredentialProvider interface {
+	// Retrieve returns the credentials of the source. They are not set if the source has none, so the next
+	// provider is tried.
+	Retrieve() (ClientCredentials, error)
+}
+
+// ExpiringCredentialProvider is a CredentialProvider whose credentials expire. A client retrieves them again
+// before its next request once they have expired.
+type ExpiringCredentialProvider interface {
+	CredentialProvider
+	Expired() bool
+}
+
+// StaticProvider provides credentials set in the config
+type StaticProvider struct {
+	Credentials ClientCredentials
+}
+
+func (p *StaticProvider) Retrieve() (ClientCredentials, error) {
+	return p.Credentials, nil
+}
+
+// EnvProvider provides credentials from the TURBOT_ACCESS_KEY, TURBOT_SECRET_KEY and TURBOT_WORKSPACE environment
+// variables
+type EnvProvider struct{}
+
+func (p *EnvProvider) Retrieve() (ClientCredentials, error) {
+	credentials, _ := getCredentialsFromEnv()
+	return credentials, nil
+}
+
+// FileProvider provides the credentials of a profile of a Turbot CLI credentials file, the default profile if
+// none is given. It fails if the file or profile does not exist.
+type FileProvider struct {
+	Path    string
+	Profile string
+}
+
+func (p *FileProvider) Retrieve() (ClientCredentials, error) {
+	return loadProfile(p.Path, p.Profile)
+}
+
+// ExecProvider provides credentials written to stdout by a command, which is run by the shell, e.g. to read
+// them from a vault. The output is a JSON object, e.g.
+//
+//	{"accessKey": "c8e2c2ed-...", "secretKey": "a3d8385d-...", "workspace": "https://acme.cloud.turbot.com/", "expiration": "2023-01-01T12:00:00Z"}
+//
+// The workspace is optional, and the expiration is an RFC 3339 timestamp. The credentials are cached, and
+// the command is run again shortly before they expire. Credentials with no expiration are cached for the
+// lifetime of the provider.
+type ExecProvider struct {
+	Command string
+	// the workspace of the credentials, if the command does not return one
+	Workspace string
+	// how long the command may run, one minute if not set
+	Timeout time.Duration
+
+	mutex       sync.Mutex
+	credentials *ClientCredentials
+	expiration  time.Time
+}
+
+// credentials of an ExecProvider are refreshed this long before they expire, so they do not expire during a
+// request
+const execExpiryWindow = time.Minute
+
+// processCredentials is the output of the command of an ExecProvider
+type processCredentials struct {
+	AccessKey  string     `json:"accessKey"`
+	SecretKey  string     `json:"secretKey"`
+	Workspace  string     `json:"workspace"`
+	Expiration *time.Time `json:"expiration"`
+}
+
+func (p *ExecProvider) Retrieve() (ClientCredentials, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.credentials != nil && !p.expired() {
+		return *p.credentials, nil
+	}
+
+	output, err := p.run()
+	if err != nil {
+		return ClientCredentials{}, fmt.Errorf("credential_process %q failed: %s", p.Command, err.Error())
+	}
+	var result processCredentials
+	if err = json.Unmarshal(output, &result); err != nil {
+		return ClientCredentials{}, fmt.Errorf("credential_process %q returned invalid JSON: %s", p.Command, err.Error())
+	}
+	if result.AccessKey == "" || result.SecretKey == "" {
+		return ClientCredentials{}, fmt.Errorf("credential_process %q returned no accessKey or secretKey", p.Command)
+	}
+	credentials := ClientCredentials{AccessKey: result.AccessKey, SecretKey: result.SecretKey, Workspace: result.Workspace}
+	if credentials.Workspace == "" {
+		credentials.Workspace = p.Workspace
+	}
+	p.credentials = &credentials
+	p.expiration = time.Time{}
+	if result.Expiration != nil {
+		p.expiration = *result.Expiration
+	}
+	return credentials, nil
+}
+
+func (p *ExecProvider) Expired() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.credentials == nil || p.expired()
+}
+
+func (p *ExecProvider) expired() bool {
+	return !p.expiration.IsZero() && time.Now().Add(execExpiryWindow).After(p.expiration)
+}
+
+// run the command with the shell, returning its stdout
+func (p *ExecProvider) run() ([]byte, error) {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", p.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", p.Command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr, cmd.Env = &stdout, &stderr, os.Environ()
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s: %s", err.Error(), message)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// credentialProviders returns the providers of the config, in order of precedence:
+//   - the providers set in the config, if any, instead of the others
+//   - credentials set in the config
+//   - the credential process set in the config
+//   - the profile set in the config
+//   - ENV vars {TURBOT_ACCESS_KEY, TURBOT_SECRET_KEY, TURBOT_WORKSPACE}
+//   - the TURBOT_PROFILE env var, or the default profile
+func credentialProviders(config ClientConfig) ([]CredentialProvider, error) {
+	if len(config.CredentialProviders) > 0 {
+		return config.CredentialProviders, nil
+	}
+	credentialsPath, err := getCredentialsPath(config)
+	if err != nil {
+		return nil, err
+	}
+	providers := []CredentialProvider{&StaticProvider{Credentials: config.Credentials}}
+	if config.CredentialProcess != "" {
+		providers = append(providers, &ExecProvider{Command: config.CredentialProcess, Workspace: config.Credentials.Workspace})
+	}
+	if len(config.Profile) != 0 {
+		return append(providers, &FileProvider{Path: credentialsPath, Profile: config.Profile}), nil
+	}
+	return append(providers, &EnvProvider{}, &FileProvider{Path: credentialsPath, Profile: os.Getenv("TURBOT_PROFILE")}), nil
+}
+
+// ResolveCredentials returns the credentials of the config, and the provider they are from
+func ResolveCredentials(config ClientConfig) (ClientCredentials, CredentialProvider, error) {
+	return getCredentials(config)
+}
+
+// CredentialsPath returns the path of the Turbot CLI credentials file of the config
+func CredentialsPath(config ClientConfig) (string, error) {
+	return getCredentialsPath(config)
+}
+
+// CredentialSource names the source of a provider: config, credential_process, env or profile, or custom for
+// providers set in the config
+func CredentialSource(provider CredentialProvider) string {
+	switch provider.(type) {
+	case *StaticProvider:
+		return "config"
+	case *ExecProvider:
+		return "credential_process"
+	case *EnvProvider:
+		return "env"
+	case *FileProvider:
+		return "profile"
+	}
+	return "custom"
+}
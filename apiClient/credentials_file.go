@@ -0,0 +1,197 @@
+package apiClient
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CredentialProfile is a profile of a Turbot CLI credentials file, e.g.
+//
+//	default:
+//	  accessKey: c8e2c2ed-1ca8-429b-b369-010e3cf75aac
+//	  secretKey: $TURBOT_DEFAULT_SECRET_KEY
+//	  workspace: https://turbot-acme.cloud.turbot.com/
+//
+// Values may refer to environment variables as $NAME or ${NAME}.
+type CredentialProfile struct {
+	Name        string
+	Credentials ClientCredentials
+	// the position of the profile in the file
+	Line   int
+	Column int
+	// the problem with the profile which stops it being used, e.g. an environment variable which is not set
+	Err error
+}
+
+// CredentialsFileError is a problem with a credentials file, at the line and column of the YAML it is in, if known
+type CredentialsFileError struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *CredentialsFileError) Error() string {
+	switch {
+	case e.Line == 0:
+		return fmt.Sprintf("credentials file %s: %s", e.Path, e.Message)
+	case e.Column == 0:
+		return fmt.Sprintf("credentials file %s: line %d: %s", e.Path, e.Line, e.Message)
+	}
+	return fmt.Sprintf("credentials file %s: line %d, column %d: %s", e.Path, e.Line, e.Column, e.Message)
+}
+
+// ProfileNotFoundError is a profile which is not in the credentials file
+type ProfileNotFoundError struct {
+	Path      string
+	Profile   string
+	Available []string
+}
+
+func (e *ProfileNotFoundError) Error() string {
+	available := "it has no profiles"
+	if len(e.Available) > 0 {
+		available = "available profiles are " + strings.Join(e.Available, ", ")
+	}
+	return fmt.Sprintf("profile %s not found in credentials file %s, %s", e.Profile, e.Path, available)
+}
+
+// the fields of a profile, by their YAML key
+var profileFields = map[string]func(*ClientCredentials) *string{
+	"accessKey": func(c *ClientCredentials) *string { return &c.AccessKey },
+	"secretKey": func(c *ClientCredentials) *string { return &c.SecretKey },
+	"workspace": func(c *ClientCredentials) *string { return &c.Workspace },
+}
+
+// the line of a YAML syntax error, e.g. "yaml: line 3: did not find expected key"
+var yamlLineError = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// LoadCredentialsFile returns the profiles of a credentials file, in the order of the file. It fails if the file
+// is not valid YAML or not a map of profiles. Problems with a single profile are set on the profile, so the
+// others can still be used.
+func LoadCredentialsFile(path string) ([]CredentialProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var document yaml.Node
+	if err = yaml.Unmarshal(data, &document); err != nil {
+		fileErr := &CredentialsFileError{Path: path, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+		if match := yamlLineError.FindStringSubmatch(err.Error()); match != nil {
+			fileErr.Line, _ = strconv.Atoi(match[1])
+			fileErr.Message = match[2]
+		}
+		return nil, fileErr
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	root := resolveAlias(document.Content[0])
+	if root.Kind != yaml.MappingNode {
+		return nil, &CredentialsFileError{Path: path, Line: root.Line, Column: root.Column, Message: "expected a map of profiles"}
+	}
+
+	var profiles []CredentialProfile
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], resolveAlias(root.Content[i+1])
+		if seen[key.Value] {
+			return nil, &CredentialsFileError{Path: path, Line: key.Line, Column: key.Column, Message: fmt.Sprintf("profile %s is defined more than once", key.Value)}
+		}
+		seen[key.Value] = true
+		profiles = append(profiles, parseProfile(path, key, value))
+	}
+	return profiles, nil
+}
+
+// parseProfile returns the profile of a key of the credentials file, with its fields expanded
+func parseProfile(path string, key, value *yaml.Node) CredentialProfile {
+	profile := CredentialProfile{Name: key.Value, Line: key.Line, Column: key.Column}
+	fail := func(node *yaml.Node, format string, args ...interface{}) CredentialProfile {
+		profile.Err = &CredentialsFileError{Path: path, Line: node.Line, Column: node.Column, Message: fmt.Sprintf(format, args...)}
+		return profile
+	}
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+		return profile
+	}
+	if value.Kind != yaml.MappingNode {
+		return fail(value, "profile %s must be a map of accessKey, secretKey and workspace", profile.Name)
+	}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		field, fieldValue := value.Content[i], resolveAlias(value.Content[i+1])
+		target, ok := profileFields[field.Value]
+		// other keys may be set by the Turbot CLI, so are ignored
+		if !ok {
+			continue
+		}
+		if fieldValue.Kind != yaml.ScalarNode {
+			return fail(fieldValue, "%s of profile %s must be a string", field.Value, profile.Name)
+		}
+		var missing []string
+		expanded := os.Expand(fieldValue.Value, func(name string) string {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				missing = append(missing, name)
+			}
+			return value
+		})
+		if len(missing) > 0 {
+			return fail(fieldValue, "%s of profile %s refers to environment variable %s, which is not set", field.Value, profile.Name, strings.Join(missing, ", "))
+		}
+		*target(&profile.Credentials) = expanded
+	}
+	return profile
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// loadProfile returns the credentials of a profile of a credentials file, the default profile if none is given
+func loadProfile(credentialsPath, profile string) (ClientCredentials, error) {
+	// if no profile specified, use default
+	if len(profile) == 0 {
+		profile = "default"
+	}
+	profiles, err := LoadCredentialsFile(credentialsPath)
+	if err != nil {
+		return ClientCredentials{}, err
+	}
+
+	var available []string
+	for _, p := range profiles {
+		if p.Name != profile {
+			available = append(available, p.Name)
+			continue
+		}
+		if p.Err != nil {
+			return ClientCredentials{}, p.Err
+		}
+		if missing := missingCredentials(p.Credentials); len(missing) > 0 {
+			return ClientCredentials{}, &CredentialsFileError{Path: credentialsPath, Line: p.Line, Column: p.Column, Message: fmt.Sprintf("profile %s is missing %s", profile, strings.Join(missing, ", "))}
+		}
+		return p.Credentials, nil
+	}
+	sort.Strings(available)
+	return ClientCredentials{}, &ProfileNotFoundError{Path: credentialsPath, Profile: profile, Available: available}
+}
+
+// missingCredentials returns the fields of the credentials which are not set
+func missingCredentials(credentials ClientCredentials) []string {
+	var missing []string
+	for _, field := range []string{"accessKey", "secretKey", "workspace"} {
+		if *profileFields[field](&credentials) == "" {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,210 @@
+package apiClient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/machinebox/graphql"
+	"github.com/stretchr/testify/assert"
+)
+
+// processCommand returns a command which counts its runs in a file and writes credentials with the keys and
+// expiration
+func processCommand(count, keys, expiration string) string {
+	output := `{"accessKey": "` + keys + `", "secretKey": "` + keys + `-secret"`
+	if expiration != "" {
+		output += `, "expiration": "` + expiration + `"`
+	}
+	output += "}"
+	return "echo run >> '" + count + "' && echo '" + output + "'"
+}
+
+func runs(t *testing.T, count string) int {
+	data, err := os.ReadFile(count)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	assert.NoError(t, err)
+	return strings.Count(string(data), "run")
+}
+
+func TestExecProvider(t *testing.T) {
+	count := filepath.Join(t.TempDir(), "count")
+
+	// credentials with no expiration are cached
+	provider := &ExecProvider{Command: processCommand(count, "access", ""), Workspace: "acme.cloud.turbot.com"}
+	assert.True(t, provider.Expired())
+	for i := 0; i < 2; i++ {
+		credentials, err := provider.Retrieve()
+		assert.NoError(t, err)
+		assert.Equal(t, ClientCredentials{AccessKey: "access", SecretKey: "access-secret", Workspace: "acme.cloud.turbot.com"}, credentials)
+	}
+	assert.False(t, provider.Expired())
+	assert.Equal(t, 1, runs(t, count))
+
+	// credentials which expire within the expiry window are retrieved again
+	expiration := time.Now().Add(execExpiryWindow / 2).UTC().Format(time.RFC3339)
+	provider = &ExecProvider{Command: processCommand(count, "access", expiration)}
+	_, err := provider.Retrieve()
+	assert.NoError(t, err)
+	assert.True(t, provider.Expired())
+	_, err = provider.Retrieve()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, runs(t, count))
+
+	tests := []struct {
+		command, expected string
+	}{
+		{"echo denied >&2; exit 3", "exit status 3: denied"},
+		{"echo not json", "returned invalid JSON"},
+		{`echo '{"accessKey": "access"}'`, "returned no accessKey or secretKey"},
+	}
+	for _, test := range tests {
+		_, err := (&ExecProvider{Command: test.command}).Retrieve()
+		if assert.Error(t, err, test.command) {
+			assert.Contains(t, err.Error(), test.expected, test.command)
+		}
+	}
+}
+
+func TestCredentialProviders(t *testing.T) {
+	count := filepath.Join(t.TempDir(), "count")
+	t.Setenv("TURBOT_ACCESS_KEY", "env")
+	t.Setenv("TURBOT_SECRET_KEY", "env-secret")
+	t.Setenv("TURBOT_WORKSPACE", "env.cloud.turbot.com")
+
+	// the credential process takes precedence over the environment, and its workspace is that of the config
+	credentials, err := GetCredentials(ClientConfig{
+		Credentials:       ClientCredentials{Workspace: "acme.cloud.turbot.com"},
+		CredentialProcess: processCommand(count, "process", ""),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, ClientCredentials{AccessKey: "process", SecretKey: "process-secret", Workspace: "https://acme.cloud.turbot.com/api/latest/graphql"}, credentials)
+
+	// but credentials set in the config take precedence over it
+	credentials, err = GetCredentials(ClientConfig{
+		Credentials:       ClientCredentials{AccessKey: "static", SecretKey: "static-secret", Workspace: "acme.cloud.turbot.com"},
+		CredentialProcess: processCommand(count, "process", ""),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "static", credentials.AccessKey)
+	assert.Equal(t, 1, runs(t, count))
+
+	// providers set in the config replace the others
+	_, err = GetCredentials(ClientConfig{CredentialProviders: []CredentialProvider{&StaticProvider{}}})
+	assert.EqualError(t, err, "failed to get credentials")
+	credentials, err = GetCredentials(ClientConfig{CredentialProviders: []CredentialProvider{&StaticProvider{}, &EnvProvider{}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "env", credentials.AccessKey)
+}
+
+// expiringProvider returns new keys each time they are retrieved
+type expiringProvider struct {
+	retrieved int
+}
+
+func (p *expiringProvider) Retrieve() (ClientCredentials, error) {
+	p.retrieved++
+	keys := strings.Repeat("k", p.retrieved)
+	return ClientCredentials{AccessKey: keys, SecretKey: keys, Workspace: "acme.cloud.turbot.com"}, nil
+}
+
+func (p *expiringProvider) Expired() bool {
+	return true
+}
+
+func TestClientRefreshesExpiredCredentials(t *testing.T) {
+	var headers []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {}}`))
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(ClientConfig{CredentialProviders: []CredentialProvider{&expiringProvider{}}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	client.Graphql = graphql.NewClient(server.URL)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, client.DoRequest(`{ actor { identity { turbot { id } } } }`, nil, &map[string]interface{}{}))
+	}
+	assert.Equal(t, []string{basicAuthHeader("kk", "kk"), basicAuthHeader("kkk", "kkk")}, headers)
+}
+
+func TestClientReportsAuthFailures(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {}}`))
+	}))
+	defer server.Close()
+
+	failures := 0
+	client := &Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(server.URL), OnAuthFailure: func() { failures++ }}
+	for _, status = range []int{http.StatusOK, http.StatusUnauthorized, http.StatusServiceUnavailable, http.StatusForbidden} {
+		err := client.DoRequest(`{ actor { identity { turbot { id } } } }`, nil, &map[string]interface{}{})
+		assert.Equal(t, status != http.StatusOK, err != nil, "status %d", status)
+	}
+	assert.Equal(t, 2, failures)
+}
+
+func TestLoadCredentialsFile(t *testing.T) {
+	t.Setenv("TURBOT_TEST_SECRET_KEY", "env-secret")
+	path := filepath.Join(t.TempDir(), "credentials.yml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`default:
+  accessKey: access
+  secretKey: ${TURBOT_TEST_SECRET_KEY}
+  workspace: acme.cloud.turbot.com
+incomplete:
+  accessKey: access
+unset:
+  accessKey: access
+  secretKey: $TURBOT_TEST_UNSET
+  workspace: acme.cloud.turbot.com
+`)
+	credentials, err := loadProfile(path, "")
+	assert.NoError(t, err)
+	assert.Equal(t, ClientCredentials{AccessKey: "access", SecretKey: "env-secret", Workspace: "acme.cloud.turbot.com"}, credentials)
+
+	_, err = loadProfile(path, "incomplete")
+	assert.EqualError(t, err, "credentials file "+path+": line 5, column 1: profile incomplete is missing secretKey, workspace")
+	_, err = loadProfile(path, "unset")
+	assert.EqualError(t, err, "credentials file "+path+": line 9, column 14: secretKey of profile unset refers to environment variable TURBOT_TEST_UNSET, which is not set")
+	_, err = loadProfile(path, "missing")
+	assert.EqualError(t, err, "profile missing not found in credentials file "+path+", available profiles are default, incomplete, unset")
+	var notFound *ProfileNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+
+	// problems with the file fail every profile, with their position
+	tests := []struct {
+		content, expected string
+	}{
+		{"default:\n  accessKey: [a, b]\n", "line 2, column 14: accessKey of profile default must be a string"},
+		{"default:\n  accessKey: a\n default: b\n", "line 2: did not find expected key"},
+		{"- default\n", "line 1, column 1: expected a map of profiles"},
+		{"default: {}\ndefault: {}\n", "line 2, column 1: profile default is defined more than once"},
+	}
+	for _, test := range tests {
+		write(test.content)
+		_, err := loadProfile(path, "default")
+		if assert.Error(t, err, test.content) {
+			assert.Contains(t, err.Error(), test.expected, test.content)
+		}
+	}
+}
@@ -54,3 +54,27 @@ func (client *Client) GrantExists(id string) (bool, error) {
 	exists := grant.Turbot.Id != ""
 	return exists, nil
 }
+
+// ListGrants returns all the grants matching the filter, reading every page
+func (client *Client) ListGrants(filter string) ([]Grant, error) {
+	query := listGrantsQuery()
+	var grants []Grant
+	next := ""
+	for {
+		responseData := &ListGrantsResponse{}
+		variables := map[string]interface{}{
+			"filter":     filter,
+			"next_token": next,
+		}
+
+		// execute api call
+		if err := client.doRequest(query, variables, responseData); err != nil {
+			return nil, fmt.Errorf("error listing grants: %s", err.Error())
+		}
+		grants = append(grants, responseData.Grants.Items...)
+		next = responseData.Grants.Paging.Next
+		if next == "" {
+			return grants, nil
+		}
+	}
+}
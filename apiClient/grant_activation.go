@@ -54,3 +54,27 @@ func (client *Client) GrantActivationExists(id string) (bool, error) {
 	exists := grantActivate.Turbot.Id != ""
 	return exists, nil
 }
+
+// ListActiveGrants returns all the active grants matching the filter, reading every page
+func (client *Client) ListActiveGrants(filter string) ([]ActiveGrant, error) {
+	query := listActiveGrantsQuery()
+	var activeGrants []ActiveGrant
+	next := ""
+	for {
+		responseData := &ListActiveGrantsResponse{}
+		variables := map[string]interface{}{
+			"filter":     filter,
+			"next_token": next,
+		}
+
+		// execute api call
+		if err := client.doRequest(query, variables, responseData); err != nil {
+			return nil, fmt.Errorf("error listing active grants: %s", err.Error())
+		}
+		activeGrants = append(activeGrants, responseData.ActiveGrants.Items...)
+		next = responseData.ActiveGrants.Paging.Next
+		if next == "" {
+			return activeGrants, nil
+		}
+	}
+}
@@ -0,0 +1,464 @@
+package apiClient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	errorsHandler "github.com/turbot/steampipe-plugin-turbot/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer and meter of the client's requests
+const instrumentationName = "steampipe-plugin-turbot"
+
+// slowRequestThreshold is the latency above which a request is logged as a warning
+const slowRequestThreshold = 10 * time.Second
+
+// RequestStats describes a GraphQL request made by a client, for its log line, span and metrics, and the
+// turbot_api_call_stats table. Clients do not retry failed requests, so there is no retry count.
+type RequestStats struct {
+	// the name of the GraphQL operation, or of its first field if it is not named
+	Operation string `json:"operation"`
+	// the table the request was made for, if known, see WithTable
+	Table  string `json:"table,omitempty"`
+	Filter string `json:"filter,omitempty"`
+	// the page of the results, counting from 1, or 0 if not known
+	Page          int           `json:"page,omitempty"`
+	BytesReceived int64         `json:"bytesReceived"`
+	Rows          int           `json:"rows"`
+	Start         time.Time     `json:"start"`
+	Latency       time.Duration `json:"-"`
+	// the class of the error of the request, see ErrorClass, or empty if it succeeded
+	ErrorClass string `json:"errorClass,omitempty"`
+	// the GraphQL document and variables of the request, if the client debugs queries
+	Query     string                 `json:"query,omitempty"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type tableContextKey struct{}
+
+// WithTable tags the requests made with the context with the table they are made for
+func WithTable(ctx context.Context, table string) context.Context {
+	return context.WithValue(ctx, tableContextKey{}, table)
+}
+
+// requestError is the error returned for a request, with the class of the error it was built from, as the
+// message it is built into may no longer say, e.g. the status code of the response
+type requestError struct {
+	error
+	class string
+}
+
+func (e *requestError) Unwrap() error {
+	return e.error
+}
+
+// ErrorClass classifies the error of a request, for metrics: auth, throttled, server, client, network,
+// not_found or graphql
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	var requestErr *requestError
+	if errors.As(err, &requestErr) {
+		return requestErr.class
+	}
+	if code, _ := errorsHandler.ExtractErrorCode(err); code != 0 {
+		switch {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return "auth"
+		case code == http.StatusTooManyRequests:
+			return "throttled"
+		case code >= 500:
+			return "server"
+		}
+		return "client"
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return "network"
+	}
+	if errorsHandler.NotFoundError(err) {
+		return "not_found"
+	}
+	return "graphql"
+}
+
+var (
+	namedOperation   = regexp.MustCompile(`^\s*(?:query|mutation|subscription)\s+(\w+)`)
+	unnamedOperation = regexp.MustCompile(`^\s*(?:query|mutation|subscription)?\s*\{\s*(\w+)(?:\s*:\s*(\w+))?`)
+)
+
+// operationName returns the name of the operation of a query, or of its first field if it is not named
+func operationName(query string) string {
+	if match := namedOperation.FindStringSubmatch(query); match != nil {
+		return match[1]
+	}
+	if match := unnamedOperation.FindStringSubmatch(query); match != nil {
+		if match[2] != "" {
+			return match[2]
+		}
+		return match[1]
+	}
+	return "unknown"
+}
+
+// requestFilter returns the filter variable of a request, as a single string
+func requestFilter(vars map[string]interface{}) string {
+	switch filter := vars["filter"].(type) {
+	case string:
+		return filter
+	case []string:
+		return strings.Join(filter, " ")
+	}
+	return ""
+}
+
+// requestToken returns the paging token variable of a request
+func requestToken(vars map[string]interface{}) string {
+	for _, name := range []string{"next_token", "paging"} {
+		if token, ok := vars[name].(string); ok {
+			return token
+		}
+	}
+	return ""
+}
+
+// inspectResponse returns the number of items of the lists of a response, and the token of its next page.
+// Lists are the items fields of the response, e.g. resources.items, which are not themselves searched.
+func inspectResponse(value reflect.Value, depth int) (rows int, next string) {
+	if depth > 8 {
+		return 0, ""
+	}
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return 0, ""
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fieldRows, fieldNext := inspectField(field.Name, value.Field(i), depth)
+			rows += fieldRows
+			if next == "" {
+				next = fieldNext
+			}
+		}
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return 0, ""
+		}
+		for _, key := range value.MapKeys() {
+			fieldRows, fieldNext := inspectField(key.String(), value.MapIndex(key), depth)
+			rows += fieldRows
+			if next == "" {
+				next = fieldNext
+			}
+		}
+	}
+	return rows, next
+}
+
+func inspectField(name string, value reflect.Value, depth int) (int, string) {
+	for value.Kind() == reflect.Interface && !value.IsNil() {
+		value = value.Elem()
+	}
+	switch {
+	case strings.EqualFold(name, "items") && value.Kind() == reflect.Slice:
+		return value.Len(), ""
+	case strings.EqualFold(name, "next") && value.Kind() == reflect.String:
+		return 0, value.String()
+	case value.Kind() == reflect.Slice:
+		return 0, ""
+	}
+	return inspectResponse(value, depth+1)
+}
+
+// pageTracker numbers the pages of paged requests, by the token of the next page of each response
+type pageTracker struct {
+	mutex sync.Mutex
+	pages map[string]int
+}
+
+// maxTrackedPages bounds the tokens kept for pages which are never requested
+const maxTrackedPages = 1000
+
+// page returns the page of a request with the paging token, 1 for the first page, or 0 if not known
+func (t *pageTracker) page(token string) int {
+	if token == "" {
+		return 1
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	page := t.pages[token]
+	delete(t.pages, token)
+	return page
+}
+
+// next records the token of the page after a page
+func (t *pageTracker) next(token string, page int) {
+	if token == "" || page == 0 {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.pages == nil || len(t.pages) >= maxTrackedPages {
+		t.pages = map[string]int{}
+	}
+	t.pages[token] = page + 1
+}
+
+type byteCountContextKey struct{}
+
+// countingTransport counts the bytes of the response bodies of requests whose context has a counter
+type countingTransport struct {
+	transport http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if counter, ok := req.Context().Value(byteCountContextKey{}).(*int64); ok {
+		resp.Body = &countingReader{ReadCloser: resp.Body, count: counter}
+	}
+	return resp, nil
+}
+
+type countingReader struct {
+	io.ReadCloser
+	count *int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.count, int64(n))
+	return n, err
+}
+
+// requestMetrics are the OpenTelemetry instruments of requests, exported with the plugin's telemetry
+type requestMetrics struct {
+	calls   syncint64.Counter
+	rows    syncint64.Counter
+	bytes   syncint64.Counter
+	latency syncfloat64.Histogram
+}
+
+var (
+	metrics     *requestMetrics
+	metricsOnce sync.Once
+)
+
+// getRequestMetrics creates the instruments of requests, with the meter provider set by the plugin, or none
+// if telemetry is not enabled
+func getRequestMetrics() *requestMetrics {
+	metricsOnce.Do(func() {
+		meter := global.Meter(instrumentationName)
+		m := &requestMetrics{}
+		var errs []error
+		var err error
+		m.calls, err = meter.SyncInt64().Counter("turbot.api.calls")
+		errs = append(errs, err)
+		m.rows, err = meter.SyncInt64().Counter("turbot.api.rows")
+		errs = append(errs, err)
+		m.bytes, err = meter.SyncInt64().Counter("turbot.api.bytes_received")
+		errs = append(errs, err)
+		m.latency, err = meter.SyncFloat64().Histogram("turbot.api.latency_ms")
+		errs = append(errs, err)
+		for _, err := range errs {
+			if err != nil {
+				log.Printf("[WARN] graphql.metrics: %s", err.Error())
+				return
+			}
+		}
+		metrics = m
+	})
+	return metrics
+}
+
+// startRequestSpan starts the span of a request, a child of the span of the context if it has one
+func startRequestSpan(ctx context.Context, stats RequestStats) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, "graphql "+stats.Operation, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// observeRequest logs a completed request, and records it in its span, the metrics, the client's request log
+// and its OnRequest callback
+func (client *Client) observeRequest(ctx context.Context, span trace.Span, stats RequestStats, err error) {
+	level := "DEBUG"
+	if stats.Latency > slowRequestThreshold {
+		level = "WARN"
+	}
+	log.Printf("[%s] graphql.request operation=%s table=%s filter=%q page=%d bytes=%d rows=%d latency_ms=%d error_class=%s",
+		level, stats.Operation, stats.Table, stats.Filter, stats.Page, stats.BytesReceived, stats.Rows, stats.Latency.Milliseconds(), stats.ErrorClass)
+	// the variables may hold ids and values given in queries, so are only logged at debug level
+	if stats.Query != "" {
+		variables, _ := json.Marshal(stats.Variables)
+		log.Printf("[DEBUG] graphql.query operation=%s table=%s page=%d variables=%s query=%q", stats.Operation, stats.Table, stats.Page, variables, stats.Query)
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("turbot.operation", stats.Operation),
+		attribute.String("turbot.table", stats.Table),
+		attribute.String("turbot.error_class", stats.ErrorClass),
+	}
+	span.SetAttributes(append(attributes,
+		attribute.String("turbot.filter", stats.Filter),
+		attribute.Int("turbot.page", stats.Page),
+		attribute.Int64("turbot.bytes_received", stats.BytesReceived),
+		attribute.Int("turbot.rows", stats.Rows),
+	)...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, stats.ErrorClass)
+	}
+	span.End()
+
+	if m := getRequestMetrics(); m != nil {
+		m.calls.Add(ctx, 1, attributes...)
+		m.rows.Add(ctx, int64(stats.Rows), attributes...)
+		m.bytes.Add(ctx, stats.BytesReceived, attributes...)
+		m.latency.Record(ctx, float64(stats.Latency.Microseconds())/1000, attributes...)
+	}
+
+	if client.requestLog != nil {
+		client.requestLog.write(span.SpanContext(), stats)
+	}
+	if client.OnRequest != nil {
+		client.OnRequest(stats)
+	}
+}
+
+// requestLog writes a JSON line for each request of a client to a file, with the IDs of its span
+type requestLog struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// requestLogEntry is a line of a request log
+type requestLogEntry struct {
+	RequestStats
+	LatencyMs float64 `json:"latencyMs"`
+	TraceID   string  `json:"traceId,omitempty"`
+	SpanID    string  `json:"spanId,omitempty"`
+}
+
+// newRequestLog opens a request log, appending to the file if it exists
+func newRequestLog(path string) (*requestLog, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file %s: %s", path, err.Error())
+	}
+	return &requestLog{file: file}, nil
+}
+
+func (l *requestLog) write(spanContext trace.SpanContext, stats RequestStats) {
+	entry := requestLogEntry{RequestStats: stats, LatencyMs: float64(stats.Latency.Microseconds()) / 1000}
+	if spanContext.IsValid() {
+		entry.TraceID, entry.SpanID = spanContext.TraceID().String(), spanContext.SpanID().String()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if _, err = l.file.Write(append(data, '\n')); err != nil {
+		log.Printf("[WARN] graphql.trace_file: %s", err.Error())
+	}
+}
+
+// CallStats summarises the requests of clients by table and operation
+type CallStats struct {
+	mutex sync.Mutex
+	calls map[callKey]*CallSummary
+}
+
+type callKey struct {
+	table     string
+	operation string
+}
+
+// CallSummary is a summary of the requests for a table with an operation
+type CallSummary struct {
+	Table         string
+	Operation     string
+	Calls         int64
+	Errors        int64
+	Rows          int64
+	BytesReceived int64
+	TotalLatency  time.Duration
+	MaxLatency    time.Duration
+	LastError     string
+	FirstCall     time.Time
+	LastCall      time.Time
+}
+
+// Record adds a request to the summary of its table and operation, e.g. as the OnRequest callback of a client
+func (s *CallStats) Record(stats RequestStats) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.calls == nil {
+		s.calls = map[callKey]*CallSummary{}
+	}
+	key := callKey{table: stats.Table, operation: stats.Operation}
+	summary, ok := s.calls[key]
+	if !ok {
+		summary = &CallSummary{Table: stats.Table, Operation: stats.Operation, FirstCall: stats.Start}
+		s.calls[key] = summary
+	}
+	summary.Calls++
+	summary.Rows += int64(stats.Rows)
+	summary.BytesReceived += stats.BytesReceived
+	summary.TotalLatency += stats.Latency
+	if stats.Latency > summary.MaxLatency {
+		summary.MaxLatency = stats.Latency
+	}
+	if stats.ErrorClass != "" {
+		summary.Errors++
+		summary.LastError = stats.ErrorClass
+	}
+	summary.LastCall = stats.Start
+}
+
+// Summaries returns the summaries of the requests, by table and operation
+func (s *CallStats) Summaries() []CallSummary {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	summaries := make([]CallSummary, 0, len(s.calls))
+	for _, summary := range s.calls {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Table != summaries[j].Table {
+			return summaries[i].Table < summaries[j].Table
+		}
+		return summaries[i].Operation < summaries[j].Operation
+	})
+	return summaries
+}
@@ -0,0 +1,129 @@
+package apiClient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/machinebox/graphql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationName(t *testing.T) {
+	for query, expected := range map[string]string{
+		"query resourceList($filter: [String!]) { resources { items { turbot { id } } } }":                              "resourceList",
+		"\n\tmutation UpdateResource($input: UpdateResourceInput!) { updateResource(input: $input) { turbot { id } } }": "UpdateResource",
+		"{\n\tschema: __schema {\n\t\tqueryType { name }\n\t}\n}":                                                       "__schema",
+		"{ actor { identity { turbot { id } } } }":                                                                      "actor",
+		"not graphql": "unknown",
+	} {
+		assert.Equal(t, expected, operationName(query), query)
+	}
+}
+
+type resourcesResponse struct {
+	Resources struct {
+		Items  []map[string]interface{}
+		Paging struct {
+			Next string
+		}
+	}
+}
+
+func TestDoRequestStats(t *testing.T) {
+	// there are two pages of results
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		switch {
+		case request.Variables["next_token"] == "":
+			_, _ = w.Write([]byte(`{"data": {"resources": {"items": [{"turbot": {"id": "1"}}, {"turbot": {"id": "2"}}], "paging": {"next": "page-2"}}}}`))
+		case request.Variables["next_token"] == "page-2":
+			_, _ = w.Write([]byte(`{"data": {"resources": {"items": [{"turbot": {"id": "3"}}], "paging": {"next": ""}}}}`))
+		default:
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	tracePath := filepath.Join(t.TempDir(), "trace.jsonl")
+	requestLog, err := newRequestLog(tracePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats []RequestStats
+	callStats := &CallStats{}
+	client := &Client{
+		AccessKey:  "access",
+		SecretKey:  "secret",
+		Graphql:    graphql.NewClient(server.URL, graphql.WithHTTPClient(&http.Client{Transport: &countingTransport{transport: http.DefaultTransport}})),
+		requestLog: requestLog,
+		OnRequest: func(s RequestStats) {
+			stats = append(stats, s)
+			callStats.Record(s)
+		},
+	}
+
+	ctx := WithTable(context.Background(), "turbot_resource")
+	query := "query resourceList($filter: [String!], $next_token: String) { resources(filter: $filter, paging: $next_token) { items { turbot { id } } paging { next } } }"
+	nextToken := ""
+	for {
+		result := &resourcesResponse{}
+		if !assert.NoError(t, client.DoRequestContext(ctx, query, map[string]interface{}{"filter": []string{"resourceType:bucket", "limit:2"}, "next_token": nextToken}, result)) {
+			return
+		}
+		if nextToken = result.Resources.Paging.Next; nextToken == "" {
+			break
+		}
+	}
+	assert.Error(t, client.DoRequest(query, map[string]interface{}{"next_token": "unknown"}, &resourcesResponse{}))
+
+	if !assert.Len(t, stats, 3) {
+		return
+	}
+	assert.Equal(t, "resourceList", stats[0].Operation)
+	assert.Equal(t, "turbot_resource", stats[0].Table)
+	assert.Equal(t, "resourceType:bucket limit:2", stats[0].Filter)
+	assert.Equal(t, []int{1, 2, 0}, []int{stats[0].Page, stats[1].Page, stats[2].Page})
+	assert.Equal(t, []int{2, 1, 0}, []int{stats[0].Rows, stats[1].Rows, stats[2].Rows})
+	assert.Equal(t, []string{"", "", "client"}, []string{stats[0].ErrorClass, stats[1].ErrorClass, stats[2].ErrorClass})
+	assert.Greater(t, stats[0].BytesReceived, int64(100))
+	assert.Equal(t, "", stats[2].Table)
+
+	summaries := callStats.Summaries()
+	if assert.Len(t, summaries, 2) {
+		assert.Equal(t, "", summaries[0].Table)
+		assert.Equal(t, int64(1), summaries[0].Errors)
+		assert.Equal(t, "client", summaries[0].LastError)
+		assert.Equal(t, "turbot_resource", summaries[1].Table)
+		assert.Equal(t, int64(2), summaries[1].Calls)
+		assert.Equal(t, int64(3), summaries[1].Rows)
+		assert.Equal(t, int64(0), summaries[1].Errors)
+	}
+
+	// each request is a line of the trace file
+	file, err := os.Open(tracePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var lines []requestLogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry requestLogEntry
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		lines = append(lines, entry)
+	}
+	if assert.Len(t, lines, 3) {
+		assert.Equal(t, "resourceList", lines[1].Operation)
+		assert.Equal(t, 2, lines[1].Page)
+		assert.Equal(t, 1, lines[1].Rows)
+	}
+}
@@ -1,5 +1,7 @@
 package apiClient
 
+import "fmt"
+
 // create a map of the properties we want the graphql query to return
 var localDirectoryUserProperties = []interface{}{
 	map[string]string{"parent": "turbot.parentId"},
@@ -13,11 +15,14 @@ var localDirectoryUserProperties = []interface{}{
 	"picture",
 }
 
+// the resource type of local directory users
+const localDirectoryUserResourceType = "tmod:@turbot/turbot-iam#/resource/types/localDirectoryUser"
+
 func (client *Client) CreateLocalDirectoryUser(input map[string]interface{}) (*LocalDirectoryUser, error) {
 	query := createResourceMutation(localDirectoryUserProperties)
 	responseData := &LocalDirectoryUserResponse{}
 	// set type in input data
-	input["type"] = "tmod:@turbot/turbot-iam#/resource/types/localDirectoryUser"
+	input["type"] = localDirectoryUserResourceType
 	variables := map[string]interface{}{
 		"input": input,
 	}
@@ -52,3 +57,28 @@ func (client *Client) UpdateLocalDirectoryUserResource(input map[string]interfac
 	}
 	return &responseData.Resource, nil
 }
+
+// ListLocalDirectoryUsers returns the users of a local directory, given by its id or aka, reading every page
+func (client *Client) ListLocalDirectoryUsers(directory string) ([]LocalDirectoryUser, error) {
+	query := listResourcesQuery(localDirectoryUserProperties)
+	filter := fmt.Sprintf("resourceTypeId:'%s' resourceTypeLevel:self resourceId:'%s' level:descendant limit:5000", localDirectoryUserResourceType, EscapeFilterString(directory))
+	var users []LocalDirectoryUser
+	next := ""
+	for {
+		responseData := &ListLocalDirectoryUsersResponse{}
+		variables := map[string]interface{}{
+			"filter":     filter,
+			"next_token": next,
+		}
+
+		// execute api call
+		if err := client.doRequest(query, variables, responseData); err != nil {
+			return nil, fmt.Errorf("error listing local directory users: %s", err.Error())
+		}
+		users = append(users, responseData.Resources.Items...)
+		next = responseData.Resources.Paging.Next
+		if next == "" {
+			return users, nil
+		}
+	}
+}
@@ -81,3 +81,64 @@ func (client *Client) GetModVersions(org, mod string) ([]ModRegistryVersion, err
 
 	return responseData.Versions.Items, nil
 }
+
+// the resource type of installed mods
+const modResourceType = "tmod:@turbot/turbot#/resource/types/mod"
+
+// ListMods returns the mods installed in the workspace, reading every page
+func (client *Client) ListMods() ([]Mod, error) {
+	query := listModsQuery()
+	var mods []Mod
+	next := ""
+	for {
+		responseData := &ListModsResponse{}
+		variables := map[string]interface{}{
+			"filter":     fmt.Sprintf("resourceTypeId:'%s' resourceTypeLevel:self limit:5000", modResourceType),
+			"next_token": next,
+		}
+
+		// execute api call
+		if err := client.doRequest(query, variables, responseData); err != nil {
+			return nil, fmt.Errorf("error listing mods: %s", err.Error())
+		}
+		for _, item := range responseData.Mods.Items {
+			mod := Mod{Id: item.Turbot.Id, Version: item.Version, Parent: item.Turbot.ParentId}
+			if len(item.Turbot.Akas) > 0 {
+				mod.Uri = item.Turbot.Akas[0]
+				mod.Org, mod.Mod = ParseModUri(mod.Uri)
+			}
+			mods = append(mods, mod)
+		}
+		next = responseData.Mods.Paging.Next
+		if next == "" {
+			return mods, nil
+		}
+	}
+}
+
+// GetModPeerDependencies returns the peer dependencies of each version of a mod in the registry, by version
+func (client *Client) GetModPeerDependencies(org, mod string) (map[string][]ModPeerDependency, error) {
+	query := modPeerDependenciesQuery()
+	responseData := &ModVersionSearchResponse{}
+	variables := map[string]interface{}{
+		"orgName": org,
+		"modName": mod,
+	}
+
+	// execute api call
+	if err := client.doRequest(query, variables, responseData); err != nil {
+		return nil, fmt.Errorf("error fetching mod peer dependencies: %s", err.Error())
+	}
+
+	dependencies := map[string][]ModPeerDependency{}
+	for _, item := range responseData.ModVersionSearches.Items {
+		// the search matches mods whose names contain the mod name
+		if item.IdentityName != org || item.Name != mod {
+			continue
+		}
+		for _, version := range item.Versions {
+			dependencies[version.Version] = version.Head.PeerDependencies
+		}
+	}
+	return dependencies, nil
+}
@@ -2,6 +2,7 @@ package apiClient
 
 import (
 	"fmt"
+	"strings"
 )
 
 func (client *Client) CreatePolicySetting(input map[string]interface{}) (*PolicySetting, error) {
@@ -58,20 +59,55 @@ func (client *Client) DeletePolicySetting(id string) error {
 	return nil
 }
 
+// FindPolicySetting returns the policy setting of the policy type on the resource, or an empty setting if there
+// is none. Settings on the resource's ancestors are not returned. A resource has at most one setting of each
+// policy type, which is returned whatever its precedence.
 func (client *Client) FindPolicySetting(policyTypeUri, resourceAka string) (PolicySetting, error) {
 	responseData := &FindPolicySettingResponse{}
 
-	query := findPolicySettingQuery(policyTypeUri, resourceAka)
+	query := findPolicySettingQuery()
+	variables := map[string]interface{}{
+		"filter": fmt.Sprintf("policyTypeId:'%s' policyTypeLevel:self resourceId:'%s' level:self", EscapeFilterString(policyTypeUri), EscapeFilterString(resourceAka)),
+	}
 
 	// execute api call
-	if err := client.doRequest(query, nil, &responseData); err != nil {
+	if err := client.doRequest(query, variables, &responseData); err != nil {
 		return PolicySetting{}, client.handleReadError(err, policyTypeUri, "policy setting")
 	}
 
-	for _, setting := range responseData.PolicySettings.Items {
-		if setting.Default {
-			return setting, nil
+	if len(responseData.PolicySettings.Items) == 0 {
+		return PolicySetting{}, nil
+	}
+	return responseData.PolicySettings.Items[0], nil
+}
+
+// EscapeFilterString escapes a value to be quoted in a filter, e.g. resourceId:'<value>'
+func EscapeFilterString(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "'", "\\'", -1)
+	return s
+}
+
+// ListPolicySettings returns all the policy settings matching the filter, reading every page
+func (client *Client) ListPolicySettings(filter string) ([]PolicySetting, error) {
+	query := listPolicySettingsQuery()
+	var settings []PolicySetting
+	next := ""
+	for {
+		responseData := &ListPolicySettingsResponse{}
+		variables := map[string]interface{}{
+			"filter":     filter,
+			"next_token": next,
+		}
+
+		// execute api call
+		if err := client.doRequest(query, variables, responseData); err != nil {
+			return nil, fmt.Errorf("error listing policy settings: %s", err.Error())
+		}
+		settings = append(settings, responseData.PolicySettings.Items...)
+		next = responseData.PolicySettings.Paging.Next
+		if next == "" {
+			return settings, nil
 		}
 	}
-	return PolicySetting{}, nil
 }
@@ -0,0 +1,35 @@
+package apiClient
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/machinebox/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/mockapi"
+)
+
+func TestFindPolicySetting(t *testing.T) {
+	store, err := mockapi.LoadStore("../turbot/testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mockapi.NewServer(store))
+	defer server.Close()
+	client := &Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(server.URL)}
+
+	// a required setting is returned as well as a default one
+	setting, err := client.FindPolicySetting("tmod:@turbot/aws-s3#/policy/types/bucketVersioning", "1001")
+	assert.NoError(t, err)
+	assert.Equal(t, "9001", setting.Turbot.Id)
+	assert.False(t, setting.Default)
+
+	// quotes are escaped, rather than breaking the filter
+	setting, err = client.FindPolicySetting("tmod:@turbot/aws-s3#/policy/types/bucketVersioning", "arn:aws:s3:::it's-a-bucket")
+	assert.NoError(t, err)
+	assert.Empty(t, setting.Turbot.Id)
+}
+
+func TestEscapeFilterString(t *testing.T) {
+	assert.Equal(t, `it\'s a \\ path`, EscapeFilterString(`it's a \ path`))
+}
@@ -1,5 +1,7 @@
 package apiClient
 
+import "fmt"
+
 var profileProperties = []interface{}{
 	map[string]string{"parent": "turbot.parentId"},
 	"title",
@@ -16,11 +18,14 @@ var profileProperties = []interface{}{
 	"lastLoginTimestamp",
 }
 
+// the resource type of profiles
+const profileResourceType = "tmod:@turbot/turbot-iam#/resource/types/profile"
+
 func (client *Client) CreateProfile(input map[string]interface{}) (*Profile, error) {
 	query := createResourceMutation(profileProperties)
 	responseData := &ProfileResponse{}
 	// set type in input data
-	input["type"] = "tmod:@turbot/turbot-iam#/resource/types/profile"
+	input["type"] = profileResourceType
 	variables := map[string]interface{}{
 		"input": input,
 	}
@@ -56,3 +61,28 @@ func (client *Client) UpdateProfile(input map[string]interface{}) (*Profile, err
 	}
 	return &responseData.Resource, nil
 }
+
+// ListProfiles returns the profiles of a directory, given by its id or aka, reading every page
+func (client *Client) ListProfiles(directory string) ([]Profile, error) {
+	query := listResourcesQuery(profileProperties)
+	filter := fmt.Sprintf("resourceTypeId:'%s' resourceTypeLevel:self resourceId:'%s' level:descendant limit:5000", profileResourceType, EscapeFilterString(directory))
+	var profiles []Profile
+	next := ""
+	for {
+		responseData := &ListProfilesResponse{}
+		variables := map[string]interface{}{
+			"filter":     filter,
+			"next_token": next,
+		}
+
+		// execute api call
+		if err := client.doRequest(query, variables, responseData); err != nil {
+			return nil, fmt.Errorf("error listing profiles: %s", err.Error())
+		}
+		profiles = append(profiles, responseData.Resources.Items...)
+		next = responseData.Resources.Paging.Next
+		if next == "" {
+			return profiles, nil
+		}
+	}
+}
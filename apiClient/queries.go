@@ -47,6 +47,27 @@ func validationQuery() (string, ValidationResponse) {
 	return query, ValidationResponse{}
 }
 
+// the identity of the access key of a request, checked once the client is validated
+func actorQuery() string {
+	return `{
+	actor {
+		identity {
+			trunk {
+				title
+			}
+			turbot {
+				id
+				title
+				akas
+			}
+			type {
+				uri
+			}
+		}
+	}
+}`
+}
+
 // policySetting
 func createPolicySettingMutation() string {
 	return `mutation CreatePolicySetting($input: CreatePolicySettingInput!) {
@@ -137,26 +158,63 @@ func deletePolicySettingMutation() string {
 }`
 }
 
-func findPolicySettingQuery(policyTypeUri, resourceAka string) string {
-	return fmt.Sprintf(`{
-  policySettings: policySettingList(filter: "policyType:%s resource:%s") {
-    items {
-      	value: secretValue
-		valueSource: secretValueSource
-		template
-		precedence
-		templateInput
-		input
-		note
-		validFromTimestamp
-		validToTimestamp
-		turbot {
-			id
+// find the policy setting of a type on a resource - the resource may be given by id or aka
+func findPolicySettingQuery() string {
+	return `query FindPolicySetting($filter: [String!]) {
+	policySettings(filter: $filter) {
+		items {
+			type {
+				uri
+			}
+			value: secretValue
+			valueSource: secretValueSource
+			template
+			default
+			precedence
+			templateInput
+			input
+			note
+			validFromTimestamp
+			validToTimestamp
+			turbot {
+				id
+				resourceId
+			}
 		}
-    }
-  }
+	}
+}`
 }
-`, policyTypeUri, resourceAka)
+
+// list the policy settings matching a filter, a page at a time. Values of secret policy types are not returned.
+func listPolicySettingsQuery() string {
+	return `query ListPolicySettings($filter: [String!], $next_token: String) {
+	policySettings(filter: $filter, paging: $next_token) {
+		items {
+			type {
+				uri
+				secret
+			}
+			resource {
+				akas
+			}
+			value
+			valueSource
+			template
+			default
+			isCalculated
+			precedence
+			templateInput
+			note
+			turbot {
+				id
+				resourceId
+			}
+		}
+		paging {
+			next
+		}
+	}
+}`
 }
 
 // policy value
@@ -215,6 +273,75 @@ func readSmartFolderQuery(id string) string {
 }`, id)
 }
 
+func listSmartFoldersQuery() string {
+	return `query ListSmartFolders($filter: [String!], $next_token: String) {
+	smartFolders: resources(filter: $filter, paging: $next_token) {
+		items {
+			title: get(path:"turbot.title")
+			description: get(path:"description")
+			filters: get(path:"filters")
+			parent: get(path:"turbot.parentId")
+			turbot: get(path:"turbot")
+			attachedResources {
+				items {
+					turbot: get(path:"turbot")
+				}
+				paging {
+					next
+				}
+			}
+		}
+		paging {
+			next
+		}
+	}
+}`
+}
+
+func listTagsQuery() string {
+	return `query ListTags($filter: [String!], $next_token: String) {
+	tags(filter: $filter, paging: $next_token) {
+		items {
+			key
+			value
+			turbot {
+				id
+			}
+			resources {
+				items {
+					turbot {
+						id
+					}
+				}
+				paging {
+					next
+				}
+			}
+		}
+		paging {
+			next
+		}
+	}
+}`
+}
+
+// connectionPageQuery returns the query of a page of a connection of an item, e.g. the resources of a tag, with
+// the given fields of its items
+func connectionPageQuery(root, connection, fields string) string {
+	return fmt.Sprintf(`query ConnectionPage($id: ID!, $next_token: String) {
+	item: %s(id: $id) {
+		connection: %s(paging: $next_token) {
+			items {
+				%s
+			}
+			paging {
+				next
+			}
+		}
+	}
+}`, root, connection, fields)
+}
+
 func updateSmartFolderMutation() string {
 	return `mutation UpdateSmartFolder($input: UpdateSmartFolderInput!) {
 		smartFolder: updateSmartFolder(input: $input) {
@@ -290,6 +417,39 @@ func modVersionsQuery(org, mod string) string {
 }`, org, mod)
 }
 
+func listModsQuery() string {
+	return `query ListMods($filter: [String!], $next_token: String) {
+	mods: resources(filter: $filter, paging: $next_token) {
+		items {
+			version: get(path: "version")
+			turbot {
+				id
+				parentId
+				akas
+			}
+		}
+		paging {
+			next
+		}
+	}
+}`
+}
+
+func modPeerDependenciesQuery() string {
+	return `query ModPeerDependencies($orgName: String, $modName: String) {
+	modVersionSearches(orgName: $orgName, modName: $modName) {
+		items {
+			identityName
+			name
+			versions {
+				version
+				head
+			}
+		}
+	}
+}`
+}
+
 // resource
 func createResourceMutation(properties []interface{}) string {
 	return fmt.Sprintf(`mutation CreateResource($input: CreateResourceInput!) {
@@ -309,6 +469,20 @@ func updateResourceMutation(properties []interface{}) string {
 }`, buildResourceProperties(properties))
 }
 
+func listResourcesQuery(properties []interface{}) string {
+	return fmt.Sprintf(`query ListResources($filter: [String!], $next_token: String) {
+	resources(filter: $filter, paging: $next_token) {
+		items {
+%s
+			turbot: get(path:"turbot")
+		}
+		paging {
+			next
+		}
+	}
+}`, buildResourceProperties(properties))
+}
+
 func deleteResourceMutation() string {
 	return `mutation DeleteResource($input: DeleteResourceInput!) {
  	resource: deleteResource(input: $input) {
@@ -416,6 +590,44 @@ func deleteGrantMutation() string {
 }`, turbotGrantMetadataFragment("\t\t\t"))
 }
 
+// list the grants matching a filter, a page at a time, with their identity, level and resource
+func listGrantsQuery() string {
+	return `query ListGrants($filter: [String!], $next_token: String) {
+	grants(filter: $filter, paging: $next_token) {
+		items {
+			identity {
+				akas
+				email: get(path: "email")
+				profileId: get(path: "profileId")
+				lastLoginTimestamp: get(path: "lastLoginTimestamp")
+				trunk {
+					title
+				}
+				type {
+					uri
+				}
+			}
+			level {
+				uri
+			}
+			resource {
+				akas
+				turbot {
+					id
+				}
+			}
+			turbot {
+				id
+				createTimestamp
+			}
+		}
+		paging {
+			next
+		}
+	}
+}`
+}
+
 // active grant
 func readActiveGrantQuery(aka string) string {
 	return fmt.Sprintf(`{
@@ -425,6 +637,32 @@ func readActiveGrantQuery(aka string) string {
 }`, aka, turbotActiveGrantMetadataFragment("\t\t"))
 }
 
+// list the active grants matching a filter, a page at a time
+func listActiveGrantsQuery() string {
+	return `query ListActiveGrants($filter: [String!], $next_token: String) {
+	activeGrants(filter: $filter, paging: $next_token) {
+		items {
+			grant {
+				turbot {
+					id
+				}
+			}
+			resource {
+				turbot {
+					id
+				}
+			}
+			turbot {
+				id
+			}
+		}
+		paging {
+			next
+		}
+	}
+}`
+}
+
 func activateGrantMutation() string {
 	return fmt.Sprintf(`mutation ActivateGrant($input: ActivateGrantInput!) {
 	grantActivate: activateGrant(input: $input) {
@@ -1,11 +1,14 @@
 package apiClient
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/mitchellh/mapstructure"
 	"github.com/turbot/steampipe-plugin-turbot/errors"
 	"github.com/turbot/steampipe-plugin-turbot/helpers"
 	"log"
+	"regexp"
+	"strings"
 )
 
 func (client *Client) CreateResource(input map[string]interface{}) (*TurbotResourceMetadata, error) {
@@ -104,6 +107,28 @@ func (client *Client) ReadSerializableResource(resourceAka string) (*Serializabl
 	return &result, nil
 }
 
+// read a batch of resources by aka in a single request, returning the raw JSON for each resource, keyed by aka.
+// selection is the GraphQL selection set requested for each resource.
+// Resources which could not be found are omitted from the result, rather than failing the whole batch.
+func (client *Client) ReadResourceBatch(akas []string, selection string) (map[string]json.RawMessage, error) {
+	results := map[string]json.RawMessage{}
+	if len(akas) == 0 {
+		return results, nil
+	}
+	for aka, result := range client.readBatch("resource", akas, selection) {
+		if result.err != nil {
+			if errors.NotFoundError(result.err) {
+				continue
+			}
+			return nil, fmt.Errorf("error reading resource batch: %s", result.err.Error())
+		}
+		if result.data != nil {
+			results[aka] = result.data
+		}
+	}
+	return results, nil
+}
+
 func (client *Client) ReadResourceList(filter string, properties map[string]string) ([]Resource, error) {
 	query := readResourceListQuery(filter, properties)
 	var responseData = &ReadResourceListResponse{}
@@ -199,3 +224,47 @@ func (client *Client) AssignResourceResults(responseData interface{}, properties
 	return &resource, nil
 
 }
+
+// the limit of list filters which give none of their own
+const defaultListLimit = "limit:5000"
+
+// a limit term of a filter
+var filterLimitRegex = regexp.MustCompile(`(^|\s)limit:`)
+
+// withDefaultLimit appends the default limit to a filter which has no limit. The earlier limit of a filter wins,
+// so the default limit is appended after the filter rather than prepended, and only when the filter has none.
+func withDefaultLimit(filter string) string {
+	if filterLimitRegex.MatchString(filter) {
+		return filter
+	}
+	return strings.TrimSpace(filter + " " + defaultListLimit)
+}
+
+// readConnectionPages reads the items of a connection of an item, e.g. the resources of a tag, from the page with
+// the next cursor on. root is the field to look the item up by id, and fields the fields of the items.
+func (client *Client) readConnectionPages(root, id, connection, fields, next string) ([]json.RawMessage, error) {
+	query := connectionPageQuery(root, connection, fields)
+	var items []json.RawMessage
+	for next != "" {
+		responseData := &struct {
+			Item struct {
+				Connection struct {
+					Items  []json.RawMessage
+					Paging struct {
+						Next string
+					}
+				}
+			}
+		}{}
+		variables := map[string]interface{}{
+			"id":         id,
+			"next_token": next,
+		}
+		if err := client.doRequest(query, variables, responseData); err != nil {
+			return nil, err
+		}
+		items = append(items, responseData.Item.Connection.Items...)
+		next = responseData.Item.Connection.Paging.Next
+	}
+	return items, nil
+}
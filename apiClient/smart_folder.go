@@ -1,5 +1,11 @@
 package apiClient
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 func (client *Client) CreateSmartFolder(input map[string]interface{}) (*SmartFolder, error) {
 	query := createSmartFolderMutation()
 	responseData := &SmartFolderResponse{}
@@ -37,3 +43,68 @@ func (client *Client) UpdateSmartFolder(input map[string]interface{}) (*SmartFol
 	}
 	return &responseData.SmartFolder, nil
 }
+
+// the resource type of smart folders
+const smartFolderResourceType = "tmod:@turbot/turbot#/resource/types/smartFolder"
+
+// ListSmartFolders returns the smart folders matching the filter, with their attached resources, reading every
+// page. Values quoted in the filter are escaped with EscapeFilterString.
+func (client *Client) ListSmartFolders(filter string) ([]SmartFolder, error) {
+	query := listSmartFoldersQuery()
+	filter = withDefaultLimit(strings.TrimSpace(fmt.Sprintf("resourceTypeId:'%s' resourceTypeLevel:self %s", smartFolderResourceType, filter)))
+	var smartFolders []SmartFolder
+	next := ""
+	for {
+		responseData := &ListSmartFoldersResponse{}
+		variables := map[string]interface{}{
+			"filter":     filter,
+			"next_token": next,
+		}
+
+		// execute api call
+		if err := client.doRequest(query, variables, responseData); err != nil {
+			return nil, fmt.Errorf("error listing smart folders: %s", err.Error())
+		}
+		for _, folder := range responseData.SmartFolders.Items {
+			// the attached resources of a smart folder are paged too, so read the pages after the first
+			items, err := client.readConnectionPages("resource", folder.Turbot.Id, "attachedResources", `turbot: get(path:"turbot")`, folder.AttachedResources.Paging.Next)
+			if err != nil {
+				return nil, fmt.Errorf("error listing the attached resources of smart folder %s: %s", folder.Turbot.Id, err.Error())
+			}
+			for _, data := range items {
+				var item struct {
+					Turbot TurbotResourceMetadata
+				}
+				if err := json.Unmarshal(data, &item); err != nil {
+					return nil, fmt.Errorf("error listing the attached resources of smart folder %s: %s", folder.Turbot.Id, err.Error())
+				}
+				folder.AttachedResources.Items = append(folder.AttachedResources.Items, item)
+			}
+			folder.AttachedResources.Paging.Next = ""
+			smartFolders = append(smartFolders, folder)
+		}
+		next = responseData.SmartFolders.Paging.Next
+		if next == "" {
+			return smartFolders, nil
+		}
+	}
+}
+
+// DeleteSmartFolder deletes a smart folder, which detaches it from its resources
+func (client *Client) DeleteSmartFolder(id string) error {
+	query := deleteResourceMutation()
+	// we do not care about the response
+	var responseData interface{}
+
+	variables := map[string]interface{}{
+		"input": map[string]string{
+			"id": id,
+		},
+	}
+
+	// execute api call
+	if err := client.doRequest(query, variables, &responseData); err != nil {
+		return fmt.Errorf("error deleting smart folder: %s", err.Error())
+	}
+	return nil
+}
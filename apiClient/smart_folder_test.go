@@ -0,0 +1,54 @@
+package apiClient
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/machinebox/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/mockapi"
+)
+
+func TestSmartFolderLifecycle(t *testing.T) {
+	store, err := mockapi.LoadStore("../turbot/testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mockapi.NewServer(store))
+	defer server.Close()
+	client := &Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(server.URL)}
+
+	folders, err := client.ListSmartFolders("")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, folders, 1)
+	assert.Equal(t, "AWS Baseline", folders[0].Title)
+	assert.Equal(t, "Baseline policies for AWS accounts", folders[0].Description)
+	assert.Len(t, folders[0].AttachedResources.Items, 1)
+
+	folder, err := client.CreateSmartFolder(map[string]interface{}{"parent": "arn:aws:::123456789012", "title": "Buckets"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	id := folder.Turbot.Id
+	_, err = client.CreateSmartFolderAttachment(map[string]interface{}{"resource": "arn:aws:s3:::my-bucket", "smartFolders": []string{id}})
+	assert.NoError(t, err)
+	folders, err = client.ListSmartFolders("resourceId:'" + EscapeFilterString(id) + "' level:self")
+	if assert.NoError(t, err) && assert.Len(t, folders, 1) {
+		assert.Equal(t, "Buckets", folders[0].Title)
+		assert.Equal(t, "1002", folders[0].AttachedResources.Items[0].Turbot.Id)
+	}
+
+	assert.NoError(t, client.DeleteSmartFolderAttachment(map[string]interface{}{"resource": "arn:aws:s3:::my-bucket", "smartFolders": []string{id}}))
+	folders, err = client.ListSmartFolders("resourceId:'" + EscapeFilterString(id) + "' level:self")
+	if assert.NoError(t, err) && assert.Len(t, folders, 1) {
+		assert.Empty(t, folders[0].AttachedResources.Items)
+	}
+
+	assert.NoError(t, client.DeleteSmartFolder(id))
+	folders, err = client.ListSmartFolders("")
+	assert.NoError(t, err)
+	assert.Len(t, folders, 1)
+	assert.ErrorContains(t, client.DeleteSmartFolder(id), "error deleting smart folder")
+}
@@ -0,0 +1,420 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// a term of a Turbot filter, e.g. resourceTypeId:'tmod:@turbot/aws-s3#/resource/types/bucket', -is:orphan,
+// createTimestamp:>='2023-01-01T00:00:00.000Z' or a bare search word
+type filterTerm struct {
+	key      string
+	negate   bool
+	operator string
+	values   []string
+}
+
+// a parsed filter - its terms, which must all match, and the page size set by its limit
+type filter struct {
+	terms []filterTerm
+	limit int
+	// the levels of the resources matched by resourceId terms, set by e.g. level:self,descendant
+	levels map[string]bool
+}
+
+var filterKeyRegex = regexp.MustCompile(`^-?[A-Za-z$][A-Za-z0-9_.$]*:`)
+
+// parseFilter parses a Turbot filter, given as a string or a list of strings, whose terms are combined.
+// Only the first limit in the filter is used.
+func parseFilter(value interface{}) (*filter, error) {
+	var strs []string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		strs = []string{v}
+	case []interface{}:
+		for _, s := range v {
+			str, ok := s.(string)
+			if !ok {
+				return nil, fmt.Errorf("filter must be a string or list of strings")
+			}
+			strs = append(strs, str)
+		}
+	default:
+		return nil, fmt.Errorf("filter must be a string or list of strings")
+	}
+
+	f := &filter{}
+	for _, s := range strs {
+		terms, err := parseFilterTerms(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, term := range terms {
+			if term.key == "level" {
+				f.levels = map[string]bool{}
+				for _, v := range term.values {
+					if v != "self" && v != "descendant" {
+						return nil, fmt.Errorf("unsupported filter level:%s - only the self and descendant levels are supported", strings.Join(term.values, ","))
+					}
+					f.levels[v] = true
+				}
+				continue
+			}
+			if term.key != "limit" {
+				f.terms = append(f.terms, term)
+				continue
+			}
+			if f.limit != 0 {
+				continue
+			}
+			limit, err := strconv.Atoi(strings.Join(term.values, ","))
+			if err != nil || limit <= 0 {
+				return nil, fmt.Errorf("invalid filter limit:%s", strings.Join(term.values, ","))
+			}
+			f.limit = limit
+		}
+	}
+	return f, nil
+}
+
+// split a filter string into its terms, which are separated by white space
+func parseFilterTerms(s string) ([]filterTerm, error) {
+	var terms []filterTerm
+	i := 0
+	for {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return terms, nil
+		}
+
+		term := filterTerm{}
+		if key := filterKeyRegex.FindString(s[i:]); key != "" {
+			i += len(key)
+			key = strings.TrimSuffix(key, ":")
+			if strings.HasPrefix(key, "-") {
+				term.negate = true
+				key = key[1:]
+			}
+			term.key = key
+			for _, op := range []string{">=", "<=", ">", "<"} {
+				if strings.HasPrefix(s[i:], op) {
+					term.operator = op
+					i += len(op)
+					break
+				}
+			}
+		}
+
+		// a comma separated list of values, any of which may be quoted
+		for {
+			value, n, err := parseFilterValue(s[i:])
+			if err != nil {
+				return nil, err
+			}
+			term.values = append(term.values, value)
+			i += n
+			// bare search words are not split on commas
+			if term.key == "" || i >= len(s) || s[i] != ',' {
+				break
+			}
+			i++
+		}
+		terms = append(terms, term)
+	}
+}
+
+// read a filter value, returning the value and its length in the filter string
+func parseFilterValue(s string) (string, int, error) {
+	if s == "" || s[0] != '\'' && s[0] != '"' {
+		end := 0
+		for end < len(s) && !isSpace(s[end]) && s[end] != ',' {
+			end++
+		}
+		return s[:end], end, nil
+	}
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+			if i < len(s) {
+				b.WriteByte(s[i])
+			}
+		case quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted value in filter: %s", s)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// levelKeys are the filter keys setting the level of a type filter. Only the self level is supported, since the
+// fixtures have no type hierarchy. The resource level is applied by the filter to its resourceId terms.
+var levelKeys = map[string]bool{
+	"controlTypeLevel":  true,
+	"policyTypeLevel":   true,
+	"resourceTypeLevel": true,
+}
+
+// matches returns whether an item of the collection matches all the terms of the filter
+func (f *filter) matches(s *Store, collection string, item map[string]interface{}) (bool, error) {
+	for _, term := range f.terms {
+		ok, err := term.matches(collection, item)
+		if term.key == "resourceId" && f.levels != nil && err == nil {
+			if !f.levels["self"] {
+				ok = false
+			}
+			if !ok && f.levels["descendant"] {
+				ok = s.isDescendant(collection, item, term.values)
+			}
+		}
+		if err != nil {
+			return false, err
+		}
+		if ok == term.negate {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matches returns whether an item matches the term, ignoring negation
+func (t filterTerm) matches(collection string, item map[string]interface{}) (bool, error) {
+	switch {
+	case t.key == "":
+		// search for the words anywhere in the item
+		data, _ := json.Marshal(item)
+		return strings.Contains(strings.ToLower(string(data)), strings.ToLower(t.values[0])), nil
+	case levelKeys[t.key]:
+		if len(t.values) != 1 || t.values[0] != "self" {
+			return false, fmt.Errorf("unsupported filter %s:%s - only the self level is supported", t.key, strings.Join(t.values, ","))
+		}
+		return true, nil
+	case t.key == "is":
+		for _, v := range t.values {
+			if !isFlags[v] {
+				return false, fmt.Errorf("unsupported filter is:%s", v)
+			}
+			if truthy(Lookup(item, v)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case strings.HasPrefix(t.key, "$."):
+		return t.matchesAny([]interface{}{Lookup(item["data"], strings.TrimPrefix(t.key, "$."))})
+	}
+
+	paths, ok := filterPaths[collection][t.key]
+	if !ok {
+		paths, ok = timestampFilterPaths[t.key]
+	}
+	if !ok {
+		return false, fmt.Errorf("unsupported filter key %s for %s", t.key, collection)
+	}
+	var candidates []interface{}
+	for _, path := range paths {
+		candidates = append(candidates, Lookup(item, path))
+	}
+	return t.matchesAny(candidates)
+}
+
+// matchesAny returns whether any of the candidate values (or any element of a candidate list) matches any
+// of the term values
+func (t filterTerm) matchesAny(candidates []interface{}) (bool, error) {
+	for _, candidate := range candidates {
+		if list, ok := candidate.([]interface{}); ok {
+			if ok, err := t.matchesAny(list); ok || err != nil {
+				return ok, err
+			}
+			continue
+		}
+		if candidate == nil {
+			continue
+		}
+		actual := ScalarString(candidate)
+		for _, value := range t.values {
+			if t.operator == "" {
+				if actual == value {
+					return true, nil
+				}
+				continue
+			}
+			if compare(actual, value, t.operator) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// compare two values with the operator, as times or numbers if both parse as such, otherwise as strings
+func compare(actual, value, operator string) bool {
+	var c int
+	at, aerr := time.Parse(time.RFC3339, actual)
+	vt, verr := time.Parse(time.RFC3339, value)
+	an, anerr := strconv.ParseFloat(actual, 64)
+	vn, vnerr := strconv.ParseFloat(value, 64)
+	switch {
+	case aerr == nil && verr == nil:
+		switch {
+		case at.Before(vt):
+			c = -1
+		case at.After(vt):
+			c = 1
+		}
+	case anerr == nil && vnerr == nil:
+		switch {
+		case an < vn:
+			c = -1
+		case an > vn:
+			c = 1
+		}
+	default:
+		c = strings.Compare(actual, value)
+	}
+	switch operator {
+	case ">=":
+		return c >= 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c < 0
+	}
+}
+
+// isFlags are the values supported by the is: filter key, which match items whose field of the same name is true
+var isFlags = map[string]bool{
+	"exception": true,
+	"orphan":    true,
+}
+
+// filterPaths are the filter keys supported for each collection, with the paths of the item fields each
+// matches - an item matches if any of the fields has one of the filter values
+var filterPaths = map[string]map[string][]string{
+	"activeGrants": {
+		"id":         {"grant.turbot.id", "turbot.id"},
+		"resourceId": {"resource.turbot.id"},
+	},
+	"controls": {
+		"id":             {"turbot.id"},
+		"controlTypeId":  {"turbot.controlTypeId", "type.uri", "type.turbot.id"},
+		"resourceId":     {"turbot.resourceId", "resource.turbot.id"},
+		"resourceTypeId": {"turbot.resourceTypeId", "resource.type.uri", "resource.type.turbot.id"},
+		"state":          {"state"},
+	},
+	"controlTypes": {
+		"id":              {"turbot.id"},
+		"controlTypeId":   {"turbot.id", "uri"},
+		"controlCategory": {"category.uri", "category.turbot.id"},
+	},
+	"grants": {
+		"id":         {"turbot.id"},
+		"resourceId": {"resource.turbot.id"},
+	},
+	"notifications": {
+		"id":               {"turbot.id"},
+		"actorIdentityId":  {"turbot.actorIdentityId", "actor.identity.turbot.id", "actor.identity.turbot.actorIdentityId"},
+		"controlTypeId":    {"control.type.uri", "control.type.turbot.id"},
+		"notificationType": {"notificationType", "turbot.type"},
+		"policyTypeId":     {"policySetting.type.uri", "policySetting.type.turbot.id"},
+		"resourceId":       {"turbot.resourceId"},
+		"resourceTypeId":   {"resource.type.uri", "resource.type.turbot.id"},
+	},
+	"policySettings": {
+		"id":           {"turbot.id"},
+		"policyTypeId": {"turbot.policyTypeId", "type.uri", "type.turbot.id"},
+		"resourceId":   {"turbot.resourceId", "resource.akas"},
+	},
+	"policyTypes": {
+		"id":           {"turbot.id"},
+		"policyTypeId": {"turbot.id", "uri"},
+	},
+	"policyValues": {
+		"id":             {"turbot.id"},
+		"policyTypeId":   {"turbot.policyTypeId", "type.uri", "type.turbot.id"},
+		"resourceId":     {"turbot.resourceId"},
+		"resourceTypeId": {"turbot.resourceTypeId", "resource.type.uri", "resource.type.turbot.id"},
+		"state":          {"state"},
+	},
+	"resources": {
+		"id":             {"turbot.id"},
+		"resourceId":     {"turbot.id", "turbot.akas"},
+		"resourceTypeId": {"turbot.resourceTypeId", "type.uri", "type.turbot.id"},
+	},
+	"resourceTypes": {
+		"id":               {"turbot.id"},
+		"resourceTypeId":   {"turbot.id", "uri"},
+		"resourceCategory": {"categoryUri", "category.uri", "category.turbot.id"},
+	},
+	"tags": {
+		"id":    {"turbot.id"},
+		"key":   {"key"},
+		"value": {"value"},
+	},
+}
+
+// timestampFilterPaths are the timestamp filter keys supported for every collection
+var timestampFilterPaths = map[string][]string{
+	"createTimestamp": {"turbot.createTimestamp"},
+	"timestamp":       {"turbot.timestamp"},
+	"updateTimestamp": {"turbot.updateTimestamp"},
+}
+
+// Lookup returns the value at the dot separated path of a JSON value, or nil
+func Lookup(value interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// ScalarString returns the string form of a JSON scalar, as used in filters
+func ScalarString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case json.Number:
+		// the exception and orphan fields are counts
+		return v.String() != "0"
+	case float64:
+		return v != 0
+	default:
+		return true
+	}
+}
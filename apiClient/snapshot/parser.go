@@ -0,0 +1,389 @@
+package snapshot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// a field of a selection set, with its arguments resolved against the request variables
+type field struct {
+	alias     string
+	name      string
+	arguments map[string]interface{}
+	selection []*field
+}
+
+// key returns the key of the field in the response
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// parser for the subset of GraphQL used by the plugin - a single query or mutation operation, with variables,
+// aliases, arguments and nested selection sets. Fragments and directives are not supported.
+type parser struct {
+	source    string
+	pos       int
+	variables map[string]interface{}
+}
+
+// parseQuery parses a query document, returning the selection set of its operation
+func parseQuery(query string, variables map[string]interface{}) ([]*field, error) {
+	operation, selection, err := parseDocument(query, variables)
+	if err != nil {
+		return nil, err
+	}
+	if operation != "query" {
+		return nil, fmt.Errorf("%s operations are not supported", operation)
+	}
+	return selection, nil
+}
+
+// parseDocument parses a query or mutation document, returning the type and selection set of its operation
+func parseDocument(document string, variables map[string]interface{}) (string, []*field, error) {
+	if variables == nil {
+		variables = map[string]interface{}{}
+	}
+	p := &parser{source: document, variables: variables}
+	operation, selection, err := p.parseOperation()
+	if err != nil {
+		return "", nil, err
+	}
+	if p.peek() != 0 {
+		return "", nil, p.errorf("unexpected %q after operation", p.peek())
+	}
+	return operation, selection, nil
+}
+
+func (p *parser) parseOperation() (string, []*field, error) {
+	operation := "query"
+	if p.peek() != '{' {
+		switch keyword := p.parseName(); keyword {
+		case "query", "mutation":
+			operation = keyword
+		case "":
+			return "", nil, p.errorf("expected an operation")
+		default:
+			return "", nil, p.errorf("%s operations are not supported", keyword)
+		}
+		// operation name
+		if isNameStart(p.peek()) {
+			p.parseName()
+		}
+		if p.peek() == '(' {
+			if err := p.parseVariableDefinitions(); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	selection, err := p.parseSelectionSet()
+	return operation, selection, err
+}
+
+// parse the variable definitions of the operation, applying any defaults to the request variables
+func (p *parser) parseVariableDefinitions() error {
+	p.pos++
+	for p.peek() != ')' {
+		if err := p.expect('$'); err != nil {
+			return err
+		}
+		name := p.parseName()
+		if name == "" {
+			return p.errorf("expected a variable name")
+		}
+		if err := p.expect(':'); err != nil {
+			return err
+		}
+		if err := p.parseType(); err != nil {
+			return err
+		}
+		if p.peek() == '=' {
+			p.pos++
+			value, err := p.parseValue()
+			if err != nil {
+				return err
+			}
+			if _, ok := p.variables[name]; !ok {
+				p.variables[name] = value
+			}
+		}
+	}
+	p.pos++
+	return nil
+}
+
+// parse a variable type, e.g. [String!]! - types are not checked
+func (p *parser) parseType() error {
+	if p.peek() == '[' {
+		p.pos++
+		if err := p.parseType(); err != nil {
+			return err
+		}
+		if err := p.expect(']'); err != nil {
+			return err
+		}
+	} else if p.parseName() == "" {
+		return p.errorf("expected a type")
+	}
+	if p.peek() == '!' {
+		p.pos++
+	}
+	return nil
+}
+
+func (p *parser) parseSelectionSet() ([]*field, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	var fields []*field
+	for p.peek() != '}' {
+		switch {
+		case p.peek() == 0:
+			return nil, p.errorf("unterminated selection set")
+		case strings.HasPrefix(p.source[p.pos:], "..."):
+			return nil, p.errorf("fragments are not supported")
+		}
+		f, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	p.pos++
+	return fields, nil
+}
+
+func (p *parser) parseField() (*field, error) {
+	f := &field{name: p.parseName()}
+	if f.name == "" {
+		return nil, p.errorf("expected a field name")
+	}
+	if p.peek() == ':' {
+		p.pos++
+		f.alias = f.name
+		if f.name = p.parseName(); f.name == "" {
+			return nil, p.errorf("expected a field name")
+		}
+	}
+	if p.peek() == '(' {
+		arguments, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		f.arguments = arguments
+	}
+	if p.peek() == '@' {
+		return nil, p.errorf("directives are not supported")
+	}
+	if p.peek() == '{' {
+		selection, err := p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+		f.selection = selection
+	}
+	return f, nil
+}
+
+func (p *parser) parseArguments() (map[string]interface{}, error) {
+	p.pos++
+	arguments := map[string]interface{}{}
+	for p.peek() != ')' {
+		name := p.parseName()
+		if name == "" {
+			return nil, p.errorf("expected an argument name")
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arguments[name] = value
+	}
+	p.pos++
+	return arguments, nil
+}
+
+func (p *parser) parseValue() (interface{}, error) {
+	c := p.peek()
+	switch {
+	case c == '$':
+		p.pos++
+		name := p.parseName()
+		if name == "" {
+			return nil, p.errorf("expected a variable name")
+		}
+		return p.variables[name], nil
+	case c == '"':
+		return p.parseString()
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.source) && strings.IndexByte("0123456789.eE+-", p.source[p.pos]) >= 0 {
+			p.pos++
+		}
+		number := p.source[start:p.pos]
+		if i, err := strconv.ParseInt(number, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", number)
+		}
+		return f, nil
+	case c == '[':
+		p.pos++
+		list := []interface{}{}
+		for p.peek() != ']' {
+			if p.peek() == 0 {
+				return nil, p.errorf("unterminated list")
+			}
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		p.pos++
+		return list, nil
+	case c == '{':
+		p.pos++
+		object := map[string]interface{}{}
+		for p.peek() != '}' {
+			name := p.parseName()
+			if name == "" {
+				return nil, p.errorf("expected an object field name")
+			}
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			object[name] = value
+		}
+		p.pos++
+		return object, nil
+	case isNameStart(c):
+		switch name := p.parseName(); name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		default:
+			// enum values are passed as strings
+			return name, nil
+		}
+	}
+	return nil, p.errorf("expected a value")
+}
+
+func (p *parser) parseString() (string, error) {
+	if strings.HasPrefix(p.source[p.pos:], `"""`) {
+		end := strings.Index(p.source[p.pos+3:], `"""`)
+		if end < 0 {
+			return "", p.errorf("unterminated string")
+		}
+		value := p.source[p.pos+3 : p.pos+3+end]
+		p.pos += end + 6
+		return value, nil
+	}
+	var b strings.Builder
+	for i := p.pos + 1; i < len(p.source); i++ {
+		c := p.source[i]
+		switch c {
+		case '"':
+			p.pos = i + 1
+			return b.String(), nil
+		case '\\':
+			i++
+			if i >= len(p.source) {
+				break
+			}
+			switch p.source[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'u':
+				if i+4 < len(p.source) {
+					if r, err := strconv.ParseUint(p.source[i+1:i+5], 16, 32); err == nil {
+						b.WriteRune(rune(r))
+						i += 4
+					}
+				}
+			default:
+				b.WriteByte(p.source[i])
+			}
+		case '\n':
+			return "", p.errorf("unterminated string")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) parseName() string {
+	p.skipIgnored()
+	start := p.pos
+	if p.pos < len(p.source) && isNameStart(p.source[p.pos]) {
+		p.pos++
+		for p.pos < len(p.source) && (isNameStart(p.source[p.pos]) || p.source[p.pos] >= '0' && p.source[p.pos] <= '9') {
+			p.pos++
+		}
+	}
+	return p.source[start:p.pos]
+}
+
+func (p *parser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// peek returns the next significant character, or 0 at the end of the document
+func (p *parser) peek() byte {
+	p.skipIgnored()
+	if p.pos >= len(p.source) {
+		return 0
+	}
+	return p.source[p.pos]
+}
+
+// skip white space, commas and comments, which are insignificant in GraphQL
+func (p *parser) skipIgnored() {
+	for p.pos < len(p.source) {
+		switch c := p.source[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.source) && p.source[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	line := strings.Count(p.source[:p.pos], "\n") + 1
+	return fmt.Errorf("syntax error: %s (line %d)", fmt.Sprintf(format, args...), line)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,320 @@
+package snapshot
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// resolve a root query field
+func (s *Store) resolve(f *field) (interface{}, error) {
+	switch {
+	case f.name == "__schema":
+		return project(map[string]interface{}{"queryType": map[string]interface{}{"name": "Query"}}, f.selection), nil
+	case f.name == "modVersionSearches":
+		items, err := s.modVersionSearches(f.arguments)
+		if err != nil {
+			return nil, err
+		}
+		return project(map[string]interface{}{"items": items, "paging": map[string]interface{}{"next": nil}}, f.selection), nil
+	case f.name == "modVersionList":
+		items := s.ModVersions(ScalarString(f.arguments["orgName"]), ScalarString(f.arguments["modName"]))
+		return project(map[string]interface{}{"items": items}, f.selection), nil
+	case f.name == "actor":
+		return project(map[string]interface{}{"identity": s.actorIdentity()}, f.selection), nil
+	case f.name == "policyValue" && f.arguments["uri"] != nil:
+		uri, resourceID := ScalarString(f.arguments["uri"]), ScalarString(f.arguments["resourceId"])
+		item := s.policyValue(uri, resourceID)
+		if item == nil {
+			return nil, fmt.Errorf("Not Found: policy value %s for resource %s", uri, resourceID)
+		}
+		return project(item, f.selection), nil
+	case isCollection(f.name):
+		page, err := s.list(f.name, f.arguments)
+		if err != nil {
+			return nil, err
+		}
+		return project(page, f.selection), nil
+	}
+	if collection, ok := collectionOf(f.name); ok {
+		id := ScalarString(f.arguments["id"])
+		item := s.Find(collection, id)
+		if item == nil {
+			return nil, fmt.Errorf("Not Found: %s %s", f.name, id)
+		}
+		return project(item, f.selection), nil
+	}
+	return nil, fmt.Errorf("Cannot query field %q on type \"Query\"", f.name)
+}
+
+// list returns a page of the items of a collection matching the filter argument, starting at the paging cursor
+func (s *Store) list(collection string, arguments map[string]interface{}) (map[string]interface{}, error) {
+	f, err := parseFilter(arguments["filter"])
+	if err != nil {
+		return nil, err
+	}
+	offset, err := decodeCursor(arguments["paging"])
+	if err != nil {
+		return nil, err
+	}
+	size := f.limit
+	if size == 0 {
+		size = DefaultPageSize
+	}
+
+	var matched []interface{}
+	for _, item := range s.collections[collection] {
+		ok, err := f.matches(s, collection, item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, item)
+		}
+	}
+
+	return pageOf(matched, offset, size), nil
+}
+
+// pageItems returns a page of the items of a connection nested in an item, like the attachedResources of a smart
+// folder, given the paging and limit of the arguments of the connection field
+func pageItems(items []interface{}, arguments map[string]interface{}) (map[string]interface{}, error) {
+	f, err := parseFilter(arguments["filter"])
+	if err != nil {
+		return nil, err
+	}
+	offset, err := decodeCursor(arguments["paging"])
+	if err != nil {
+		return nil, err
+	}
+	size := f.limit
+	if size == 0 {
+		size = DefaultPageSize
+	}
+	return pageOf(items, offset, size), nil
+}
+
+// pageOf returns the page of size items starting at offset, with the cursor of the next page if there is one
+func pageOf(matched []interface{}, offset, size int) map[string]interface{} {
+	items := []interface{}{}
+	var next interface{}
+	if offset < len(matched) {
+		end := offset + size
+		if end < len(matched) {
+			next = encodeCursor(end)
+		} else {
+			end = len(matched)
+		}
+		items = matched[offset:end]
+	}
+	return map[string]interface{}{"items": items, "paging": map[string]interface{}{"next": next}}
+}
+
+// the aka of the root resource, which is the ancestor of every resource
+const rootAka = "tmod:@turbot/turbot#/"
+
+// isDescendant returns whether the resource of an item is a descendant of any of the given resources, which are
+// ids or akas
+func (s *Store) isDescendant(collection string, item map[string]interface{}, ancestors []string) bool {
+	var path []string
+	for _, p := range filterPaths[collection]["resourceId"] {
+		if resource := s.Find("resources", ScalarString(Lookup(item, p))); resource != nil {
+			path = strings.Split(ScalarString(Lookup(resource, "turbot.path")), ".")
+			break
+		}
+	}
+	if len(path) < 2 {
+		return false
+	}
+	for _, ancestor := range ancestors {
+		if ancestor == rootAka {
+			return true
+		}
+		if resource := s.Find("resources", ancestor); resource != nil {
+			ancestor = ScalarString(Lookup(resource, "turbot.id"))
+		}
+		// the last element of the path is the resource itself
+		for _, id := range path[:len(path)-1] {
+			if id == ancestor {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// actorIdentity returns the identity requests are made as. Credentials are not checked, so every request is
+// made as the first profile of the store, or no identity if there is none.
+func (s *Store) actorIdentity() interface{} {
+	for _, item := range s.collections["resources"] {
+		if Lookup(item, "type.uri") == "tmod:@turbot/turbot-iam#/resource/types/profile" {
+			return item
+		}
+	}
+	return nil
+}
+
+// policyValue returns the value of a policy type, given by its uri, for a resource, given by its id or aka
+func (s *Store) policyValue(uri, resourceID string) map[string]interface{} {
+	if resource := s.Find("resources", resourceID); resource != nil {
+		resourceID = ScalarString(Lookup(resource, "turbot.id"))
+	}
+	for _, item := range s.collections["policyValues"] {
+		if Lookup(item, "type.uri") == uri && ScalarString(Lookup(item, "turbot.resourceId")) == resourceID {
+			return item
+		}
+	}
+	return nil
+}
+
+// Find returns the item of a collection with the given id, uri or aka, or nil
+func (s *Store) Find(collection string, id string) map[string]interface{} {
+	for _, item := range s.collections[collection] {
+		if ScalarString(Lookup(item, "turbot.id")) == id || item["uri"] == id {
+			return item
+		}
+		for _, path := range []string{"turbot.akas", "akas"} {
+			if akas, ok := Lookup(item, path).([]interface{}); ok {
+				for _, aka := range akas {
+					if aka == id {
+						return item
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// modVersionSearches returns the mods matching the search arguments, with only the versions matching the status
+func (s *Store) modVersionSearches(arguments map[string]interface{}) ([]interface{}, error) {
+	search, _ := arguments["search"].(string)
+	modName, _ := arguments["modName"].(string)
+	orgName, _ := arguments["orgName"].(string)
+	var statuses []string
+	switch v := arguments["status"].(type) {
+	case nil:
+	case string:
+		statuses = []string{v}
+	case []interface{}:
+		for _, status := range v {
+			statuses = append(statuses, ScalarString(status))
+		}
+	default:
+		return nil, fmt.Errorf("invalid status %v", v)
+	}
+
+	items := []interface{}{}
+	for _, mod := range s.collections["modVersionSearches"] {
+		name := ScalarString(mod["name"])
+		switch {
+		case modName != "" && name != modName:
+			continue
+		case orgName != "" && ScalarString(mod["identityName"]) != orgName:
+			continue
+		case search != "" && !strings.Contains(name, search):
+			continue
+		}
+		if len(statuses) == 0 {
+			items = append(items, mod)
+			continue
+		}
+		var versions []interface{}
+		list, _ := mod["versions"].([]interface{})
+		for _, version := range list {
+			for _, status := range statuses {
+				if strings.EqualFold(ScalarString(Lookup(version, "status")), status) {
+					versions = append(versions, version)
+					break
+				}
+			}
+		}
+		if len(versions) > 0 {
+			filtered := map[string]interface{}{}
+			for k, v := range mod {
+				filtered[k] = v
+			}
+			filtered["versions"] = versions
+			items = append(items, filtered)
+		}
+	}
+	return items, nil
+}
+
+// ModVersions returns the versions of a mod in the registry, or nil if it is not in the registry
+func (s *Store) ModVersions(orgName, modName string) []interface{} {
+	for _, mod := range s.collections["modVersionSearches"] {
+		if ScalarString(mod["identityName"]) == orgName && ScalarString(mod["name"]) == modName {
+			versions, _ := mod["versions"].([]interface{})
+			return versions
+		}
+	}
+	return nil
+}
+
+// project a value through a selection set, returning only the selected fields. Values with no selection set
+// are returned as is, so JSON fields like data are returned whole.
+func project(value interface{}, selection []*field) interface{} {
+	if selection == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = project(item, selection)
+		}
+		return list
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for _, f := range selection {
+			switch f.name {
+			case "__typename":
+				result[f.key()] = "Object"
+			case "get":
+				// get(path: "x") reads a path of the data of a resource, except for its turbot metadata
+				path, _ := f.arguments["path"].(string)
+				if path == "turbot" || strings.HasPrefix(path, "turbot.") {
+					result[f.key()] = project(Lookup(v, path), f.selection)
+				} else {
+					result[f.key()] = project(Lookup(v["data"], path), f.selection)
+				}
+			default:
+				value := v[f.name]
+				if connection, ok := value.(map[string]interface{}); ok {
+					if items, ok := connection["items"].([]interface{}); ok {
+						// an invalid filter or paging cursor of a nested connection returns it whole
+						if page, err := pageItems(items, f.arguments); err == nil {
+							value = page
+						}
+					}
+				}
+				result[f.key()] = project(value, f.selection)
+			}
+		}
+		return result
+	}
+	return value
+}
+
+// a paging cursor is the base64 encoded offset of the next page
+func encodeCursor(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(value interface{}) (int, error) {
+	cursor, _ := value.(string)
+	if cursor == "" {
+		return 0, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid paging cursor %s", cursor)
+	}
+	offset, err := strconv.Atoi(string(data))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid paging cursor %s", cursor)
+	}
+	return offset, nil
+}
@@ -0,0 +1,216 @@
+// Package snapshot serves the Turbot GraphQL API from a store of items, e.g. a snapshot of a workspace
+// exported for offline analysis, see the snapshot_path connection option.
+//
+// The items of a store are held in collections, one per list query field, e.g. resources, controls or
+// policyTypes, each item in the shape returned by the API. List fields support the Turbot filter terms used by
+// the plugin tables, a limit setting the page size and cursor paging. Single item fields, e.g.
+// resource(id: $id), look an item up by id, uri or aka. Responses are projected through the selection set of
+// the query, so fields which are not requested are not returned and fields missing from the store are null.
+// Mutations are only served if the server is given them, see Server.Mutations.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Collections are the list query fields served by the API
+var Collections = []string{
+	"activeGrants",
+	"controlTypes",
+	"controls",
+	"grants",
+	"modVersionSearches",
+	"notifications",
+	"policySettings",
+	"policyTypes",
+	"policyValues",
+	"resourceTypes",
+	"resources",
+	"tags",
+}
+
+// DefaultPageSize is the page size of list fields whose filter has no limit
+const DefaultPageSize = 100
+
+// Store holds the items of each collection
+type Store struct {
+	collections map[string][]map[string]interface{}
+	// index of the items of each collection by turbot.id, built as items are added
+	index map[string]map[string]int
+	// the last id given to an item, see NewID
+	lastID int
+
+	// mutations are exclusive of queries
+	mutex sync.RWMutex
+}
+
+// NewStore creates a store with the given items of each collection
+func NewStore(collections map[string][]map[string]interface{}) (*Store, error) {
+	store := &Store{collections: map[string][]map[string]interface{}{}}
+	for name, items := range collections {
+		if !isCollection(name) {
+			return nil, fmt.Errorf("unknown collection %s", name)
+		}
+		store.collections[name] = items
+	}
+	return store, nil
+}
+
+// Mutation applies the input of a mutation field to the store, returning the item it created, updated or
+// deleted. It is called with the store locked.
+type Mutation func(s *Store, input map[string]interface{}) (map[string]interface{}, error)
+
+// Server is an http.Handler serving GraphQL requests against a store
+type Server struct {
+	store *Store
+
+	// Mutations are the mutation fields served, by name. A snapshot is served read only, with none.
+	Mutations map[string]Mutation
+
+	mutex    sync.Mutex
+	requests []Request
+}
+
+// Request is a GraphQL request received by the server
+type Request struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type response struct {
+	Data   interface{}     `json:"data"`
+	Errors []responseError `json:"errors,omitempty"`
+}
+
+type responseError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+// NewServer creates a server for the store
+func NewServer(store *Store) *Server {
+	return &Server{store: store}
+}
+
+// Requests returns the requests received by the server, in order
+func (s *Server) Requests() []Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+// ServeHTTP executes a GraphQL request. Any path is accepted, since clients add the API version to the
+// workspace URL.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request Request
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	s.mutex.Lock()
+	s.requests = append(s.requests, request)
+	s.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.Execute(request))
+}
+
+// RoundTrip serves a request in process, so the server can be used as the transport of an http.Client
+// with no network access. Requests served this way are not recorded.
+func (s *Server) RoundTrip(r *http.Request) (*http.Response, error) {
+	var request Request
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	err := decoder.Decode(&request)
+	r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("invalid request body: %s", err.Error())
+	}
+	body, err := json.Marshal(s.Execute(request))
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}, nil
+}
+
+// Execute executes a GraphQL request, returning the response body
+func (s *Server) Execute(request Request) interface{} {
+	operation, fields, err := parseDocument(request.Query, request.Variables)
+	if err != nil {
+		return response{Errors: []responseError{{Message: err.Error()}}}
+	}
+
+	resolve := s.store.resolve
+	if operation == "mutation" {
+		resolve = s.mutate
+		s.store.mutex.Lock()
+		defer s.store.mutex.Unlock()
+	} else {
+		s.store.mutex.RLock()
+		defer s.store.mutex.RUnlock()
+	}
+
+	data := map[string]interface{}{}
+	var errs []responseError
+	for _, f := range fields {
+		value, err := resolve(f)
+		if err != nil {
+			errs = append(errs, responseError{Message: err.Error(), Path: []string{f.key()}})
+		}
+		data[f.key()] = value
+	}
+	return response{Data: data, Errors: errs}
+}
+
+// mutate executes a root mutation field
+func (s *Server) mutate(f *field) (interface{}, error) {
+	m, ok := s.Mutations[f.name]
+	if !ok {
+		return nil, fmt.Errorf("Cannot query field %q on type \"Mutation\"", f.name)
+	}
+	input, _ := f.arguments["input"].(map[string]interface{})
+	if input == nil {
+		return nil, fmt.Errorf("Field %q argument \"input\" of type object is required", f.name)
+	}
+	item, err := m(s.store, input)
+	if err != nil {
+		return nil, err
+	}
+	return project(item, f.selection), nil
+}
+
+func isCollection(name string) bool {
+	for _, c := range Collections {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// collectionOf returns the collection of a single item field, e.g. resources for resource
+func collectionOf(name string) (string, bool) {
+	collection := name + "s"
+	return collection, isCollection(collection)
+}
@@ -0,0 +1,230 @@
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// a line of a snapshot file
+type snapshotItem struct {
+	Collection string                 `json:"collection"`
+	Item       map[string]interface{} `json:"item"`
+}
+
+// LoadSnapshot loads a store from a snapshot file, a gzip compressed JSON lines file with an item of a
+// collection on each line
+func LoadSnapshot(path string) (*Store, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %s", path, err.Error())
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %s", path, err.Error())
+	}
+	defer reader.Close()
+
+	store := &Store{collections: map[string][]map[string]interface{}{}}
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+	for line := 1; ; line++ {
+		var item snapshotItem
+		err := decoder.Decode(&item)
+		if err == io.EOF {
+			return store, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse snapshot %s, line %d: %s", path, line, err.Error())
+		}
+		if !isCollection(item.Collection) {
+			return nil, fmt.Errorf("unknown collection %s in snapshot %s, line %d", item.Collection, path, line)
+		}
+		store.collections[item.Collection] = append(store.collections[item.Collection], item.Item)
+	}
+}
+
+// WriteSnapshot writes the items of the store as a snapshot, see LoadSnapshot
+func (s *Store) WriteSnapshot(w io.Writer) error {
+	writer := gzip.NewWriter(w)
+	encoder := json.NewEncoder(writer)
+	for _, collection := range Collections {
+		for _, item := range s.collections[collection] {
+			if err := encoder.Encode(snapshotItem{Collection: collection, Item: item}); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Close()
+}
+
+// Count returns the number of items in a collection
+func (s *Store) Count(collection string) int {
+	return len(s.collections[collection])
+}
+
+// AddResponse adds the items in the response data of a list query to the store. The items are stored in
+// the shape the server serves them from, e.g. a field read with get(path: "x") is stored in the item's
+// data, so the query returns the same items when run against the store. Items with the same turbot.id
+// are merged, so a collection can be built from several queries selecting different fields.
+func (s *Store) AddResponse(query string, variables map[string]interface{}, data map[string]interface{}) error {
+	fields, err := parseQuery(query, variables)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if !isCollection(f.name) {
+			return fmt.Errorf("%s is not a list query field", f.name)
+		}
+		var itemSelection []*field
+		for _, sub := range f.selection {
+			if sub.name == "items" {
+				itemSelection = sub.selection
+			}
+		}
+		items, _ := Lookup(data[f.key()], "items").([]interface{})
+		for _, item := range items {
+			if m, ok := unproject(item, itemSelection).(map[string]interface{}); ok {
+				s.Add(f.name, m)
+			}
+		}
+	}
+	return nil
+}
+
+// Add adds an item to a collection, merging it into an existing item with the same turbot.id
+func (s *Store) Add(collection string, item map[string]interface{}) {
+	if s.collections == nil {
+		s.collections = map[string][]map[string]interface{}{}
+	}
+	if s.index == nil {
+		s.index = map[string]map[string]int{}
+	}
+	index, ok := s.index[collection]
+	if !ok {
+		index = map[string]int{}
+		for i, existing := range s.collections[collection] {
+			if id := Lookup(existing, "turbot.id"); id != nil {
+				index[ScalarString(id)] = i
+			}
+		}
+		s.index[collection] = index
+	}
+
+	id := Lookup(item, "turbot.id")
+	if id == nil {
+		s.collections[collection] = append(s.collections[collection], item)
+		return
+	}
+	if i, ok := index[ScalarString(id)]; ok {
+		s.collections[collection][i] = merge(s.collections[collection][i], item).(map[string]interface{})
+		return
+	}
+	index[ScalarString(id)] = len(s.collections[collection])
+	s.collections[collection] = append(s.collections[collection], item)
+}
+
+// unproject reverses project, returning a value with the fields of the selection set stored under their
+// names rather than their aliases, and the fields read with get(path: "x") stored in data
+func unproject(value interface{}, selection []*field) interface{} {
+	if selection == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = unproject(item, selection)
+		}
+		return list
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for _, f := range selection {
+			fieldValue, ok := v[f.key()]
+			if !ok {
+				continue
+			}
+			switch f.name {
+			case "__typename":
+			case "get":
+				path, _ := f.arguments["path"].(string)
+				if path == "" || fieldValue == nil {
+					continue
+				}
+				result["data"] = merge(result["data"], nest(strings.Split(path, "."), unproject(fieldValue, f.selection)))
+			default:
+				result[f.name] = merge(result[f.name], unproject(fieldValue, f.selection))
+			}
+		}
+		return result
+	}
+	return value
+}
+
+// nest returns the value nested in objects with the given keys, e.g. {"a": {"b": value}} for a.b
+func nest(keys []string, value interface{}) interface{} {
+	for i := len(keys) - 1; i >= 0; i-- {
+		value = map[string]interface{}{keys[i]: value}
+	}
+	return value
+}
+
+// merge two values, recursively merging objects. Other values of b replace those of a, unless b is nil.
+func merge(a, b interface{}) interface{} {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		if b == nil {
+			return a
+		}
+		return b
+	}
+	result := make(map[string]interface{}, len(am)+len(bm))
+	for k, v := range am {
+		result[k] = v
+	}
+	for k, v := range bm {
+		result[k] = merge(result[k], v)
+	}
+	return result
+}
+
+// Items returns the items of a collection
+func (s *Store) Items(collection string) []map[string]interface{} {
+	return s.collections[collection]
+}
+
+// Remove removes the item with the given id from a collection, returning it
+func (s *Store) Remove(collection string, id string) map[string]interface{} {
+	items := s.collections[collection]
+	for i, item := range items {
+		if ScalarString(Lookup(item, "turbot.id")) == id {
+			s.collections[collection] = append(items[:i:i], items[i+1:]...)
+			// the index is rebuilt when next needed
+			delete(s.index, collection)
+			return item
+		}
+	}
+	return nil
+}
+
+// NewID returns an id for an item added to the store, after the ids of its items
+func (s *Store) NewID() string {
+	if s.lastID == 0 {
+		for _, items := range s.collections {
+			for _, item := range items {
+				if id, err := strconv.Atoi(ScalarString(Lookup(item, "turbot.id"))); err == nil && id > s.lastID {
+					s.lastID = id
+				}
+			}
+		}
+	}
+	s.lastID++
+	return strconv.Itoa(s.lastID)
+}
@@ -0,0 +1,202 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilterTerms(t *testing.T) {
+	type test struct {
+		name     string
+		filter   string
+		expected []filterTerm
+	}
+	tests := []test{
+		{
+			"Key and quoted values",
+			"resourceTypeId:'tmod:@turbot/aws-s3#/resource/types/bucket','tmod:@turbot/aws#/resource/types/account' resourceTypeLevel:self",
+			[]filterTerm{
+				{key: "resourceTypeId", values: []string{"tmod:@turbot/aws-s3#/resource/types/bucket", "tmod:@turbot/aws#/resource/types/account"}},
+				{key: "resourceTypeLevel", values: []string{"self"}},
+			},
+		},
+		{
+			"Negation, comparison and escapes",
+			`-is:orphan createTimestamp:>='2023-01-01T00:00:00.000Z' $.Name:'it\'s'`,
+			[]filterTerm{
+				{key: "is", negate: true, values: []string{"orphan"}},
+				{key: "createTimestamp", operator: ">=", values: []string{"2023-01-01T00:00:00.000Z"}},
+				{key: "$.Name", values: []string{"it's"}},
+			},
+		},
+		{
+			"Search words",
+			"bucket  'my bucket'",
+			[]filterTerm{
+				{values: []string{"bucket"}},
+				{values: []string{"my bucket"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		log.Println(test.name)
+		terms, err := parseFilterTerms(test.filter)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, terms)
+	}
+}
+
+func testStore(t *testing.T) *Store {
+	var resources []map[string]interface{}
+	err := json.Unmarshal([]byte(`[
+		{"data": {"Name": "a"}, "turbot": {"id": "1", "path": "3.1", "akas": ["arn:a"], "resourceTypeId": "10", "createTimestamp": "2023-01-01T00:00:00.000Z"}, "type": {"uri": "tmod:@turbot/aws-s3#/resource/types/bucket"}},
+		{"data": {"Name": "b"}, "turbot": {"id": "2", "path": "3.2", "akas": ["arn:b"], "resourceTypeId": "10", "createTimestamp": "2023-02-01T00:00:00.000Z"}, "type": {"uri": "tmod:@turbot/aws-s3#/resource/types/bucket"}},
+		{"data": {"Name": "c"}, "turbot": {"id": "3", "path": "3", "akas": ["arn:c"], "resourceTypeId": "20", "createTimestamp": "2023-03-01T00:00:00.000Z"}, "type": {"uri": "tmod:@turbot/aws#/resource/types/account"}}
+	]`), &resources)
+	assert.NoError(t, err)
+	store, err := NewStore(map[string][]map[string]interface{}{"resources": resources})
+	assert.NoError(t, err)
+	return store
+}
+
+// execute a request and return its response as generic JSON
+func execute(t *testing.T, server *Server, query string, variables map[string]interface{}) map[string]interface{} {
+	data, err := json.Marshal(server.Execute(Request{Query: query, Variables: variables}))
+	assert.NoError(t, err)
+	var result map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &result))
+	return result
+}
+
+func TestExecuteList(t *testing.T) {
+	server := NewServer(testStore(t))
+	query := `
+query resourceList($filter: [String!], $next_token: String) {
+	resources(filter: $filter, paging: $next_token) {
+		items {
+			name: get(path: "Name")
+			turbot { id }
+		}
+		paging { next }
+	}
+}`
+	type test struct {
+		name     string
+		filter   []interface{}
+		expected []string
+	}
+	tests := []test{
+		{"No filter", nil, []string{"1", "2", "3"}},
+		{"Type uri", []interface{}{"resourceTypeId:'tmod:@turbot/aws-s3#/resource/types/bucket' resourceTypeLevel:self"}, []string{"1", "2"}},
+		{"Type id and negated id", []interface{}{"resourceTypeId:10", "-id:1"}, []string{"2"}},
+		{"Aka", []interface{}{"resourceId:'arn:c' level:self"}, []string{"3"}},
+		{"Descendants", []interface{}{"resourceId:'arn:c' level:descendant"}, []string{"1", "2"}},
+		{"Self and descendants", []interface{}{"resourceId:3 level:self,descendant"}, []string{"1", "2", "3"}},
+		{"Root descendants", []interface{}{"resourceId:'tmod:@turbot/turbot#/' level:self,descendant"}, []string{"1", "2"}},
+		{"Data", []interface{}{"$.Name:b,c"}, []string{"2", "3"}},
+		{"Timestamp", []interface{}{"createTimestamp:>='2023-02-01T00:00:00.000Z'"}, []string{"2", "3"}},
+		{"Search", []interface{}{"account"}, []string{"3"}},
+	}
+	for _, test := range tests {
+		log.Println(test.name)
+		result := execute(t, server, query, map[string]interface{}{"filter": test.filter})
+		assert.Nil(t, result["errors"])
+		var ids []string
+		for _, item := range Lookup(result, "data.resources.items").([]interface{}) {
+			ids = append(ids, Lookup(item, "turbot.id").(string))
+			assert.NotNil(t, Lookup(item, "name"))
+		}
+		assert.Equal(t, test.expected, ids)
+	}
+}
+
+func TestExecutePaging(t *testing.T) {
+	server := NewServer(testStore(t))
+	query := `query($filter: [String!], $next_token: String) { resources(filter: $filter, paging: $next_token) { items { turbot { id } } paging { next } } }`
+	var ids []interface{}
+	next := ""
+	for pages := 0; pages < 5; pages++ {
+		result := execute(t, server, query, map[string]interface{}{"filter": []interface{}{"limit:2", "limit:5000"}, "next_token": next})
+		for _, item := range Lookup(result, "data.resources.items").([]interface{}) {
+			ids = append(ids, Lookup(item, "turbot.id"))
+		}
+		n, ok := Lookup(result, "data.resources.paging.next").(string)
+		if !ok {
+			break
+		}
+		next = n
+	}
+	assert.Equal(t, []interface{}{"1", "2", "3"}, ids)
+}
+
+func TestExecuteGet(t *testing.T) {
+	server := NewServer(testStore(t))
+	result := execute(t, server, `query resourceBatch($id0: ID!, $id1: ID!) {
+	item0: resource(id: $id0) { turbot { id } }
+	item1: resource(id: $id1) { turbot { id } }
+}`, map[string]interface{}{"id0": "arn:b", "id1": "99"})
+	assert.Equal(t, "2", Lookup(result, "data.item0.turbot.id"))
+	assert.Nil(t, Lookup(result, "data.item1"))
+	assert.Contains(t, result["errors"].([]interface{})[0].(map[string]interface{})["message"], "Not Found")
+}
+
+func TestExecuteErrors(t *testing.T) {
+	server := NewServer(testStore(t))
+	tests := map[string]string{
+		`{ resources(filter: "owner:me") { items { data } } }`:              "unsupported filter key owner",
+		`{ resources(filter: "resourceTypeLevel:sub") { items { data } } }`: "only the self level",
+		`{ resources(paging: "???") { items { data } } }`:                   "invalid paging cursor",
+		`{ widgets { items { data } } }`:                                    `Cannot query field "widgets"`,
+		`mutation { createFolder { turbot { id } } }`:                       `Cannot query field "createFolder" on type "Mutation"`,
+		`subscription { resources { items { data } } }`:                     "subscription operations are not supported",
+		`{ resources { items { ...fields } } }`:                             "fragments are not supported",
+	}
+	for query, expected := range tests {
+		result := execute(t, server, query, nil)
+		errs, _ := result["errors"].([]interface{})
+		if assert.Len(t, errs, 1, query) {
+			assert.Contains(t, errs[0].(map[string]interface{})["message"], expected)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	store, err := NewStore(map[string][]map[string]interface{}{})
+	assert.NoError(t, err)
+	grantsQuery := `query($filter: [String!]) { grants(filter: $filter) { items { identity { email: get(path: "email") } turbot { id } } paging { next } } }`
+	levelsQuery := `{ grants { items { level { uri } turbot { id } } } }`
+	assert.NoError(t, store.AddResponse(grantsQuery, nil, map[string]interface{}{
+		"grants": map[string]interface{}{"items": []interface{}{
+			map[string]interface{}{"identity": map[string]interface{}{"email": "jane@example.com"}, "turbot": map[string]interface{}{"id": "1"}},
+		}},
+	}))
+	assert.NoError(t, store.AddResponse(levelsQuery, nil, map[string]interface{}{
+		"grants": map[string]interface{}{"items": []interface{}{
+			map[string]interface{}{"level": map[string]interface{}{"uri": "tmod:@turbot/turbot-iam#/permission/levels/owner"}, "turbot": map[string]interface{}{"id": "1"}},
+			map[string]interface{}{"level": map[string]interface{}{"uri": "tmod:@turbot/turbot-iam#/permission/levels/user"}, "turbot": map[string]interface{}{"id": "2"}},
+		}},
+	}))
+	assert.Equal(t, 2, store.Count("grants"))
+
+	path := filepath.Join(t.TempDir(), "snapshot.jsonl.gz")
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	assert.NoError(t, store.WriteSnapshot(file))
+	assert.NoError(t, file.Close())
+	loaded, err := LoadSnapshot(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the merged item is served with the fields of both queries
+	result := execute(t, NewServer(loaded), `{ grants(filter: "id:1") { items { identity { email: get(path: "email") } level { uri } } } }`, nil)
+	assert.Nil(t, result["errors"])
+	item := Lookup(result, "data.grants.items").([]interface{})[0]
+	assert.Equal(t, "jane@example.com", Lookup(item, "identity.email"))
+	assert.Equal(t, "tmod:@turbot/turbot-iam#/permission/levels/owner", Lookup(item, "level.uri"))
+}
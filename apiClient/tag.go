@@ -0,0 +1,54 @@
+package apiClient
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ListTags returns the tags matching the filter, with the resources they are on, reading every page
+func (client *Client) ListTags(filter string) ([]Tag, error) {
+	query := listTagsQuery()
+	filter = withDefaultLimit(filter)
+	var tags []Tag
+	next := ""
+	for {
+		responseData := &ListTagsResponse{}
+		variables := map[string]interface{}{
+			"filter":     filter,
+			"next_token": next,
+		}
+
+		// execute api call
+		if err := client.doRequest(query, variables, responseData); err != nil {
+			return nil, fmt.Errorf("error listing tags: %s", err.Error())
+		}
+		for _, tag := range responseData.Tags.Items {
+			// the resources of a tag are paged too, so read the pages after the first
+			items, err := client.readConnectionPages("tag", tag.Turbot.Id, "resources", "turbot { id }", tag.Resources.Paging.Next)
+			if err != nil {
+				return nil, fmt.Errorf("error listing the resources of tag %s: %s", tag.Turbot.Id, err.Error())
+			}
+			for _, data := range items {
+				var item struct {
+					Turbot TurbotResourceMetadata
+				}
+				if err := json.Unmarshal(data, &item); err != nil {
+					return nil, fmt.Errorf("error listing the resources of tag %s: %s", tag.Turbot.Id, err.Error())
+				}
+				tag.Resources.Items = append(tag.Resources.Items, item)
+			}
+			tag.Resources.Paging.Next = ""
+			tags = append(tags, tag)
+		}
+		next = responseData.Tags.Paging.Next
+		if next == "" {
+			return tags, nil
+		}
+	}
+}
+
+// UpdateResourceTags sets the tags of a resource. Tags which are not given are kept, and tags set to nil are
+// removed.
+func (client *Client) UpdateResourceTags(id string, tags map[string]interface{}) (*TurbotResourceMetadata, error) {
+	return client.UpdateResource(map[string]interface{}{"id": id, "tags": tags})
+}
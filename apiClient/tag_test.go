@@ -0,0 +1,47 @@
+package apiClient
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/machinebox/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/mockapi"
+)
+
+func TestListTags(t *testing.T) {
+	// a tag on more resources than fit in a page
+	var resources []interface{}
+	for i := 0; i < 150; i++ {
+		resources = append(resources, map[string]interface{}{"turbot": map[string]interface{}{"id": strconv.Itoa(5000 + i)}})
+	}
+	store, err := mockapi.NewStore(map[string][]map[string]interface{}{"tags": {
+		{"key": "env", "value": "prod", "turbot": map[string]interface{}{"id": "16001"}, "resources": map[string]interface{}{"items": resources}},
+		{"key": "env", "value": "dev", "turbot": map[string]interface{}{"id": "16002"}, "resources": map[string]interface{}{"items": []interface{}{}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mockapi.NewServer(store))
+	defer server.Close()
+	client := &Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(server.URL)}
+
+	// the limit of the filter is the page size, rather than the default limit
+	tags, err := client.ListTags("limit:1")
+	if !assert.NoError(t, err) || !assert.Len(t, tags, 2) {
+		return
+	}
+	if assert.Len(t, tags[0].Resources.Items, 150) {
+		assert.Equal(t, "5149", tags[0].Resources.Items[149].Turbot.Id)
+	}
+	assert.Empty(t, tags[1].Resources.Items)
+}
+
+func TestWithDefaultLimit(t *testing.T) {
+	assert.Equal(t, "limit:5000", withDefaultLimit(""))
+	assert.Equal(t, "key:env limit:5000", withDefaultLimit("key:env"))
+	assert.Equal(t, "key:env limit:10", withDefaultLimit("key:env limit:10"))
+	assert.Equal(t, "limit:10 key:env", withDefaultLimit("limit:10 key:env"))
+	assert.Equal(t, "title:nolimit:x limit:5000", withDefaultLimit("title:nolimit:x"))
+}
@@ -119,13 +119,27 @@ type FindPolicySettingResponse struct {
 	}
 }
 
+type ListPolicySettingsResponse struct {
+	PolicySettings struct {
+		Items  []PolicySetting
+		Paging struct {
+			Next string
+		}
+	}
+}
+
 type PolicySetting struct {
 	Type struct {
-		Uri string
+		Uri    string
+		Secret bool
+	}
+	Resource struct {
+		Akas []string
 	}
 	Value              interface{}
 	ValueSource        string
 	Default            bool
+	IsCalculated       bool
 	Precedence         string
 	Template           string
 	TemplateInput      interface{}
@@ -176,6 +190,39 @@ type ModVersionResponse struct {
 	}
 }
 
+type ListModsResponse struct {
+	Mods struct {
+		Items []struct {
+			Version string
+			Turbot  TurbotResourceMetadata
+		}
+		Paging struct {
+			Next string
+		}
+	}
+}
+
+type ModVersionSearchResponse struct {
+	ModVersionSearches struct {
+		Items []struct {
+			IdentityName string
+			Name         string
+			Versions     []struct {
+				Version string
+				Head    struct {
+					PeerDependencies []ModPeerDependency
+				}
+			}
+		}
+	}
+}
+
+// ModPeerDependency is a mod which must be installed alongside a mod version, in a range of versions
+type ModPeerDependency struct {
+	FullName     string
+	VersionRange string
+}
+
 type UninstallModResponse struct {
 	UninstallMod struct {
 		Success bool
@@ -183,6 +230,7 @@ type UninstallModResponse struct {
 }
 
 type Mod struct {
+	Id      string
 	Org     string
 	Mod     string
 	Version string
@@ -201,10 +249,42 @@ type ReadGrantResponse struct {
 	Grant Grant
 }
 
+type ListGrantsResponse struct {
+	Grants struct {
+		Items  []Grant
+		Paging struct {
+			Next string
+		}
+	}
+}
+
 type Grant struct {
 	Turbot            TurbotGrantMetadata
 	PermissionTypeId  string
 	PermissionLevelId string
+	Identity          GrantIdentity
+	Level             struct {
+		Uri string
+	}
+	Resource struct {
+		Akas   []string
+		Turbot struct {
+			Id string
+		}
+	}
+}
+
+type GrantIdentity struct {
+	Akas               []string
+	Email              string
+	ProfileId          string
+	LastLoginTimestamp string
+	Trunk              struct {
+		Title string
+	}
+	Type struct {
+		Uri string
+	}
 }
 
 // Active Grant
@@ -218,8 +298,27 @@ type ReadActiveGrantResponse struct {
 	ActiveGrant ActiveGrant
 }
 
+type ListActiveGrantsResponse struct {
+	ActiveGrants struct {
+		Items  []ActiveGrant
+		Paging struct {
+			Next string
+		}
+	}
+}
+
 type ActiveGrant struct {
 	Turbot TurbotActiveGrantMetadata
+	Grant  struct {
+		Turbot struct {
+			Id string
+		}
+	}
+	Resource struct {
+		Turbot struct {
+			Id string
+		}
+	}
 }
 
 // Folder
@@ -239,6 +338,15 @@ type ProfileResponse struct {
 	Resource Profile
 }
 
+type ListProfilesResponse struct {
+	Resources struct {
+		Items  []Profile
+		Paging struct {
+			Next string
+		}
+	}
+}
+
 type Profile struct {
 	Turbot             TurbotResourceMetadata
 	Title              string
@@ -272,6 +380,18 @@ type SmartFolder struct {
 		Items []struct {
 			Turbot TurbotResourceMetadata
 		}
+		Paging struct {
+			Next string
+		}
+	}
+}
+
+type ListSmartFoldersResponse struct {
+	SmartFolders struct {
+		Items  []SmartFolder
+		Paging struct {
+			Next string
+		}
 	}
 }
 
@@ -290,6 +410,30 @@ type CreateSmartFolderAttachResponse struct {
 	}
 }
 
+// Tag
+type Tag struct {
+	Key       string
+	Value     string
+	Turbot    TurbotResourceMetadata
+	Resources struct {
+		Items []struct {
+			Turbot TurbotResourceMetadata
+		}
+		Paging struct {
+			Next string
+		}
+	}
+}
+
+type ListTagsResponse struct {
+	Tags struct {
+		Items  []Tag
+		Paging struct {
+			Next string
+		}
+	}
+}
+
 // Local directory
 type LocalDirectoryResponse struct {
 	Resource LocalDirectory
@@ -310,6 +454,15 @@ type LocalDirectoryUserResponse struct {
 	Resource LocalDirectoryUser
 }
 
+type ListLocalDirectoryUsersResponse struct {
+	Resources struct {
+		Items  []LocalDirectoryUser
+		Paging struct {
+			Next string
+		}
+	}
+}
+
 type LocalDirectoryUser struct {
 	Turbot      TurbotResourceMetadata
 	Parent      string
@@ -470,9 +623,11 @@ type TurbotPolicyMetadata struct {
 }
 
 type TurbotGrantMetadata struct {
-	Id         string
-	ProfileId  string
-	ResourceId string
+	Id               string
+	ProfileId        string
+	ResourceId       string
+	CreateTimestamp  string
+	ValidToTimestamp string
 }
 
 type TurbotActiveGrantMetadata struct {
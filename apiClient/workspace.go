@@ -0,0 +1,108 @@
+package apiClient
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+// Features of the Turbot API which older workspaces do not support, so queries using them have a fallback for
+// those workspaces
+const (
+	FeatureActiveGrants             = "active_grants"
+	FeatureNotificationActiveGrants = "notification_active_grants"
+	FeatureModVersionSearch         = "mod_version_search"
+)
+
+// Features are the API features whose support is detected, see SupportsFeature
+var Features = []string{FeatureActiveGrants, FeatureNotificationActiveGrants, FeatureModVersionSearch}
+
+// featureProbe is a query using the field of a feature, which workspaces without the feature reject
+type featureProbe struct {
+	field string
+	query string
+}
+
+// featureProbes detect the support of each feature by its field, rather than by the workspace version the field
+// was released in
+var featureProbes = map[string]featureProbe{
+	FeatureActiveGrants: {
+		field: "activeGrants",
+		query: `query featureProbe { activeGrants(filter: "limit:1") { paging { next } } }`,
+	},
+	FeatureNotificationActiveGrants: {
+		field: "activeGrantsId",
+		query: `query featureProbe { notifications(filter: "limit:1") { items { turbot { activeGrantsId } } } }`,
+	},
+	FeatureModVersionSearch: {
+		field: "modVersionSearches",
+		query: `query featureProbe { modVersionSearches(search: "") { paging { next } } }`,
+	},
+}
+
+// Identity is the identity a client is authenticated as, e.g. the profile of its access key
+type Identity struct {
+	Trunk struct {
+		Title string
+	}
+	Turbot TurbotResourceMetadata
+	Type   struct {
+		Uri string
+	}
+}
+
+type ActorResponse struct {
+	Actor struct {
+		Identity *Identity
+	}
+}
+
+// GetIdentity returns the identity the client is authenticated as, nil if the workspace has none, e.g. for
+// the credentials of a Turbot administrator
+func (client *Client) GetIdentity() (*Identity, error) {
+	query := actorQuery()
+	responseData := &ActorResponse{}
+
+	// execute api call
+	if err := client.doRequest(query, nil, responseData); err != nil {
+		return nil, fmt.Errorf("error reading the authenticated identity: %s", err.Error())
+	}
+	return responseData.Actor.Identity, nil
+}
+
+// GetTurbotModVersion returns the version of the @turbot/turbot mod installed in the workspace
+func (client *Client) GetTurbotModVersion() (*semver.Version, error) {
+	mod, err := client.ReadMod("tmod:@turbot/turbot")
+	if err != nil {
+		return nil, err
+	}
+	version, err := semver.New(mod.Version)
+	if err != nil {
+		return nil, fmt.Errorf("error reading turbot mod version value: %s", err.Error())
+	}
+	return version, nil
+}
+
+// SupportsFeature returns whether the workspace supports a feature, by querying its field. The workspace rejects a
+// field it does not have, e.g. Cannot query field "activeGrants" on type "Query".
+func (client *Client) SupportsFeature(feature string) (bool, error) {
+	probe, ok := featureProbes[feature]
+	if !ok {
+		return false, fmt.Errorf("unknown feature %s", feature)
+	}
+	var responseData interface{}
+	err := client.doRequest(probe.query, nil, &responseData)
+	if err == nil {
+		return true, nil
+	}
+	if strings.Contains(err.Error(), "Cannot query field") {
+		return false, nil
+	}
+	return false, fmt.Errorf("error detecting support for %s: %s", probe.field, err.Error())
+}
+
+// FeatureField returns the API field of a feature, e.g. activeGrants
+func FeatureField(feature string) string {
+	return featureProbes[feature].field
+}
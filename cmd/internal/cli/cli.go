@@ -0,0 +1,55 @@
+// Package cli holds the helpers shared by the command line tools built on apiClient.
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+)
+
+// Connect creates and validates a client. Credentials are read the same way as for a connection with no
+// credentials set: from the profile if given, otherwise the TURBOT_ACCESS_KEY, TURBOT_SECRET_KEY and
+// TURBOT_WORKSPACE environment variables, or the TURBOT_PROFILE profile in the Turbot CLI credentials file.
+func Connect(profile string) (*apiClient.Client, error) {
+	client, err := apiClient.CreateClient(apiClient.ClientConfig{Profile: profile})
+	if err != nil {
+		return nil, err
+	}
+	if err = client.Validate(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Turbot: %s", err.Error())
+	}
+	return client, nil
+}
+
+// Exit prints the error, prefixed with the command name, and exits with status 1
+func Exit(command string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", command, err.Error())
+	os.Exit(1)
+}
+
+// WriteJSON writes a value to stdout as indented JSON
+func WriteJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// PlanAndApply writes a plan to stdout, as JSON if jsonOutput is set or otherwise with write, then applies it
+// with applyPlan if apply is set. The plan is on stdout, so JSON output stays parseable, and applyPlan writes
+// its progress to stderr.
+func PlanAndApply(plan interface{}, jsonOutput, apply bool, write func(out io.Writer), applyPlan func(progress io.Writer) error) error {
+	if jsonOutput {
+		if err := WriteJSON(plan); err != nil {
+			return err
+		}
+	} else {
+		write(os.Stdout)
+	}
+	if !apply {
+		return nil
+	}
+	return applyPlan(os.Stderr)
+}
@@ -0,0 +1,53 @@
+// Package clitest holds the test helpers shared by the command line tools, which are tested against the mock API.
+package clitest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/machinebox/graphql"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/mockapi"
+)
+
+// NewStore returns a store of the items of a JSON object of collections, e.g. {"resources": [...]}
+func NewStore(t *testing.T, collections string) *mockapi.Store {
+	var items map[string][]map[string]interface{}
+	if err := json.Unmarshal([]byte(collections), &items); err != nil {
+		t.Fatal(err)
+	}
+	store, err := mockapi.NewStore(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// LoadStore returns a store of the fixtures in a directory, see mockapi.LoadStore
+func LoadStore(t *testing.T, dir string) *mockapi.Store {
+	store, err := mockapi.LoadStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// NewClient starts the mock API serving the store, and returns a client of it. The server is closed when the
+// test ends.
+func NewClient(t *testing.T, store *mockapi.Store) *apiClient.Client {
+	server := httptest.NewServer(mockapi.NewServer(store))
+	t.Cleanup(server.Close)
+	return &apiClient.Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(server.URL)}
+}
+
+// WriteFile writes the content to a file with the name in a temporary directory of the test, returning its path
+func WriteFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
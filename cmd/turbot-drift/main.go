@@ -0,0 +1,55 @@
+// Command turbot-drift reports the differences between the policy settings of two workspaces, e.g. a staging
+// workspace whose policy changes are promoted to production. Settings only in the to workspace are reported as
+// added, and those only in the from workspace as removed.
+//
+// Usage:
+//
+//	turbot-drift [-json] [-filter filter] from-profile to-profile
+//
+// The workspaces are given by profiles of the Turbot CLI credentials file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/cli"
+	"github.com/turbot/steampipe-plugin-turbot/drift"
+)
+
+const usage = "usage: turbot-drift [-json] [-filter filter] from-profile to-profile"
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "write the report as JSON")
+	filter := flag.String("filter", "", "Turbot filter of the policy settings to compare, e.g. resourceId:'arn:aws:::123456789012' level:self,descendant")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), flag.Arg(1), *filter, *jsonOutput); err != nil {
+		cli.Exit("turbot-drift", err)
+	}
+}
+
+func run(fromProfile, toProfile, filter string, jsonOutput bool) error {
+	from, err := cli.Connect(fromProfile)
+	if err != nil {
+		return err
+	}
+	to, err := cli.Connect(toProfile)
+	if err != nil {
+		return err
+	}
+	report, err := drift.Compare(from, to, filter)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return report.WriteJSON(os.Stdout)
+	}
+	report.WriteText(os.Stdout)
+	return nil
+}
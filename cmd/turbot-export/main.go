@@ -0,0 +1,66 @@
+// Command turbot-export exports a snapshot of a Turbot workspace, which a connection with the
+// snapshot_path option serves tables from with no access to the workspace.
+//
+// Usage:
+//
+//	turbot-export [-profile name] [-output path]
+//
+// Credentials are read from the profile if given, otherwise the same way as for a connection with no
+// credentials set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/cli"
+	"github.com/turbot/steampipe-plugin-turbot/turbot"
+)
+
+func main() {
+	profile := flag.String("profile", "", "Turbot CLI profile to read credentials from")
+	output := flag.String("output", "turbot-snapshot.jsonl.gz", "path of the snapshot file to write")
+	flag.Parse()
+
+	if err := export(*profile, *output); err != nil {
+		cli.Exit("turbot-export", err)
+	}
+}
+
+func export(profile, output string) error {
+	client, err := cli.Connect(profile)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file, so a failed export does not leave a partial snapshot
+	tmp := output + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	last := ""
+	err = turbot.ExportSnapshot(client, file, func(collection string, count int) {
+		if last != "" && collection != last {
+			fmt.Fprintln(os.Stderr)
+		}
+		last = collection
+		fmt.Fprintf(os.Stderr, "\r%s: %d", collection, count)
+	})
+	if last != "" {
+		fmt.Fprintln(os.Stderr)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, output); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported snapshot to %s\n", output)
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Grant is the desired state of the grant of a permission level to an identity on a resource. Grants files are
+// CSV, with a header row naming the columns, e.g.
+//
+//	identity,level,resource,valid_to,activate
+//	jane@example.com,tmod:@turbot/turbot-iam#/permission/levels/admin,arn:aws:::123456789012,2024-06-30,true
+//	tmod:@turbot/turbot#/identities/john,tmod:@turbot/turbot-iam#/permission/levels/readOnly,arn:aws:::123456789012,,
+//
+// or a JSON list of objects with the same keys. The identity is given by its aka, profile id or email, and the
+// resource by its aka. A grant whose valid_to has passed, or with revoke set, is revoked if it exists.
+type Grant struct {
+	Identity string `json:"identity"`
+	Level    string `json:"level"`
+	Resource string `json:"resource"`
+	// the permission type of the level, by default the Turbot permission type
+	Type string `json:"type"`
+	// when the grant expires, as a timestamp or a date, which is the end of that day in UTC
+	ValidTo  string `json:"valid_to"`
+	Activate bool   `json:"activate"`
+	Revoke   bool   `json:"revoke"`
+
+	validTo time.Time
+}
+
+const (
+	// the permission type of a grant which does not set one
+	defaultPermissionType = "tmod:@turbot/turbot-iam#/permission/types/turbot"
+	// the format of the timestamps of the Turbot API
+	timestampFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// loadGrantFile reads and validates a CSV or JSON grants file, as given by its extension
+func loadGrantFile(path string) ([]Grant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var grants []Grant
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		grants, err = parseGrantCSV(data)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&grants)
+	default:
+		return nil, fmt.Errorf("%s: grants files must be .csv or .json", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err.Error())
+	}
+
+	seen := map[string]int{}
+	for i := range grants {
+		grant := &grants[i]
+		if err := grant.validate(); err != nil {
+			return nil, fmt.Errorf("%s: grant %d: %s", path, i+1, err.Error())
+		}
+		key := grant.Identity + " " + grant.Level + " " + grant.Resource
+		if j, ok := seen[key]; ok {
+			return nil, fmt.Errorf("%s: grant %d: duplicates grant %d", path, i+1, j+1)
+		}
+		seen[key] = i
+	}
+	return grants, nil
+}
+
+// parseGrantCSV parses the rows of a CSV grants file, whose first row names the columns
+func parseGrantCSV(data []byte) ([]Grant, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		switch header[i] {
+		case "identity", "level", "resource", "type", "valid_to", "activate", "revoke":
+		default:
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+	}
+
+	var grants []Grant
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return grants, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var grant Grant
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch header[i] {
+			case "identity":
+				grant.Identity = value
+			case "level":
+				grant.Level = value
+			case "resource":
+				grant.Resource = value
+			case "type":
+				grant.Type = value
+			case "valid_to":
+				grant.ValidTo = value
+			case "activate", "revoke":
+				set := false
+				if value != "" {
+					if set, err = strconv.ParseBool(value); err != nil {
+						return nil, fmt.Errorf("line %d: %s must be true or false", len(grants)+2, header[i])
+					}
+				}
+				if header[i] == "activate" {
+					grant.Activate = set
+				} else {
+					grant.Revoke = set
+				}
+			}
+		}
+		grants = append(grants, grant)
+	}
+}
+
+// validate the grant, normalizing its type and expiry
+func (g *Grant) validate() error {
+	switch {
+	case g.Identity == "":
+		return fmt.Errorf("identity is required")
+	case g.Level == "":
+		return fmt.Errorf("level is required")
+	case g.Resource == "":
+		return fmt.Errorf("resource is required")
+	case g.Revoke && g.Activate:
+		return fmt.Errorf("a revoked grant cannot be activated")
+	}
+	if g.Type == "" {
+		g.Type = defaultPermissionType
+	}
+	if g.ValidTo != "" {
+		validTo, err := time.Parse(time.RFC3339, g.ValidTo)
+		if err != nil {
+			date, dateErr := time.Parse("2006-01-02", g.ValidTo)
+			if dateErr != nil {
+				return fmt.Errorf("valid_to must be a timestamp or a date, e.g. 2024-06-30")
+			}
+			validTo = date.AddDate(0, 0, 1).Add(-time.Millisecond)
+		}
+		g.validTo = validTo.UTC()
+		g.ValidTo = g.validTo.Format(timestampFormat)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Command turbot-grant manages Turbot grants from a list, e.g. for an access review. It compares a CSV or JSON
+// file of grants with the workspace, and creates, activates and revokes grants to match it. The revoke-expired
+// command revokes the grants of profiles which have not logged in for a number of days. A revoke-expired plan
+// revoking more than a fifth of the grants of profiles is not applied unless -allow-mass-revoke is given.
+//
+// Usage:
+//
+//	turbot-grant plan [-profile name] [-json] grants.csv
+//	turbot-grant apply [-profile name] [-json] [-dry-run] grants.csv
+//	turbot-grant revoke-expired [-profile name] [-json] [-dry-run] [-allow-mass-revoke] -days n
+//
+// See Grant for the format of the file. Credentials are read from the profile if given, otherwise the same way
+// as for a connection with no credentials set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/cli"
+)
+
+const usage = `usage:
+  turbot-grant plan [-profile name] [-json] grants.csv
+  turbot-grant apply [-profile name] [-json] [-dry-run] grants.csv
+  turbot-grant revoke-expired [-profile name] [-json] [-dry-run] [-allow-mass-revoke] -days n`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	if command != "plan" && command != "apply" && command != "revoke-expired" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	profile := flags.String("profile", "", "Turbot CLI profile to read credentials from")
+	jsonOutput := flags.Bool("json", false, "write the plan as JSON")
+	dryRun := false
+	if command != "plan" {
+		flags.BoolVar(&dryRun, "dry-run", false, "show the plan without applying it")
+	}
+	days := 0
+	allowMassRevoke := false
+	if command == "revoke-expired" {
+		flags.IntVar(&days, "days", 0, "revoke the grants of profiles which have not logged in for this many days")
+		flags.BoolVar(&allowMassRevoke, "allow-mass-revoke", false, "apply the plan even if it revokes more than a fifth of the grants of profiles")
+	}
+	_ = flags.Parse(os.Args[2:])
+	if command == "revoke-expired" && (flags.NArg() != 0 || days <= 0) || command != "revoke-expired" && flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(command, flags.Arg(0), days, *profile, *jsonOutput, command != "plan" && !dryRun, allowMassRevoke); err != nil {
+		cli.Exit("turbot-grant", err)
+	}
+}
+
+func run(command, path string, days int, profile string, jsonOutput, apply, allowMassRevoke bool) error {
+	var grants []Grant
+	if command != "revoke-expired" {
+		var err error
+		if grants, err = loadGrantFile(path); err != nil {
+			return err
+		}
+	}
+	client, err := cli.Connect(profile)
+	if err != nil {
+		return err
+	}
+
+	var plan *Plan
+	now := time.Now().UTC()
+	if command == "revoke-expired" {
+		plan, err = stalePlan(client, now.AddDate(0, 0, -days))
+	} else {
+		plan, err = computePlan(client, grants, now)
+	}
+	if err != nil {
+		return err
+	}
+
+	return cli.PlanAndApply(plan, jsonOutput, apply, func(out io.Writer) { writePlan(plan, out) }, func(progress io.Writer) error {
+		if command == "revoke-expired" {
+			if err := checkRevocations(plan, allowMassRevoke); err != nil {
+				return err
+			}
+		}
+		return applyPlan(client, plan, progress)
+	})
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+)
+
+// Action is the change planned for a grant
+type Action string
+
+const (
+	ActionCreate   Action = "create"
+	ActionActivate Action = "activate"
+	ActionRevoke   Action = "revoke"
+	ActionExpire   Action = "expire"
+	ActionNone     Action = "no-op"
+)
+
+const (
+	// the page size of the grants listed from the workspace
+	pageLimit = "limit:5000"
+
+	// the resource type of the identities revoke-expired revokes the grants of. Other identities, e.g. groups, never
+	// log in.
+	profileTypeUri = "tmod:@turbot/turbot-iam#/resource/types/profile"
+
+	// a revoke-expired plan revoking more than this fraction of the grants of profiles is refused unless mass
+	// revocation is allowed, in case the last login timestamps are wrong. Revoking a single grant is always allowed.
+	massRevokeFraction = 0.2
+)
+
+// Plan is the changes needed to bring the grants of the workspace to the desired state
+type Plan struct {
+	Changes []Change    `json:"changes"`
+	Summary PlanSummary `json:"summary"`
+
+	// the number of grants of profiles in the workspace, for revoke-expired plans
+	profileGrants int
+}
+
+type PlanSummary struct {
+	Create   int `json:"create"`
+	Activate int `json:"activate"`
+	Revoke   int `json:"revoke"`
+	Expire   int `json:"expire"`
+	NoOp     int `json:"no_op"`
+}
+
+// Change is the change planned for the grant of a level to an identity on a resource
+type Change struct {
+	Action   Action `json:"action"`
+	Identity string `json:"identity"`
+	Level    string `json:"level"`
+	Resource string `json:"resource"`
+	Type     string `json:"type,omitempty"`
+	ValidTo  string `json:"valid_to,omitempty"`
+	Activate bool   `json:"activate,omitempty"`
+	GrantID  string `json:"grant_id,omitempty"`
+	// the activations of the grant, which are deactivated before it is revoked
+	ActivationIDs []string `json:"activation_ids,omitempty"`
+	// why a grant is revoked by revoke-expired
+	Reason string `json:"reason,omitempty"`
+}
+
+// workspaceGrants is the grants of the workspace, with the ids of their activations
+type workspaceGrants struct {
+	grants      []apiClient.Grant
+	activations map[string][]apiClient.ActiveGrant
+}
+
+func readWorkspaceGrants(client *apiClient.Client) (*workspaceGrants, error) {
+	grants, err := client.ListGrants(pageLimit)
+	if err != nil {
+		return nil, err
+	}
+	activeGrants, err := client.ListActiveGrants(pageLimit)
+	if err != nil {
+		return nil, err
+	}
+	w := &workspaceGrants{grants: grants, activations: map[string][]apiClient.ActiveGrant{}}
+	for _, activeGrant := range activeGrants {
+		grantID := activeGrant.Grant.Turbot.Id
+		w.activations[grantID] = append(w.activations[grantID], activeGrant)
+	}
+	return w, nil
+}
+
+// find returns the grant of the level to the identity on the resource, or nil
+func (w *workspaceGrants) find(desired Grant) *apiClient.Grant {
+	for i, grant := range w.grants {
+		if grant.Level.Uri == desired.Level && matchesIdentity(grant.Identity, desired.Identity) && matchesResource(grant, desired.Resource) {
+			return &w.grants[i]
+		}
+	}
+	return nil
+}
+
+// activationIDs returns the ids of the activations of a grant, and whether it is active on its own resource
+func (w *workspaceGrants) activationIDs(grant *apiClient.Grant) ([]string, bool) {
+	var ids []string
+	active := false
+	for _, activeGrant := range w.activations[grant.Turbot.Id] {
+		ids = append(ids, activeGrant.Turbot.Id)
+		if activeGrant.Resource.Turbot.Id == grant.Resource.Turbot.Id {
+			active = true
+		}
+	}
+	return ids, active
+}
+
+func matchesIdentity(identity apiClient.GrantIdentity, id string) bool {
+	if strings.EqualFold(identity.Email, id) || identity.ProfileId == id {
+		return true
+	}
+	for _, aka := range identity.Akas {
+		if aka == id {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesResource(grant apiClient.Grant, resource string) bool {
+	if grant.Resource.Turbot.Id == resource {
+		return true
+	}
+	for _, aka := range grant.Resource.Akas {
+		if aka == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// computePlan compares the desired grants with those in the workspace. Grants which are not in the file are left
+// as they are.
+func computePlan(client *apiClient.Client, desired []Grant, now time.Time) (*Plan, error) {
+	workspace, err := readWorkspaceGrants(client)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Changes: []Change{}}
+	for _, grant := range desired {
+		change := Change{
+			Action:   ActionNone,
+			Identity: grant.Identity,
+			Level:    grant.Level,
+			Resource: grant.Resource,
+			ValidTo:  grant.ValidTo,
+		}
+		expired := !grant.validTo.IsZero() && !grant.validTo.After(now)
+		existing := workspace.find(grant)
+		if existing != nil {
+			change.GrantID = existing.Turbot.Id
+		}
+
+		switch {
+		case existing == nil && (grant.Revoke || expired):
+		case existing == nil:
+			change.Action = ActionCreate
+			change.Type = grant.Type
+			change.Activate = grant.Activate
+		case grant.Revoke || expired:
+			change.Action = ActionRevoke
+			if expired {
+				change.Action = ActionExpire
+			}
+			change.ActivationIDs, _ = workspace.activationIDs(existing)
+		case grant.Activate:
+			if _, active := workspace.activationIDs(existing); !active {
+				change.Action = ActionActivate
+			}
+		}
+		plan.add(change)
+	}
+	return plan, nil
+}
+
+// stalePlan plans revoking the grants whose profile has not logged in since the cutoff. Grants of profiles which
+// have never logged in are revoked if they were created before the cutoff. Grants of other identities, e.g.
+// groups, are kept.
+func stalePlan(client *apiClient.Client, cutoff time.Time) (*Plan, error) {
+	workspace, err := readWorkspaceGrants(client)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Changes: []Change{}}
+	for i := range workspace.grants {
+		grant := &workspace.grants[i]
+		if grant.Identity.Type.Uri != profileTypeUri {
+			continue
+		}
+		plan.profileGrants++
+		var reason string
+		if grant.Identity.LastLoginTimestamp != "" {
+			lastLogin, err := time.Parse(time.RFC3339, grant.Identity.LastLoginTimestamp)
+			if err != nil {
+				return nil, fmt.Errorf("invalid last login timestamp of %s: %s", identityName(grant.Identity), err.Error())
+			}
+			if !lastLogin.Before(cutoff) {
+				continue
+			}
+			reason = "last login " + lastLogin.UTC().Format("2006-01-02")
+		} else {
+			created, err := time.Parse(time.RFC3339, grant.Turbot.CreateTimestamp)
+			if err != nil || !created.Before(cutoff) {
+				continue
+			}
+			reason = "never logged in"
+		}
+
+		change := Change{
+			Action:   ActionRevoke,
+			Identity: identityName(grant.Identity),
+			Level:    grant.Level.Uri,
+			Resource: grant.Resource.Turbot.Id,
+			GrantID:  grant.Turbot.Id,
+			Reason:   reason,
+		}
+		if len(grant.Resource.Akas) > 0 {
+			change.Resource = grant.Resource.Akas[0]
+		}
+		change.ActivationIDs, _ = workspace.activationIDs(grant)
+		plan.add(change)
+	}
+	return plan, nil
+}
+
+// checkRevocations returns an error if a revoke-expired plan revokes more than massRevokeFraction of the grants of
+// profiles, unless mass revocation is allowed
+func checkRevocations(plan *Plan, allowMassRevoke bool) error {
+	revoked := plan.Summary.Revoke
+	if allowMassRevoke || revoked <= 1 {
+		return nil
+	}
+	if float64(revoked) > massRevokeFraction*float64(plan.profileGrants) {
+		return fmt.Errorf("the plan revokes %d of the %d grants of profiles, more than %.0f%%. Check the -days cutoff, or use -allow-mass-revoke to apply the plan", revoked, plan.profileGrants, massRevokeFraction*100)
+	}
+	return nil
+}
+
+// identityName returns the name of an identity shown in plans - its email, or else its first aka
+func identityName(identity apiClient.GrantIdentity) string {
+	if identity.Email != "" {
+		return identity.Email
+	}
+	if len(identity.Akas) > 0 {
+		return identity.Akas[0]
+	}
+	return identity.ProfileId
+}
+
+func (p *Plan) add(change Change) {
+	p.Changes = append(p.Changes, change)
+	switch change.Action {
+	case ActionCreate:
+		p.Summary.Create++
+	case ActionActivate:
+		p.Summary.Activate++
+	case ActionRevoke:
+		p.Summary.Revoke++
+	case ActionExpire:
+		p.Summary.Expire++
+	default:
+		p.Summary.NoOp++
+	}
+}
+
+// applyPlan makes the changes of the plan, stopping at the first which fails
+func applyPlan(client *apiClient.Client, plan *Plan, out io.Writer) error {
+	for _, change := range plan.Changes {
+		if err := applyChange(client, change, out); err != nil {
+			return fmt.Errorf("failed to %s %s for %s on %s: %s", change.Action, change.Level, change.Identity, change.Resource, err.Error())
+		}
+	}
+	return nil
+}
+
+func applyChange(client *apiClient.Client, change Change, out io.Writer) error {
+	switch change.Action {
+	case ActionCreate:
+		input := map[string]interface{}{
+			"identity": change.Identity,
+			"level":    change.Level,
+			"resource": change.Resource,
+			"type":     change.Type,
+		}
+		if change.ValidTo != "" {
+			input["validToTimestamp"] = change.ValidTo
+		}
+		grant, err := client.CreateGrant(input)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %s for %s on %s (%s)\n", change.Level, change.Identity, change.Resource, grant.Id)
+		if change.Activate {
+			change.GrantID = grant.Id
+			return activate(client, change, out)
+		}
+	case ActionActivate:
+		return activate(client, change, out)
+	case ActionRevoke, ActionExpire:
+		for _, id := range change.ActivationIDs {
+			if err := client.DeleteGrantActivation(id); err != nil {
+				return err
+			}
+		}
+		if err := client.DeleteGrant(change.GrantID); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Revoked %s for %s on %s (%s)\n", change.Level, change.Identity, change.Resource, change.GrantID)
+	}
+	return nil
+}
+
+func activate(client *apiClient.Client, change Change, out io.Writer) error {
+	activation, err := client.CreateGrantActivation(map[string]interface{}{
+		"grant":    change.GrantID,
+		"resource": change.Resource,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Activated %s for %s on %s (%s)\n", change.Level, change.Identity, change.Resource, activation.Id)
+	return nil
+}
+
+// writePlan writes a line for each grant to create, activate or revoke, with its expiry and reason, and the counts of
+// each action
+func writePlan(plan *Plan, out io.Writer) {
+	symbols := map[Action]string{ActionCreate: "+", ActionActivate: "*", ActionRevoke: "-", ActionExpire: "-"}
+	for _, change := range plan.Changes {
+		if change.Action == ActionNone {
+			continue
+		}
+		line := fmt.Sprintf("%s %s %s for %s on %s", symbols[change.Action], change.Action, change.Level, change.Identity, change.Resource)
+		var details []string
+		if change.Action == ActionCreate && change.ValidTo != "" {
+			details = append(details, "valid to "+change.ValidTo)
+		}
+		if change.Action == ActionCreate && change.Activate {
+			details = append(details, "activated")
+		}
+		if change.Reason != "" {
+			details = append(details, change.Reason)
+		}
+		if len(details) > 0 {
+			line += fmt.Sprintf(" (%s)", strings.Join(details, ", "))
+		}
+		fmt.Fprintln(out, line)
+	}
+	fmt.Fprintf(out, "Plan: %d to create, %d to activate, %d to revoke, %d expired, %d unchanged.\n", plan.Summary.Create, plan.Summary.Activate, plan.Summary.Revoke, plan.Summary.Expire, plan.Summary.NoOp)
+}
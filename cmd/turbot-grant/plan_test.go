@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/clitest"
+)
+
+const (
+	admin    = "tmod:@turbot/turbot-iam#/permission/levels/admin"
+	readOnly = "tmod:@turbot/turbot-iam#/permission/levels/readOnly"
+	account  = "arn:aws:::123456789012"
+)
+
+// unchanged, activated, created and already expired
+const testGrantFile = `identity,level,resource,valid_to,activate
+jane,` + admin + `,` + account + `,,true
+tmod:@turbot/turbot#/identities/john,` + readOnly + `,` + account + `,,true
+jane@example.com,` + readOnly + `,arn:aws:s3:::my-bucket,2099-06-30,true
+john,` + admin + `,arn:aws:s3:::my-bucket,2023-01-31,
+`
+
+func newTestClient(t *testing.T) *apiClient.Client {
+	return clitest.NewClient(t, clitest.LoadStore(t, "../../turbot/testdata"))
+}
+
+func actions(plan *Plan) []Action {
+	var result []Action
+	for _, change := range plan.Changes {
+		result = append(result, change.Action)
+	}
+	return result
+}
+
+func TestPlanAndApply(t *testing.T) {
+	grants, err := loadGrantFile(clitest.WriteFile(t, "grants.csv", testGrantFile))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "2099-06-30T23:59:59.999Z", grants[2].ValidTo)
+	assert.Equal(t, defaultPermissionType, grants[2].Type)
+	client := newTestClient(t)
+	now := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	plan, err := computePlan(client, grants, now)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Action{ActionNone, ActionActivate, ActionCreate, ActionNone}, actions(plan))
+	assert.Equal(t, "14002", plan.Changes[1].GrantID)
+
+	var out bytes.Buffer
+	writePlan(plan, &out)
+	assert.Equal(t, `* activate `+readOnly+` for tmod:@turbot/turbot#/identities/john on `+account+`
++ create `+readOnly+` for jane@example.com on arn:aws:s3:::my-bucket (valid to 2099-06-30T23:59:59.999Z, activated)
+Plan: 1 to create, 1 to activate, 0 to revoke, 0 expired, 2 unchanged.
+`, out.String())
+	assert.Equal(t, PlanSummary{Create: 1, Activate: 1, NoOp: 2}, plan.Summary)
+
+	// once applied, the workspace matches the file
+	out.Reset()
+	assert.NoError(t, applyPlan(client, plan, &out))
+	assert.Contains(t, out.String(), "Created "+readOnly+" for jane@example.com on arn:aws:s3:::my-bucket")
+	plan, err = computePlan(client, grants, now)
+	assert.NoError(t, err)
+	assert.Equal(t, PlanSummary{NoOp: 4}, plan.Summary)
+
+	// revoking a grant deactivates it first, and expiring a grant revokes it
+	revoke, err := loadGrantFile(clitest.WriteFile(t, "revoke.json", `[
+		{"identity": "jane", "level": "`+admin+`", "resource": "`+account+`", "revoke": true},
+		{"identity": "john", "level": "`+readOnly+`", "resource": "`+account+`", "valid_to": "2023-02-01T00:00:00Z"}
+	]`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	plan, err = computePlan(client, revoke, now)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Action{ActionRevoke, ActionExpire}, actions(plan))
+	assert.Equal(t, []string{"15001"}, plan.Changes[0].ActivationIDs)
+	assert.NoError(t, applyPlan(client, plan, &out))
+	activeGrants, err := client.ListActiveGrants("")
+	assert.NoError(t, err)
+	assert.Len(t, activeGrants, 1)
+	plan, err = computePlan(client, revoke, now)
+	assert.NoError(t, err)
+	assert.Equal(t, PlanSummary{NoOp: 2}, plan.Summary)
+}
+
+func TestStalePlan(t *testing.T) {
+	client := newTestClient(t)
+
+	// jane last logged in on 2023-01-12, and john, whose grant was created on 2023-01-11, never has. The grant of
+	// the auditors group is kept, since groups never log in.
+	plan, err := stalePlan(client, time.Date(2023, 1, 30, 0, 0, 0, 0, time.UTC))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Action{ActionRevoke, ActionRevoke}, actions(plan))
+	assert.Equal(t, "jane@example.com", plan.Changes[0].Identity)
+	assert.Equal(t, "last login 2023-01-12", plan.Changes[0].Reason)
+	assert.Equal(t, account, plan.Changes[0].Resource)
+	assert.Equal(t, []string{"15001"}, plan.Changes[0].ActivationIDs)
+	assert.Equal(t, "never logged in", plan.Changes[1].Reason)
+	assert.Equal(t, "john@example.com", plan.Changes[1].Identity)
+
+	// revoking both grants of profiles needs -allow-mass-revoke
+	assert.EqualError(t, checkRevocations(plan, false), "the plan revokes 2 of the 2 grants of profiles, more than 20%. Check the -days cutoff, or use -allow-mass-revoke to apply the plan")
+	assert.NoError(t, checkRevocations(plan, true))
+
+	plan, err = stalePlan(client, time.Date(2023, 1, 11, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Empty(t, plan.Changes)
+	assert.NoError(t, checkRevocations(plan, false))
+}
+
+func TestLoadGrantFileErrors(t *testing.T) {
+	tests := map[string]string{
+		"identity,level\njane,x\n":                                          "resource is required",
+		"identity,level,resource,owner\njane,x,arn:a,me\n":                  `unknown column "owner"`,
+		"identity,level,resource,valid_to\njane,x,arn:a,soon\n":             "valid_to must be a timestamp or a date",
+		"identity,level,resource,activate\njane,x,arn:a,maybe\n":            "activate must be true or false",
+		"identity,level,resource,revoke,activate\njane,x,arn:a,true,true\n": "a revoked grant cannot be activated",
+		"identity,level,resource\njane,x,arn:a\njane,x,arn:a\n":             "grant 2: duplicates grant 1",
+	}
+	for content, expected := range tests {
+		_, err := loadGrantFile(clitest.WriteFile(t, "grants.csv", content))
+		assert.ErrorContains(t, err, expected)
+	}
+	_, err := loadGrantFile(clitest.WriteFile(t, "grants.json", `[{"identity": "jane", "level": "x", "resource": "arn:a", "owner": "me"}]`))
+	assert.ErrorContains(t, err, `unknown field "owner"`)
+	_, err = loadGrantFile(clitest.WriteFile(t, "grants.yaml", ""))
+	assert.ErrorContains(t, err, "grants files must be .csv or .json")
+}
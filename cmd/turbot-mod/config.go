@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/go-yaml/yaml"
+)
+
+// Manifest is the desired mods of a workspace, e.g.
+//
+//	mods:
+//	  - uri: tmod:@turbot/aws
+//	    version: ^5.0.0
+//	  - uri: tmod:@turbot/aws-s3
+//	    version: 5.10.0
+//
+// The version of a mod is a semver constraint - see parseConstraint. A mod is installed, or upgraded, to its
+// newest available version which matches the constraint and the peer dependencies of the workspace. Mods are
+// installed under the parent resource, by default the Turbot root resource.
+type Manifest struct {
+	Parent string `yaml:"parent,omitempty"`
+	Mods   []Mod  `yaml:"mods"`
+}
+
+// Mod is the desired version of a mod
+type Mod struct {
+	Uri     string `yaml:"uri"`
+	Version string `yaml:"version,omitempty"`
+	// the resource to install the mod under, if not that of the manifest
+	Parent string `yaml:"parent,omitempty"`
+
+	constraint semver.Range
+}
+
+// the aka of the Turbot root resource, under which mods are installed by default
+const rootResource = "tmod:@turbot/turbot#/"
+
+// loadManifest reads and validates a manifest
+func loadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err = yaml.UnmarshalStrict(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err.Error())
+	}
+
+	seen := map[string]int{}
+	for i := range manifest.Mods {
+		mod := &manifest.Mods[i]
+		if err := mod.validate(); err != nil {
+			return nil, fmt.Errorf("%s: mods[%d]: %s", path, i, err.Error())
+		}
+		if j, ok := seen[mod.Uri]; ok {
+			return nil, fmt.Errorf("%s: mods[%d]: duplicates mods[%d]", path, i, j)
+		}
+		seen[mod.Uri] = i
+		if mod.Parent == "" {
+			mod.Parent = manifest.Parent
+		}
+		if mod.Parent == "" {
+			mod.Parent = rootResource
+		}
+	}
+	return manifest, nil
+}
+
+// validate the mod, parsing its constraint
+func (m *Mod) validate() error {
+	if !validModUri(m.Uri) {
+		return fmt.Errorf("uri must be a mod uri, e.g. tmod:@turbot/aws")
+	}
+	if m.Version == "" {
+		m.Version = "*"
+	}
+	constraint, err := parseConstraint(m.Version)
+	if err != nil {
+		return err
+	}
+	m.constraint = constraint
+	return nil
+}
+
+// validModUri returns whether a uri is of the form tmod:@org/mod, as parsed by apiClient.ParseModUri
+func validModUri(uri string) bool {
+	segments := strings.Split(strings.TrimPrefix(uri, "tmod:@"), "/")
+	return strings.HasPrefix(uri, "tmod:@") && len(segments) == 2 && segments[0] != "" && segments[1] != ""
+}
+
+// writeManifest writes a manifest as YAML
+func writeManifest(manifest *Manifest, out io.Writer) error {
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
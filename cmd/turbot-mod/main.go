@@ -0,0 +1,142 @@
+// Command turbot-mod manages the mods of a Turbot workspace from a manifest of mod URIs and semver constraints.
+// It resolves the version of each mod against the mod registry, checking the peer dependencies of the mods,
+// and installs or upgrades mods to match the manifest. The pin command writes a manifest of the installed
+// versions, and the report command lists the installed mods with their upgrades and broken peer dependencies.
+//
+// Usage:
+//
+//	turbot-mod plan [-profile name] [-json] mods.yaml
+//	turbot-mod apply [-profile name] [-json] [-dry-run] mods.yaml
+//	turbot-mod pin [-profile name] [-output mods.yaml]
+//	turbot-mod report [-profile name] [-json]
+//
+// See Manifest for the format of the file. Credentials are read from the profile if given, otherwise the same
+// way as for a connection with no credentials set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/cli"
+)
+
+const usage = `usage:
+  turbot-mod plan [-profile name] [-json] mods.yaml
+  turbot-mod apply [-profile name] [-json] [-dry-run] mods.yaml
+  turbot-mod pin [-profile name] [-output mods.yaml]
+  turbot-mod report [-profile name] [-json]`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	if command != "plan" && command != "apply" && command != "pin" && command != "report" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	profile := flags.String("profile", "", "Turbot CLI profile to read credentials from")
+	jsonOutput, dryRun, output := false, false, ""
+	if command != "pin" {
+		flags.BoolVar(&jsonOutput, "json", false, "write the output as JSON")
+	}
+	if command == "apply" {
+		flags.BoolVar(&dryRun, "dry-run", false, "show the plan without applying it")
+	}
+	if command == "pin" {
+		flags.StringVar(&output, "output", "", "path of the manifest to write, instead of stdout")
+	}
+	_ = flags.Parse(os.Args[2:])
+	args := 0
+	if command == "plan" || command == "apply" {
+		args = 1
+	}
+	if flags.NArg() != args {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch command {
+	case "pin":
+		err = pin(*profile, output)
+	case "report":
+		err = report(*profile, jsonOutput)
+	default:
+		err = run(flags.Arg(0), *profile, jsonOutput, command == "apply" && !dryRun)
+	}
+	if err != nil {
+		cli.Exit("turbot-mod", err)
+	}
+}
+
+func run(path, profile string, jsonOutput, apply bool) error {
+	manifest, err := loadManifest(path)
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(profile)
+	if err != nil {
+		return err
+	}
+	plan, err := computePlan(client, manifest)
+	if err != nil {
+		return err
+	}
+
+	return cli.PlanAndApply(plan, jsonOutput, apply, func(out io.Writer) { writePlan(plan, out) }, func(progress io.Writer) error {
+		return applyPlan(client, plan, progress)
+	})
+}
+
+func pin(profile, output string) error {
+	client, err := cli.Connect(profile)
+	if err != nil {
+		return err
+	}
+	manifest, err := pinManifest(client)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		return writeManifest(manifest, os.Stdout)
+	}
+
+	// write to a temporary file, so a failed pin does not leave a partial manifest
+	tmp := output + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	err = writeManifest(manifest, out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, output)
+}
+
+func report(profile string, jsonOutput bool) error {
+	client, err := cli.Connect(profile)
+	if err != nil {
+		return err
+	}
+	reports, err := reportMods(client)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return cli.WriteJSON(reports)
+	}
+	writeReport(reports, os.Stdout)
+	return nil
+}
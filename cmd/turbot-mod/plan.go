@@ -0,0 +1,429 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+)
+
+// Action is the change planned for a mod
+type Action string
+
+const (
+	ActionInstall Action = "install"
+	ActionUpgrade Action = "upgrade"
+	ActionRefuse  Action = "refuse"
+	ActionNone    Action = "no-op"
+)
+
+// the registry status of versions which can be installed
+const availableStatus = "AVAILABLE"
+
+// Plan is the changes needed to bring the mods of the workspace to the desired state. Changes are in the order
+// they are applied, so peer dependencies are installed and upgraded before the mods which need them.
+type Plan struct {
+	Changes []Change    `json:"changes"`
+	Summary PlanSummary `json:"summary"`
+}
+
+type PlanSummary struct {
+	Install int `json:"install"`
+	Upgrade int `json:"upgrade"`
+	Refuse  int `json:"refuse"`
+	NoOp    int `json:"no_op"`
+}
+
+// Change is the change planned for a mod
+type Change struct {
+	Action     Action `json:"action"`
+	Uri        string `json:"uri"`
+	Constraint string `json:"constraint"`
+	Parent     string `json:"parent,omitempty"`
+	// the installed version, if any
+	From string `json:"from,omitempty"`
+	// the version to install or upgrade to
+	To string `json:"to,omitempty"`
+	// why the change is refused
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// registry reads the available versions of mods, and their peer dependencies, caching them by mod
+type registry struct {
+	client   *apiClient.Client
+	versions map[string][]semver.Version
+	peers    map[string]map[string][]apiClient.ModPeerDependency
+}
+
+func newRegistry(client *apiClient.Client) *registry {
+	return &registry{
+		client:   client,
+		versions: map[string][]semver.Version{},
+		peers:    map[string]map[string][]apiClient.ModPeerDependency{},
+	}
+}
+
+// available returns the available versions of a mod, newest first
+func (r *registry) available(uri string) ([]semver.Version, error) {
+	if versions, ok := r.versions[uri]; ok {
+		return versions, nil
+	}
+	org, mod := apiClient.ParseModUri(uri)
+	items, err := r.client.GetModVersions(org, mod)
+	if err != nil {
+		return nil, err
+	}
+	var versions []semver.Version
+	for _, item := range items {
+		if item.Status != availableStatus {
+			continue
+		}
+		if v, err := semver.Parse(item.Version); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	sort.Sort(sort.Reverse(semver.Versions(versions)))
+	r.versions[uri] = versions
+	return versions, nil
+}
+
+// peerDependencies returns the peer dependencies of a version of a mod, which are unknown for versions which
+// are not in the registry
+func (r *registry) peerDependencies(uri, version string) ([]apiClient.ModPeerDependency, error) {
+	if _, ok := r.peers[uri]; !ok {
+		org, mod := apiClient.ParseModUri(uri)
+		peers, err := r.client.GetModPeerDependencies(org, mod)
+		if err != nil {
+			return nil, err
+		}
+		r.peers[uri] = peers
+	}
+	return r.peers[uri][version], nil
+}
+
+// peerProblems returns how installing a version of a mod would break the peer dependencies of the workspace,
+// given the version of each installed mod: the peers of the version which are missing or do not match, and the
+// installed mods which need another version of the mod
+func (r *registry) peerProblems(uri string, version semver.Version, installed map[string]semver.Version) ([]string, error) {
+	problems, err := r.unmetPeers(uri, version, installed)
+	if err != nil {
+		return nil, err
+	}
+
+	var dependents []string
+	for dependent := range installed {
+		if dependent != uri {
+			dependents = append(dependents, dependent)
+		}
+	}
+	sort.Strings(dependents)
+	for _, dependent := range dependents {
+		peers, err := r.peerDependencies(dependent, installed[dependent].String())
+		if err != nil {
+			return nil, err
+		}
+		for _, peer := range peers {
+			if "tmod:"+peer.FullName != uri {
+				continue
+			}
+			constraint, err := parseConstraint(peer.VersionRange)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %s", dependent, installed[dependent], err.Error())
+			}
+			if !constraint(version) {
+				problems = append(problems, fmt.Sprintf("%s %s requires %s %s", strings.TrimPrefix(dependent, "tmod:"), installed[dependent], peer.FullName, peer.VersionRange))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// unmetPeers returns the peer dependencies of a version of a mod which are missing or do not match the
+// installed mods
+func (r *registry) unmetPeers(uri string, version semver.Version, installed map[string]semver.Version) ([]string, error) {
+	var problems []string
+	peers, err := r.peerDependencies(uri, version.String())
+	if err != nil {
+		return nil, err
+	}
+	for _, peer := range peers {
+		constraint, err := parseConstraint(peer.VersionRange)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %s", uri, version, err.Error())
+		}
+		peerVersion, ok := installed["tmod:"+peer.FullName]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("requires %s %s, which is not installed", peer.FullName, peer.VersionRange))
+		case !constraint(peerVersion):
+			problems = append(problems, fmt.Sprintf("requires %s %s, not %s", peer.FullName, peer.VersionRange, peerVersion))
+		}
+	}
+	return problems, nil
+}
+
+// workspaceMods returns the installed mods of the workspace by uri, and their versions
+func workspaceMods(client *apiClient.Client) (map[string]apiClient.Mod, map[string]semver.Version, error) {
+	mods, err := client.ListMods()
+	if err != nil {
+		return nil, nil, err
+	}
+	byUri := map[string]apiClient.Mod{}
+	versions := map[string]semver.Version{}
+	for _, mod := range mods {
+		byUri[mod.Uri] = mod
+		if v, err := semver.Parse(mod.Version); err == nil {
+			versions[mod.Uri] = v
+		}
+	}
+	return byUri, versions, nil
+}
+
+// computePlan resolves the version of each mod of the manifest: its newest available version which matches its
+// constraint and keeps the peer dependencies of the workspace, with the versions already resolved. Mods are
+// resolved in manifest order, and those refused for a peer which is planned later are resolved again. A mod is
+// never downgraded, and an upgrade which breaks a peer dependency is refused, unless the installed version
+// matches the constraint, when the mod is left as it is.
+func computePlan(client *apiClient.Client, manifest *Manifest) (*Plan, error) {
+	mods, installed, err := workspaceMods(client)
+	if err != nil {
+		return nil, err
+	}
+	r := newRegistry(client)
+
+	plan := &Plan{Changes: []Change{}}
+	refused := map[int]Change{}
+	pending := make([]int, len(manifest.Mods))
+	for i := range pending {
+		pending[i] = i
+	}
+	for len(pending) > 0 {
+		var next []int
+		for _, i := range pending {
+			change, err := resolve(r, manifest.Mods[i], mods, installed)
+			if err != nil {
+				return nil, err
+			}
+			if change.Action == ActionRefuse {
+				refused[i] = change
+				next = append(next, i)
+				continue
+			}
+			if change.Action != ActionNone {
+				installed[change.Uri] = semver.MustParse(change.To)
+			}
+			plan.add(change)
+		}
+		// stop once a pass resolves no more mods
+		if len(next) == len(pending) {
+			break
+		}
+		pending = next
+	}
+	for _, i := range pending {
+		plan.add(refused[i])
+	}
+	return plan, nil
+}
+
+// resolve plans the change for a mod, given the versions of the installed and already resolved mods
+func resolve(r *registry, mod Mod, mods map[string]apiClient.Mod, installed map[string]semver.Version) (Change, error) {
+	change := Change{Action: ActionNone, Uri: mod.Uri, Constraint: mod.Version, Parent: mod.Parent}
+	current, isInstalled := installed[mod.Uri]
+	if existing, ok := mods[mod.Uri]; ok {
+		change.From = existing.Version
+		change.Parent = existing.Parent
+	}
+
+	versions, err := r.available(mod.Uri)
+	if err != nil {
+		return change, err
+	}
+	var candidates []semver.Version
+	for _, v := range versions {
+		if mod.constraint(v) && (!isInstalled || v.GT(current)) {
+			candidates = append(candidates, v)
+		}
+	}
+	if len(candidates) == 0 {
+		switch {
+		case isInstalled && mod.constraint(current):
+			return change, nil
+		case isInstalled:
+			change.Action = ActionRefuse
+			change.Reasons = []string{fmt.Sprintf("installed %s does not match %s, and no newer version does", current, mod.Version)}
+			return change, nil
+		}
+		return change, fmt.Errorf("no available version of %s matches %s", mod.Uri, mod.Version)
+	}
+
+	action := ActionInstall
+	if change.From != "" {
+		action = ActionUpgrade
+	}
+	var reasons []string
+	for _, candidate := range candidates {
+		problems, err := r.peerProblems(mod.Uri, candidate, installed)
+		if err != nil {
+			return change, err
+		}
+		if len(problems) == 0 {
+			change.Action = action
+			change.To = candidate.String()
+			return change, nil
+		}
+		if reasons == nil {
+			reasons = problems
+		}
+	}
+
+	// no newer version keeps the peer dependencies, which is only a problem if the installed version does not
+	// match the constraint
+	if isInstalled && mod.constraint(current) {
+		return change, nil
+	}
+	change.Action = ActionRefuse
+	change.To = candidates[0].String()
+	change.Reasons = reasons
+	return change, nil
+}
+
+func (p *Plan) add(change Change) {
+	p.Changes = append(p.Changes, change)
+	switch change.Action {
+	case ActionInstall:
+		p.Summary.Install++
+	case ActionUpgrade:
+		p.Summary.Upgrade++
+	case ActionRefuse:
+		p.Summary.Refuse++
+	default:
+		p.Summary.NoOp++
+	}
+}
+
+// applyPlan installs and upgrades the mods of the plan, stopping at the first which fails. Refused changes are
+// not applied, and are returned as an error once the others are.
+func applyPlan(client *apiClient.Client, plan *Plan, out io.Writer) error {
+	for _, change := range plan.Changes {
+		if change.Action != ActionInstall && change.Action != ActionUpgrade {
+			continue
+		}
+		org, mod := apiClient.ParseModUri(change.Uri)
+		result, err := client.InstallMod(map[string]interface{}{
+			"parent":  change.Parent,
+			"org":     org,
+			"mod":     mod,
+			"version": change.To,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to %s %s %s: %s", change.Action, change.Uri, change.To, err.Error())
+		}
+		if change.Action == ActionInstall {
+			fmt.Fprintf(out, "Installed %s %s (%s)\n", change.Uri, change.To, result.Turbot.Id)
+		} else {
+			fmt.Fprintf(out, "Upgraded %s from %s to %s (%s)\n", change.Uri, change.From, change.To, result.Turbot.Id)
+		}
+	}
+	if plan.Summary.Refuse > 0 {
+		return fmt.Errorf("%d refused, see the plan", plan.Summary.Refuse)
+	}
+	return nil
+}
+
+// writePlan writes a line for each mod to install, upgrade or refuse, with the constraint it was chosen by, and
+// the counts of each action
+func writePlan(plan *Plan, out io.Writer) {
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case ActionInstall:
+			fmt.Fprintf(out, "+ install %s %s (%s)\n", change.Uri, change.To, change.Constraint)
+		case ActionUpgrade:
+			fmt.Fprintf(out, "~ upgrade %s %s -> %s (%s)\n", change.Uri, change.From, change.To, change.Constraint)
+		case ActionRefuse:
+			line := fmt.Sprintf("! refuse %s", change.Uri)
+			if change.To != "" {
+				line += fmt.Sprintf(" %s", change.To)
+			}
+			fmt.Fprintf(out, "%s: %s\n", line, strings.Join(change.Reasons, "; "))
+		}
+	}
+	fmt.Fprintf(out, "Plan: %d to install, %d to upgrade, %d refused, %d unchanged.\n", plan.Summary.Install, plan.Summary.Upgrade, plan.Summary.Refuse, plan.Summary.NoOp)
+}
+
+// pinManifest returns a manifest of the installed mods of the workspace, each constrained to its installed
+// version, e.g. to install the same versions in another workspace
+func pinManifest(client *apiClient.Client) (*Manifest, error) {
+	mods, err := client.ListMods()
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{Mods: []Mod{}}
+	for _, mod := range mods {
+		manifest.Mods = append(manifest.Mods, Mod{Uri: mod.Uri, Version: mod.Version})
+	}
+	sort.Slice(manifest.Mods, func(i, j int) bool {
+		return manifest.Mods[i].Uri < manifest.Mods[j].Uri
+	})
+	return manifest, nil
+}
+
+// ModReport is the state of an installed mod
+type ModReport struct {
+	Uri     string `json:"uri"`
+	Version string `json:"version"`
+	// the newest available version, if newer than the installed version
+	Latest string `json:"latest,omitempty"`
+	// the peer dependencies of the installed version which are missing or do not match
+	Problems []string `json:"problems,omitempty"`
+}
+
+// reportMods reports the installed mods of the workspace, with their available upgrades and broken peer
+// dependencies
+func reportMods(client *apiClient.Client) ([]ModReport, error) {
+	mods, installed, err := workspaceMods(client)
+	if err != nil {
+		return nil, err
+	}
+	r := newRegistry(client)
+
+	reports := []ModReport{}
+	for uri, mod := range mods {
+		report := ModReport{Uri: uri, Version: mod.Version}
+		versions, err := r.available(uri)
+		if err != nil {
+			return nil, err
+		}
+		current, ok := installed[uri]
+		if len(versions) > 0 && (!ok || versions[0].GT(current)) {
+			report.Latest = versions[0].String()
+		}
+		if ok {
+			if report.Problems, err = r.unmetPeers(uri, current, installed); err != nil {
+				return nil, err
+			}
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Uri < reports[j].Uri
+	})
+	return reports, nil
+}
+
+// writeReport writes the report for a reader, one line per mod followed by its problems
+func writeReport(reports []ModReport, out io.Writer) {
+	for _, report := range reports {
+		status := "up to date"
+		if report.Latest != "" {
+			status = report.Latest + " available"
+		}
+		fmt.Fprintf(out, "%s %s (%s)\n", report.Uri, report.Version, status)
+		for _, problem := range report.Problems {
+			fmt.Fprintf(out, "  ! %s\n", problem)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/clitest"
+)
+
+// the root resource and installed mods of the test workspace
+const testResources = `[
+	{"turbot": {"id": "178806", "akas": ["tmod:@turbot/turbot#/"], "path": "178806"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/turbot"}},
+	{"data": {"version": "5.31.0"}, "turbot": {"id": "2101", "parentId": "178806", "akas": ["tmod:@turbot/turbot"], "path": "178806.2101"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/mod"}},
+	{"data": {"version": "5.19.0"}, "turbot": {"id": "2102", "parentId": "178806", "akas": ["tmod:@turbot/aws"], "path": "178806.2102"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/mod"}},
+	{"data": {"version": "5.9.0"}, "turbot": {"id": "2103", "parentId": "178806", "akas": ["tmod:@turbot/aws-s3"], "path": "178806.2103"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/mod"}},
+	{"data": {"version": "5.0.0"}, "turbot": {"id": "2104", "parentId": "178806", "akas": ["tmod:@turbot/aws-ec2"], "path": "178806.2104"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/mod"}}
+]`
+
+// the mod registry of the test workspace
+const testRegistry = `[
+	{"identityName": "turbot", "name": "turbot", "versions": [
+		{"version": "5.31.0", "status": "AVAILABLE"},
+		{"version": "5.32.0", "status": "AVAILABLE"}
+	]},
+	{"identityName": "turbot", "name": "aws", "versions": [
+		{"version": "5.19.0", "status": "AVAILABLE", "head": {"peerDependencies": [{"fullName": "@turbot/turbot", "versionRange": ">=5.30.0"}]}},
+		{"version": "5.20.0", "status": "AVAILABLE", "head": {"peerDependencies": [{"fullName": "@turbot/turbot", "versionRange": ">=5.30.0"}]}},
+		{"version": "6.0.0", "status": "AVAILABLE", "head": {"peerDependencies": [{"fullName": "@turbot/turbot", "versionRange": ">=5.40.0"}]}}
+	]},
+	{"identityName": "turbot", "name": "aws-s3", "versions": [
+		{"version": "5.9.0", "status": "AVAILABLE", "head": {"peerDependencies": [{"fullName": "@turbot/aws", "versionRange": "^5.0.0"}]}},
+		{"version": "5.10.0", "status": "AVAILABLE", "head": {"peerDependencies": [{"fullName": "@turbot/aws", "versionRange": "^5.0.0"}]}},
+		{"version": "5.11.0-beta.1", "status": "RC", "head": {"peerDependencies": [{"fullName": "@turbot/aws", "versionRange": "^5.0.0"}]}},
+		{"version": "6.0.0", "status": "AVAILABLE", "head": {"peerDependencies": [{"fullName": "@turbot/aws", "versionRange": "^6.0.0"}]}}
+	]},
+	{"identityName": "turbot", "name": "aws-ec2", "versions": [
+		{"version": "5.0.0", "status": "AVAILABLE", "head": {"peerDependencies": [{"fullName": "@turbot/aws", "versionRange": "^5.20.0"}]}}
+	]},
+	{"identityName": "turbot", "name": "aws-iam", "versions": [
+		{"version": "5.5.0", "status": "AVAILABLE", "head": {"peerDependencies": [{"fullName": "@turbot/aws", "versionRange": "^5.20.0"}]}}
+	]}
+]`
+
+const testManifest = `mods:
+  - uri: tmod:@turbot/aws-iam
+    version: ^5.0.0
+  - uri: tmod:@turbot/aws
+  - uri: tmod:@turbot/aws-s3
+    version: ">=5.0.0"
+  - uri: tmod:@turbot/turbot
+    version: ~5.31.0
+`
+
+func newTestClient(t *testing.T) *apiClient.Client {
+	return clitest.NewClient(t, clitest.NewStore(t, `{"resources": `+testResources+`, "modVersionSearches": `+testRegistry+`}`))
+}
+
+func actions(plan *Plan) []Action {
+	var result []Action
+	for _, change := range plan.Changes {
+		result = append(result, change.Action)
+	}
+	return result
+}
+
+func TestPlanAndApply(t *testing.T) {
+	manifest, err := loadManifest(clitest.WriteFile(t, "mods.yaml", testManifest))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, rootResource, manifest.Mods[0].Parent)
+	client := newTestClient(t)
+
+	// aws 6.0.0 needs a newer turbot mod, and aws-s3 6.0.0 needs aws 6, so both upgrade to their newest 5.x.
+	// aws-iam needs the aws upgrade, so is installed after it.
+	plan, err := computePlan(client, manifest)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Action{ActionUpgrade, ActionUpgrade, ActionNone, ActionInstall}, actions(plan))
+	var out bytes.Buffer
+	writePlan(plan, &out)
+	assert.Equal(t, `~ upgrade tmod:@turbot/aws 5.19.0 -> 5.20.0 (*)
+~ upgrade tmod:@turbot/aws-s3 5.9.0 -> 5.10.0 (>=5.0.0)
++ install tmod:@turbot/aws-iam 5.5.0 (^5.0.0)
+Plan: 1 to install, 2 to upgrade, 0 refused, 1 unchanged.
+`, out.String())
+
+	// once applied, the workspace matches the manifest
+	out.Reset()
+	assert.NoError(t, applyPlan(client, plan, &out))
+	assert.Contains(t, out.String(), "Upgraded tmod:@turbot/aws from 5.19.0 to 5.20.0 (2102)")
+	plan, err = computePlan(client, manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, PlanSummary{NoOp: 4}, plan.Summary)
+
+	// upgrades which break a peer dependency, and downgrades, are refused
+	manifest, err = loadManifest(clitest.WriteFile(t, "refuse.yaml", `mods:
+  - uri: tmod:@turbot/aws
+    version: ^6.0.0
+  - uri: tmod:@turbot/aws-iam
+    version: ~5.4.0
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	plan, err = computePlan(client, manifest)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Action{ActionRefuse, ActionRefuse}, actions(plan))
+	assert.Equal(t, []string{
+		"requires @turbot/turbot >=5.40.0, not 5.31.0",
+		"@turbot/aws-ec2 5.0.0 requires @turbot/aws ^5.20.0",
+		"@turbot/aws-iam 5.5.0 requires @turbot/aws ^5.20.0",
+		"@turbot/aws-s3 5.10.0 requires @turbot/aws ^5.0.0",
+	}, plan.Changes[0].Reasons)
+	assert.Equal(t, []string{"installed 5.5.0 does not match ~5.4.0, and no newer version does"}, plan.Changes[1].Reasons)
+	assert.EqualError(t, applyPlan(client, plan, &out), "2 refused, see the plan")
+
+	manifest, err = loadManifest(clitest.WriteFile(t, "missing.yaml", "mods:\n  - uri: tmod:@turbot/gcp\n"))
+	assert.NoError(t, err)
+	_, err = computePlan(client, manifest)
+	assert.EqualError(t, err, "no available version of tmod:@turbot/gcp matches *")
+}
+
+func TestPinAndReport(t *testing.T) {
+	client := newTestClient(t)
+
+	reports, err := reportMods(client)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var out bytes.Buffer
+	writeReport(reports, &out)
+	assert.Equal(t, `tmod:@turbot/aws 5.19.0 (6.0.0 available)
+tmod:@turbot/aws-ec2 5.0.0 (up to date)
+  ! requires @turbot/aws ^5.20.0, not 5.19.0
+tmod:@turbot/aws-s3 5.9.0 (6.0.0 available)
+tmod:@turbot/turbot 5.31.0 (5.32.0 available)
+`, out.String())
+
+	// a pinned manifest plans no changes
+	manifest, err := pinManifest(client)
+	if !assert.NoError(t, err) {
+		return
+	}
+	out.Reset()
+	assert.NoError(t, writeManifest(manifest, &out))
+	assert.Contains(t, out.String(), "- uri: tmod:@turbot/aws\n  version: 5.19.0\n")
+	manifest, err = loadManifest(clitest.WriteFile(t, "pinned.yaml", out.String()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	plan, err := computePlan(client, manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, PlanSummary{NoOp: 4}, plan.Summary)
+}
+
+func TestLoadManifestErrors(t *testing.T) {
+	tests := map[string]string{
+		"mods:\n  - uri: aws\n":                                           "mods[0]: uri must be a mod uri",
+		"mods:\n  - uri: tmod:@turbot/aws\n    version: ^five\n":          `invalid version constraint "^five"`,
+		"mods:\n  - uri: tmod:@turbot/aws\n  - uri: tmod:@turbot/aws\n":   "mods[1]: duplicates mods[0]",
+		"mods:\n  - uri: tmod:@turbot/aws\n    versions: 5.0.0\n":         "field versions not found",
+		"mods:\n  - uri: tmod:@turbot/aws\n    version: 5.0.0 || >>6\n":   `invalid version constraint "5.0.0 || >>6"`,
+		"mods:\n  - uri: tmod:@turbot/aws\n    version: \">=5.0.0 <6\"\n": `invalid version constraint ">=5.0.0 <6"`,
+	}
+	for content, expected := range tests {
+		_, err := loadManifest(clitest.WriteFile(t, "mods.yaml", content))
+		assert.ErrorContains(t, err, expected)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+// parseConstraint parses an npm style semver constraint, as used by mod peer dependencies. A constraint is a
+// version, which matches only itself, a comparison, e.g. >=5.30.0, a caret or tilde range, e.g. ^5.0.0 or
+// ~5.10.0, a wildcard, e.g. 5.x, or * for any version. Space separated constraints must all match, and
+// alternatives are separated by ||.
+func parseConstraint(constraint string) (semver.Range, error) {
+	var alternatives []string
+	for _, alternative := range strings.Split(constraint, "||") {
+		var parts []string
+		for _, part := range strings.Fields(alternative) {
+			expanded, err := expandConstraint(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %s", constraint, err.Error())
+			}
+			parts = append(parts, expanded)
+		}
+		if len(parts) == 0 {
+			parts = []string{">=0.0.0"}
+		}
+		alternatives = append(alternatives, strings.Join(parts, " "))
+	}
+	r, err := semver.ParseRange(strings.Join(alternatives, " || "))
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint %q: %s", constraint, err.Error())
+	}
+	return r, nil
+}
+
+// expandConstraint rewrites a caret, tilde or any version constraint as comparisons, which semver.ParseRange
+// supports
+func expandConstraint(part string) (string, error) {
+	if part == "*" || part == "x" {
+		return ">=0.0.0", nil
+	}
+	if !strings.HasPrefix(part, "^") && !strings.HasPrefix(part, "~") {
+		return part, nil
+	}
+	v, given, err := parsePartialVersion(part[1:])
+	if err != nil {
+		return "", err
+	}
+	upper := semver.Version{Major: v.Major + 1}
+	switch {
+	case part[0] == '~' && given > 1, part[0] == '^' && v.Major == 0 && (v.Minor > 0 || given == 2):
+		upper = semver.Version{Major: v.Major, Minor: v.Minor + 1}
+	case part[0] == '^' && v.Major == 0 && given == 3:
+		upper = semver.Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+	return fmt.Sprintf(">=%s <%s", v, upper), nil
+}
+
+// parsePartialVersion parses a version which may omit its minor and patch numbers, e.g. 5 or 5.1, returning
+// the number of parts given
+func parsePartialVersion(s string) (semver.Version, int, error) {
+	given := len(strings.Split(strings.SplitN(s, "-", 2)[0], "."))
+	if given > 3 {
+		given = 3
+	}
+	for i := given; i < 3; i++ {
+		s += ".0"
+	}
+	v, err := semver.Parse(s)
+	return v, given, err
+}
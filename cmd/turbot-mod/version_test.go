@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		excludes   []string
+	}{
+		{"5.10.0", []string{"5.10.0"}, []string{"5.10.1", "5.9.0"}},
+		{">=5.30.0", []string{"5.30.0", "6.0.0"}, []string{"5.29.9"}},
+		{"^5.1.0", []string{"5.1.0", "5.99.0"}, []string{"5.0.9", "6.0.0"}},
+		{"^0.2.1", []string{"0.2.1", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^5", []string{"5.0.0", "5.9.0"}, []string{"6.0.0"}},
+		{"~5.10.0", []string{"5.10.0", "5.10.7"}, []string{"5.11.0"}},
+		{"~5", []string{"5.11.0"}, []string{"6.0.0"}},
+		{"5.x", []string{"5.0.0", "5.11.0"}, []string{"4.9.0", "6.0.0"}},
+		{"*", []string{"0.0.1", "9.0.0"}, nil},
+		{">=5.0.0 <5.10.0 || ^7.0.0", []string{"5.9.0", "7.1.0"}, []string{"5.10.0", "6.0.0"}},
+	}
+	for _, test := range tests {
+		constraint, err := parseConstraint(test.constraint)
+		if !assert.NoError(t, err, test.constraint) {
+			continue
+		}
+		for _, v := range test.matches {
+			assert.True(t, constraint(semver.MustParse(v)), "%s should match %s", test.constraint, v)
+		}
+		for _, v := range test.excludes {
+			assert.False(t, constraint(semver.MustParse(v)), "%s should not match %s", test.constraint, v)
+		}
+	}
+}
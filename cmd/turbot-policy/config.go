@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+	"github.com/turbot/steampipe-plugin-turbot/helpers"
+)
+
+// PolicyFile is a declarative file of policy settings, e.g.
+//
+//	policy_settings:
+//	  - policy_type: tmod:@turbot/aws-s3#/policy/types/bucketVersioning
+//	    resource: arn:aws:::123456789012
+//	    value: "Check: Enabled"
+//	    note: Require versioning for all buckets.
+//	  - policy_type: tmod:@turbot/aws-s3#/policy/types/bucketTagsTemplate
+//	    resource: arn:aws:::123456789012
+//	    template: "{{ $.resource.turbot.tags | dump }}"
+//	    template_input: "{ resource { turbot { tags } } }"
+//	    precedence: RECOMMENDED
+//	  - policy_type: tmod:@turbot/aws-s3#/policy/types/bucketVersioning
+//	    resource: arn:aws:s3:::other-bucket
+//	    delete: true
+//
+// Settings can also be grouped by the aka of their resource, as written by turbot-policy export, e.g.
+//
+//	resources:
+//	  arn:aws:::123456789012:
+//	    - policy_type: tmod:@turbot/aws-s3#/policy/types/bucketVersioning
+//	      value: "Check: Enabled"
+//	    - policy_type: tmod:@turbot/aws#/policy/types/accountSecret
+//	      secret_ref: env:ACCOUNT_SECRET
+type PolicyFile struct {
+	PolicySettings []PolicySetting            `yaml:"policy_settings,omitempty"`
+	Resources      map[string][]PolicySetting `yaml:"resources,omitempty"`
+}
+
+// PolicySetting is the desired state of the setting of a policy type on a resource. A setting has one of a
+// value, a template or a secret reference, unless it is to be deleted. A secret reference is env:NAME, for the
+// value of an environment variable, or file:path, for the content of a file.
+type PolicySetting struct {
+	PolicyType    string      `yaml:"policy_type"`
+	Resource      string      `yaml:"resource,omitempty"`
+	Value         interface{} `yaml:"value,omitempty"`
+	SecretRef     string      `yaml:"secret_ref,omitempty"`
+	Template      string      `yaml:"template,omitempty"`
+	TemplateInput string      `yaml:"template_input,omitempty"`
+	Precedence    string      `yaml:"precedence,omitempty"`
+	Note          string      `yaml:"note,omitempty"`
+	Delete        bool        `yaml:"delete,omitempty"`
+}
+
+// the precedence of a setting which does not set one
+const defaultPrecedence = "REQUIRED"
+
+// loadPolicyFile reads and validates a policy file
+func loadPolicyFile(path string) (*PolicyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &PolicyFile{}
+	if err = yaml.UnmarshalStrict(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err.Error())
+	}
+
+	// the location of each setting in the file, for errors
+	var locations []string
+	for i := range file.PolicySettings {
+		locations = append(locations, fmt.Sprintf("policy_settings[%d]", i))
+	}
+	// grouped settings are added to the list, with the resource of their group
+	var akas []string
+	for aka := range file.Resources {
+		akas = append(akas, aka)
+	}
+	sort.Strings(akas)
+	for _, aka := range akas {
+		for i, setting := range file.Resources[aka] {
+			location := fmt.Sprintf("resources[%s][%d]", aka, i)
+			if setting.Resource != "" {
+				return nil, fmt.Errorf("%s: %s: resource cannot be set on a setting grouped by resource", path, location)
+			}
+			setting.Resource = aka
+			file.PolicySettings = append(file.PolicySettings, setting)
+			locations = append(locations, location)
+		}
+	}
+	file.Resources = nil
+
+	seen := map[string]int{}
+	for i := range file.PolicySettings {
+		setting := &file.PolicySettings[i]
+		if err := setting.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s: %s", path, locations[i], err.Error())
+		}
+		key := setting.PolicyType + " " + setting.Resource
+		if j, ok := seen[key]; ok {
+			return nil, fmt.Errorf("%s: %s: duplicates %s", path, locations[i], locations[j])
+		}
+		seen[key] = i
+	}
+	return file, nil
+}
+
+// validate the setting, normalizing its value and precedence
+func (s *PolicySetting) validate() error {
+	switch {
+	case s.PolicyType == "":
+		return fmt.Errorf("policy_type is required")
+	case s.Resource == "":
+		return fmt.Errorf("resource is required")
+	case s.Delete:
+		if s.Value != nil || s.Template != "" || s.SecretRef != "" {
+			return fmt.Errorf("a deleted setting cannot have a value, template or secret_ref")
+		}
+		return nil
+	}
+	set := 0
+	for _, ok := range []bool{s.Value != nil, s.Template != "", s.SecretRef != ""} {
+		if ok {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		return fmt.Errorf("only one of value, template and secret_ref can be set")
+	case set == 0:
+		return fmt.Errorf("one of value, template and secret_ref is required")
+	case s.TemplateInput != "" && s.Template == "":
+		return fmt.Errorf("template_input is only valid with a template")
+	case s.SecretRef != "" && !strings.HasPrefix(s.SecretRef, "env:") && !strings.HasPrefix(s.SecretRef, "file:"):
+		return fmt.Errorf("secret_ref must be env:NAME or file:path")
+	}
+
+	s.Value = helpers.NormalizeYamlValue(s.Value)
+	s.Precedence = strings.ToUpper(s.Precedence)
+	switch s.Precedence {
+	case "":
+		s.Precedence = defaultPrecedence
+	case "REQUIRED", "RECOMMENDED":
+	default:
+		return fmt.Errorf("precedence must be REQUIRED or RECOMMENDED")
+	}
+	return nil
+}
+
+// resolveSecretRef returns the value of a secret reference, and whether it is set - a reference to an unset
+// environment variable or a missing file is not an error, since its value may only be available when applying
+func resolveSecretRef(ref string) (interface{}, bool, error) {
+	if name := strings.TrimPrefix(ref, "env:"); name != ref {
+		value, ok := os.LookupEnv(name)
+		return value, ok, nil
+	}
+	data, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	// files usually end with a newline, which is not part of the secret
+	return strings.TrimSuffix(string(data), "\n"), true, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/go-yaml/yaml"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/helpers"
+)
+
+// the resource whose subtree is exported by default
+const rootResource = "tmod:@turbot/turbot#/"
+
+// exportPolicyFile returns the policy file of the settings on a resource and its descendants, grouped by resource.
+// Default settings, which are created by mods, are not exported. Applying the file to the workspace it was
+// exported from plans no changes.
+func exportPolicyFile(client *apiClient.Client, resource string) (*PolicyFile, error) {
+	settings, err := client.ListPolicySettings(fmt.Sprintf("resourceId:'%s' level:self,descendant", apiClient.EscapeFilterString(resource)))
+	if err != nil {
+		return nil, err
+	}
+
+	file := &PolicyFile{Resources: map[string][]PolicySetting{}}
+	for _, live := range settings {
+		if live.Default {
+			continue
+		}
+		setting, err := exportSetting(live)
+		if err != nil {
+			return nil, fmt.Errorf("error exporting policy setting %s: %s", live.Turbot.Id, err.Error())
+		}
+		key := live.Turbot.ResourceId
+		if len(live.Resource.Akas) > 0 {
+			key = live.Resource.Akas[0]
+		}
+		file.Resources[key] = append(file.Resources[key], setting)
+	}
+	for _, group := range file.Resources {
+		sort.Slice(group, func(i, j int) bool { return group[i].PolicyType < group[j].PolicyType })
+	}
+	return file, nil
+}
+
+// exportSetting returns the declarative form of a live setting. Calculated settings keep their template, and the
+// values of secret policy types, which are not returned by the API, are replaced by a reference to an
+// environment variable, named by secretEnvName.
+func exportSetting(live apiClient.PolicySetting) (PolicySetting, error) {
+	setting := PolicySetting{
+		PolicyType: live.Type.Uri,
+		Note:       live.Note,
+	}
+	if live.Precedence != defaultPrecedence {
+		setting.Precedence = live.Precedence
+	}
+	switch {
+	case live.Template != "":
+		templateInput, err := helpers.InterfaceToStringOrYaml(live.TemplateInput)
+		if err != nil {
+			return setting, err
+		}
+		setting.Template = live.Template
+		setting.TemplateInput = templateInput
+	case live.Type.Secret:
+		setting.SecretRef = "env:" + secretEnvName(live.Turbot.Id)
+	default:
+		setting.Value = helpers.NormalizeYamlValue(live.Value)
+	}
+	return setting, nil
+}
+
+// secretEnvName returns the name of the environment variable referenced by an exported secret setting
+func secretEnvName(settingID string) string {
+	return "TURBOT_SECRET_" + settingID
+}
+
+// writePolicyFile writes a policy file as YAML
+func writePolicyFile(file *PolicyFile, out io.Writer) error {
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
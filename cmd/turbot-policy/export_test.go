@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/clitest"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	client := newTestClient(t)
+
+	file, err := exportPolicyFile(client, rootResource)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, file.Resources["arn:aws:::123456789012"], 2)
+	assert.Len(t, file.Resources["arn:aws:s3:::other-bucket"], 1)
+	template := file.Resources["arn:aws:::123456789012"][0]
+	assert.Equal(t, "tmod:@turbot/aws-s3#/policy/types/bucketTagsTemplate", template.PolicyType)
+	assert.Equal(t, "{ resource { turbot { tags } } }", template.TemplateInput)
+	assert.Equal(t, "RECOMMENDED", template.Precedence)
+
+	// a subtree only has the settings of its resources
+	bucket, err := exportPolicyFile(client, "arn:aws:s3:::other-bucket")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, bucket.Resources, 1)
+
+	// applying the exported file changes nothing
+	var out bytes.Buffer
+	if !assert.NoError(t, writePolicyFile(file, &out)) {
+		return
+	}
+	loaded, err := loadPolicyFile(clitest.WriteFile(t, "policies.yaml", out.String()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	plan, err := computePlan(client, loaded)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, PlanSummary{NoOp: 3}, plan.Summary)
+}
+
+func TestExportSecret(t *testing.T) {
+	live := apiClient.PolicySetting{Value: "<redacted>", Precedence: "REQUIRED"}
+	live.Type.Uri = "tmod:@turbot/aws#/policy/types/accountSecret"
+	live.Type.Secret = true
+	live.Turbot.Id = "9100"
+	setting, err := exportSetting(live)
+	assert.NoError(t, err)
+	assert.Equal(t, PolicySetting{PolicyType: live.Type.Uri, SecretRef: "env:TURBOT_SECRET_9100"}, setting)
+
+	// a secret which is not set is left unchanged, but can be compared once it is
+	setting.Resource = "arn:aws:::123456789012"
+	setting.Precedence = defaultPrecedence
+	current := &SettingState{Precedence: defaultPrecedence, secret: "s3cret"}
+	desired, err := desiredState(setting)
+	assert.NoError(t, err)
+	fields, err := changedFields(current, desired)
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+	assert.NotContains(t, settingInput(desired), "value")
+
+	t.Setenv("TURBOT_SECRET_9100", "changed")
+	desired, err = desiredState(setting)
+	assert.NoError(t, err)
+	fields, err = changedFields(current, desired)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"value"}, fields)
+	assert.Equal(t, "changed", settingInput(desired)["value"])
+}
@@ -0,0 +1,136 @@
+// Command turbot-policy manages Turbot policy settings as code. It compares a declarative YAML file of policy
+// settings with the workspace, and creates, updates and deletes settings to match it. The export command
+// writes the file for the settings already in a workspace.
+//
+// Usage:
+//
+//	turbot-policy plan [-profile name] [-json] file.yaml
+//	turbot-policy apply [-profile name] [-json] [-dry-run] file.yaml
+//	turbot-policy export [-profile name] [-output file.yaml] [resource]
+//
+// See PolicyFile for the format of the file. Credentials are read from the profile if given, otherwise the
+// same way as for a connection with no credentials set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/cli"
+)
+
+const usage = `usage:
+  turbot-policy plan [-profile name] [-json] file.yaml
+  turbot-policy apply [-profile name] [-json] [-dry-run] file.yaml
+  turbot-policy export [-profile name] [-output file.yaml] [resource]`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	if command == "export" {
+		exportMain()
+		return
+	}
+	if command != "plan" && command != "apply" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	profile := flags.String("profile", "", "Turbot CLI profile to read credentials from")
+	jsonOutput := flags.Bool("json", false, "write the plan as JSON")
+	dryRun := false
+	if command == "apply" {
+		flags.BoolVar(&dryRun, "dry-run", false, "show the plan without applying it")
+	}
+	_ = flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(flags.Arg(0), *profile, *jsonOutput, command == "apply" && !dryRun); err != nil {
+		cli.Exit("turbot-policy", err)
+	}
+}
+
+func run(path, profile string, jsonOutput, apply bool) error {
+	file, err := loadPolicyFile(path)
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(profile)
+	if err != nil {
+		return err
+	}
+	plan, err := computePlan(client, file)
+	if err != nil {
+		return err
+	}
+
+	return cli.PlanAndApply(plan, jsonOutput, apply, func(out io.Writer) { writePlan(plan, out) }, func(progress io.Writer) error {
+		return applyPlan(client, plan, progress)
+	})
+}
+
+func exportMain() {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	profile := flags.String("profile", "", "Turbot CLI profile to read credentials from")
+	output := flags.String("output", "", "path of the policy file to write, instead of stdout")
+	_ = flags.Parse(os.Args[2:])
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	resource := rootResource
+	if flags.NArg() == 1 {
+		resource = flags.Arg(0)
+	}
+
+	if err := export(resource, *profile, *output); err != nil {
+		cli.Exit("turbot-policy", err)
+	}
+}
+
+func export(resource, profile, output string) error {
+	client, err := cli.Connect(profile)
+	if err != nil {
+		return err
+	}
+	file, err := exportPolicyFile(client, resource)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		return writePolicyFile(file, os.Stdout)
+	}
+
+	// write to a temporary file, so a failed export does not leave a partial file
+	tmp := output + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	err = writePolicyFile(file, out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, output); err != nil {
+		return err
+	}
+	count := 0
+	for _, settings := range file.Resources {
+		count += len(settings)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d policy settings to %s\n", count, output)
+	return nil
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/helpers"
+)
+
+// Action is the change planned for a policy setting
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+	ActionNone   Action = "no-op"
+)
+
+// Plan is the changes needed to bring the workspace to the state of a policy file
+type Plan struct {
+	Changes []Change    `json:"changes"`
+	Summary PlanSummary `json:"summary"`
+}
+
+type PlanSummary struct {
+	Create int `json:"create"`
+	Update int `json:"update"`
+	Delete int `json:"delete"`
+	NoOp   int `json:"no_op"`
+}
+
+// Change is the change planned for the setting of a policy type on a resource
+type Change struct {
+	Action     Action        `json:"action"`
+	PolicyType string        `json:"policy_type"`
+	Resource   string        `json:"resource"`
+	ResourceID string        `json:"resource_id,omitempty"`
+	SettingID  string        `json:"setting_id,omitempty"`
+	Before     *SettingState `json:"before,omitempty"`
+	After      *SettingState `json:"after,omitempty"`
+	// the fields which differ, for an update
+	Fields []string `json:"fields,omitempty"`
+}
+
+// SettingState is the state of a policy setting, as shown in a plan
+type SettingState struct {
+	Value         interface{} `json:"value,omitempty"`
+	SecretRef     string      `json:"secret_ref,omitempty"`
+	Template      string      `json:"template,omitempty"`
+	TemplateInput string      `json:"template_input,omitempty"`
+	Precedence    string      `json:"precedence"`
+	Note          string      `json:"note,omitempty"`
+
+	// the value of a secret, which is not shown in the plan, and whether the secret reference could not be
+	// resolved, in which case the value is unknown
+	secret        interface{}
+	secretUnknown bool
+}
+
+// computePlan compares the settings of the policy file with those in the workspace
+func computePlan(client *apiClient.Client, file *PolicyFile) (*Plan, error) {
+	resourceIDs, err := resolveResources(client, file)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Changes: []Change{}}
+	for _, desired := range file.PolicySettings {
+		change := Change{
+			Action:     ActionNone,
+			PolicyType: desired.PolicyType,
+			Resource:   desired.Resource,
+			ResourceID: resourceIDs[desired.Resource],
+		}
+		if !desired.Delete {
+			if change.After, err = desiredState(desired); err != nil {
+				return nil, err
+			}
+		}
+
+		if change.ResourceID == "" {
+			// a setting cannot exist on a resource which does not
+			if !desired.Delete {
+				return nil, fmt.Errorf("resource not found: %s", desired.Resource)
+			}
+			plan.add(change)
+			continue
+		}
+
+		live, err := client.FindPolicySetting(desired.PolicyType, change.ResourceID)
+		if err != nil {
+			return nil, err
+		}
+		exists := live.Turbot.Id != ""
+		if exists {
+			change.SettingID = live.Turbot.Id
+			change.Before = liveState(live)
+			if desired.SecretRef != "" {
+				// the live value is as secret as the desired one
+				change.Before.secret, change.Before.Value = change.Before.Value, nil
+			}
+		}
+
+		switch {
+		case desired.Delete && exists:
+			change.Action = ActionDelete
+		case desired.Delete:
+		case !exists:
+			if change.After.secretUnknown {
+				return nil, fmt.Errorf("cannot create %s on %s: secret_ref %s is not set", desired.PolicyType, desired.Resource, desired.SecretRef)
+			}
+			change.Action = ActionCreate
+		default:
+			change.Fields, err = changedFields(change.Before, change.After)
+			if err != nil {
+				return nil, fmt.Errorf("error comparing the setting of %s on %s: %s", desired.PolicyType, desired.Resource, err.Error())
+			}
+			if len(change.Fields) > 0 {
+				change.Action = ActionUpdate
+			}
+		}
+		plan.add(change)
+	}
+	return plan, nil
+}
+
+// resolveResources returns the ids of the resources of the policy file, keyed by the aka used in the file.
+// Resources which do not exist are omitted.
+func resolveResources(client *apiClient.Client, file *PolicyFile) (map[string]string, error) {
+	var akas []string
+	seen := map[string]bool{}
+	for _, setting := range file.PolicySettings {
+		if !seen[setting.Resource] {
+			seen[setting.Resource] = true
+			akas = append(akas, setting.Resource)
+		}
+	}
+	items, err := client.Batcher("resource", "turbot { id }").GetAll(akas)
+	if err != nil {
+		return nil, fmt.Errorf("error reading resources: %s", err.Error())
+	}
+	ids := map[string]string{}
+	for aka, data := range items {
+		var resource struct {
+			Turbot struct {
+				Id string
+			}
+		}
+		if err := json.Unmarshal(data, &resource); err != nil {
+			return nil, err
+		}
+		ids[aka] = resource.Turbot.Id
+	}
+	return ids, nil
+}
+
+func (p *Plan) add(change Change) {
+	p.Changes = append(p.Changes, change)
+	switch change.Action {
+	case ActionCreate:
+		p.Summary.Create++
+	case ActionUpdate:
+		p.Summary.Update++
+	case ActionDelete:
+		p.Summary.Delete++
+	default:
+		p.Summary.NoOp++
+	}
+}
+
+func desiredState(setting PolicySetting) (*SettingState, error) {
+	state := &SettingState{
+		Value:         setting.Value,
+		SecretRef:     setting.SecretRef,
+		Template:      setting.Template,
+		TemplateInput: setting.TemplateInput,
+		Precedence:    setting.Precedence,
+		Note:          setting.Note,
+	}
+	if setting.SecretRef != "" {
+		value, ok, err := resolveSecretRef(setting.SecretRef)
+		if err != nil {
+			return nil, fmt.Errorf("error reading secret_ref of %s on %s: %s", setting.PolicyType, setting.Resource, err.Error())
+		}
+		state.secret, state.secretUnknown = value, !ok
+	}
+	return state, nil
+}
+
+func liveState(setting apiClient.PolicySetting) *SettingState {
+	templateInput, _ := helpers.InterfaceToStringOrYaml(setting.TemplateInput)
+	state := &SettingState{
+		Template:      setting.Template,
+		TemplateInput: templateInput,
+		Precedence:    setting.Precedence,
+		Note:          setting.Note,
+	}
+	if setting.Template == "" {
+		state.Value = setting.Value
+	}
+	return state
+}
+
+// value returns the value of the state, which is its secret if it has one
+func (s *SettingState) value() interface{} {
+	if s.SecretRef != "" || s.secret != nil {
+		return s.secret
+	}
+	return s.Value
+}
+
+// changedFields returns the fields of the live state which differ from the desired state. Values and template
+// inputs are compared as YAML, ignoring formatting differences, and template inputs which are not YAML as text.
+// An unknown secret is assumed to be unchanged.
+func changedFields(live, desired *SettingState) ([]string, error) {
+	var fields []string
+	if desired.Template == "" {
+		equal := live.Template == ""
+		if equal && !desired.secretUnknown {
+			liveValue, err := helpers.InterfaceToStringOrYaml(live.value())
+			if err != nil {
+				return nil, err
+			}
+			desiredValue, err := helpers.InterfaceToStringOrYaml(desired.value())
+			if err != nil {
+				return nil, err
+			}
+			if equal, err = helpers.YamlStringsAreEqual(liveValue, desiredValue); err != nil {
+				return nil, err
+			}
+		}
+		if !equal {
+			if desired.secretUnknown {
+				return nil, fmt.Errorf("secret_ref %s is not set", desired.SecretRef)
+			}
+			fields = append(fields, "value")
+		}
+	} else {
+		if strings.TrimSpace(live.Template) != strings.TrimSpace(desired.Template) {
+			fields = append(fields, "template")
+		}
+		// a template input is a GraphQL query, or a YAML list of them
+		equal, err := helpers.YamlStringsAreEqual(live.TemplateInput, desired.TemplateInput)
+		if err != nil {
+			equal = strings.TrimSpace(live.TemplateInput) == strings.TrimSpace(desired.TemplateInput)
+		}
+		if !equal {
+			fields = append(fields, "template_input")
+		}
+	}
+	if !strings.EqualFold(live.Precedence, desired.Precedence) {
+		fields = append(fields, "precedence")
+	}
+	if live.Note != desired.Note {
+		fields = append(fields, "note")
+	}
+	return fields, nil
+}
+
+// applyPlan makes the changes of the plan, stopping at the first which fails
+func applyPlan(client *apiClient.Client, plan *Plan, out io.Writer) error {
+	for _, change := range plan.Changes {
+		var err error
+		switch change.Action {
+		case ActionCreate:
+			input := settingInput(change.After)
+			input["type"] = change.PolicyType
+			input["resource"] = change.ResourceID
+			var created *apiClient.PolicySetting
+			if created, err = client.CreatePolicySetting(input); err == nil {
+				fmt.Fprintf(out, "Created %s on %s (%s)\n", change.PolicyType, change.Resource, created.Turbot.Id)
+			}
+		case ActionUpdate:
+			input := settingInput(change.After)
+			input["id"] = change.SettingID
+			if _, err = client.UpdatePolicySetting(input); err == nil {
+				fmt.Fprintf(out, "Updated %s on %s (%s)\n", change.PolicyType, change.Resource, change.SettingID)
+			}
+		case ActionDelete:
+			if err = client.DeletePolicySetting(change.SettingID); err == nil {
+				fmt.Fprintf(out, "Deleted %s on %s (%s)\n", change.PolicyType, change.Resource, change.SettingID)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to %s %s on %s: %s", change.Action, change.PolicyType, change.Resource, err.Error())
+		}
+	}
+	return nil
+}
+
+// settingInput returns the input of a create or update mutation setting the state
+func settingInput(state *SettingState) map[string]interface{} {
+	input := map[string]interface{}{
+		"precedence": state.Precedence,
+		"note":       state.Note,
+	}
+	if state.Template != "" {
+		input["template"] = state.Template
+		input["templateInput"] = state.TemplateInput
+	} else if !state.secretUnknown {
+		input["value"] = state.value()
+	}
+	return input
+}
+
+// writePlan writes a line for each policy setting to create, update or delete, with the fields which change, and
+// the counts of each action
+func writePlan(plan *Plan, out io.Writer) {
+	symbols := map[Action]string{ActionCreate: "+", ActionUpdate: "~", ActionDelete: "-"}
+	for _, change := range plan.Changes {
+		if change.Action == ActionNone {
+			continue
+		}
+		line := fmt.Sprintf("%s %s %s on %s", symbols[change.Action], change.Action, change.PolicyType, change.Resource)
+		if len(change.Fields) > 0 {
+			line += fmt.Sprintf(" (%s)", strings.Join(change.Fields, ", "))
+		}
+		fmt.Fprintln(out, line)
+	}
+	fmt.Fprintf(out, "Plan: %d to create, %d to update, %d to delete, %d unchanged.\n", plan.Summary.Create, plan.Summary.Update, plan.Summary.Delete, plan.Summary.NoOp)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/clitest"
+)
+
+const testPolicyFile = `
+policy_settings:
+  # unchanged
+  - policy_type: tmod:@turbot/aws-s3#/policy/types/bucketVersioning
+    resource: arn:aws:::123456789012
+    value: "Check: Enabled"
+    note: Require versioning for all buckets.
+  # created
+  - policy_type: tmod:@turbot/aws-s3#/policy/types/bucketVersioning
+    resource: arn:aws:s3:::my-bucket
+    value: "Enforce: Enabled"
+  # updated, from RECOMMENDED
+  - policy_type: tmod:@turbot/aws-s3#/policy/types/bucketTagsTemplate
+    resource: arn:aws:::123456789012
+    template: "{{ $.resource.turbot.tags | dump }}"
+    template_input: "{ resource { turbot { tags } } }"
+  # deleted
+  - policy_type: tmod:@turbot/aws-s3#/policy/types/bucketVersioning
+    resource: arn:aws:s3:::other-bucket
+    delete: true
+  # already absent
+  - policy_type: tmod:@turbot/aws-s3#/policy/types/bucketVersioning
+    resource: arn:aws:s3:::missing-bucket
+    delete: true
+`
+
+func newTestClient(t *testing.T) *apiClient.Client {
+	return clitest.NewClient(t, clitest.LoadStore(t, "../../turbot/testdata"))
+}
+
+func TestPlanAndApply(t *testing.T) {
+	file, err := loadPolicyFile(clitest.WriteFile(t, "policies.yaml", testPolicyFile))
+	if !assert.NoError(t, err) {
+		return
+	}
+	client := newTestClient(t)
+
+	plan, err := computePlan(client, file)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var actions []Action
+	for _, change := range plan.Changes {
+		actions = append(actions, change.Action)
+	}
+	assert.Equal(t, []Action{ActionNone, ActionCreate, ActionUpdate, ActionDelete, ActionNone}, actions)
+	assert.Equal(t, []string{"precedence"}, plan.Changes[2].Fields)
+	assert.Equal(t, "9002", plan.Changes[3].SettingID)
+	assert.Equal(t, PlanSummary{Create: 1, Update: 1, Delete: 1, NoOp: 2}, plan.Summary)
+
+	var out bytes.Buffer
+	writePlan(plan, &out)
+	assert.Contains(t, out.String(), "~ update tmod:@turbot/aws-s3#/policy/types/bucketTagsTemplate on arn:aws:::123456789012 (precedence)")
+	assert.Contains(t, out.String(), "Plan: 1 to create, 1 to update, 1 to delete, 2 unchanged.")
+
+	// once applied, the workspace matches the file
+	out.Reset()
+	assert.NoError(t, applyPlan(client, plan, &out))
+	assert.Contains(t, out.String(), "Deleted tmod:@turbot/aws-s3#/policy/types/bucketVersioning on arn:aws:s3:::other-bucket (9002)")
+	plan, err = computePlan(client, file)
+	assert.NoError(t, err)
+	assert.Equal(t, PlanSummary{NoOp: 5}, plan.Summary)
+}
+
+func TestLoadPolicyFileErrors(t *testing.T) {
+	tests := map[string]string{
+		"policy_settings:\n  - resource: arn:a\n    value: 1\n":                                                                       "policy_type is required",
+		"policy_settings:\n  - policy_type: t\n    resource: arn:a\n":                                                                 "one of value, template and secret_ref is required",
+		"policy_settings:\n  - policy_type: t\n    resource: arn:a\n    value: 1\n    template: x\n":                                  "only one of value, template and secret_ref",
+		"policy_settings:\n  - policy_type: t\n    resource: arn:a\n    value: 1\n    precedence: must\n":                             "precedence must be",
+		"policy_settings:\n  - policy_type: t\n    resource: arn:a\n    value: 1\n    owner: me\n":                                    "field owner not found",
+		"policy_settings:\n  - {policy_type: t, resource: arn:a, value: 1}\n  - {policy_type: t, resource: arn:a, delete: true}\n":    "duplicates policy_settings[0]",
+		"policy_settings:\n  - policy_type: t\n    resource: arn:a\n    secret_ref: vault:x\n":                                        "secret_ref must be env:NAME or file:path",
+		"resources:\n  arn:a:\n    - {policy_type: t, resource: arn:b, value: 1}\n":                                                   "resources[arn:a][0]: resource cannot be set",
+		"policy_settings:\n  - {policy_type: t, resource: arn:a, value: 1}\nresources:\n  arn:a:\n    - {policy_type: t, value: 2}\n": "resources[arn:a][0]: duplicates policy_settings[0]",
+	}
+	for content, expected := range tests {
+		_, err := loadPolicyFile(clitest.WriteFile(t, "policies.yaml", content))
+		assert.ErrorContains(t, err, expected)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Row is a row of the edit file: the id of a resource, and the properties of its data to set. Edit files are
+// the output of a query, as CSV with a header row naming the columns, e.g.
+//
+//	id,title,description
+//	1004,Billing,Accounts of the billing team
+//	1005,,Accounts of the data team
+//
+// or JSON, a list of objects or the output of steampipe query --output json, e.g.
+//
+//	[{"id": 1004, "title": "Billing", "description": "Accounts of the billing team"}]
+//
+// Every column other than id is a property. An empty CSV cell leaves the property unchanged, and a JSON null
+// removes it. CSV values are converted to the type of the current value of the property, so numbers, booleans
+// and JSON objects and arrays can be set.
+type Row struct {
+	// the number of the row in the file, from 1
+	Number     int
+	ID         string
+	Properties map[string]interface{}
+	// whether the properties are CSV strings, to be converted to the type of the current value
+	raw bool
+}
+
+// loadRows reads and validates a CSV or JSON edit file, as given by its extension
+func loadRows(path string) ([]Row, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rows []Row
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		rows, err = parseRowsCSV(data)
+	case ".json":
+		rows, err = parseRowsJSON(data)
+	default:
+		return nil, fmt.Errorf("%s: edit files must be .csv or .json", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err.Error())
+	}
+
+	seen := map[string]int{}
+	for _, row := range rows {
+		if row.ID == "" {
+			return nil, fmt.Errorf("%s: row %d: id is required", path, row.Number)
+		}
+		if n, ok := seen[row.ID]; ok {
+			return nil, fmt.Errorf("%s: row %d: duplicates row %d", path, row.Number, n)
+		}
+		seen[row.ID] = row.Number
+	}
+	return rows, nil
+}
+
+// parseRowsCSV parses the rows of a CSV edit file, whose first row names the columns
+func parseRowsCSV(data []byte) ([]Row, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateColumns(header); err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := Row{Number: len(rows) + 1, Properties: map[string]interface{}{}, raw: true}
+		for i, value := range record {
+			if header[i] == "id" {
+				row.ID = strings.TrimSpace(value)
+			} else if value != "" {
+				row.Properties[header[i]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// parseRowsJSON parses the rows of a JSON edit file, a list of objects or an object with a list of rows
+func parseRowsJSON(data []byte) ([]Row, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if output, ok := value.(map[string]interface{}); ok {
+		value = output["rows"]
+	}
+	objects, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list of rows")
+	}
+
+	var rows []Row
+	for i, object := range objects {
+		properties, ok := object.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("row %d: expected an object", i+1)
+		}
+		var columns []string
+		for column := range properties {
+			columns = append(columns, column)
+		}
+		if err := validateColumns(columns); err != nil {
+			return nil, fmt.Errorf("row %d: %s", i+1, err.Error())
+		}
+		row := Row{Number: i + 1, Properties: properties}
+		switch id := properties["id"].(type) {
+		case string:
+			row.ID = strings.TrimSpace(id)
+		case json.Number:
+			row.ID = id.String()
+		case nil:
+		default:
+			return nil, fmt.Errorf("row %d: id must be a string or number", i+1)
+		}
+		delete(properties, "id")
+		for name, value := range properties {
+			properties[name] = jsonValue(value)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// validateColumns checks the columns of the edit file name an id and at least one property
+func validateColumns(columns []string) error {
+	hasID := false
+	for i, column := range columns {
+		columns[i] = strings.TrimSpace(column)
+		switch columns[i] {
+		case "":
+			return fmt.Errorf("column %d has no name", i+1)
+		case "id":
+			hasID = true
+		case "turbot":
+			return fmt.Errorf("the turbot column cannot be edited")
+		}
+	}
+	if !hasID {
+		return fmt.Errorf("an id column is required")
+	}
+	if len(columns) < 2 {
+		return fmt.Errorf("at least one property column is required")
+	}
+	return nil
+}
+
+// jsonValue converts the numbers of a decoded JSON value to int64 or float64, as they are sent to the API
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, element := range v {
+			v[key] = jsonValue(element)
+		}
+	case []interface{}:
+		for i, element := range v {
+			v[i] = jsonValue(element)
+		}
+	}
+	return value
+}
+
+// convertCSVValue converts a CSV value to the type of the current value of the property. Values of new
+// properties are strings.
+func convertCSVValue(value string, current interface{}) (interface{}, error) {
+	switch current.(type) {
+	case nil, string:
+		return value, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	var converted interface{}
+	if err := decoder.Decode(&converted); err != nil || decoder.More() {
+		return nil, fmt.Errorf("%q is not a valid %s", value, typeName(current))
+	}
+	converted = jsonValue(converted)
+	if typeName(converted) != typeName(current) {
+		return nil, fmt.Errorf("%q is not a valid %s", value, typeName(current))
+	}
+	return converted, nil
+}
+
+// typeName returns the JSON type of a value
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "boolean"
+	case int, int64, float64, json.Number:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case nil:
+		return "null"
+	}
+	return "string"
+}
@@ -0,0 +1,70 @@
+// Command turbot-resource-edit bulk updates the data of Turbot managed resources, e.g. folders and smart
+// folders, from the output of a query. Each row of the CSV or JSON file gives the id of a resource and the
+// properties to set. Rows are checked against the update schema of the resource type before any change is
+// made, and the plan of the changes to each resource is printed.
+//
+// Usage:
+//
+//	turbot-resource-edit plan [-profile name] [-json] edits.csv
+//	turbot-resource-edit apply [-profile name] [-json] [-dry-run] edits.csv
+//
+// See Row for the format of the file. Credentials are read from the profile if given, otherwise the same way
+// as for a connection with no credentials set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/cli"
+)
+
+const usage = `usage:
+  turbot-resource-edit plan [-profile name] [-json] edits.csv
+  turbot-resource-edit apply [-profile name] [-json] [-dry-run] edits.csv`
+
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "plan" && os.Args[1] != "apply") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	profile := flags.String("profile", "", "Turbot CLI profile to read credentials from")
+	jsonOutput := flags.Bool("json", false, "write the plan as JSON")
+	dryRun := false
+	if command == "apply" {
+		flags.BoolVar(&dryRun, "dry-run", false, "show the plan without applying it")
+	}
+	_ = flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(flags.Arg(0), *profile, *jsonOutput, command == "apply" && !dryRun); err != nil {
+		cli.Exit("turbot-resource-edit", err)
+	}
+}
+
+func run(path, profile string, jsonOutput, apply bool) error {
+	rows, err := loadRows(path)
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(profile)
+	if err != nil {
+		return err
+	}
+	plan, err := computePlan(client, rows)
+	if err != nil {
+		return err
+	}
+
+	return cli.PlanAndApply(plan, jsonOutput, apply, func(out io.Writer) { writePlan(plan, out) }, func(progress io.Writer) error {
+		return applyPlan(client, plan, progress)
+	})
+}
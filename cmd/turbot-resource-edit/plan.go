@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/errors"
+	"github.com/turbot/steampipe-plugin-turbot/helpers"
+)
+
+// Action is the change planned for a row of the edit file
+type Action string
+
+const (
+	ActionUpdate Action = "update"
+	ActionRefuse Action = "refuse"
+	ActionNone   Action = "no-op"
+)
+
+// the prefix of the resource types of the @turbot/turbot mod, e.g. folders and smart folders. Other resources
+// are discovered from the cloud by their mods, so edits to them would be overwritten.
+const turbotTypePrefix = "tmod:@turbot/turbot#/resource/types/"
+
+// Plan is the changes needed to set the properties of the rows of the edit file
+type Plan struct {
+	Changes []Change    `json:"changes"`
+	Summary PlanSummary `json:"summary"`
+}
+
+type PlanSummary struct {
+	Update int `json:"update"`
+	Refuse int `json:"refuse"`
+	NoOp   int `json:"no_op"`
+}
+
+// Change is the change planned for the resource of a row
+type Change struct {
+	Action Action `json:"action"`
+	Row    int    `json:"row"`
+	ID     string `json:"id"`
+	Title  string `json:"title,omitempty"`
+	Type   string `json:"type,omitempty"`
+	// the properties changed on update
+	Fields map[string]FieldChange `json:"fields,omitempty"`
+	// why the change is refused
+	Reasons []string `json:"reasons,omitempty"`
+
+	// the data of the updated resource
+	data map[string]interface{}
+}
+
+// FieldChange is the change of a property. From is nil for a property which is not set, and To is nil for a
+// property which is removed.
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// schemas reads the update schemas of resource types, caching them by type
+type schemas struct {
+	client  *apiClient.Client
+	schemas map[string]*updateSchema
+}
+
+// updateSchema is the properties of a resource type which can be updated, and those its update schema excludes
+type updateSchema struct {
+	allowed  map[string]bool
+	excluded []string
+	// whether the schema allows properties it does not list
+	open bool
+}
+
+// updateSchema returns the update schema of the type of a resource
+func (s *schemas) updateSchema(resource *apiClient.Resource) (*updateSchema, error) {
+	if schema, ok := s.schemas[resource.Type.Uri]; ok {
+		return schema, nil
+	}
+	m, err := s.client.ReadUpdateSchema(resource.Turbot.Id)
+	if err != nil {
+		return nil, err
+	}
+	schema := &updateSchema{allowed: map[string]bool{}}
+	schema.addProperties(m)
+	if allOf, ok := m["allOf"].([]interface{}); ok {
+		for _, object := range allOf {
+			if object, ok := object.(map[string]interface{}); ok && object["type"] == "object" {
+				schema.addProperties(object)
+				// as BuildPropertiesFromUpdateSchema, the null properties of the object schemas are excluded
+				schema.excluded = append(schema.excluded, helpers.GetNullProperties(object)...)
+			}
+		}
+	}
+	s.schemas[resource.Type.Uri] = schema
+	return schema, nil
+}
+
+// addProperties adds the properties an object schema allows to be set
+func (u *updateSchema) addProperties(object map[string]interface{}) {
+	if object["additionalProperties"] == true {
+		u.open = true
+	}
+	for _, name := range helpers.GetSchemaProperties(object) {
+		u.allowed[name] = true
+	}
+}
+
+// computePlan compares the rows of the edit file with the data of their resources. Rows are refused if their
+// resource is not found or not managed by Turbot, or if they set a property its update schema excludes or does
+// not have.
+func computePlan(client *apiClient.Client, rows []Row) (*Plan, error) {
+	plan := &Plan{Changes: []Change{}}
+	schemas := &schemas{client: client, schemas: map[string]*updateSchema{}}
+	for _, row := range rows {
+		change, err := planChange(client, schemas, row)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %s", row.Number, err.Error())
+		}
+		plan.add(change)
+	}
+	return plan, nil
+}
+
+// planChange plans the change of the resource of a row
+func planChange(client *apiClient.Client, schemas *schemas, row Row) (Change, error) {
+	change := Change{Action: ActionNone, Row: row.Number, ID: row.ID}
+	resource, err := client.ReadFullResource(row.ID)
+	if err != nil {
+		if errors.NotFoundError(err) {
+			return change.refuse("resource not found"), nil
+		}
+		return change, err
+	}
+	change.Title, change.Type = resource.Turbot.Title, resource.Type.Uri
+	if !strings.HasPrefix(resource.Type.Uri, turbotTypePrefix) {
+		return change.refuse(fmt.Sprintf("%s is not a resource type managed by Turbot", resource.Type.Uri)), nil
+	}
+	schema, err := schemas.updateSchema(resource)
+	if err != nil {
+		return change, err
+	}
+
+	// the update sets the whole data of the resource, so it is the current data, less the properties the
+	// update schema excludes, with the properties of the row
+	data := map[string]interface{}{}
+	for name, value := range resource.Data {
+		data[name] = value
+	}
+	for _, name := range schema.excluded {
+		if _, ok := row.Properties[name]; ok {
+			change.Reasons = append(change.Reasons, fmt.Sprintf("%s cannot be updated", name))
+		}
+		delete(data, name)
+	}
+	fields := map[string]FieldChange{}
+	for name, value := range row.Properties {
+		// properties unknown to the update schema, e.g. misspelt, are refused rather than written to the data
+		if !schema.allowed[name] && !schema.open {
+			if !contains(schema.excluded, name) {
+				change.Reasons = append(change.Reasons, fmt.Sprintf("%s is not a property of %s", name, resource.Type.Uri))
+			}
+			continue
+		}
+		current := resource.Data[name]
+		if row.raw {
+			if value, err = convertCSVValue(value.(string), current); err != nil {
+				change.Reasons = append(change.Reasons, fmt.Sprintf("%s: %s", name, err.Error()))
+				continue
+			}
+		}
+		if value == nil {
+			delete(data, name)
+		} else {
+			data[name] = value
+		}
+		if !equal(current, value) {
+			fields[name] = FieldChange{From: current, To: value}
+		}
+	}
+	if len(change.Reasons) > 0 {
+		sort.Strings(change.Reasons)
+		change.Action = ActionRefuse
+		return change, nil
+	}
+	if len(fields) > 0 {
+		change.Action = ActionUpdate
+		change.Fields = fields
+		change.data = data
+	}
+	return change, nil
+}
+
+// contains returns whether the values include the value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Change) refuse(reason string) Change {
+	c.Action = ActionRefuse
+	c.Reasons = append(c.Reasons, reason)
+	return c
+}
+
+// equal returns whether two property values are the same JSON, since numbers read from the API are float64
+func equal(a, b interface{}) bool {
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return string(aJSON) == string(bJSON)
+}
+
+func (p *Plan) add(change Change) {
+	p.Changes = append(p.Changes, change)
+	switch change.Action {
+	case ActionUpdate:
+		p.Summary.Update++
+	case ActionRefuse:
+		p.Summary.Refuse++
+	default:
+		p.Summary.NoOp++
+	}
+}
+
+// applyPlan updates the resources of the plan, stopping at the first which fails. Refused changes are not
+// applied, and are returned as an error once the others are.
+func applyPlan(client *apiClient.Client, plan *Plan, out io.Writer) error {
+	for _, change := range plan.Changes {
+		if change.Action != ActionUpdate {
+			continue
+		}
+		if _, err := client.UpdateResource(map[string]interface{}{"id": change.ID, "data": change.data}); err != nil {
+			return fmt.Errorf("failed to update row %d (%s): %s", change.Row, change.ID, err.Error())
+		}
+		fmt.Fprintf(out, "Updated %s (%s)\n", change.ID, change.Title)
+	}
+	if plan.Summary.Refuse > 0 {
+		return fmt.Errorf("%d refused, see the plan", plan.Summary.Refuse)
+	}
+	return nil
+}
+
+// writePlan writes the changed fields of each resource to update, the reasons each refused row is refused, and the
+// counts of each action
+func writePlan(plan *Plan, out io.Writer) {
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case ActionUpdate:
+			var names []string
+			for name := range change.Fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			var fields []string
+			for _, name := range names {
+				field := change.Fields[name]
+				fields = append(fields, fmt.Sprintf("%s %s -> %s", name, formatValue(field.From), formatValue(field.To)))
+			}
+			fmt.Fprintf(out, "~ update %s (%s): %s\n", change.ID, change.Title, strings.Join(fields, ", "))
+		case ActionRefuse:
+			fmt.Fprintf(out, "! refuse row %d (%s): %s\n", change.Row, change.ID, strings.Join(change.Reasons, "; "))
+		}
+	}
+	fmt.Fprintf(out, "Plan: %d to update, %d refused, %d unchanged.\n", plan.Summary.Update, plan.Summary.Refuse, plan.Summary.NoOp)
+}
+
+// formatValue formats a property value as JSON, or unset if it is not set
+func formatValue(value interface{}) string {
+	if value == nil {
+		return "(unset)"
+	}
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(valueJSON)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/clitest"
+)
+
+// a folder, whose update schema excludes its read-only id property, a smart folder and an AWS bucket
+const testResources = `[
+	{"turbot": {"id": "178806", "akas": ["tmod:@turbot/turbot#/"], "path": "178806"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/turbot"}},
+	{"data": {"updateSchema": {"allOf": [{"type": "object", "properties": {"title": {"type": "string"}, "description": {"type": "string"}, "archived": {"type": "boolean"}, "folderId": {"type": "null"}}}]}}, "turbot": {"id": "201", "parentId": "178806", "path": "178806.201"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/resourceType"}},
+	{"data": {"updateSchema": {"type": "object", "properties": {"title": {"type": "string"}, "description": {"type": "string"}, "color": {"type": "string"}}}}, "turbot": {"id": "202", "parentId": "178806", "path": "178806.202"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/resourceType"}},
+	{"data": {"title": "Billing", "description": "Billing accounts", "folderId": "billing", "archived": false}, "turbot": {"id": "1004", "title": "Billing", "parentId": "178806", "path": "178806.1004", "resourceTypeId": "201"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/folder"}},
+	{"data": {"title": "Data", "description": "Data accounts", "folderId": "data"}, "turbot": {"id": "1005", "title": "Data", "parentId": "178806", "path": "178806.1005", "resourceTypeId": "201"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/folder"}},
+	{"data": {"title": "CIS", "description": "CIS controls", "color": "red"}, "turbot": {"id": "1006", "title": "CIS", "parentId": "178806", "path": "178806.1006", "resourceTypeId": "202"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/smartFolder"}},
+	{"data": {"Name": "logs"}, "turbot": {"id": "1007", "title": "logs", "parentId": "178806", "path": "178806.1007"}, "type": {"uri": "tmod:@turbot/aws-s3#/resource/types/bucket"}}
+]`
+
+func newTestClient(t *testing.T) *apiClient.Client {
+	return clitest.NewClient(t, clitest.NewStore(t, `{"resources": `+testResources+`}`))
+}
+
+func TestPlanAndApply(t *testing.T) {
+	rows, err := loadRows(clitest.WriteFile(t, "edits.csv", `id,description,archived
+1004,Accounts of the billing team,true
+1005,Data accounts,
+1006,,
+1007,Log buckets,
+9999,Missing,
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	client := newTestClient(t)
+
+	plan, err := computePlan(client, rows)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, PlanSummary{Update: 1, Refuse: 2, NoOp: 2}, plan.Summary)
+	var out bytes.Buffer
+	writePlan(plan, &out)
+	assert.Equal(t, `~ update 1004 (Billing): archived false -> true, description "Billing accounts" -> "Accounts of the billing team"
+! refuse row 4 (1007): tmod:@turbot/aws-s3#/resource/types/bucket is not a resource type managed by Turbot
+! refuse row 5 (9999): resource not found
+Plan: 1 to update, 2 refused, 2 unchanged.
+`, out.String())
+
+	// the changes which are not refused are applied, leaving the excluded properties out of the data
+	out.Reset()
+	assert.EqualError(t, applyPlan(client, plan, &out), "2 refused, see the plan")
+	assert.Equal(t, "Updated 1004 (Billing)\n", out.String())
+	resource, err := client.ReadFullResource("1004")
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{"title": "Billing", "description": "Accounts of the billing team", "archived": true}, resource.Data)
+	}
+
+	// JSON rows can remove properties, but not set those the update schema excludes or does not have
+	rows, err = loadRows(clitest.WriteFile(t, "edits.json", `{"rows": [
+		{"id": 1006, "color": null, "title": "CIS v2"},
+		{"id": "1005", "folderId": "other"},
+		{"id": "1004", "descripton": "Typo", "colour": null}
+	]}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	plan, err = computePlan(client, rows)
+	if !assert.NoError(t, err) {
+		return
+	}
+	out.Reset()
+	writePlan(plan, &out)
+	assert.Equal(t, `~ update 1006 (CIS): color "red" -> (unset), title "CIS" -> "CIS v2"
+! refuse row 2 (1005): folderId cannot be updated
+! refuse row 3 (1004): colour is not a property of tmod:@turbot/turbot#/resource/types/folder; descripton is not a property of tmod:@turbot/turbot#/resource/types/folder
+Plan: 1 to update, 2 refused, 0 unchanged.
+`, out.String())
+}
+
+func TestConvertCSVValue(t *testing.T) {
+	tests := []struct {
+		value    string
+		current  interface{}
+		expected interface{}
+		err      string
+	}{
+		{"true", false, true, ""},
+		{"12", float64(3), int64(12), ""},
+		{`{"a": 1}`, map[string]interface{}{}, map[string]interface{}{"a": int64(1)}, ""},
+		{"12", "3", "12", ""},
+		{"new", nil, "new", ""},
+		{"yes", false, nil, `"yes" is not a valid boolean`},
+		{"[1]", map[string]interface{}{}, nil, `"[1]" is not a valid object`},
+	}
+	for _, test := range tests {
+		converted, err := convertCSVValue(test.value, test.current)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, test.value)
+			continue
+		}
+		if assert.NoError(t, err, test.value) {
+			assert.Equal(t, test.expected, converted, test.value)
+		}
+	}
+}
+
+func TestLoadRowsErrors(t *testing.T) {
+	tests := []struct {
+		name, content, expected string
+	}{
+		{"edits.csv", "title,description\nBilling,Accounts\n", "an id column is required"},
+		{"edits.csv", "id\n1004\n", "at least one property column is required"},
+		{"edits.csv", "id,turbot\n1004,{}\n", "the turbot column cannot be edited"},
+		{"edits.csv", "id,title\n1004,Billing\n1004,Data\n", "row 2: duplicates row 1"},
+		{"edits.csv", "id,title\n,Billing\n", "row 1: id is required"},
+		{"edits.json", `[{"id": true, "title": "Billing"}]`, "row 1: id must be a string or number"},
+		{"edits.json", `{"title": "Billing"}`, "expected a list of rows"},
+		{"edits.yaml", "", "edit files must be .csv or .json"},
+	}
+	for _, test := range tests {
+		_, err := loadRows(clitest.WriteFile(t, test.name, test.content))
+		if assert.Error(t, err, test.content) {
+			assert.Contains(t, err.Error(), test.expected, test.content)
+		}
+	}
+}
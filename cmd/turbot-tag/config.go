@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+// Mapping is the canonical keys and values of tags, e.g.
+//
+//	tags:
+//	  - key: Environment
+//	    aliases: [env, environment_name]
+//	    values:
+//	      - value: Production
+//	        aliases: [prod, prd]
+//	      - value: Development
+//	        aliases: [dev]
+//
+// Keys and values match their canonical form and aliases regardless of case, so env, Env and ENVIRONMENT are all
+// normalized to Environment. Values which are not listed keep their value under the canonical key.
+type Mapping struct {
+	Tags []TagMapping `yaml:"tags"`
+}
+
+// TagMapping is the canonical key of a tag, with its aliases and canonical values
+type TagMapping struct {
+	Key     string         `yaml:"key"`
+	Aliases []string       `yaml:"aliases,omitempty"`
+	Values  []ValueMapping `yaml:"values,omitempty"`
+}
+
+// ValueMapping is a canonical value of a tag, with its aliases
+type ValueMapping struct {
+	Value   string   `yaml:"value"`
+	Aliases []string `yaml:"aliases,omitempty"`
+}
+
+// normalizer normalizes tag keys and values, by their lower case form
+type normalizer struct {
+	keys   map[string]string
+	values map[string]map[string]string
+}
+
+// loadMapping reads and validates a mapping, returning its normalizer
+func loadMapping(path string) (*normalizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mapping := &Mapping{}
+	if err = yaml.UnmarshalStrict(data, mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err.Error())
+	}
+	if len(mapping.Tags) == 0 {
+		return nil, fmt.Errorf("%s: no tags are mapped", path)
+	}
+
+	n := &normalizer{keys: map[string]string{}, values: map[string]map[string]string{}}
+	for i, tag := range mapping.Tags {
+		if err := n.add(tag); err != nil {
+			return nil, fmt.Errorf("%s: tags[%d]: %s", path, i, err.Error())
+		}
+	}
+	return n, nil
+}
+
+// add the keys and values of a tag mapping to the normalizer
+func (n *normalizer) add(tag TagMapping) error {
+	tag.Key = strings.TrimSpace(tag.Key)
+	if tag.Key == "" {
+		return fmt.Errorf("key is required")
+	}
+	for _, key := range append([]string{tag.Key}, tag.Aliases...) {
+		lower := strings.ToLower(strings.TrimSpace(key))
+		if existing, ok := n.keys[lower]; ok {
+			return fmt.Errorf("key %q is already mapped to %s", key, existing)
+		}
+		n.keys[lower] = tag.Key
+	}
+
+	values := map[string]string{}
+	for i, value := range tag.Values {
+		if value.Value == "" {
+			return fmt.Errorf("values[%d]: value is required", i)
+		}
+		for _, alias := range append([]string{value.Value}, value.Aliases...) {
+			lower := strings.ToLower(strings.TrimSpace(alias))
+			if existing, ok := values[lower]; ok {
+				return fmt.Errorf("values[%d]: value %q is already mapped to %s", i, alias, existing)
+			}
+			values[lower] = value.Value
+		}
+	}
+	n.values[tag.Key] = values
+	return nil
+}
+
+// normalize returns the canonical key and value of a tag, and false if its key is not mapped
+func (n *normalizer) normalize(key, value string) (string, string, bool) {
+	canonical, ok := n.keys[strings.ToLower(strings.TrimSpace(key))]
+	if !ok {
+		return "", "", false
+	}
+	if canonicalValue, ok := n.values[canonical][strings.ToLower(strings.TrimSpace(value))]; ok {
+		value = canonicalValue
+	}
+	return canonical, value, true
+}
@@ -0,0 +1,69 @@
+// Command turbot-tag normalizes the tags of the resources of a workspace, e.g. Env, env and environment, to the
+// canonical keys and values of a YAML mapping file. It compares the tags of the workspace with the mapping,
+// and updates the tags of each resource whose keys or values differ, removing the tags with other keys.
+//
+// Usage:
+//
+//	turbot-tag plan [-profile name] [-json] mapping.yaml
+//	turbot-tag apply [-profile name] [-json] [-dry-run] mapping.yaml
+//
+// See Mapping for the format of the file. Credentials are read from the profile if given, otherwise the same
+// way as for a connection with no credentials set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/cli"
+)
+
+const usage = `usage:
+  turbot-tag plan [-profile name] [-json] mapping.yaml
+  turbot-tag apply [-profile name] [-json] [-dry-run] mapping.yaml`
+
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "plan" && os.Args[1] != "apply") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	profile := flags.String("profile", "", "Turbot CLI profile to read credentials from")
+	jsonOutput := flags.Bool("json", false, "write the plan as JSON")
+	dryRun := false
+	if command == "apply" {
+		flags.BoolVar(&dryRun, "dry-run", false, "show the plan without applying it")
+	}
+	_ = flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(flags.Arg(0), *profile, *jsonOutput, command == "apply" && !dryRun); err != nil {
+		cli.Exit("turbot-tag", err)
+	}
+}
+
+func run(path, profile string, jsonOutput, apply bool) error {
+	normalizer, err := loadMapping(path)
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(profile)
+	if err != nil {
+		return err
+	}
+	plan, err := computePlan(client, normalizer)
+	if err != nil {
+		return err
+	}
+
+	return cli.PlanAndApply(plan, jsonOutput, apply, func(out io.Writer) { writePlan(plan, out) }, func(progress io.Writer) error {
+		return applyPlan(client, plan, progress)
+	})
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+)
+
+// Action is the change planned for the tags of a resource
+type Action string
+
+const (
+	ActionUpdate Action = "update"
+	ActionRefuse Action = "refuse"
+	ActionNone   Action = "no-op"
+)
+
+// Plan is the changes needed to normalize the mapped tags of the resources of the workspace
+type Plan struct {
+	Changes []Change    `json:"changes"`
+	Summary PlanSummary `json:"summary"`
+}
+
+type PlanSummary struct {
+	Update int `json:"update"`
+	Refuse int `json:"refuse"`
+	NoOp   int `json:"no_op"`
+}
+
+// Change is the change planned for the tags of a resource with mapped tags
+type Change struct {
+	Action   Action `json:"action"`
+	Resource string `json:"resource"`
+	// the tags changed on update, by key
+	Tags map[string]TagChange `json:"tags,omitempty"`
+	// why the change is refused
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// TagChange is the change of the value of a tag key. From is nil for a key which is added, and To is nil for a
+// key which is removed.
+type TagChange struct {
+	From *string `json:"from"`
+	To   *string `json:"to"`
+}
+
+// computePlan compares the tags of the workspace with their canonical keys and values. The tags of a resource
+// are refused if more than one of them normalizes to the same key with different values.
+func computePlan(client *apiClient.Client, n *normalizer) (*Plan, error) {
+	tags, err := client.ListTags("")
+	if err != nil {
+		return nil, err
+	}
+	// the mapped tags of each resource, by key
+	resources := map[string]map[string]string{}
+	for _, tag := range tags {
+		if _, _, ok := n.normalize(tag.Key, tag.Value); !ok {
+			continue
+		}
+		for _, item := range tag.Resources.Items {
+			id := item.Turbot.Id
+			if resources[id] == nil {
+				resources[id] = map[string]string{}
+			}
+			resources[id][tag.Key] = tag.Value
+		}
+	}
+
+	var ids []string
+	for id := range resources {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) < len(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+	plan := &Plan{Changes: []Change{}}
+	for _, id := range ids {
+		plan.add(planChange(n, id, resources[id]))
+	}
+	return plan, nil
+}
+
+// planChange plans the change of the mapped tags of a resource to their canonical keys and values
+func planChange(n *normalizer, id string, tags map[string]string) Change {
+	change := Change{Action: ActionNone, Resource: id}
+	desired := map[string]string{}
+	conflicts := map[string][]string{}
+	var keys []string
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		canonical, value, _ := n.normalize(key, tags[key])
+		if existing, ok := desired[canonical]; ok && existing != value {
+			if len(conflicts[canonical]) == 0 {
+				conflicts[canonical] = []string{existing}
+			}
+			conflicts[canonical] = append(conflicts[canonical], value)
+			continue
+		}
+		desired[canonical] = value
+	}
+	if len(conflicts) > 0 {
+		change.Action = ActionRefuse
+		for canonical, values := range conflicts {
+			sort.Strings(values)
+			var quoted []string
+			for _, value := range values {
+				quoted = append(quoted, fmt.Sprintf("%q", value))
+			}
+			change.Reasons = append(change.Reasons, fmt.Sprintf("%s has conflicting values %s", canonical, strings.Join(quoted, ", ")))
+		}
+		sort.Strings(change.Reasons)
+		return change
+	}
+
+	fields := map[string]TagChange{}
+	for _, key := range keys {
+		if _, ok := desired[key]; !ok {
+			fields[key] = TagChange{From: stringPointer(tags[key])}
+		}
+	}
+	for key, value := range desired {
+		current, ok := tags[key]
+		if !ok {
+			fields[key] = TagChange{To: stringPointer(value)}
+		} else if current != value {
+			fields[key] = TagChange{From: stringPointer(current), To: stringPointer(value)}
+		}
+	}
+	if len(fields) > 0 {
+		change.Action = ActionUpdate
+		change.Tags = fields
+	}
+	return change
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
+
+func (p *Plan) add(change Change) {
+	p.Changes = append(p.Changes, change)
+	switch change.Action {
+	case ActionUpdate:
+		p.Summary.Update++
+	case ActionRefuse:
+		p.Summary.Refuse++
+	default:
+		p.Summary.NoOp++
+	}
+}
+
+// applyPlan updates the tags of the resources of the plan, stopping at the first which fails. Refused changes
+// are not applied, and are returned as an error once the others are.
+func applyPlan(client *apiClient.Client, plan *Plan, out io.Writer) error {
+	for _, change := range plan.Changes {
+		if change.Action != ActionUpdate {
+			continue
+		}
+		// tags are merged into those of the resource, and removed if nil
+		tags := map[string]interface{}{}
+		for key, tag := range change.Tags {
+			if tag.To == nil {
+				tags[key] = nil
+			} else {
+				tags[key] = *tag.To
+			}
+		}
+		if _, err := client.UpdateResourceTags(change.Resource, tags); err != nil {
+			return fmt.Errorf("failed to update the tags of %s: %s", change.Resource, err.Error())
+		}
+		fmt.Fprintf(out, "Updated the tags of %s\n", change.Resource)
+	}
+	if plan.Summary.Refuse > 0 {
+		return fmt.Errorf("%d refused, see the plan", plan.Summary.Refuse)
+	}
+	return nil
+}
+
+// writePlan writes the changed tags of each resource to update, the reasons each refused resource is refused, and
+// the counts of each action
+func writePlan(plan *Plan, out io.Writer) {
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case ActionUpdate:
+			var keys []string
+			for key := range change.Tags {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			var tags []string
+			for _, key := range keys {
+				tag := change.Tags[key]
+				tags = append(tags, fmt.Sprintf("%s %s -> %s", key, formatValue(tag.From), formatValue(tag.To)))
+			}
+			fmt.Fprintf(out, "~ update %s: %s\n", change.Resource, strings.Join(tags, ", "))
+		case ActionRefuse:
+			fmt.Fprintf(out, "! refuse %s: %s\n", change.Resource, strings.Join(change.Reasons, "; "))
+		}
+	}
+	fmt.Fprintf(out, "Plan: %d to update, %d refused, %d unchanged.\n", plan.Summary.Update, plan.Summary.Refuse, plan.Summary.NoOp)
+}
+
+// formatValue formats a tag value as JSON, or unset if the key is not set
+func formatValue(value *string) string {
+	if value == nil {
+		return "(unset)"
+	}
+	valueJSON, _ := json.Marshal(*value)
+	return string(valueJSON)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/clitest"
+)
+
+// buckets tagged with variants of the Environment and Owner tags
+const testStore = `{
+	"resources": [
+		{"turbot": {"id": "1001", "tags": {"Environment": "Production", "Owner": "ops"}}, "type": {"uri": "tmod:@turbot/aws-s3#/resource/types/bucket"}},
+		{"turbot": {"id": "1002", "tags": {"env": "prod", "Name": "logs"}}, "type": {"uri": "tmod:@turbot/aws-s3#/resource/types/bucket"}},
+		{"turbot": {"id": "1003", "tags": {"Env": "dev", "environment": "PROD"}}, "type": {"uri": "tmod:@turbot/aws-s3#/resource/types/bucket"}},
+		{"turbot": {"id": "1004", "tags": {"ENV": "Staging", "Environment": "Staging"}}, "type": {"uri": "tmod:@turbot/aws-s3#/resource/types/bucket"}}
+	],
+	"tags": [
+		{"key": "Environment", "value": "Production", "turbot": {"id": "16001"}, "resources": {"items": [{"turbot": {"id": "1001"}}]}},
+		{"key": "Owner", "value": "ops", "turbot": {"id": "16002"}, "resources": {"items": [{"turbot": {"id": "1001"}}]}},
+		{"key": "env", "value": "prod", "turbot": {"id": "16003"}, "resources": {"items": [{"turbot": {"id": "1002"}}]}},
+		{"key": "Name", "value": "logs", "turbot": {"id": "16004"}, "resources": {"items": [{"turbot": {"id": "1002"}}]}},
+		{"key": "Env", "value": "dev", "turbot": {"id": "16005"}, "resources": {"items": [{"turbot": {"id": "1003"}}]}},
+		{"key": "environment", "value": "PROD", "turbot": {"id": "16006"}, "resources": {"items": [{"turbot": {"id": "1003"}}]}},
+		{"key": "ENV", "value": "Staging", "turbot": {"id": "16007"}, "resources": {"items": [{"turbot": {"id": "1004"}}]}},
+		{"key": "Environment", "value": "Staging", "turbot": {"id": "16008"}, "resources": {"items": [{"turbot": {"id": "1004"}}]}}
+	]
+}`
+
+const testMapping = `tags:
+  - key: Environment
+    aliases: [env]
+    values:
+      - value: Production
+        aliases: [prod]
+      - value: Development
+        aliases: [dev]
+  - key: Owner
+`
+
+func newTestClient(t *testing.T) *apiClient.Client {
+	return clitest.NewClient(t, clitest.NewStore(t, testStore))
+}
+
+func TestPlanAndApply(t *testing.T) {
+	normalizer, err := loadMapping(clitest.WriteFile(t, "mapping.yaml", testMapping))
+	if !assert.NoError(t, err) {
+		return
+	}
+	client := newTestClient(t)
+
+	plan, err := computePlan(client, normalizer)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, PlanSummary{Update: 2, Refuse: 1, NoOp: 1}, plan.Summary)
+	var out bytes.Buffer
+	writePlan(plan, &out)
+	assert.Equal(t, `~ update 1002: Environment (unset) -> "Production", env "prod" -> (unset)
+! refuse 1003: Environment has conflicting values "Development", "Production"
+~ update 1004: ENV "Staging" -> (unset)
+Plan: 2 to update, 1 refused, 1 unchanged.
+`, out.String())
+
+	// the tags which are not refused are normalized, keeping the tags which are not mapped
+	out.Reset()
+	assert.EqualError(t, applyPlan(client, plan, &out), "1 refused, see the plan")
+	assert.Equal(t, "Updated the tags of 1002\nUpdated the tags of 1004\n", out.String())
+	resource, err := client.ReadFullResource("1002")
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{"Environment": "Production", "Name": "logs"}, resource.Turbot.Tags)
+	}
+	plan, err = computePlan(client, normalizer)
+	assert.NoError(t, err)
+	assert.Equal(t, PlanSummary{Refuse: 1, NoOp: 3}, plan.Summary)
+}
+
+func TestLoadMappingErrors(t *testing.T) {
+	tests := []struct {
+		content, expected string
+	}{
+		{"tags: []\n", "no tags are mapped"},
+		{"tags:\n  - aliases: [env]\n", "tags[0]: key is required"},
+		{"tags:\n  - key: Environment\n  - key: Env\n    aliases: [environment]\n", `tags[1]: key "environment" is already mapped to Environment`},
+		{"tags:\n  - key: Environment\n    values:\n      - value: Production\n        aliases: [prod]\n      - value: Prod\n", `tags[0]: values[1]: value "Prod" is already mapped to Production`},
+		{"tags:\n  - key: Environment\n    value: Production\n", "field value not found"},
+	}
+	for _, test := range tests {
+		_, err := loadMapping(clitest.WriteFile(t, "mapping.yaml", test.content))
+		if assert.Error(t, err, test.content) {
+			assert.Contains(t, err.Error(), test.expected, test.content)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+// User is a user of the roster, identified by their email. Rosters are CSV, with a header row naming the
+// columns, e.g.
+//
+//	email,given_name,family_name,display_name
+//	jane@example.com,Jane,Doe,
+//	john@example.com,John,Smith,Johnny Smith
+//
+// or YAML, a list of users with the same keys, e.g.
+//
+//	users:
+//	  - email: jane@example.com
+//	    given_name: Jane
+//	    family_name: Doe
+//
+// The display name is the given and family names if not set.
+type User struct {
+	Email       string `yaml:"email"`
+	DisplayName string `yaml:"display_name"`
+	GivenName   string `yaml:"given_name"`
+	MiddleName  string `yaml:"middle_name"`
+	FamilyName  string `yaml:"family_name"`
+}
+
+// Roster is a YAML roster
+type Roster struct {
+	Users []User `yaml:"users"`
+}
+
+// loadRoster reads and validates a CSV or YAML roster, as given by its extension
+func loadRoster(path string) ([]User, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		users, err = parseRosterCSV(data)
+	case ".yaml", ".yml":
+		roster := &Roster{}
+		err = yaml.UnmarshalStrict(data, roster)
+		users = roster.Users
+	default:
+		return nil, fmt.Errorf("%s: rosters must be .csv, .yaml or .yml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, err.Error())
+	}
+
+	seen := map[string]int{}
+	for i := range users {
+		user := &users[i]
+		if err := user.validate(); err != nil {
+			return nil, fmt.Errorf("%s: user %d: %s", path, i+1, err.Error())
+		}
+		key := strings.ToLower(user.Email)
+		if j, ok := seen[key]; ok {
+			return nil, fmt.Errorf("%s: user %d: duplicates user %d", path, i+1, j+1)
+		}
+		seen[key] = i
+	}
+	return users, nil
+}
+
+// parseRosterCSV parses the rows of a CSV roster, whose first row names the columns
+func parseRosterCSV(data []byte) ([]User, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		switch header[i] {
+		case "email", "display_name", "given_name", "middle_name", "family_name":
+		default:
+			return nil, fmt.Errorf("unknown column %q", column)
+		}
+	}
+
+	var users []User
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return users, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var user User
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			switch header[i] {
+			case "email":
+				user.Email = value
+			case "display_name":
+				user.DisplayName = value
+			case "given_name":
+				user.GivenName = value
+			case "middle_name":
+				user.MiddleName = value
+			case "family_name":
+				user.FamilyName = value
+			}
+		}
+		users = append(users, user)
+	}
+}
+
+// validate the user, defaulting their display name
+func (u *User) validate() error {
+	u.Email = strings.TrimSpace(u.Email)
+	switch {
+	case u.Email == "":
+		return fmt.Errorf("email is required")
+	case !strings.Contains(u.Email, "@"):
+		return fmt.Errorf("invalid email %q", u.Email)
+	case u.GivenName == "" && u.FamilyName == "" && u.DisplayName == "":
+		return fmt.Errorf("one of display_name, given_name and family_name is required")
+	}
+	if u.DisplayName == "" {
+		u.DisplayName = strings.TrimSpace(u.GivenName + " " + u.FamilyName)
+	}
+	return nil
+}
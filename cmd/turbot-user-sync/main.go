@@ -0,0 +1,86 @@
+// Command turbot-user-sync provisions the users of a Turbot local directory from a roster, a CSV or YAML list
+// of users. It creates the local directory users and profiles missing from the directory, updates their names
+// where they differ from the roster, and suspends the users and profiles which are no longer listed by setting
+// their status to Inactive. A plan suspending every user because the roster is empty, or more than a fifth of the
+// active users, is not applied unless -allow-mass-suspend is given. The plan is printed before any change is made, and every change made is appended to
+// an audit log, one JSON object per line.
+//
+// Usage:
+//
+//	turbot-user-sync plan [-profile name] [-json] -directory id roster.csv
+//	turbot-user-sync apply [-profile name] [-json] [-dry-run] [-audit-log path] [-allow-mass-suspend] -directory id roster.csv
+//
+// See User for the format of the roster. The directory is given by its id or aka. Credentials are read from
+// the profile if given, otherwise the same way as for a connection with no credentials set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/cli"
+)
+
+const usage = `usage:
+  turbot-user-sync plan [-profile name] [-json] -directory id roster.csv
+  turbot-user-sync apply [-profile name] [-json] [-dry-run] [-audit-log path] [-allow-mass-suspend] -directory id roster.csv`
+
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "plan" && os.Args[1] != "apply") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	profile := flags.String("profile", "", "Turbot CLI profile to read credentials from")
+	directory := flags.String("directory", "", "id or aka of the local directory")
+	jsonOutput := flags.Bool("json", false, "write the plan as JSON")
+	dryRun, auditLog, allowMassSuspend := false, "", false
+	if command == "apply" {
+		flags.BoolVar(&dryRun, "dry-run", false, "show the plan without applying it")
+		flags.StringVar(&auditLog, "audit-log", "turbot-user-sync-audit.jsonl", "path of the audit log to append the changes to")
+		flags.BoolVar(&allowMassSuspend, "allow-mass-suspend", false, "apply the plan even if the roster is empty, or it suspends more than a fifth of the active users")
+	}
+	_ = flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 || *directory == "" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(flags.Arg(0), *directory, *profile, *jsonOutput, command == "apply" && !dryRun, auditLog, allowMassSuspend); err != nil {
+		cli.Exit("turbot-user-sync", err)
+	}
+}
+
+func run(path, directory, profile string, jsonOutput, apply bool, auditLog string, allowMassSuspend bool) error {
+	roster, err := loadRoster(path)
+	if err != nil {
+		return err
+	}
+	client, err := cli.Connect(profile)
+	if err != nil {
+		return err
+	}
+	plan, err := computePlan(client, directory, roster)
+	if err != nil {
+		return err
+	}
+
+	return cli.PlanAndApply(plan, jsonOutput, apply, func(out io.Writer) { writePlan(plan, out) }, func(progress io.Writer) error {
+		if err := checkSuspensions(plan, allowMassSuspend); err != nil {
+			return err
+		}
+		audit, err := os.OpenFile(auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		err = applyPlan(client, directory, plan, progress, audit)
+		if closeErr := audit.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+}
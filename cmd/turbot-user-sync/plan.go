@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+)
+
+// Action is the change planned for a local directory user or profile
+type Action string
+
+const (
+	ActionCreate  Action = "create"
+	ActionUpdate  Action = "update"
+	ActionSuspend Action = "suspend"
+	ActionNone    Action = "no-op"
+)
+
+// Kind is the kind of resource changed: a local directory user, or its profile
+type Kind string
+
+const (
+	KindUser    Kind = "user"
+	KindProfile Kind = "profile"
+)
+
+const (
+	activeStatus = "Active"
+	// Turbot has no suspended status - an inactive user cannot log in, and is active again once listed
+	suspendedStatus = "Inactive"
+
+	// a plan suspending more than this fraction of the active users, or any user when the roster is empty, is
+	// refused unless mass suspension is allowed, in case the roster is truncated. Suspending a single user is
+	// always allowed, so small directories can be synced.
+	massSuspendFraction = 0.2
+)
+
+// the attributes of users and profiles which are synced from the roster, by their data field name. Updates
+// set the whole data of the resource, so other attributes, e.g. the profile id, are kept by the plan.
+var attributeNames = []string{"title", "email", "status", "displayName", "givenName", "middleName", "familyName"}
+
+// Plan is the changes needed to bring the users and profiles of the directory to the roster
+type Plan struct {
+	Changes []Change    `json:"changes"`
+	Summary PlanSummary `json:"summary"`
+
+	// the number of users of the roster, and of the active users of the directory
+	rosterUsers int
+	activeUsers int
+}
+
+type PlanSummary struct {
+	Create  int `json:"create"`
+	Update  int `json:"update"`
+	Suspend int `json:"suspend"`
+	NoOp    int `json:"no_op"`
+}
+
+// Change is the change planned for the user or profile with an email
+type Change struct {
+	Action Action `json:"action"`
+	Kind   Kind   `json:"kind"`
+	Email  string `json:"email"`
+	ID     string `json:"id,omitempty"`
+	// the attributes set on create, or the attributes changed on update or suspend
+	Fields map[string]FieldChange `json:"fields,omitempty"`
+
+	// the data of the created or updated resource
+	data map[string]interface{}
+}
+
+// FieldChange is the change of an attribute
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AuditEntry is a line of the audit log, written for every change made
+type AuditEntry struct {
+	Time      string                 `json:"time"`
+	Directory string                 `json:"directory"`
+	Action    Action                 `json:"action"`
+	Kind      Kind                   `json:"kind"`
+	Email     string                 `json:"email"`
+	ID        string                 `json:"id"`
+	Fields    map[string]FieldChange `json:"fields"`
+}
+
+// attributes are the synced attributes of a user or profile, by data field name
+type attributes map[string]string
+
+// desiredAttributes returns the attributes of an active user of the roster
+func desiredAttributes(user User) attributes {
+	return attributes{
+		"title":       user.DisplayName,
+		"email":       user.Email,
+		"status":      activeStatus,
+		"displayName": user.DisplayName,
+		"givenName":   user.GivenName,
+		"middleName":  user.MiddleName,
+		"familyName":  user.FamilyName,
+	}
+}
+
+func userAttributes(user apiClient.LocalDirectoryUser) attributes {
+	return attributes{
+		"title":       user.Title,
+		"email":       user.Email,
+		"status":      user.Status,
+		"displayName": user.DisplayName,
+		"givenName":   user.GivenName,
+		"middleName":  user.MiddleName,
+		"familyName":  user.FamilyName,
+	}
+}
+
+func profileAttributes(profile apiClient.Profile) attributes {
+	return attributes{
+		"title":       profile.Title,
+		"email":       profile.Email,
+		"status":      profile.Status,
+		"displayName": profile.DisplayName,
+		"givenName":   profile.GivenName,
+		"middleName":  profile.MiddleName,
+		"familyName":  profile.FamilyName,
+		"profileId":   profile.ProfileId,
+	}
+}
+
+// diff returns the attributes which differ between the current and desired attributes
+func diff(current, desired attributes) map[string]FieldChange {
+	fields := map[string]FieldChange{}
+	for _, name := range attributeNames {
+		if current[name] != desired[name] {
+			fields[name] = FieldChange{From: current[name], To: desired[name]}
+		}
+	}
+	return fields
+}
+
+// data returns the resource data of the attributes. The synced attributes are always set, so an attribute
+// cleared in the roster is cleared in the directory, while the others are left out when they are not set.
+func (a attributes) data() map[string]interface{} {
+	data := map[string]interface{}{}
+	for _, name := range attributeNames {
+		data[name] = a[name]
+	}
+	for name, value := range a {
+		if value != "" {
+			data[name] = value
+		}
+	}
+	return data
+}
+
+// computePlan compares the roster with the users and profiles of a local directory, given by its id or aka.
+// Users and profiles which are not on the roster are suspended.
+func computePlan(client *apiClient.Client, directory string, roster []User) (*Plan, error) {
+	users, err := client.ListLocalDirectoryUsers(directory)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := client.ListProfiles(directory)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Changes: []Change{}, rosterUsers: len(roster)}
+	existingUsers := map[string]attributes{}
+	userIDs := map[string]string{}
+	for _, user := range users {
+		key := strings.ToLower(user.Email)
+		existingUsers[key] = userAttributes(user)
+		userIDs[key] = user.Turbot.Id
+		if user.Status != suspendedStatus {
+			plan.activeUsers++
+		}
+	}
+	existingProfiles := map[string]attributes{}
+	profileIDs := map[string]string{}
+	for _, profile := range profiles {
+		key := strings.ToLower(profile.Email)
+		existingProfiles[key] = profileAttributes(profile)
+		profileIDs[key] = profile.Turbot.Id
+	}
+
+	listed := map[string]bool{}
+	for _, user := range roster {
+		key := strings.ToLower(user.Email)
+		listed[key] = true
+		desired := desiredAttributes(user)
+		plan.add(planChange(KindUser, user.Email, userIDs[key], existingUsers[key], desired))
+
+		// the profile id of a local directory user is their email, and is kept once set
+		desired = desiredAttributes(user)
+		desired["profileId"] = user.Email
+		if existing, ok := existingProfiles[key]; ok && existing["profileId"] != "" {
+			desired["profileId"] = existing["profileId"]
+		}
+		plan.add(planChange(KindProfile, user.Email, profileIDs[key], existingProfiles[key], desired))
+	}
+
+	// suspend the active users, then profiles, which are not on the roster
+	for _, kind := range []Kind{KindUser, KindProfile} {
+		existing, ids := existingUsers, userIDs
+		if kind == KindProfile {
+			existing, ids = existingProfiles, profileIDs
+		}
+		var keys []string
+		for key := range existing {
+			if !listed[key] && existing[key]["status"] != suspendedStatus {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			desired := attributes{}
+			for name, value := range existing[key] {
+				desired[name] = value
+			}
+			desired["status"] = suspendedStatus
+			change := planChange(kind, existing[key]["email"], ids[key], existing[key], desired)
+			change.Action = ActionSuspend
+			plan.add(change)
+		}
+	}
+	return plan, nil
+}
+
+// planChange plans the change of a user or profile from its current attributes, nil if it does not exist, to
+// the desired attributes
+func planChange(kind Kind, email, id string, current, desired attributes) Change {
+	change := Change{Action: ActionNone, Kind: kind, Email: email, ID: id, data: desired.data()}
+	if current == nil {
+		change.Action = ActionCreate
+		change.Fields = diff(attributes{}, desired)
+		return change
+	}
+	if fields := diff(current, desired); len(fields) > 0 {
+		change.Action = ActionUpdate
+		change.Fields = fields
+	}
+	return change
+}
+
+func (p *Plan) add(change Change) {
+	p.Changes = append(p.Changes, change)
+	switch change.Action {
+	case ActionCreate:
+		p.Summary.Create++
+	case ActionUpdate:
+		p.Summary.Update++
+	case ActionSuspend:
+		p.Summary.Suspend++
+	default:
+		p.Summary.NoOp++
+	}
+}
+
+// checkSuspensions returns an error if the plan suspends every user because the roster is empty, or more than
+// massSuspendFraction of the active users, unless mass suspension is allowed
+func checkSuspensions(plan *Plan, allowMassSuspend bool) error {
+	suspended := 0
+	for _, change := range plan.Changes {
+		if change.Action == ActionSuspend && change.Kind == KindUser {
+			suspended++
+		}
+	}
+	if allowMassSuspend || suspended == 0 {
+		return nil
+	}
+	if plan.rosterUsers == 0 {
+		return fmt.Errorf("the roster is empty, so every user of the directory would be suspended. Use -allow-mass-suspend to apply the plan")
+	}
+	if suspended > 1 && float64(suspended) > massSuspendFraction*float64(plan.activeUsers) {
+		return fmt.Errorf("the plan suspends %d of the %d active users of the directory, more than %.0f%%. Check the roster is complete, or use -allow-mass-suspend to apply the plan", suspended, plan.activeUsers, massSuspendFraction*100)
+	}
+	return nil
+}
+
+// applyPlan makes the changes of the plan, stopping at the first which fails. Each change made is written to
+// the audit log as a line of JSON.
+func applyPlan(client *apiClient.Client, directory string, plan *Plan, out, audit io.Writer) error {
+	encoder := json.NewEncoder(audit)
+	for _, change := range plan.Changes {
+		if change.Action == ActionNone {
+			continue
+		}
+		id, err := applyChange(client, directory, change)
+		if err != nil {
+			return fmt.Errorf("failed to %s %s %s: %s", change.Action, change.Kind, change.Email, err.Error())
+		}
+		fmt.Fprintf(out, "%s %s %s (%s)\n", pastTense[change.Action], change.Kind, change.Email, id)
+		entry := AuditEntry{
+			Time:      time.Now().UTC().Format(time.RFC3339),
+			Directory: directory,
+			Action:    change.Action,
+			Kind:      change.Kind,
+			Email:     change.Email,
+			ID:        id,
+			Fields:    change.Fields,
+		}
+		if err = encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to write the audit log: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+var pastTense = map[Action]string{ActionCreate: "Created", ActionUpdate: "Updated", ActionSuspend: "Suspended"}
+
+// applyChange makes a change, returning the id of the user or profile
+func applyChange(client *apiClient.Client, directory string, change Change) (string, error) {
+	if change.Action == ActionCreate {
+		input := map[string]interface{}{"parent": directory, "data": change.data}
+		if change.Kind == KindUser {
+			user, err := client.CreateLocalDirectoryUser(input)
+			if err != nil {
+				return "", err
+			}
+			return user.Turbot.Id, nil
+		}
+		profile, err := client.CreateProfile(input)
+		if err != nil {
+			return "", err
+		}
+		return profile.Turbot.Id, nil
+	}
+
+	input := map[string]interface{}{"id": change.ID, "data": change.data}
+	var err error
+	if change.Kind == KindUser {
+		_, err = client.UpdateLocalDirectoryUserResource(input)
+	} else {
+		_, err = client.UpdateProfile(input)
+	}
+	return change.ID, err
+}
+
+// writePlan writes a line for each user or profile to create, update or suspend, with the attributes which change,
+// and the counts of each action
+func writePlan(plan *Plan, out io.Writer) {
+	symbols := map[Action]string{ActionCreate: "+", ActionUpdate: "~", ActionSuspend: "-"}
+	for _, change := range plan.Changes {
+		if change.Action == ActionNone {
+			continue
+		}
+		line := fmt.Sprintf("%s %s %s %s", symbols[change.Action], change.Action, change.Kind, change.Email)
+		if change.Action == ActionUpdate {
+			var fields []string
+			for _, name := range attributeNames {
+				if field, ok := change.Fields[name]; ok {
+					fields = append(fields, fmt.Sprintf("%s %q -> %q", name, field.From, field.To))
+				}
+			}
+			line += ": " + strings.Join(fields, ", ")
+		}
+		fmt.Fprintln(out, line)
+	}
+	fmt.Fprintf(out, "Plan: %d to create, %d to update, %d to suspend, %d unchanged.\n", plan.Summary.Create, plan.Summary.Update, plan.Summary.Suspend, plan.Summary.NoOp)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/cmd/internal/clitest"
+)
+
+const (
+	userType    = "tmod:@turbot/turbot-iam#/resource/types/localDirectoryUser"
+	profileType = "tmod:@turbot/turbot-iam#/resource/types/profile"
+)
+
+// the local directory of the test workspace, with its users and profiles
+const testResources = `[
+	{"turbot": {"id": "178806", "akas": ["tmod:@turbot/turbot#/"], "path": "178806"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/turbot"}},
+	{"data": {"title": "Local"}, "turbot": {"id": "3001", "parentId": "178806", "path": "178806.3001"}, "type": {"uri": "tmod:@turbot/turbot-iam#/resource/types/localDirectory"}},
+	{"data": {"title": "Jane Doe", "email": "jane@example.com", "status": "Active", "displayName": "Jane Doe", "givenName": "Jane", "familyName": "Doe"}, "turbot": {"id": "3101", "parentId": "3001", "path": "178806.3001.3101"}, "type": {"uri": "` + userType + `"}},
+	{"data": {"title": "John", "email": "John@example.com", "status": "Active", "displayName": "John", "givenName": "John", "middleName": "Q", "familyName": "Smith"}, "turbot": {"id": "3102", "parentId": "3001", "path": "178806.3001.3102"}, "type": {"uri": "` + userType + `"}},
+	{"data": {"title": "Old User", "email": "old@example.com", "status": "Active", "displayName": "Old User"}, "turbot": {"id": "3103", "parentId": "3001", "path": "178806.3001.3103"}, "type": {"uri": "` + userType + `"}},
+	{"data": {"title": "Gone User", "email": "gone@example.com", "status": "Inactive", "displayName": "Gone User"}, "turbot": {"id": "3104", "parentId": "3001", "path": "178806.3001.3104"}, "type": {"uri": "` + userType + `"}},
+	{"data": {"title": "Jane Doe", "email": "jane@example.com", "profileId": "jane@example.com", "status": "Active", "displayName": "Jane Doe", "givenName": "Jane", "familyName": "Doe"}, "turbot": {"id": "3201", "parentId": "3001", "path": "178806.3001.3201"}, "type": {"uri": "` + profileType + `"}},
+	{"data": {"title": "Old User", "email": "old@example.com", "profileId": "old@example.com", "status": "Active", "displayName": "Old User"}, "turbot": {"id": "3203", "parentId": "3001", "path": "178806.3001.3203"}, "type": {"uri": "` + profileType + `"}}
+]`
+
+const testRoster = `email,given_name,family_name,display_name
+jane@example.com,Jane,Doe,
+john@example.com,John,Smith,
+new@example.com,New,User,Newbie
+`
+
+func newTestClient(t *testing.T) *apiClient.Client {
+	return clitest.NewClient(t, clitest.NewStore(t, `{"resources": `+testResources+`}`))
+}
+
+func TestPlanAndApply(t *testing.T) {
+	roster, err := loadRoster(clitest.WriteFile(t, "roster.csv", testRoster))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "John Smith", roster[1].DisplayName)
+	client := newTestClient(t)
+
+	plan, err := computePlan(client, "3001", roster)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, PlanSummary{Create: 3, Update: 1, Suspend: 2, NoOp: 2}, plan.Summary)
+	var out bytes.Buffer
+	writePlan(plan, &out)
+	assert.Equal(t, `~ update user john@example.com: title "John" -> "John Smith", email "John@example.com" -> "john@example.com", displayName "John" -> "John Smith", middleName "Q" -> ""
++ create profile john@example.com
++ create user new@example.com
++ create profile new@example.com
+- suspend user old@example.com
+- suspend profile old@example.com
+Plan: 3 to create, 1 to update, 2 to suspend, 2 unchanged.
+`, out.String())
+
+	assert.NoError(t, checkSuspensions(plan, false))
+
+	// once applied, every change is in the audit log and the directory matches the roster, including the
+	// attributes cleared by the roster
+	out.Reset()
+	var audit bytes.Buffer
+	if !assert.NoError(t, applyPlan(client, "3001", plan, &out, &audit)) {
+		return
+	}
+	assert.Contains(t, out.String(), "Suspended user old@example.com (3103)")
+	lines := strings.Split(strings.TrimSpace(audit.String()), "\n")
+	if assert.Len(t, lines, 6) {
+		var entry AuditEntry
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+		assert.Equal(t, ActionUpdate, entry.Action)
+		assert.Equal(t, "3102", entry.ID)
+		assert.Equal(t, FieldChange{From: "John", To: "John Smith"}, entry.Fields["displayName"])
+	}
+	plan, err = computePlan(client, "3001", roster)
+	assert.NoError(t, err)
+	assert.Equal(t, PlanSummary{NoOp: 6}, plan.Summary)
+	profiles, err := client.ListProfiles("3001")
+	if assert.NoError(t, err) {
+		for _, profile := range profiles {
+			if profile.Email == "new@example.com" {
+				assert.Equal(t, "new@example.com", profile.ProfileId)
+				assert.Equal(t, "Newbie", profile.Title)
+			}
+		}
+	}
+}
+
+func TestCheckSuspensions(t *testing.T) {
+	client := newTestClient(t)
+
+	// an empty roster would suspend every user, and their profiles
+	plan, err := computePlan(client, "3001", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 5, plan.Summary.Suspend)
+	assert.ErrorContains(t, checkSuspensions(plan, false), "the roster is empty")
+	assert.NoError(t, checkSuspensions(plan, true))
+
+	// as would a truncated roster, suspending 2 of the 3 active users
+	roster, err := loadRoster(clitest.WriteFile(t, "roster.csv", "email,given_name\njane@example.com,Jane\n"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	plan, err = computePlan(client, "3001", roster)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.ErrorContains(t, checkSuspensions(plan, false), "the plan suspends 2 of the 3 active users")
+	assert.NoError(t, checkSuspensions(plan, true))
+}
+
+func TestLoadRosterErrors(t *testing.T) {
+	tests := []struct {
+		name, content, expected string
+	}{
+		{"roster.csv", "email,nickname\njane@example.com,JD\n", `unknown column "nickname"`},
+		{"roster.csv", "email,given_name\n,Jane\n", "user 1: email is required"},
+		{"roster.csv", "email,given_name\njane,Jane\n", `user 1: invalid email "jane"`},
+		{"roster.csv", "email\njane@example.com\n", "user 1: one of display_name, given_name and family_name is required"},
+		{"roster.csv", "email,given_name\njane@example.com,Jane\nJANE@example.com,Jane\n", "user 2: duplicates user 1"},
+		{"roster.yaml", "users:\n  - email: jane@example.com\n    name: Jane\n", "field name not found"},
+		{"roster.txt", "jane@example.com\n", "rosters must be .csv, .yaml or .yml"},
+	}
+	for _, test := range tests {
+		_, err := loadRoster(clitest.WriteFile(t, test.name, test.content))
+		assert.ErrorContains(t, err, test.expected)
+	}
+}
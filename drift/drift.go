@@ -0,0 +1,233 @@
+// Package drift compares the policy settings of two Turbot workspaces, e.g. a staging workspace whose policy
+// changes are promoted to production. Settings are matched by policy type URI and resource AKA, since the ids of
+// the same resource differ between workspaces. Default settings, which are created by mods, are not compared.
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/helpers"
+)
+
+// Status is how a setting differs between the workspaces
+type Status string
+
+const (
+	// the setting is only in the workspace compared to
+	StatusAdded Status = "added"
+	// the setting is only in the workspace compared from
+	StatusRemoved Status = "removed"
+	// the setting is in both workspaces, with different fields
+	StatusChanged Status = "changed"
+)
+
+// the page size of the settings listed from each workspace
+const pageLimit = 5000
+
+// Report is the differences between the policy settings of two workspaces
+type Report struct {
+	Differences []Difference `json:"differences"`
+	Summary     Summary      `json:"summary"`
+}
+
+type Summary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Difference is the difference between the settings of a policy type on a resource
+type Difference struct {
+	Status     Status   `json:"status"`
+	PolicyType string   `json:"policy_type"`
+	Resource   string   `json:"resource"`
+	From       *Setting `json:"from,omitempty"`
+	To         *Setting `json:"to,omitempty"`
+	// the fields which differ, for a changed setting
+	Fields []string `json:"fields,omitempty"`
+}
+
+// Setting is a policy setting in one of the workspaces
+type Setting struct {
+	ID            string      `json:"id"`
+	Value         interface{} `json:"value,omitempty"`
+	Template      string      `json:"template,omitempty"`
+	TemplateInput string      `json:"template_input,omitempty"`
+	Precedence    string      `json:"precedence"`
+	Note          string      `json:"note,omitempty"`
+
+	// the values of secret policy types are not returned by the API, so are not compared
+	secret bool
+}
+
+// Compare the policy settings of two workspaces. Settings only in the to workspace are added, and those only in
+// the from workspace are removed. The filter, if any, limits the settings compared in both workspaces.
+func Compare(from, to *apiClient.Client, filter string) (*Report, error) {
+	fromSettings, err := listSettings(from, filter)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the settings to compare from: %s", err.Error())
+	}
+	toSettings, err := listSettings(to, filter)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the settings to compare to: %s", err.Error())
+	}
+
+	report := &Report{Differences: []Difference{}}
+	for key, before := range fromSettings {
+		difference := Difference{PolicyType: key.policyType, Resource: key.resource, From: before}
+		after, ok := toSettings[key]
+		if !ok {
+			difference.Status = StatusRemoved
+			report.add(difference)
+			continue
+		}
+		difference.To = after
+		if difference.Fields, err = changedFields(before, after); err != nil {
+			return nil, fmt.Errorf("error comparing the settings of %s on %s: %s", key.policyType, key.resource, err.Error())
+		}
+		if len(difference.Fields) == 0 {
+			report.Summary.Unchanged++
+			continue
+		}
+		difference.Status = StatusChanged
+		report.add(difference)
+	}
+	for key, after := range toSettings {
+		if _, ok := fromSettings[key]; !ok {
+			report.add(Difference{Status: StatusAdded, PolicyType: key.policyType, Resource: key.resource, To: after})
+		}
+	}
+
+	sort.Slice(report.Differences, func(i, j int) bool {
+		a, b := report.Differences[i], report.Differences[j]
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.PolicyType < b.PolicyType
+	})
+	return report, nil
+}
+
+func (r *Report) add(difference Difference) {
+	r.Differences = append(r.Differences, difference)
+	switch difference.Status {
+	case StatusAdded:
+		r.Summary.Added++
+	case StatusRemoved:
+		r.Summary.Removed++
+	case StatusChanged:
+		r.Summary.Changed++
+	}
+}
+
+// WriteText writes the report for a reader, one line per difference
+func (r *Report) WriteText(out io.Writer) {
+	symbols := map[Status]string{StatusAdded: "+", StatusRemoved: "-", StatusChanged: "~"}
+	for _, difference := range r.Differences {
+		line := fmt.Sprintf("%s %s %s on %s", symbols[difference.Status], difference.Status, difference.PolicyType, difference.Resource)
+		if len(difference.Fields) > 0 {
+			line += fmt.Sprintf(" (%s)", strings.Join(difference.Fields, ", "))
+		}
+		fmt.Fprintln(out, line)
+	}
+	fmt.Fprintf(out, "Drift: %d added, %d removed, %d changed, %d unchanged.\n", r.Summary.Added, r.Summary.Removed, r.Summary.Changed, r.Summary.Unchanged)
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// the key matching the settings of the workspaces
+type settingKey struct {
+	policyType string
+	resource   string
+}
+
+// listSettings returns the settings of a workspace which are not defaults, keyed by policy type and resource aka
+func listSettings(client *apiClient.Client, filter string) (map[settingKey]*Setting, error) {
+	settings, err := client.ListPolicySettings(strings.TrimSpace(fmt.Sprintf("%s limit:%d", filter, pageLimit)))
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[settingKey]*Setting{}
+	for _, live := range settings {
+		if live.Default {
+			continue
+		}
+		// settings are matched by the first aka of their resource, which is the same in both workspaces. A resource
+		// without akas can only be matched by its id.
+		resource := live.Turbot.ResourceId
+		if len(live.Resource.Akas) > 0 {
+			resource = live.Resource.Akas[0]
+		}
+
+		templateInput, err := helpers.InterfaceToStringOrYaml(live.TemplateInput)
+		if err != nil {
+			return nil, err
+		}
+		setting := &Setting{
+			ID:            live.Turbot.Id,
+			Template:      live.Template,
+			TemplateInput: templateInput,
+			Precedence:    live.Precedence,
+			Note:          live.Note,
+			secret:        live.Type.Secret,
+		}
+		if live.Template == "" && !live.Type.Secret {
+			setting.Value = live.Value
+		}
+		result[settingKey{policyType: live.Type.Uri, resource: resource}] = setting
+	}
+	return result, nil
+}
+
+// changedFields returns the fields which differ between two settings. Values and template inputs are compared as
+// YAML, ignoring formatting differences, and those which are not YAML as text.
+func changedFields(from, to *Setting) ([]string, error) {
+	var fields []string
+	if !from.secret && !to.secret {
+		fromValue, err := helpers.InterfaceToStringOrYaml(from.Value)
+		if err != nil {
+			return nil, err
+		}
+		toValue, err := helpers.InterfaceToStringOrYaml(to.Value)
+		if err != nil {
+			return nil, err
+		}
+		equal, err := helpers.YamlStringsAreEqual(fromValue, toValue)
+		if err != nil {
+			equal = fromValue == toValue
+		}
+		if !equal {
+			fields = append(fields, "value")
+		}
+	}
+	if strings.TrimSpace(from.Template) != strings.TrimSpace(to.Template) {
+		fields = append(fields, "template")
+	}
+	// a template input is a GraphQL query, or a YAML list of them
+	equal, err := helpers.YamlStringsAreEqual(from.TemplateInput, to.TemplateInput)
+	if err != nil {
+		equal = strings.TrimSpace(from.TemplateInput) == strings.TrimSpace(to.TemplateInput)
+	}
+	if !equal {
+		fields = append(fields, "template_input")
+	}
+	if !strings.EqualFold(from.Precedence, to.Precedence) {
+		fields = append(fields, "precedence")
+	}
+	if from.Note != to.Note {
+		fields = append(fields, "note")
+	}
+	return fields, nil
+}
@@ -0,0 +1,92 @@
+package drift
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/machinebox/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/mockapi"
+)
+
+func newTestClient(t *testing.T) *apiClient.Client {
+	store, err := mockapi.LoadStore("../turbot/testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mockapi.NewServer(store))
+	t.Cleanup(server.Close)
+	return &apiClient.Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(server.URL)}
+}
+
+func TestCompare(t *testing.T) {
+	from := newTestClient(t)
+	to := newTestClient(t)
+
+	report, err := Compare(from, to, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Empty(t, report.Differences)
+	assert.Equal(t, Summary{Unchanged: 3}, report.Summary)
+
+	// change the template, remove and add a setting in the workspace compared to
+	_, err = to.UpdatePolicySetting(map[string]interface{}{"id": "9003", "template": "{{ $.resource.turbot.tags | json }}", "templateInput": "{ resource { turbot { tags } } }", "precedence": "REQUIRED"})
+	assert.NoError(t, err)
+	assert.NoError(t, to.DeletePolicySetting("9002"))
+	_, err = to.CreatePolicySetting(map[string]interface{}{"type": "tmod:@turbot/aws-s3#/policy/types/bucketVersioning", "resource": "arn:aws:s3:::my-bucket", "value": "Enforce: Enabled"})
+	assert.NoError(t, err)
+
+	report, err = Compare(from, to, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, Summary{Added: 1, Removed: 1, Changed: 1, Unchanged: 1}, report.Summary)
+	changed := report.Differences[0]
+	assert.Equal(t, StatusChanged, changed.Status)
+	assert.Equal(t, "arn:aws:::123456789012", changed.Resource)
+	assert.Equal(t, []string{"template", "precedence"}, changed.Fields)
+	assert.Equal(t, "9003", changed.From.ID)
+
+	var text bytes.Buffer
+	report.WriteText(&text)
+	assert.Equal(t, `~ changed tmod:@turbot/aws-s3#/policy/types/bucketTagsTemplate on arn:aws:::123456789012 (template, precedence)
++ added tmod:@turbot/aws-s3#/policy/types/bucketVersioning on arn:aws:s3:::my-bucket
+- removed tmod:@turbot/aws-s3#/policy/types/bucketVersioning on arn:aws:s3:::other-bucket
+Drift: 1 added, 1 removed, 1 changed, 1 unchanged.
+`, text.String())
+
+	var data bytes.Buffer
+	assert.NoError(t, report.WriteJSON(&data))
+	var decoded Report
+	assert.NoError(t, json.Unmarshal(data.Bytes(), &decoded))
+	assert.Equal(t, report.Summary, decoded.Summary)
+	assert.Equal(t, StatusAdded, decoded.Differences[1].Status)
+	assert.Equal(t, "Enforce: Enabled", decoded.Differences[1].To.Value)
+}
+
+func TestCompareResourceWithoutAkas(t *testing.T) {
+	var settings []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"value": "Skip", "precedence": "REQUIRED", "resource": {"akas": []}, "type": {"uri": "tmod:@turbot/aws-s3#/policy/types/bucketVersioning"}, "turbot": {"id": "9001", "resourceId": "1001"}}
+	]`), &settings))
+	store, err := mockapi.NewStore(map[string][]map[string]interface{}{"policySettings": settings})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mockapi.NewServer(store))
+	t.Cleanup(server.Close)
+	var requests int
+	client := &apiClient.Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(server.URL)}
+	client.OnRequest = func(apiClient.RequestStats) { requests++ }
+
+	// the setting is matched by the id of its resource, and the akas are read with the settings
+	report, err := Compare(client, client, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Summary{Unchanged: 1}, report.Summary)
+	}
+	assert.Equal(t, 2, requests)
+}
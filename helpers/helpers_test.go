@@ -3,6 +3,7 @@ package helpers
 import (
 	"encoding/json"
 	"log"
+	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -185,3 +186,110 @@ func TestGetNullProperties(t *testing.T) {
 		assert.ObjectsAreEqual(test.expected, excluded)
 	}
 }
+
+func TestParsePolicyValue(t *testing.T) {
+	type test struct {
+		name          string
+		value         interface{}
+		schema        string
+		expected      interface{}
+		expectedType  string
+		expectedError string
+	}
+	tests := []test{
+		{
+			"String schema is not parsed",
+			"Check: Enabled",
+			`{"type": "string", "enum": ["Skip", "Check: Enabled"]}`,
+			"Check: Enabled",
+			"string",
+			"",
+		},
+		{
+			"Enum of strings is not parsed",
+			"Enforce: Enabled",
+			`{"enum": ["Skip", "Enforce: Enabled"]}`,
+			"Enforce: Enabled",
+			"string",
+			"",
+		},
+		{
+			"YAML array",
+			"- us-east-1\n- us-west-2\n",
+			`{"type": "array", "items": {"type": "string"}}`,
+			[]interface{}{"us-east-1", "us-west-2"},
+			"array",
+			"",
+		},
+		{
+			"JSON object",
+			`{"Owner": "jane", "CostCenter": 42}`,
+			`{"type": "object", "additionalProperties": {"type": ["string", "integer"]}}`,
+			map[string]interface{}{"Owner": "jane", "CostCenter": 42},
+			"object",
+			"",
+		},
+		{
+			"Integer",
+			"7",
+			`{"type": "integer", "minimum": 1, "maximum": 30}`,
+			7,
+			"integer",
+			"",
+		},
+		{
+			"Schema invalid",
+			"- 1\n- two\n",
+			`{"type": "array", "items": {"type": "integer"}}`,
+			[]interface{}{1, "two"},
+			"array",
+			"value does not match schema: $[1]: expected integer, got string",
+		},
+		{
+			"Missing required property",
+			"a: 1\n",
+			`{"type": "object", "required": ["b"], "additionalProperties": false, "properties": {"b": {"type": "string"}}}`,
+			map[string]interface{}{"a": 1},
+			"object",
+			"value does not match schema: $: missing required property b; $.a: property is not allowed",
+		},
+		{
+			"Invalid YAML",
+			"a: [1",
+			`{"type": "object"}`,
+			nil,
+			"",
+			"error parsing value: yaml: line 1: did not find expected ',' or ']'",
+		},
+	}
+	for _, test := range tests {
+		var schema map[string]interface{}
+		if err := json.Unmarshal([]byte(test.schema), &schema); err != nil {
+			t.Fatal(err)
+		}
+		value, valueType, err := ParsePolicyValue(test.value, schema)
+		assert.Equal(t, test.expected, value, test.name)
+		assert.Equal(t, test.expectedType, valueType, test.name)
+		if test.expectedError == "" {
+			assert.NoError(t, err, test.name)
+		} else {
+			assert.EqualError(t, err, test.expectedError, test.name)
+		}
+	}
+}
+
+func TestGetSchemaProperties(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"title":    map[string]interface{}{"type": "string"},
+			"archived": map[string]interface{}{"type": "boolean"},
+			"folderId": map[string]interface{}{"type": "null"},
+			"any":      true,
+		},
+	}
+	properties := GetSchemaProperties(schema)
+	sort.Strings(properties)
+	assert.Equal(t, []string{"any", "archived", "title"}, properties)
+	assert.Empty(t, GetSchemaProperties(map[string]interface{}{"type": "object"}))
+}
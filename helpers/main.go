@@ -144,6 +144,20 @@ func GetNullProperties(propertyMap map[string]interface{}) []string {
 	return result
 }
 
+// GetSchemaProperties returns the properties of an object schema which may be set, i.e. are not of type null
+func GetSchemaProperties(propertyMap map[string]interface{}) []string {
+	var result []string
+	if properties, ok := propertyMap["properties"]; ok {
+		for id, valueObject := range properties.(map[string]interface{}) {
+			if m, ok := valueObject.(map[string]interface{}); ok && m["type"] == "null" {
+				continue
+			}
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
 // get keys from old map not in new map
 func GetOldMapProperties(old, new map[string]interface{}) []interface{} {
 	var result []interface{}
@@ -0,0 +1,320 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// parse a policy value according to the JSON schema of its policy type. Policy values of any type other than
+// string are stored as YAML (or JSON) strings, so string values are parsed unless the schema only allows strings.
+// The parsed value is returned along with its JSON type, and any error parsing or validating the value.
+func ParsePolicyValue(value interface{}, schema interface{}) (interface{}, string, error) {
+	if value == nil {
+		return nil, "null", nil
+	}
+	schemaMap, _ := schema.(map[string]interface{})
+	if s, ok := value.(string); ok && !schemaOnlyAllowsString(schemaMap) {
+		parsed, err := ParseYamlString(s)
+		if err != nil {
+			return nil, "", fmt.Errorf("error parsing value: %s", err.Error())
+		}
+		value = NormalizeYamlValue(parsed)
+	}
+	valueType := JsonType(value)
+	if schemaMap != nil {
+		if err := ValidateSchema(value, schemaMap); err != nil {
+			return value, valueType, err
+		}
+	}
+	return value, valueType, nil
+}
+
+// convert the maps returned by the YAML parser, which have interface{} keys, to maps with string keys
+// so the value can be marshalled to JSON
+func NormalizeYamlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for k, item := range v {
+			result[fmt.Sprintf("%v", k)] = NormalizeYamlValue(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for k, item := range v {
+			result[k] = NormalizeYamlValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = NormalizeYamlValue(item)
+		}
+		return result
+	}
+	return value
+}
+
+// return the JSON schema type of a value: null, boolean, integer, number, string, array or object
+func JsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int, int32, int64, uint, uint32, uint64:
+		return "integer"
+	case float32:
+		return numberType(float64(v))
+	case float64:
+		return numberType(v)
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}, map[interface{}]interface{}:
+		return "object"
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return "string"
+}
+
+func numberType(v float64) string {
+	if v == math.Trunc(v) && !math.IsInf(v, 0) {
+		return "integer"
+	}
+	return "number"
+}
+
+// validate a value against a JSON schema. This supports the subset of JSON schema used by Turbot policy types:
+// type, enum, const, properties, required, additionalProperties, items, min/max items, length and value,
+// pattern and the allOf, anyOf and oneOf combinators.
+func ValidateSchema(value interface{}, schema map[string]interface{}) error {
+	errs := validateSchema(value, schema, "$")
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("value does not match schema: %s", strings.Join(errs, "; "))
+}
+
+func validateSchema(value interface{}, schema map[string]interface{}, path string) []string {
+	var errs []string
+
+	if types := schemaTypes(schema); len(types) > 0 && !typeAllowed(JsonType(value), types) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), JsonType(value))}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: value is not one of the allowed values", path))
+		}
+	}
+	if c, ok := schema["const"]; ok && !jsonEqual(c, value) {
+		errs = append(errs, fmt.Sprintf("%s: value does not equal the constant value", path))
+	}
+
+	switch v := value.(type) {
+	case string:
+		if n, ok := schemaNumber(schema, "minLength"); ok && float64(len([]rune(v))) < n {
+			errs = append(errs, fmt.Sprintf("%s: length must be at least %v", path, n))
+		}
+		if n, ok := schemaNumber(schema, "maxLength"); ok && float64(len([]rune(v))) > n {
+			errs = append(errs, fmt.Sprintf("%s: length must be at most %v", path, n))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			// patterns which are not supported by Go regular expressions are ignored
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				errs = append(errs, fmt.Sprintf("%s: value does not match pattern %s", path, pattern))
+			}
+		}
+	case []interface{}:
+		if n, ok := schemaNumber(schema, "minItems"); ok && float64(len(v)) < n {
+			errs = append(errs, fmt.Sprintf("%s: must have at least %v items", path, n))
+		}
+		if n, ok := schemaNumber(schema, "maxItems"); ok && float64(len(v)) > n {
+			errs = append(errs, fmt.Sprintf("%s: must have at most %v items", path, n))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = append(errs, validateSchema(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if name, ok := r.(string); ok {
+					if _, ok := v[name]; !ok {
+						errs = append(errs, fmt.Sprintf("%s: missing required property %s", path, name))
+					}
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			propertyPath := fmt.Sprintf("%s.%s", path, k)
+			if propertySchema, ok := properties[k].(map[string]interface{}); ok {
+				errs = append(errs, validateSchema(v[k], propertySchema, propertyPath)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					errs = append(errs, fmt.Sprintf("%s: property is not allowed", propertyPath))
+				}
+			case map[string]interface{}:
+				errs = append(errs, validateSchema(v[k], additional, propertyPath)...)
+			}
+		}
+	default:
+		if n, ok := toFloat(value); ok {
+			if min, ok := schemaNumber(schema, "minimum"); ok && n < min {
+				errs = append(errs, fmt.Sprintf("%s: must be at least %v", path, min))
+			}
+			if max, ok := schemaNumber(schema, "maximum"); ok && n > max {
+				errs = append(errs, fmt.Sprintf("%s: must be at most %v", path, max))
+			}
+		}
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range allOf {
+			if subschema, ok := s.(map[string]interface{}); ok {
+				errs = append(errs, validateSchema(value, subschema, path)...)
+			}
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok && countMatchingSchemas(value, anyOf, path) == 0 {
+		errs = append(errs, fmt.Sprintf("%s: value does not match any of the allowed schemas", path))
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok && countMatchingSchemas(value, oneOf, path) != 1 {
+		errs = append(errs, fmt.Sprintf("%s: value must match exactly one of the allowed schemas", path))
+	}
+	return errs
+}
+
+func countMatchingSchemas(value interface{}, schemas []interface{}, path string) int {
+	count := 0
+	for _, s := range schemas {
+		if subschema, ok := s.(map[string]interface{}); ok && len(validateSchema(value, subschema, path)) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// return the types allowed by a schema, which may be a single type or a list
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func typeAllowed(valueType string, types []string) bool {
+	for _, t := range types {
+		// integers are also numbers
+		if t == valueType || (t == "number" && valueType == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// does the schema only allow string values - if so the value must not be parsed as YAML
+func schemaOnlyAllowsString(schema map[string]interface{}) bool {
+	if schema == nil {
+		return false
+	}
+	types := schemaTypes(schema)
+	if len(types) == 0 {
+		// a schema with no type may still only allow strings, e.g. an enum of strings
+		enum, ok := schema["enum"].([]interface{})
+		if !ok {
+			return false
+		}
+		for _, e := range enum {
+			if _, ok := e.(string); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	for _, t := range types {
+		if t != "string" && t != "null" {
+			return false
+		}
+	}
+	return true
+}
+
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	value, ok := schema[key]
+	if !ok {
+		return 0, false
+	}
+	return toFloat(value)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// compare two values by their JSON representation, so e.g. 1 and 1.0 are equal
+func jsonEqual(a, b interface{}) bool {
+	aJson, err := json.Marshal(NormalizeYamlValue(a))
+	if err != nil {
+		return false
+	}
+	bJson, err := json.Marshal(NormalizeYamlValue(b))
+	if err != nil {
+		return false
+	}
+	return string(aJson) == string(bJson)
+}
@@ -0,0 +1,61 @@
+// Package mockapi is a fake Turbot GraphQL API, serving fixture data for hermetic tests.
+//
+// The fixtures of a store are JSON lists of items, one per query field, e.g. resources, controls or policyTypes,
+// each item in the shape returned by the API. Queries are served the same way as a workspace snapshot, see the
+// snapshot package. Mutations, e.g. createPolicySetting(input: $input), create, update and delete the items
+// of a store.
+package mockapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient/snapshot"
+)
+
+// Store holds the fixture items of each collection
+type Store = snapshot.Store
+
+// Server is an http.Handler serving GraphQL requests against a store
+type Server = snapshot.Server
+
+// Request is a GraphQL request received by the server
+type Request = snapshot.Request
+
+// NewStore creates a store with the given items of each collection
+func NewStore(collections map[string][]map[string]interface{}) (*Store, error) {
+	return snapshot.NewStore(collections)
+}
+
+// LoadStore loads a store from a directory of fixture files, named by collection, e.g. resources.json.
+// Collections without a fixture file are empty.
+func LoadStore(dir string) (*Store, error) {
+	collections := map[string][]map[string]interface{}{}
+	for _, name := range snapshot.Collections {
+		data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var items []map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err = decoder.Decode(&items); err != nil {
+			return nil, fmt.Errorf("error parsing fixture %s.json: %s", name, err.Error())
+		}
+		collections[name] = items
+	}
+	return snapshot.NewStore(collections)
+}
+
+// NewServer creates a server for the store, serving the mutations of the API as well as its queries
+func NewServer(store *Store) *Server {
+	server := snapshot.NewServer(store)
+	server.Mutations = mutations
+	return server
+}
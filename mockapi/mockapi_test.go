@@ -0,0 +1,132 @@
+package mockapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient/snapshot"
+)
+
+// testStore returns a store of resources, and the given items of other collections
+func testStore(t *testing.T, collections map[string][]map[string]interface{}) *Store {
+	var resources []map[string]interface{}
+	err := json.Unmarshal([]byte(`[
+		{"data": {"Name": "a"}, "turbot": {"id": "1", "path": "3.1", "akas": ["arn:a"], "resourceTypeId": "10", "createTimestamp": "2023-01-01T00:00:00.000Z"}, "type": {"uri": "tmod:@turbot/aws-s3#/resource/types/bucket"}},
+		{"data": {"Name": "b"}, "turbot": {"id": "2", "path": "3.2", "akas": ["arn:b"], "resourceTypeId": "10", "createTimestamp": "2023-02-01T00:00:00.000Z"}, "type": {"uri": "tmod:@turbot/aws-s3#/resource/types/bucket"}},
+		{"data": {"Name": "c"}, "turbot": {"id": "3", "path": "3", "akas": ["arn:c"], "resourceTypeId": "20", "createTimestamp": "2023-03-01T00:00:00.000Z"}, "type": {"uri": "tmod:@turbot/aws#/resource/types/account"}}
+	]`), &resources)
+	assert.NoError(t, err)
+	collections["resources"] = resources
+	store, err := NewStore(collections)
+	assert.NoError(t, err)
+	return store
+}
+
+// execute a request and return its response as generic JSON
+func execute(t *testing.T, server *Server, query string, variables map[string]interface{}) map[string]interface{} {
+	data, err := json.Marshal(server.Execute(Request{Query: query, Variables: variables}))
+	assert.NoError(t, err)
+	var result map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &result))
+	return result
+}
+
+func TestExecuteMutations(t *testing.T) {
+	var policyTypes []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[{"uri": "tmod:@turbot/aws-s3#/policy/types/bucketVersioning", "turbot": {"id": "50"}}]`), &policyTypes))
+	store := testStore(t, map[string][]map[string]interface{}{"policyTypes": policyTypes})
+	server := NewServer(store)
+
+	create := `mutation CreatePolicySetting($input: CreatePolicySettingInput!) {
+	policySetting: createPolicySetting(input: $input) { value precedence turbot { id resourceId policyTypeId } }
+}`
+	input := map[string]interface{}{"type": "tmod:@turbot/aws-s3#/policy/types/bucketVersioning", "resource": "arn:b", "value": "Check: Enabled"}
+	result := execute(t, server, create, map[string]interface{}{"input": input})
+	assert.Nil(t, result["errors"])
+	id := snapshot.Lookup(result, "data.policySetting.turbot.id")
+	assert.Equal(t, "51", id)
+	assert.Equal(t, "2", snapshot.Lookup(result, "data.policySetting.turbot.resourceId"))
+	assert.Equal(t, "50", snapshot.Lookup(result, "data.policySetting.turbot.policyTypeId"))
+	assert.Equal(t, "REQUIRED", snapshot.Lookup(result, "data.policySetting.precedence"))
+
+	// a resource has one setting of each policy type
+	result = execute(t, server, create, map[string]interface{}{"input": input})
+	assert.Contains(t, result["errors"].([]interface{})[0].(map[string]interface{})["message"], "already exists")
+
+	result = execute(t, server, `mutation($input: UpdatePolicySettingInput!) { updatePolicySetting(input: $input) { value template } }`,
+		map[string]interface{}{"input": map[string]interface{}{"id": id, "template": "{{ 'Skip' }}"}})
+	assert.Nil(t, result["errors"])
+	assert.Nil(t, snapshot.Lookup(result, "data.updatePolicySetting.value"))
+	assert.Equal(t, "{{ 'Skip' }}", snapshot.Lookup(result, "data.updatePolicySetting.template"))
+
+	result = execute(t, server, `mutation($input: DeletePolicySettingInput!) { deletePolicySetting(input: $input) { turbot { id } } }`,
+		map[string]interface{}{"input": map[string]interface{}{"id": id}})
+	assert.Nil(t, result["errors"])
+	result = execute(t, server, `{ policySettings { items { turbot { id } } } }`, nil)
+	assert.Empty(t, snapshot.Lookup(result, "data.policySettings.items"))
+}
+
+func TestExecuteGrantMutations(t *testing.T) {
+	var grants []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[{"identity": {"akas": ["tmod:@turbot/turbot#/identities/jane"], "data": {"email": "jane@example.com"}}, "turbot": {"id": "60"}}]`), &grants))
+	store := testStore(t, map[string][]map[string]interface{}{"grants": grants})
+	server := NewServer(store)
+
+	// the identity is one of an existing grant
+	result := execute(t, server, `mutation($input: CreateGrantInput!) { grant: createGrant(input: $input) { resource { akas } turbot { id resourceId } } }`,
+		map[string]interface{}{"input": map[string]interface{}{"identity": "jane@example.com", "level": "tmod:@turbot/turbot-iam#/permission/levels/admin", "resource": "arn:b"}})
+	assert.Nil(t, result["errors"])
+	id := snapshot.Lookup(result, "data.grant.turbot.id")
+	assert.Equal(t, "61", id)
+	assert.Equal(t, []interface{}{"arn:b"}, snapshot.Lookup(result, "data.grant.resource.akas"))
+
+	result = execute(t, server, `mutation($input: ActivateGrantInput!) { activateGrant(input: $input) { turbot { id grantId } } }`,
+		map[string]interface{}{"input": map[string]interface{}{"grant": id, "resource": "arn:b"}})
+	assert.Nil(t, result["errors"])
+	activation := snapshot.Lookup(result, "data.activateGrant.turbot.id")
+	assert.Equal(t, id, snapshot.Lookup(result, "data.activateGrant.turbot.grantId"))
+
+	result = execute(t, server, `mutation($input: DeactivateGrantInput!) { deactivateGrant(input: $input) { turbot { id } } }`,
+		map[string]interface{}{"input": map[string]interface{}{"activation": activation}})
+	assert.Nil(t, result["errors"])
+	result = execute(t, server, `mutation($input: DeleteGrantInput!) { deleteGrant(input: $input) { turbot { id } } }`,
+		map[string]interface{}{"input": map[string]interface{}{"id": id}})
+	assert.Nil(t, result["errors"])
+	result = execute(t, server, `{ grants { items { turbot { id } } } activeGrants { items { turbot { id } } } }`, nil)
+	assert.Len(t, snapshot.Lookup(result, "data.grants.items"), 1)
+	assert.Empty(t, snapshot.Lookup(result, "data.activeGrants.items"))
+
+	result = execute(t, server, `mutation($input: CreateGrantInput!) { createGrant(input: $input) { turbot { id } } }`,
+		map[string]interface{}{"input": map[string]interface{}{"identity": "nobody", "level": "x", "resource": "arn:b"}})
+	assert.Contains(t, result["errors"].([]interface{})[0].(map[string]interface{})["message"], "Not Found: identity nobody")
+}
+
+func TestExecuteModMutations(t *testing.T) {
+	var registry []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[{"identityName": "turbot", "name": "aws", "versions": [{"version": "5.19.0", "status": "AVAILABLE"}, {"version": "5.20.0", "status": "AVAILABLE"}]}]`), &registry))
+	store := testStore(t, map[string][]map[string]interface{}{"modVersionSearches": registry})
+	server := NewServer(store)
+
+	result := execute(t, server, `{ versions: modVersionList(orgName: "turbot", modName: "aws") { items { version } } }`, nil)
+	assert.Len(t, snapshot.Lookup(result, "data.versions.items"), 2)
+
+	// installing an installed mod upgrades it
+	install := `mutation($input: InstallModInput!) { installMod(input: $input) { turbot { id parentId akas } } }`
+	result = execute(t, server, install, map[string]interface{}{"input": map[string]interface{}{"parent": "arn:c", "org": "turbot", "mod": "aws", "version": "5.19.0"}})
+	assert.Nil(t, result["errors"])
+	id := snapshot.Lookup(result, "data.installMod.turbot.id")
+	assert.Equal(t, "3", snapshot.Lookup(result, "data.installMod.turbot.parentId"))
+	result = execute(t, server, install, map[string]interface{}{"input": map[string]interface{}{"parent": "arn:c", "org": "turbot", "mod": "aws", "version": "5.20.0"}})
+	assert.Equal(t, id, snapshot.Lookup(result, "data.installMod.turbot.id"))
+	result = execute(t, server, `{ mod: resource(id: "tmod:@turbot/aws") { version: get(path: "version") } }`, nil)
+	assert.Equal(t, "5.20.0", snapshot.Lookup(result, "data.mod.version"))
+
+	result = execute(t, server, install, map[string]interface{}{"input": map[string]interface{}{"parent": "arn:c", "org": "turbot", "mod": "aws", "version": "6.0.0"}})
+	assert.Contains(t, result["errors"].([]interface{})[0].(map[string]interface{})["message"], "Not Found: mod @turbot/aws@6.0.0")
+
+	result = execute(t, server, `mutation($input: UninstallModInput!) { uninstallMod(input: $input) { success } }`,
+		map[string]interface{}{"input": map[string]interface{}{"id": id}})
+	assert.Equal(t, true, snapshot.Lookup(result, "data.uninstallMod.success"))
+	assert.Nil(t, store.Find("resources", "tmod:@turbot/aws"))
+}
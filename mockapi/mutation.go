@@ -0,0 +1,556 @@
+package mockapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient/snapshot"
+)
+
+// mutations are the mutation fields served by the API
+var mutations = map[string]snapshot.Mutation{
+	"createPolicySetting": createPolicySetting,
+	"updatePolicySetting": updatePolicySetting,
+	"deletePolicySetting": deletePolicySetting,
+	"createGrant":         createGrant,
+	"deleteGrant":         deleteGrant,
+	"activateGrant":       activateGrant,
+	"deactivateGrant":     deactivateGrant,
+	"installMod":          installMod,
+	"uninstallMod":        uninstallMod,
+	"createSmartFolder":   createSmartFolder,
+	"attachSmartFolders":  attachSmartFolders,
+	"detachSmartFolders":  detachSmartFolders,
+	"createResource":      createResource,
+	"updateResource":      updateResource,
+	"deleteResource":      deleteResource,
+}
+
+// the time format of timestamps set by mutations
+const timestampFormat = "2006-01-02T15:04:05.000Z"
+
+// newTurbotMetadata returns the turbot metadata of a created item
+func newTurbotMetadata(s *Store) map[string]interface{} {
+	now := time.Now().UTC().Format(timestampFormat)
+	return map[string]interface{}{
+		"id":              s.NewID(),
+		"createTimestamp": now,
+		"timestamp":       now,
+		"updateTimestamp": now,
+		"versionId":       s.NewID(),
+	}
+}
+
+// touch updates the timestamps and version of an updated item
+func touch(s *Store, item map[string]interface{}) {
+	metadata, _ := item["turbot"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		item["turbot"] = metadata
+	}
+	now := time.Now().UTC().Format(timestampFormat)
+	metadata["timestamp"] = now
+	metadata["updateTimestamp"] = now
+	metadata["versionId"] = s.NewID()
+}
+
+// summary returns the fields of an item included in the items which refer to it
+func summary(item map[string]interface{}, fields ...string) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, f := range fields {
+		if value, ok := item[f]; ok {
+			result[f] = value
+		}
+	}
+	return result
+}
+
+// resourceSummary returns the fields of a resource included in the items which refer to it. The akas of the
+// resource are also at the top level, as served by the API.
+func resourceSummary(resource map[string]interface{}) map[string]interface{} {
+	result := summary(resource, "akas", "title", "trunk", "type", "turbot")
+	if _, ok := result["akas"]; !ok {
+		if akas := snapshot.Lookup(resource, "turbot.akas"); akas != nil {
+			result["akas"] = akas
+		}
+	}
+	return result
+}
+
+func createPolicySetting(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	typeID := snapshot.ScalarString(input["type"])
+	policyType := s.Find("policyTypes", typeID)
+	if policyType == nil {
+		return nil, fmt.Errorf("Not Found: policyType %s", typeID)
+	}
+	resourceID := snapshot.ScalarString(input["resource"])
+	resource := s.Find("resources", resourceID)
+	if resource == nil {
+		return nil, fmt.Errorf("Not Found: resource %s", resourceID)
+	}
+	policyTypeID := snapshot.ScalarString(snapshot.Lookup(policyType, "turbot.id"))
+	resourceID = snapshot.ScalarString(snapshot.Lookup(resource, "turbot.id"))
+	for _, existing := range s.Items("policySettings") {
+		if snapshot.ScalarString(snapshot.Lookup(existing, "turbot.policyTypeId")) == policyTypeID && snapshot.ScalarString(snapshot.Lookup(existing, "turbot.resourceId")) == resourceID {
+			return nil, fmt.Errorf("a policy setting for %s already exists on resource %s", snapshot.ScalarString(policyType["uri"]), resourceID)
+		}
+	}
+
+	metadata := newTurbotMetadata(s)
+	metadata["policyTypeId"] = policyTypeID
+	metadata["resourceId"] = resourceID
+	item := map[string]interface{}{
+		"default":      false,
+		"exception":    json.Number("0"),
+		"isCalculated": false,
+		"orphan":       json.Number("0"),
+		"precedence":   "REQUIRED",
+		"resource":     resourceSummary(resource),
+		"type":         summary(policyType, "schema", "secret", "trunk", "turbot", "uri"),
+		"turbot":       metadata,
+	}
+	applyPolicySettingInput(item, input)
+	s.Add("policySettings", item)
+	return item, nil
+}
+
+func updatePolicySetting(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	id := snapshot.ScalarString(input["id"])
+	item := s.Find("policySettings", id)
+	if item == nil {
+		return nil, fmt.Errorf("Not Found: policySetting %s", id)
+	}
+	applyPolicySettingInput(item, input)
+	touch(s, item)
+	return item, nil
+}
+
+func deletePolicySetting(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	id := snapshot.ScalarString(input["id"])
+	item := s.Remove("policySettings", id)
+	if item == nil {
+		return nil, fmt.Errorf("Not Found: policySetting %s", id)
+	}
+	return item, nil
+}
+
+// applyPolicySettingInput sets the fields of a policy setting given in a create or update input. A setting
+// has either a value or a template, so setting one clears the other.
+func applyPolicySettingInput(item map[string]interface{}, input map[string]interface{}) {
+	for _, key := range []string{"note", "precedence", "validFromTimestamp", "validToTimestamp"} {
+		if value, ok := input[key]; ok {
+			item[key] = value
+		}
+	}
+	if value, ok := input["value"]; ok {
+		source, _ := json.Marshal(value)
+		for _, key := range []string{"value", "secretValue"} {
+			item[key] = value
+		}
+		for _, key := range []string{"valueSource", "secretValueSource"} {
+			item[key] = string(source)
+		}
+		item["template"] = nil
+		item["templateInput"] = nil
+		item["isCalculated"] = false
+	}
+	if template, ok := input["template"]; ok {
+		item["template"] = template
+		item["templateInput"] = input["templateInput"]
+		for _, key := range []string{"value", "secretValue", "valueSource", "secretValueSource"} {
+			item[key] = nil
+		}
+		item["isCalculated"] = true
+	}
+}
+
+// findIdentity returns the identity with the given id, aka, profile id or email. The store has no identities
+// collection, so identities are those of the existing grants.
+func findIdentity(s *Store, id string) map[string]interface{} {
+	for _, grant := range s.Items("grants") {
+		identity, _ := grant["identity"].(map[string]interface{})
+		if identity == nil {
+			continue
+		}
+		if snapshot.ScalarString(snapshot.Lookup(identity, "turbot.id")) == id || snapshot.ScalarString(snapshot.Lookup(identity, "data.profileId")) == id || snapshot.ScalarString(snapshot.Lookup(identity, "data.email")) == id {
+			return identity
+		}
+		if akas, ok := identity["akas"].([]interface{}); ok {
+			for _, aka := range akas {
+				if aka == id {
+					return identity
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func createGrant(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	resourceID := snapshot.ScalarString(input["resource"])
+	resource := s.Find("resources", resourceID)
+	if resource == nil {
+		return nil, fmt.Errorf("Not Found: resource %s", resourceID)
+	}
+	identityID := snapshot.ScalarString(input["identity"])
+	identity := findIdentity(s, identityID)
+	if identity == nil {
+		return nil, fmt.Errorf("Not Found: identity %s", identityID)
+	}
+	level := snapshot.ScalarString(input["level"])
+	if level == "" {
+		return nil, fmt.Errorf("Field \"level\" of required type \"String!\" was not provided.")
+	}
+
+	metadata := newTurbotMetadata(s)
+	metadata["resourceId"] = snapshot.ScalarString(snapshot.Lookup(resource, "turbot.id"))
+	if validTo, ok := input["validToTimestamp"]; ok {
+		metadata["validToTimestamp"] = validTo
+	}
+	item := map[string]interface{}{
+		"identity": identity,
+		"level":    map[string]interface{}{"uri": level},
+		"resource": resourceSummary(resource),
+		"turbot":   metadata,
+	}
+	s.Add("grants", item)
+	return item, nil
+}
+
+func deleteGrant(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	id := snapshot.ScalarString(input["id"])
+	item := s.Remove("grants", id)
+	if item == nil {
+		return nil, fmt.Errorf("Not Found: grant %s", id)
+	}
+	return item, nil
+}
+
+func activateGrant(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	grantID := snapshot.ScalarString(input["grant"])
+	grant := s.Find("grants", grantID)
+	if grant == nil {
+		return nil, fmt.Errorf("Not Found: grant %s", grantID)
+	}
+	resourceID := snapshot.ScalarString(input["resource"])
+	resource := s.Find("resources", resourceID)
+	if resource == nil {
+		return nil, fmt.Errorf("Not Found: resource %s", resourceID)
+	}
+
+	metadata := newTurbotMetadata(s)
+	metadata["grantId"] = grantID
+	metadata["resourceId"] = snapshot.ScalarString(snapshot.Lookup(resource, "turbot.id"))
+	item := map[string]interface{}{
+		"grant":    summary(grant, "identity", "level", "turbot"),
+		"resource": resourceSummary(resource),
+		"turbot":   metadata,
+	}
+	s.Add("activeGrants", item)
+	return item, nil
+}
+
+func deactivateGrant(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	id := snapshot.ScalarString(input["activation"])
+	item := s.Remove("activeGrants", id)
+	if item == nil {
+		return nil, fmt.Errorf("Not Found: activeGrant %s", id)
+	}
+	return item, nil
+}
+
+// the resource type of installed mods, which are resources of the workspace
+const modResourceType = "tmod:@turbot/turbot#/resource/types/mod"
+
+// installMod installs a version of a mod from the registry under a resource, or upgrades the installed mod
+func installMod(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	org, name, version := snapshot.ScalarString(input["org"]), snapshot.ScalarString(input["mod"]), snapshot.ScalarString(input["version"])
+	found := false
+	for _, v := range s.ModVersions(org, name) {
+		if snapshot.ScalarString(snapshot.Lookup(v, "version")) == version {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("Not Found: mod @%s/%s@%s", org, name, version)
+	}
+
+	uri := fmt.Sprintf("tmod:@%s/%s", org, name)
+	if item := s.Find("resources", uri); item != nil {
+		data, _ := item["data"].(map[string]interface{})
+		if data == nil {
+			data = map[string]interface{}{}
+			item["data"] = data
+		}
+		data["version"] = version
+		touch(s, item)
+		return item, nil
+	}
+
+	parentID := snapshot.ScalarString(input["parent"])
+	parent := s.Find("resources", parentID)
+	if parent == nil {
+		return nil, fmt.Errorf("Not Found: resource %s", parentID)
+	}
+	metadata := newTurbotMetadata(s)
+	metadata["akas"] = []interface{}{uri}
+	metadata["parentId"] = snapshot.ScalarString(snapshot.Lookup(parent, "turbot.id"))
+	metadata["path"] = snapshot.ScalarString(snapshot.Lookup(parent, "turbot.path")) + "." + snapshot.ScalarString(metadata["id"])
+	item := map[string]interface{}{
+		"data":   map[string]interface{}{"version": version},
+		"type":   map[string]interface{}{"uri": modResourceType},
+		"turbot": metadata,
+	}
+	s.Add("resources", item)
+	return item, nil
+}
+
+func uninstallMod(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	id := snapshot.ScalarString(input["id"])
+	if s.Remove("resources", id) == nil {
+		return nil, fmt.Errorf("Not Found: mod %s", id)
+	}
+	return map[string]interface{}{"success": true}, nil
+}
+
+// the resource type of smart folders
+const smartFolderResourceType = "tmod:@turbot/turbot#/resource/types/smartFolder"
+
+func createSmartFolder(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	parentID := snapshot.ScalarString(input["parent"])
+	parent := s.Find("resources", parentID)
+	if parent == nil {
+		return nil, fmt.Errorf("Not Found: resource %s", parentID)
+	}
+	title := snapshot.ScalarString(input["title"])
+	if title == "" {
+		return nil, fmt.Errorf("Field \"title\" of required type \"String!\" was not provided.")
+	}
+
+	data := map[string]interface{}{"title": title}
+	for _, key := range []string{"description", "color", "filters"} {
+		if value, ok := input[key]; ok {
+			data[key] = value
+		}
+	}
+	metadata := newTurbotMetadata(s)
+	metadata["title"] = title
+	metadata["parentId"] = snapshot.ScalarString(snapshot.Lookup(parent, "turbot.id"))
+	metadata["path"] = snapshot.ScalarString(snapshot.Lookup(parent, "turbot.path")) + "." + snapshot.ScalarString(metadata["id"])
+	item := map[string]interface{}{
+		"attachedResources": map[string]interface{}{"items": []interface{}{}},
+		"data":              data,
+		"trunk":             map[string]interface{}{"title": snapshot.ScalarString(snapshot.Lookup(parent, "trunk.title")) + " > " + title},
+		"type":              map[string]interface{}{"uri": smartFolderResourceType},
+		"turbot":            metadata,
+	}
+	s.Add("resources", item)
+	return item, nil
+}
+
+// attachSmartFolders attaches smart folders to a resource, returning the resource. Attachments are listed on
+// both sides, as the attachedResources of each folder and the attachedSmartFolders of the resource.
+func attachSmartFolders(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	resource, folders, err := smartFolderAttachment(s, input)
+	if err != nil {
+		return nil, err
+	}
+	for _, folder := range folders {
+		detach(resource, folder)
+		appendItem(folder, "attachedResources", summary(resource, "trunk", "turbot", "type"))
+		appendItem(resource, "attachedSmartFolders", summary(folder, "trunk", "turbot", "type"))
+	}
+	return resource, nil
+}
+
+func detachSmartFolders(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	resource, folders, err := smartFolderAttachment(s, input)
+	if err != nil {
+		return nil, err
+	}
+	for _, folder := range folders {
+		detach(resource, folder)
+	}
+	return resource, nil
+}
+
+// createResource creates a resource of a type under a parent, with its data and akas
+func createResource(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	parentID := snapshot.ScalarString(input["parent"])
+	parent := s.Find("resources", parentID)
+	if parent == nil {
+		return nil, fmt.Errorf("Not Found: resource %s", parentID)
+	}
+	typeURI := snapshot.ScalarString(input["type"])
+	if typeURI == "" {
+		return nil, fmt.Errorf("Field \"type\" of required type \"String!\" was not provided.")
+	}
+
+	data, _ := input["data"].(map[string]interface{})
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	metadata := newTurbotMetadata(s)
+	metadata["parentId"] = snapshot.ScalarString(snapshot.Lookup(parent, "turbot.id"))
+	metadata["path"] = snapshot.ScalarString(snapshot.Lookup(parent, "turbot.path")) + "." + snapshot.ScalarString(metadata["id"])
+	if akas, ok := input["akas"].([]interface{}); ok {
+		metadata["akas"] = akas
+	}
+	if title, ok := data["title"]; ok {
+		metadata["title"] = title
+	}
+	item := map[string]interface{}{
+		"data":   data,
+		"type":   map[string]interface{}{"uri": typeURI},
+		"turbot": metadata,
+	}
+	s.Add("resources", item)
+	return item, nil
+}
+
+// updateResource replaces the data of a resource, if given, and its akas. Tags are merged into the tags of the
+// resource, and removed if null.
+func updateResource(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	id := snapshot.ScalarString(input["id"])
+	item := s.Find("resources", id)
+	if item == nil {
+		return nil, fmt.Errorf("Not Found: resource %s", id)
+	}
+	touch(s, item)
+	if data, ok := input["data"].(map[string]interface{}); ok {
+		item["data"] = data
+		if title, ok := data["title"]; ok {
+			item["turbot"].(map[string]interface{})["title"] = title
+		}
+	}
+	if akas, ok := input["akas"].([]interface{}); ok {
+		item["turbot"].(map[string]interface{})["akas"] = akas
+	}
+	if tags, ok := input["tags"].(map[string]interface{}); ok {
+		metadata := item["turbot"].(map[string]interface{})
+		current, _ := metadata["tags"].(map[string]interface{})
+		if current == nil {
+			current = map[string]interface{}{}
+			metadata["tags"] = current
+		}
+		for key, value := range tags {
+			if value == nil {
+				delete(current, key)
+			} else {
+				current[key] = value
+			}
+			retag(s, snapshot.ScalarString(metadata["id"]), key, value)
+		}
+	}
+	return item, nil
+}
+
+// retag moves a resource to the tag with a key and value, adding the tag if needed, or removes it from the tags
+// with the key if the value is nil
+func retag(s *Store, resourceID, key string, value interface{}) {
+	var target map[string]interface{}
+	for _, tag := range s.Items("tags") {
+		if tag["key"] != key {
+			continue
+		}
+		if value != nil && snapshot.ScalarString(tag["value"]) == snapshot.ScalarString(value) {
+			target = tag
+		}
+		resources, _ := tag["resources"].(map[string]interface{})
+		items, _ := resources["items"].([]interface{})
+		kept := []interface{}{}
+		for _, resource := range items {
+			if snapshot.ScalarString(snapshot.Lookup(resource, "turbot.id")) != resourceID {
+				kept = append(kept, resource)
+			}
+		}
+		if resources != nil {
+			resources["items"] = kept
+		}
+	}
+	if value == nil {
+		return
+	}
+	if target == nil {
+		target = map[string]interface{}{"key": key, "value": value, "turbot": newTurbotMetadata(s)}
+		s.Add("tags", target)
+	}
+	resources, _ := target["resources"].(map[string]interface{})
+	if resources == nil {
+		resources = map[string]interface{}{}
+		target["resources"] = resources
+	}
+	items, _ := resources["items"].([]interface{})
+	resources["items"] = append(items, map[string]interface{}{"turbot": map[string]interface{}{"id": resourceID}})
+}
+
+// deleteResource deletes a resource, detaching it from its smart folders, or a smart folder from its resources
+func deleteResource(s *Store, input map[string]interface{}) (map[string]interface{}, error) {
+	id := snapshot.ScalarString(input["id"])
+	item := s.Find("resources", id)
+	if item == nil {
+		return nil, fmt.Errorf("Not Found: resource %s", id)
+	}
+	for _, other := range s.Items("resources") {
+		detach(item, other)
+		detach(other, item)
+	}
+	return s.Remove("resources", snapshot.ScalarString(snapshot.Lookup(item, "turbot.id"))), nil
+}
+
+// smartFolderAttachment returns the resource and smart folders of an attach or detach input
+func smartFolderAttachment(s *Store, input map[string]interface{}) (map[string]interface{}, []map[string]interface{}, error) {
+	resourceID := snapshot.ScalarString(input["resource"])
+	resource := s.Find("resources", resourceID)
+	if resource == nil {
+		return nil, nil, fmt.Errorf("Not Found: resource %s", resourceID)
+	}
+	ids, _ := input["smartFolders"].([]interface{})
+	if len(ids) == 0 {
+		return nil, nil, fmt.Errorf("Field \"smartFolders\" of required type \"[ID!]!\" was not provided.")
+	}
+	var folders []map[string]interface{}
+	for _, id := range ids {
+		folder := s.Find("resources", snapshot.ScalarString(id))
+		if folder == nil || snapshot.ScalarString(snapshot.Lookup(folder, "type.uri")) != smartFolderResourceType {
+			return nil, nil, fmt.Errorf("Not Found: smart folder %s", snapshot.ScalarString(id))
+		}
+		folders = append(folders, folder)
+	}
+	return resource, folders, nil
+}
+
+// detach a smart folder from a resource, if it is attached
+func detach(resource, folder map[string]interface{}) {
+	removeItem(folder, "attachedResources", snapshot.ScalarString(snapshot.Lookup(resource, "turbot.id")))
+	removeItem(resource, "attachedSmartFolders", snapshot.ScalarString(snapshot.Lookup(folder, "turbot.id")))
+}
+
+// appendItem appends an item to a list field of an item, e.g. attachedResources: {items: [...]}
+func appendItem(item map[string]interface{}, field string, value map[string]interface{}) {
+	list, _ := item[field].(map[string]interface{})
+	if list == nil {
+		list = map[string]interface{}{}
+		item[field] = list
+	}
+	items, _ := list["items"].([]interface{})
+	list["items"] = append(items, value)
+}
+
+// removeItem removes the item with the given id from a list field of an item
+func removeItem(item map[string]interface{}, field string, id string) {
+	list, _ := item[field].(map[string]interface{})
+	if list == nil {
+		return
+	}
+	items, _ := list["items"].([]interface{})
+	kept := []interface{}{}
+	for _, i := range items {
+		if snapshot.ScalarString(snapshot.Lookup(i, "turbot.id")) != id {
+			kept = append(kept, i)
+		}
+	}
+	list["items"] = kept
+}
@@ -0,0 +1,529 @@
+package nunjucks
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+func applyFilter(name string, value interface{}, args []interface{}, kwargs map[string]interface{}) (interface{}, error) {
+	arg := func(i int, name string, def interface{}) interface{} {
+		if i < len(args) {
+			return args[i]
+		}
+		if v, ok := kwargs[name]; ok {
+			return v
+		}
+		return def
+	}
+
+	switch name {
+	case "abs":
+		return math.Abs(toNumber(value)), nil
+	case "capitalize":
+		s := strings.ToLower(toString(value))
+		if s == "" {
+			return s, nil
+		}
+		runes := []rune(s)
+		runes[0] = unicode.ToUpper(runes[0])
+		return string(runes), nil
+	case "default", "d":
+		// by default only undefined values are replaced - if the second argument is true, any falsy value is
+		if truthy(arg(1, "boolean", false)) {
+			if !truthy(value) {
+				return arg(0, "value", undefined{}), nil
+			}
+			return value, nil
+		}
+		if _, ok := value.(undefined); ok {
+			return arg(0, "value", undefined{}), nil
+		}
+		return value, nil
+	case "dump":
+		return dump(value, arg(0, "spaces", nil))
+	case "escape", "e":
+		return html.EscapeString(toString(value)), nil
+	case "first":
+		switch v := value.(type) {
+		case []interface{}:
+			if len(v) > 0 {
+				return v[0], nil
+			}
+		case string:
+			if v != "" {
+				return string([]rune(v)[0]), nil
+			}
+		}
+		return undefined{}, nil
+	case "last":
+		switch v := value.(type) {
+		case []interface{}:
+			if len(v) > 0 {
+				return v[len(v)-1], nil
+			}
+		case string:
+			if v != "" {
+				runes := []rune(v)
+				return string(runes[len(runes)-1]), nil
+			}
+		}
+		return undefined{}, nil
+	case "float":
+		f := toNumber(value)
+		if math.IsNaN(f) {
+			return toNumber(arg(0, "default", 0.0)), nil
+		}
+		return f, nil
+	case "int":
+		f := toNumber(value)
+		if math.IsNaN(f) {
+			return toNumber(arg(0, "default", 0.0)), nil
+		}
+		return math.Trunc(f), nil
+	case "join":
+		items, _ := value.([]interface{})
+		attr := arg(1, "attribute", undefined{})
+		parts := make([]string, len(items))
+		for i, item := range items {
+			if !isNullish(attr) {
+				item = memberLookup(item, attr)
+			}
+			parts[i] = toString(item)
+		}
+		return strings.Join(parts, toString(arg(0, "separator", ""))), nil
+	case "length":
+		switch v := value.(type) {
+		case []interface{}:
+			return float64(len(v)), nil
+		case string:
+			return float64(len([]rune(v))), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return 0.0, nil
+	case "list":
+		switch v := value.(type) {
+		case []interface{}:
+			return v, nil
+		case string:
+			result := []interface{}{}
+			for _, c := range v {
+				result = append(result, string(c))
+			}
+			return result, nil
+		case map[string]interface{}:
+			result := []interface{}{}
+			for _, k := range sortedKeys(v) {
+				result = append(result, map[string]interface{}{"key": k, "value": v[k]})
+			}
+			return result, nil
+		}
+		return nil, fmt.Errorf("list filter: type not iterable")
+	case "lower":
+		return strings.ToLower(toString(value)), nil
+	case "upper":
+		return strings.ToUpper(toString(value)), nil
+	case "replace":
+		s := toString(value)
+		old, replacement := toString(arg(0, "old", "")), toString(arg(1, "new", ""))
+		count := -1
+		if max := arg(2, "maxCount", undefined{}); !isNullish(max) {
+			count = int(toNumber(max))
+		}
+		return strings.Replace(s, old, replacement, count), nil
+	case "reverse":
+		switch v := value.(type) {
+		case []interface{}:
+			result := make([]interface{}, len(v))
+			for i, item := range v {
+				result[len(v)-1-i] = item
+			}
+			return result, nil
+		case string:
+			runes := []rune(v)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return string(runes), nil
+		}
+		return value, nil
+	case "round":
+		precision := toNumber(arg(0, "precision", 0.0))
+		factor := math.Pow(10, precision)
+		f := toNumber(value) * factor
+		switch toString(arg(1, "method", "common")) {
+		case "ceil":
+			f = math.Ceil(f)
+		case "floor":
+			f = math.Floor(f)
+		default:
+			f = math.Round(f)
+		}
+		return f / factor, nil
+	case "safe":
+		// output is never escaped, so safe has no effect
+		return value, nil
+	case "string":
+		return toString(value), nil
+	case "sort":
+		items, ok := value.([]interface{})
+		if !ok {
+			return value, nil
+		}
+		reverse := truthy(arg(0, "reverse", false))
+		caseSensitive := truthy(arg(1, "caseSens", false))
+		attr := arg(2, "attribute", undefined{})
+		result := append([]interface{}{}, items...)
+		key := func(v interface{}) interface{} {
+			if !isNullish(attr) {
+				v = memberLookup(v, attr)
+			}
+			if s, ok := v.(string); ok && !caseSensitive {
+				return strings.ToLower(s)
+			}
+			return v
+		}
+		sort.SliceStable(result, func(i, j int) bool {
+			if reverse {
+				return compare(">", key(result[i]), key(result[j]))
+			}
+			return compare("<", key(result[i]), key(result[j]))
+		})
+		return result, nil
+	case "sum":
+		items, _ := value.([]interface{})
+		attr := arg(0, "attribute", undefined{})
+		total := toNumber(arg(1, "start", 0.0))
+		for _, item := range items {
+			if !isNullish(attr) {
+				item = memberLookup(item, attr)
+			}
+			total += toNumber(item)
+		}
+		return total, nil
+	case "title":
+		words := strings.Split(toString(value), " ")
+		for i, w := range words {
+			if w != "" {
+				runes := []rune(strings.ToLower(w))
+				runes[0] = unicode.ToUpper(runes[0])
+				words[i] = string(runes)
+			}
+		}
+		return strings.Join(words, " "), nil
+	case "trim":
+		return strings.TrimSpace(toString(value)), nil
+	case "selectattr", "rejectattr":
+		items, _ := value.([]interface{})
+		attr := arg(0, "attribute", undefined{})
+		result := []interface{}{}
+		for _, item := range items {
+			if truthy(memberLookup(item, attr)) == (name == "selectattr") {
+				result = append(result, item)
+			}
+		}
+		return result, nil
+	case "select", "reject":
+		items, _ := value.([]interface{})
+		test := toString(arg(0, "test", "truthy"))
+		result := []interface{}{}
+		for _, item := range items {
+			var testArgs []interface{}
+			if len(args) > 1 {
+				testArgs = args[1:]
+			}
+			ok, err := applyTest(test, item, testArgs)
+			if err != nil {
+				return nil, err
+			}
+			if ok == (name == "select") {
+				result = append(result, item)
+			}
+		}
+		return result, nil
+	case "dictsort":
+		dict, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("dictsort filter: you can only sort objects")
+		}
+		result := []interface{}{}
+		for _, k := range sortedKeys(dict) {
+			result = append(result, []interface{}{k, dict[k]})
+		}
+		return result, nil
+	case "batch":
+		items, _ := value.([]interface{})
+		size := int(toNumber(arg(0, "linecount", 1.0)))
+		if size < 1 {
+			return nil, fmt.Errorf("batch filter: line count must be at least 1")
+		}
+		result := []interface{}{}
+		for start := 0; start < len(items); start += size {
+			end := start + size
+			if end > len(items) {
+				end = len(items)
+			}
+			result = append(result, append([]interface{}{}, items[start:end]...))
+		}
+		return result, nil
+	case "indent":
+		width := int(toNumber(arg(0, "width", 4.0)))
+		first := truthy(arg(1, "indentfirst", false))
+		lines := strings.Split(toString(value), "\n")
+		pad := strings.Repeat(" ", width)
+		for i := range lines {
+			if (i > 0 || first) && lines[i] != "" {
+				lines[i] = pad + lines[i]
+			}
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+	return nil, fmt.Errorf("filter not found: %s", name)
+}
+
+// JSON.stringify
+func dump(value interface{}, spaces interface{}) (interface{}, error) {
+	value = jsonValue(value)
+	var data []byte
+	var err error
+	if isNullish(spaces) {
+		data, err = json.Marshal(value)
+	} else {
+		indent := toString(spaces)
+		if n, ok := spaces.(float64); ok {
+			indent = strings.Repeat(" ", int(n))
+		}
+		data, err = json.MarshalIndent(value, "", indent)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// prepare a value for marshalling to JSON - undefined properties are dropped and whole numbers are integers
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case undefined, function, boundMethod:
+		return nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return int64(v)
+		}
+		return v
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = jsonValue(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for k, item := range v {
+			if _, ok := item.(undefined); ok {
+				continue
+			}
+			result[k] = jsonValue(item)
+		}
+		return result
+	}
+	return value
+}
+
+func applyTest(name string, value interface{}, args []interface{}) (bool, error) {
+	switch name {
+	case "defined":
+		_, ok := value.(undefined)
+		return !ok, nil
+	case "undefined":
+		_, ok := value.(undefined)
+		return ok, nil
+	case "none", "null":
+		return value == nil, nil
+	case "truthy":
+		return truthy(value), nil
+	case "falsy":
+		return !truthy(value), nil
+	case "number":
+		_, ok := value.(float64)
+		return ok, nil
+	case "string":
+		_, ok := value.(string)
+		return ok, nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok, nil
+	case "iterable":
+		switch value.(type) {
+		case []interface{}, string:
+			return true, nil
+		}
+		return false, nil
+	case "mapping":
+		_, ok := value.(map[string]interface{})
+		return ok, nil
+	case "odd":
+		return math.Mod(toNumber(value), 2) != 0, nil
+	case "even":
+		return math.Mod(toNumber(value), 2) == 0, nil
+	case "divisibleby":
+		if len(args) == 0 {
+			return false, fmt.Errorf("divisibleby test expects an argument")
+		}
+		return math.Mod(toNumber(value), toNumber(args[0])) == 0, nil
+	case "equalto", "eq", "sameas":
+		if len(args) == 0 {
+			return false, fmt.Errorf("%s test expects an argument", name)
+		}
+		return strictEqual(value, args[0]), nil
+	case "lower":
+		s, ok := value.(string)
+		return ok && s == strings.ToLower(s), nil
+	case "upper":
+		s, ok := value.(string)
+		return ok && s == strings.ToUpper(s), nil
+	}
+	return false, fmt.Errorf("test not found: %s", name)
+}
+
+// the javascript string and array methods which may be called in templates
+var stringMethods = map[string]bool{
+	"endsWith": true, "includes": true, "indexOf": true, "lastIndexOf": true, "replace": true, "slice": true,
+	"split": true, "startsWith": true, "substring": true, "toLowerCase": true, "toUpperCase": true, "trim": true,
+}
+
+var arrayMethods = map[string]bool{
+	"concat": true, "includes": true, "indexOf": true, "join": true, "slice": true,
+}
+
+func isMethod(receiver interface{}, name string) bool {
+	switch receiver.(type) {
+	case string:
+		return stringMethods[name]
+	case []interface{}:
+		return arrayMethods[name]
+	}
+	return false
+}
+
+func callMethod(receiver interface{}, name string, args []interface{}) (interface{}, error) {
+	arg := func(i int) interface{} {
+		if i < len(args) {
+			return args[i]
+		}
+		return undefined{}
+	}
+
+	switch r := receiver.(type) {
+	case string:
+		switch name {
+		case "endsWith":
+			return strings.HasSuffix(r, toString(arg(0))), nil
+		case "startsWith":
+			return strings.HasPrefix(r, toString(arg(0))), nil
+		case "includes":
+			return strings.Contains(r, toString(arg(0))), nil
+		case "indexOf":
+			return float64(runeIndex(r, strings.Index(r, toString(arg(0))))), nil
+		case "lastIndexOf":
+			return float64(runeIndex(r, strings.LastIndex(r, toString(arg(0))))), nil
+		case "replace":
+			return strings.Replace(r, toString(arg(0)), toString(arg(1)), 1), nil
+		case "slice", "substring":
+			runes := []rune(r)
+			start, end := sliceBounds(len(runes), arg(0), arg(1), name == "slice")
+			return string(runes[start:end]), nil
+		case "split":
+			if isNullish(arg(0)) {
+				return []interface{}{r}, nil
+			}
+			parts := strings.Split(r, toString(arg(0)))
+			result := make([]interface{}, len(parts))
+			for i, p := range parts {
+				result[i] = p
+			}
+			return result, nil
+		case "toLowerCase":
+			return strings.ToLower(r), nil
+		case "toUpperCase":
+			return strings.ToUpper(r), nil
+		case "trim":
+			return strings.TrimSpace(r), nil
+		}
+	case []interface{}:
+		switch name {
+		case "concat":
+			result := append([]interface{}{}, r...)
+			for _, a := range args {
+				if items, ok := a.([]interface{}); ok {
+					result = append(result, items...)
+				} else {
+					result = append(result, a)
+				}
+			}
+			return result, nil
+		case "includes":
+			return contains(r, arg(0))
+		case "indexOf":
+			for i, item := range r {
+				if strictEqual(item, arg(0)) {
+					return float64(i), nil
+				}
+			}
+			return -1.0, nil
+		case "join":
+			separator := ","
+			if !isNullish(arg(0)) {
+				separator = toString(arg(0))
+			}
+			return applyFilter("join", r, []interface{}{separator}, nil)
+		case "slice":
+			start, end := sliceBounds(len(r), arg(0), arg(1), true)
+			return append([]interface{}{}, r[start:end]...), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown method %s", name)
+}
+
+// convert a byte index into a string to a character index
+func runeIndex(s string, byteIndex int) int {
+	if byteIndex < 0 {
+		return -1
+	}
+	return len([]rune(s[:byteIndex]))
+}
+
+// the bounds for javascript slice (where negative indexes count from the end) and substring
+func sliceBounds(length int, startArg, endArg interface{}, negativeFromEnd bool) (int, int) {
+	bound := func(v interface{}, def int) int {
+		if isNullish(v) {
+			return def
+		}
+		i := int(toNumber(v))
+		if i < 0 {
+			if negativeFromEnd {
+				i += length
+			}
+			if i < 0 {
+				i = 0
+			}
+		}
+		if i > length {
+			i = length
+		}
+		return i
+	}
+	start, end := bound(startArg, 0), bound(endArg, length)
+	if start > end {
+		if negativeFromEnd {
+			return start, start
+		}
+		start, end = end, start
+	}
+	return start, end
+}
@@ -0,0 +1,256 @@
+package nunjucks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenText tokenKind = iota
+	tokenOutput
+	tokenTag
+)
+
+// a template token - literal text, the contents of an output {{ ... }} or the contents of a tag {% ... %}
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+}
+
+var rawEndRegex = regexp.MustCompile(`\{%-?\s*endraw\s*-?%\}`)
+
+// split a template into text, output and tag tokens, dropping comments and applying whitespace control
+func lex(source string) ([]token, error) {
+	var tokens []token
+	line := 1
+	trimNext := false
+	pos := 0
+
+	addText := func(text string) {
+		if trimNext {
+			text = strings.TrimLeftFunc(text, unicode.IsSpace)
+			trimNext = false
+		}
+		if text != "" {
+			tokens = append(tokens, token{kind: tokenText, value: text, line: line})
+		}
+	}
+	trimPrevious := func() {
+		if len(tokens) > 0 && tokens[len(tokens)-1].kind == tokenText {
+			tokens[len(tokens)-1].value = strings.TrimRightFunc(tokens[len(tokens)-1].value, unicode.IsSpace)
+		}
+	}
+
+	for pos < len(source) {
+		start := nextTagStart(source, pos)
+		if start < 0 {
+			addText(source[pos:])
+			break
+		}
+		addText(source[pos:start])
+		line += strings.Count(source[pos:start], "\n")
+
+		open := source[start : start+2]
+		contentStart := start + 2
+		if contentStart < len(source) && source[contentStart] == '-' {
+			trimPrevious()
+			contentStart++
+		}
+
+		if open == "{#" {
+			end := strings.Index(source[contentStart:], "#}")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment (line %d)", line)
+			}
+			comment := source[contentStart : contentStart+end]
+			if strings.HasSuffix(comment, "-") {
+				trimNext = true
+			}
+			line += strings.Count(comment, "\n")
+			pos = contentStart + end + 2
+			continue
+		}
+
+		closing := "}}"
+		kind := tokenOutput
+		if open == "{%" {
+			closing = "%}"
+			kind = tokenTag
+		}
+		end := findTagEnd(source, contentStart, closing)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated %s (line %d)", open, line)
+		}
+		content := source[contentStart:end]
+		if strings.HasSuffix(content, "-") {
+			content = content[:len(content)-1]
+			trimNext = true
+		}
+		tokens = append(tokens, token{kind: kind, value: strings.TrimSpace(content), line: line})
+		line += strings.Count(content, "\n")
+		pos = end + 2
+
+		// the contents of a raw block are output as is
+		if kind == tokenTag && strings.TrimSpace(content) == "raw" {
+			tokens = tokens[:len(tokens)-1]
+			loc := rawEndRegex.FindStringIndex(source[pos:])
+			if loc == nil {
+				return nil, fmt.Errorf("unterminated raw block (line %d)", line)
+			}
+			raw := source[pos : pos+loc[0]]
+			if strings.HasPrefix(source[pos+loc[0]:], "{%-") {
+				raw = strings.TrimRightFunc(raw, unicode.IsSpace)
+			}
+			addText(raw)
+			line += strings.Count(source[pos:pos+loc[1]], "\n")
+			if strings.HasSuffix(source[pos:pos+loc[1]], "-%}") {
+				trimNext = true
+			}
+			pos += loc[1]
+		}
+	}
+	return tokens, nil
+}
+
+// find the start of the next {{, {% or {# from pos, or -1
+func nextTagStart(source string, pos int) int {
+	for i := pos; i < len(source)-1; i++ {
+		if source[i] == '{' && (source[i+1] == '{' || source[i+1] == '%' || source[i+1] == '#') {
+			return i
+		}
+	}
+	return -1
+}
+
+// find the closing delimiter of a tag, ignoring any inside string literals
+func findTagEnd(source string, pos int, closing string) int {
+	var quote byte
+	for i := pos; i < len(source)-1; i++ {
+		c := source[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case source[i:i+2] == closing:
+			return i
+		}
+	}
+	return -1
+}
+
+type exprTokenKind int
+
+const (
+	exprName exprTokenKind = iota
+	exprNumber
+	exprString
+	exprOperator
+	exprEnd
+)
+
+type exprToken struct {
+	kind  exprTokenKind
+	value string
+}
+
+// operators, longest first so e.g. == is matched before =
+var operators = []string{"===", "!==", "**", "//", "==", "!=", "<=", ">=", "+", "-", "*", "/", "%", "~", "<", ">", "(", ")", "[", "]", "{", "}", ",", ":", ".", "|", "="}
+
+// split the contents of an output or tag into expression tokens
+func lexExpression(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	i := 0
+	for i < len(source) {
+		c := source[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '"' || c == '\'':
+			value, n, err := lexString(source[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, exprToken{kind: exprString, value: value})
+			i += n
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(source) && (source[j] >= '0' && source[j] <= '9' || source[j] == '_') {
+				j++
+			}
+			if j+1 < len(source) && source[j] == '.' && source[j+1] >= '0' && source[j+1] <= '9' {
+				j++
+				for j < len(source) && source[j] >= '0' && source[j] <= '9' {
+					j++
+				}
+			}
+			tokens = append(tokens, exprToken{kind: exprNumber, value: strings.Replace(source[i:j], "_", "", -1)})
+			i = j
+		case isNameChar(c, true):
+			j := i
+			for j < len(source) && isNameChar(source[j], false) {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: exprName, value: source[i:j]})
+			i = j
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, exprToken{kind: exprOperator, value: op})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+		}
+	}
+	return append(tokens, exprToken{kind: exprEnd}), nil
+}
+
+func isNameChar(c byte, first bool) bool {
+	if c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+		return true
+	}
+	return !first && c >= '0' && c <= '9'
+}
+
+// read a quoted string literal, returning its value and length in the source
+func lexString(source string) (string, int, error) {
+	quote := source[0]
+	var b strings.Builder
+	for i := 1; i < len(source); i++ {
+		c := source[i]
+		if c == quote {
+			return b.String(), i + 1, nil
+		}
+		if c == '\\' && i+1 < len(source) {
+			i++
+			switch source[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(source[i])
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
@@ -0,0 +1,41 @@
+// Package nunjucks renders the subset of the Nunjucks template language used by Turbot calculated policies:
+// output expressions with filters, if/elif/else, for loops (with loop variables and else), set, raw and
+// comments, including whitespace control.
+package nunjucks
+
+// Template is a parsed template which can be rendered any number of times
+type Template struct {
+	nodes []node
+}
+
+// Parse parses a template
+func Parse(source string) (*Template, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	nodes, err := p.parseNodes()
+	if err != nil {
+		return nil, err
+	}
+	return &Template{nodes: nodes}, nil
+}
+
+// Execute renders the template with the given context. The context keys are available as variables in the template.
+func (t *Template) Execute(context map[string]interface{}) (string, error) {
+	r := newRenderer(context)
+	if err := r.renderNodes(t.nodes); err != nil {
+		return "", err
+	}
+	return r.output.String(), nil
+}
+
+// Render parses and renders a template with the given context
+func Render(source string, context map[string]interface{}) (string, error) {
+	t, err := Parse(source)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(context)
+}
@@ -0,0 +1,82 @@
+package nunjucks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	type test struct {
+		name     string
+		template string
+		context  map[string]interface{}
+		expected string
+	}
+	resource := map[string]interface{}{
+		"resource": map[string]interface{}{
+			"data": map[string]interface{}{
+				"Name":    "my-bucket",
+				"Regions": []interface{}{"us-east-1", "us-west-2"},
+				"Size":    float64(3),
+			},
+			"tags":  map[string]interface{}{"Owner": "jane", "Env": "prod"},
+			"title": "my-bucket",
+		},
+	}
+	tests := []test{
+		{"Text", "Skip", nil, "Skip"},
+		{"Output", "{{ $.resource.data.Name }}", resource, "my-bucket"},
+		{"Top level variable", "{{ resource.title | upper }}", resource, "MY-BUCKET"},
+		{"Undefined", "[{{ $.resource.data.Missing.Deep }}]", resource, "[]"},
+		{"If", "{% if $.resource.tags.Env == 'prod' %}Check: Enabled{% else %}Skip{% endif %}", resource, "Check: Enabled"},
+		{"Elif", "{% if resource.data.Size > 5 %}big{% elif resource.data.Size > 1 %}medium{% else %}small{% endif %}", resource, "medium"},
+		{"For", "{% for r in resource.data.Regions %}- {{ r }}\n{% endfor %}", resource, "- us-east-1\n- us-west-2\n"},
+		{"For object", "{% for k, v in resource.tags %}{{ k }}={{ v }};{% endfor %}", resource, "Env=prod;Owner=jane;"},
+		{"For else", "{% for x in [] %}{{ x }}{% else %}empty{% endfor %}", nil, "empty"},
+		{"Loop variables", "{% for x in ['a', 'b', 'c'] %}{{ loop.index }}{{ x }}{% if not loop.last %},{% endif %}{% endfor %}", nil, "1a,2b,3c"},
+		{"Set", "{% set owner = resource.tags.Owner | default('nobody') %}{{ owner }}", resource, "jane"},
+		{"Block set", "b{% set x %}a{{ 1 + 2 }}{% endset %}{{ x }}", nil, "ba3"},
+		{"Default", "{{ resource.tags.Missing | default('none') }}", resource, "none"},
+		{"Dump", "{{ resource.data.Regions | dump | safe }}", resource, `["us-east-1","us-west-2"]`},
+		{"Dump object", "{{ {Name: resource.data.Name, Size: resource.data.Size} | dump }}", resource, `{"Name":"my-bucket","Size":3}`},
+		{"Length", "{{ resource.data.Regions | length }}", resource, "2"},
+		{"Join", "{{ resource.data.Regions | join(', ') }}", resource, "us-east-1, us-west-2"},
+		{"In", "{{ 'us-east-1' in resource.data.Regions }} {{ 'eu-west-1' not in resource.data.Regions }}", resource, "true true"},
+		{"Is defined", "{{ resource.tags.Owner is defined }} {{ resource.tags.Cost is not defined }}", resource, "true true"},
+		{"Inline if", "{{ 'yes' if resource.tags.Env == 'prod' else 'no' }}", resource, "yes"},
+		{"Arithmetic", "{{ 7 // 2 }} {{ 7 % 2 }} {{ 2 ** 3 }} {{ 1 / 4 }} {{ 'a' ~ 1 }}", nil, "3 1 8 0.25 a1"},
+		{"String methods", "{{ resource.title.startsWith('my') }} {{ resource.title.split('-')[1] }} {{ resource.title.toUpperCase() }}", resource, "true bucket MY-BUCKET"},
+		{"Whitespace control", "a\n  {%- if true -%}\n  b\n  {%- endif %}", nil, "ab"},
+		{"Comment", "a{# ignored #}b", nil, "ab"},
+		{"Raw", "{% raw %}{{ not rendered }}{% endraw %}", nil, "{{ not rendered }}"},
+		{"Sort", "{{ ['b', 'C', 'a'] | sort | join }}", nil, "abC"},
+		{"Range", "{% for i in range(3) %}{{ i }}{% endfor %}", nil, "012"},
+		{"Selectattr", "{{ items | selectattr('on') | length }}", map[string]interface{}{"items": []interface{}{map[string]interface{}{"on": true}, map[string]interface{}{"on": false}}}, "1"},
+	}
+	for _, test := range tests {
+		result, err := Render(test.template, test.context)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, result, test.name)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	type test struct {
+		name     string
+		template string
+		expected string
+	}
+	tests := []test{
+		{"Unclosed if", "{% if true %}a", "expected elif or elseif or else or endif, got end of template (if on line 1)"},
+		{"Unknown tag", "a\n{% macro x() %}", "unknown tag \"macro\" (line 2)"},
+		{"Unknown filter", "{{ 1 | nope }}", "filter not found: nope (line 1)"},
+		{"Bad expression", "{{ a + }}", "unexpected end of expression (line 1)"},
+		{"Unterminated output", "{{ a", "unterminated {{ (line 1)"},
+		{"Call non function", "{{ a.b() }}", "unable to call a.b, which is not a function (line 1)"},
+	}
+	for _, test := range tests {
+		_, err := Render(test.template, map[string]interface{}{"a": map[string]interface{}{}})
+		assert.EqualError(t, err, test.expected, test.name)
+	}
+}
@@ -0,0 +1,709 @@
+package nunjucks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// template nodes
+type node interface{}
+
+type textNode struct {
+	text string
+}
+
+type outputNode struct {
+	expr expr
+	line int
+}
+
+type ifBranch struct {
+	cond expr
+	body []node
+}
+
+type ifNode struct {
+	branches []ifBranch
+	elseBody []node
+	line     int
+}
+
+type forNode struct {
+	vars     []string
+	iter     expr
+	body     []node
+	elseBody []node
+	line     int
+}
+
+type setNode struct {
+	targets []string
+	value   expr
+	// for a block set, e.g. {% set x %}...{% endset %}, the body is rendered and assigned instead of a value
+	body []node
+	line int
+}
+
+// expression nodes
+type expr interface{}
+
+type literalExpr struct {
+	value interface{}
+}
+
+type nameExpr struct {
+	name string
+}
+
+type memberExpr struct {
+	target expr
+	key    expr
+}
+
+type callExpr struct {
+	fn     expr
+	args   []expr
+	kwargs map[string]expr
+}
+
+type filterExpr struct {
+	name   string
+	target expr
+	args   []expr
+	kwargs map[string]expr
+}
+
+type testExpr struct {
+	name   string
+	target expr
+	args   []expr
+	negate bool
+}
+
+type unaryExpr struct {
+	op      string
+	operand expr
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+}
+
+type condExpr struct {
+	cond, then, otherwise expr
+}
+
+type listExpr struct {
+	items []expr
+}
+
+type dictExpr struct {
+	keys, values []expr
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse nodes until the end of the template, or until one of the given end tags, which is returned
+func (p *parser) parseNodes(endTags ...string) ([]node, error) {
+	nodes, _, err := p.parseNodesUntil(endTags...)
+	return nodes, err
+}
+
+func (p *parser) parseNodesUntil(endTags ...string) ([]node, *token, error) {
+	var nodes []node
+	for p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		p.pos++
+		switch t.kind {
+		case tokenText:
+			nodes = append(nodes, &textNode{text: t.value})
+		case tokenOutput:
+			e, err := parseExpressionString(t.value, t.line)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, &outputNode{expr: e, line: t.line})
+		case tokenTag:
+			name := tagName(t.value)
+			for _, end := range endTags {
+				if name == end {
+					return nodes, &t, nil
+				}
+			}
+			n, err := p.parseTag(t, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	if len(endTags) > 0 {
+		return nil, nil, fmt.Errorf("expected %s, got end of template", strings.Join(endTags, " or "))
+	}
+	return nodes, nil, nil
+}
+
+func tagName(tag string) string {
+	if fields := strings.Fields(tag); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+func (p *parser) parseTag(t token, name string) (node, error) {
+	args := strings.TrimSpace(strings.TrimPrefix(t.value, name))
+	switch name {
+	case "if":
+		return p.parseIf(t, args)
+	case "for":
+		return p.parseFor(t, args)
+	case "set":
+		return p.parseSet(t, args)
+	}
+	return nil, fmt.Errorf("unknown tag %q (line %d)", name, t.line)
+}
+
+func (p *parser) parseIf(t token, args string) (node, error) {
+	n := &ifNode{line: t.line}
+	cond, err := parseExpressionString(args, t.line)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		body, end, err := p.parseNodesUntil("elif", "elseif", "else", "endif")
+		if err != nil {
+			return nil, fmt.Errorf("%s (if on line %d)", err.Error(), t.line)
+		}
+		n.branches = append(n.branches, ifBranch{cond: cond, body: body})
+		switch tagName(end.value) {
+		case "elif", "elseif":
+			cond, err = parseExpressionString(strings.TrimSpace(strings.TrimPrefix(end.value, tagName(end.value))), end.line)
+			if err != nil {
+				return nil, err
+			}
+		case "else":
+			n.elseBody, err = p.parseNodes("endif")
+			if err != nil {
+				return nil, fmt.Errorf("%s (if on line %d)", err.Error(), t.line)
+			}
+			return n, nil
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parseFor(t token, args string) (node, error) {
+	in := strings.Index(args, " in ")
+	if in < 0 {
+		return nil, fmt.Errorf("expected \"in\" in for loop (line %d)", t.line)
+	}
+	n := &forNode{line: t.line}
+	for _, v := range strings.Split(args[:in], ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return nil, fmt.Errorf("invalid for loop variable (line %d)", t.line)
+		}
+		n.vars = append(n.vars, v)
+	}
+	iter, err := parseExpressionString(args[in+4:], t.line)
+	if err != nil {
+		return nil, err
+	}
+	n.iter = iter
+
+	body, end, err := p.parseNodesUntil("else", "endfor")
+	if err != nil {
+		return nil, fmt.Errorf("%s (for on line %d)", err.Error(), t.line)
+	}
+	n.body = body
+	if tagName(end.value) == "else" {
+		if n.elseBody, err = p.parseNodes("endfor"); err != nil {
+			return nil, fmt.Errorf("%s (for on line %d)", err.Error(), t.line)
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) parseSet(t token, args string) (node, error) {
+	n := &setNode{line: t.line}
+	targets := args
+	eq := strings.Index(args, "=")
+	if eq >= 0 {
+		targets = args[:eq]
+	}
+	for _, v := range strings.Split(targets, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			return nil, fmt.Errorf("invalid set target (line %d)", t.line)
+		}
+		n.targets = append(n.targets, v)
+	}
+	if eq < 0 {
+		body, err := p.parseNodes("endset")
+		if err != nil {
+			return nil, fmt.Errorf("%s (set on line %d)", err.Error(), t.line)
+		}
+		n.body = body
+		return n, nil
+	}
+	value, err := parseExpressionString(args[eq+1:], t.line)
+	if err != nil {
+		return nil, err
+	}
+	n.value = value
+	return n, nil
+}
+
+// expression parser
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	line   int
+}
+
+func parseExpressionString(source string, line int) (expr, error) {
+	tokens, err := lexExpression(source)
+	if err != nil {
+		return nil, fmt.Errorf("%s (line %d)", err.Error(), line)
+	}
+	p := &exprParser{tokens: tokens, line: line}
+	e, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != exprEnd {
+		return nil, p.errorf("unexpected %q", p.peek().value)
+	}
+	return e, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != exprEnd {
+		p.pos++
+	}
+	return t
+}
+
+// is the next token the given operator or keyword
+func (p *exprParser) is(value string) bool {
+	t := p.peek()
+	return (t.kind == exprOperator || t.kind == exprName) && t.value == value
+}
+
+func (p *exprParser) accept(value string) bool {
+	if p.is(value) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(value string) error {
+	if !p.accept(value) {
+		if p.peek().kind == exprEnd {
+			return p.errorf("expected %q, got end of expression", value)
+		}
+		return p.errorf("expected %q, got %q", value, p.peek().value)
+	}
+	return nil
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s (line %d)", fmt.Sprintf(format, args...), p.line)
+}
+
+func (p *exprParser) parseExpression() (expr, error) {
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	// inline if, e.g. "a if b else c"
+	if p.accept("if") {
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		var otherwise expr = &literalExpr{value: undefined{}}
+		if p.accept("else") {
+			if otherwise, err = p.parseExpression(); err != nil {
+				return nil, err
+			}
+		}
+		return &condExpr{cond: cond, then: e, otherwise: otherwise}, nil
+	}
+	return e, nil
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (expr, error) {
+	if p.accept("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "not", operand: operand}, nil
+	}
+	return p.parseIn()
+}
+
+func (p *exprParser) parseIn() (expr, error) {
+	left, err := p.parseIs()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		negate := false
+		if p.is("not") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == exprName && p.tokens[p.pos+1].value == "in" {
+			p.pos++
+			negate = true
+		}
+		if !p.accept("in") {
+			return left, nil
+		}
+		right, err := p.parseIs()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "in", left: left, right: right}
+		if negate {
+			left = &unaryExpr{op: "not", operand: left}
+		}
+	}
+}
+
+func (p *exprParser) parseIs() (expr, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("is") {
+		return left, nil
+	}
+	negate := p.accept("not")
+	name := p.next()
+	if name.kind != exprName {
+		return nil, p.errorf("expected test name after \"is\"")
+	}
+	test := &testExpr{name: name.value, target: left, negate: negate}
+	if p.is("(") {
+		if test.args, _, err = p.parseArgs(); err != nil {
+			return nil, err
+		}
+	} else if t := p.peek(); t.kind == exprNumber || t.kind == exprString {
+		// a single argument may be given without parentheses, e.g. "x is divisibleby 3"
+		arg, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		test.args = []expr{arg}
+	}
+	return test, nil
+}
+
+var compareOperators = []string{"===", "!==", "==", "!=", "<=", ">=", "<", ">"}
+
+func (p *exprParser) parseCompare() (expr, error) {
+	left, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range compareOperators {
+			if p.peek().kind == exprOperator && p.peek().value == o {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseConcat() (expr, error) {
+	return p.parseBinary([]string{"~"}, p.parseAdd)
+}
+
+func (p *exprParser) parseAdd() (expr, error) {
+	return p.parseBinary([]string{"+", "-"}, p.parseMul)
+}
+
+func (p *exprParser) parseMul() (expr, error) {
+	return p.parseBinary([]string{"*", "//", "/", "%"}, p.parsePow)
+}
+
+func (p *exprParser) parsePow() (expr, error) {
+	return p.parseBinary([]string{"**"}, p.parseUnary)
+}
+
+func (p *exprParser) parseBinary(ops []string, operand func() (expr, error)) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range ops {
+			if p.peek().kind == exprOperator && p.peek().value == o {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		p.pos++
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	if p.peek().kind == exprOperator && (p.peek().value == "-" || p.peek().value == "+") {
+		op := p.next().value
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: op, operand: operand}, nil
+	}
+	e, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	return p.parseFilters(e)
+}
+
+func (p *exprParser) parseFilters(e expr) (expr, error) {
+	for p.peek().kind == exprOperator && p.peek().value == "|" {
+		p.pos++
+		name := p.next()
+		if name.kind != exprName {
+			return nil, p.errorf("expected filter name after \"|\"")
+		}
+		f := &filterExpr{name: name.value, target: e}
+		if p.is("(") {
+			var err error
+			if f.args, f.kwargs, err = p.parseArgs(); err != nil {
+				return nil, err
+			}
+		}
+		e = f
+	}
+	return e, nil
+}
+
+func (p *exprParser) parsePostfix() (expr, error) {
+	e, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.peek().kind == exprOperator && p.peek().value == ".":
+			p.pos++
+			name := p.next()
+			if name.kind != exprName && name.kind != exprNumber {
+				return nil, p.errorf("expected property name after \".\"")
+			}
+			e = &memberExpr{target: e, key: &literalExpr{value: name.value}}
+		case p.peek().kind == exprOperator && p.peek().value == "[":
+			p.pos++
+			key, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			e = &memberExpr{target: e, key: key}
+		case p.peek().kind == exprOperator && p.peek().value == "(":
+			args, kwargs, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			e = &callExpr{fn: e, args: args, kwargs: kwargs}
+		default:
+			return e, nil
+		}
+	}
+}
+
+// parse a parenthesised argument list, which may include keyword arguments, e.g. (1, 2, reverse=true)
+func (p *exprParser) parseArgs() ([]expr, map[string]expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, nil, err
+	}
+	var args []expr
+	var kwargs map[string]expr
+	for !p.accept(")") {
+		if len(args) > 0 || len(kwargs) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, nil, err
+			}
+		}
+		if p.peek().kind == exprName && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == exprOperator && p.tokens[p.pos+1].value == "=" {
+			name := p.next().value
+			p.pos++
+			value, err := p.parseExpression()
+			if err != nil {
+				return nil, nil, err
+			}
+			if kwargs == nil {
+				kwargs = map[string]expr{}
+			}
+			kwargs[name] = value
+			continue
+		}
+		arg, err := p.parseExpression()
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, kwargs, nil
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case exprString:
+		value := t.value
+		// adjacent strings are concatenated
+		for p.peek().kind == exprString {
+			value += p.next().value
+		}
+		return &literalExpr{value: value}, nil
+	case exprNumber:
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", t.value)
+		}
+		return &literalExpr{value: f}, nil
+	case exprName:
+		switch t.value {
+		case "true", "True":
+			return &literalExpr{value: true}, nil
+		case "false", "False":
+			return &literalExpr{value: false}, nil
+		case "none", "None", "null":
+			return &literalExpr{value: nil}, nil
+		}
+		return &nameExpr{name: t.value}, nil
+	case exprOperator:
+		switch t.value {
+		case "(":
+			e, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		case "[":
+			list := &listExpr{}
+			for !p.accept("]") {
+				if len(list.items) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+					// allow a trailing comma
+					if p.accept("]") {
+						break
+					}
+				}
+				item, err := p.parseExpression()
+				if err != nil {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+			}
+			return list, nil
+		case "{":
+			dict := &dictExpr{}
+			for !p.accept("}") {
+				if len(dict.keys) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+					if p.accept("}") {
+						break
+					}
+				}
+				var key expr
+				// bare names are keys, as in javascript object literals
+				if p.peek().kind == exprName {
+					key = &literalExpr{value: p.next().value}
+				} else {
+					var err error
+					if key, err = p.parsePrimary(); err != nil {
+						return nil, err
+					}
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				value, err := p.parseExpression()
+				if err != nil {
+					return nil, err
+				}
+				dict.keys = append(dict.keys, key)
+				dict.values = append(dict.values, value)
+			}
+			return dict, nil
+		}
+		return nil, p.errorf("unexpected %q", t.value)
+	}
+	return nil, p.errorf("unexpected end of expression")
+}
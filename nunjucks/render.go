@@ -0,0 +1,677 @@
+package nunjucks
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// undefined is the value of variables and properties which do not exist. As in Nunjucks, it renders as an
+// empty string, and looking up a property of it returns undefined rather than an error.
+type undefined struct{}
+
+// a javascript method bound to its receiver, e.g. "a,b".split
+type boundMethod struct {
+	receiver interface{}
+	name     string
+}
+
+// a global function, e.g. range
+type function func(args []interface{}) (interface{}, error)
+
+type renderer struct {
+	output *strings.Builder
+	scopes []map[string]interface{}
+}
+
+func newRenderer(context map[string]interface{}) *renderer {
+	globals := map[string]interface{}{
+		"range": function(rangeFunction),
+	}
+	root := map[string]interface{}{}
+	for k, v := range context {
+		root[k] = normalize(v)
+		globals[k] = root[k]
+	}
+	// as in Turbot templates, the whole context is also available as $, e.g. {{ $.resource.title }}
+	if _, ok := globals["$"]; !ok {
+		globals["$"] = root
+	}
+	return &renderer{output: &strings.Builder{}, scopes: []map[string]interface{}{globals}}
+}
+
+func (r *renderer) lookup(name string) interface{} {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if v, ok := r.scopes[i][name]; ok {
+			return v
+		}
+	}
+	return undefined{}
+}
+
+func (r *renderer) set(name string, value interface{}) {
+	r.scopes[len(r.scopes)-1][name] = value
+}
+
+func (r *renderer) renderNodes(nodes []node) error {
+	for _, n := range nodes {
+		if err := r.renderNode(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *renderer) renderNode(n node) error {
+	switch n := n.(type) {
+	case *textNode:
+		r.output.WriteString(n.text)
+	case *outputNode:
+		v, err := r.eval(n.expr)
+		if err != nil {
+			return fmt.Errorf("%s (line %d)", err.Error(), n.line)
+		}
+		r.output.WriteString(toString(v))
+	case *ifNode:
+		for _, b := range n.branches {
+			cond, err := r.eval(b.cond)
+			if err != nil {
+				return fmt.Errorf("%s (line %d)", err.Error(), n.line)
+			}
+			if truthy(cond) {
+				return r.renderNodes(b.body)
+			}
+		}
+		return r.renderNodes(n.elseBody)
+	case *forNode:
+		return r.renderFor(n)
+	case *setNode:
+		var value interface{}
+		if n.body != nil {
+			saved := r.output
+			r.output = &strings.Builder{}
+			if err := r.renderNodes(n.body); err != nil {
+				return err
+			}
+			value = r.output.String()
+			r.output = saved
+		} else {
+			var err error
+			if value, err = r.eval(n.value); err != nil {
+				return fmt.Errorf("%s (line %d)", err.Error(), n.line)
+			}
+		}
+		for _, t := range n.targets {
+			r.set(t, value)
+		}
+	}
+	return nil
+}
+
+func (r *renderer) renderFor(n *forNode) error {
+	iter, err := r.eval(n.iter)
+	if err != nil {
+		return fmt.Errorf("%s (line %d)", err.Error(), n.line)
+	}
+
+	// each iteration is a list of values assigned to the loop variables
+	var items [][]interface{}
+	switch v := iter.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if len(n.vars) > 1 {
+				// unpack arrays into several variables, e.g. {% for k, v in [["a", 1]] %}
+				values, _ := item.([]interface{})
+				items = append(items, values)
+			} else {
+				items = append(items, []interface{}{item})
+			}
+		}
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			items = append(items, []interface{}{k, v[k]})
+		}
+	case string:
+		for _, c := range v {
+			items = append(items, []interface{}{string(c)})
+		}
+	}
+
+	if len(items) == 0 {
+		return r.renderNodes(n.elseBody)
+	}
+
+	r.scopes = append(r.scopes, map[string]interface{}{})
+	defer func() { r.scopes = r.scopes[:len(r.scopes)-1] }()
+	for i, values := range items {
+		for j, name := range n.vars {
+			if j < len(values) {
+				r.set(name, values[j])
+			} else {
+				r.set(name, undefined{})
+			}
+		}
+		r.set("loop", map[string]interface{}{
+			"index":     float64(i + 1),
+			"index0":    float64(i),
+			"revindex":  float64(len(items) - i),
+			"revindex0": float64(len(items) - i - 1),
+			"first":     i == 0,
+			"last":      i == len(items)-1,
+			"length":    float64(len(items)),
+		})
+		if err := r.renderNodes(n.body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *renderer) eval(e expr) (interface{}, error) {
+	switch e := e.(type) {
+	case *literalExpr:
+		return e.value, nil
+	case *nameExpr:
+		return r.lookup(e.name), nil
+	case *memberExpr:
+		target, err := r.eval(e.target)
+		if err != nil {
+			return nil, err
+		}
+		key, err := r.eval(e.key)
+		if err != nil {
+			return nil, err
+		}
+		return memberLookup(target, key), nil
+	case *callExpr:
+		return r.evalCall(e)
+	case *filterExpr:
+		target, err := r.eval(e.target)
+		if err != nil {
+			return nil, err
+		}
+		args, err := r.evalArgs(e.args)
+		if err != nil {
+			return nil, err
+		}
+		kwargs := map[string]interface{}{}
+		for k, v := range e.kwargs {
+			if kwargs[k], err = r.eval(v); err != nil {
+				return nil, err
+			}
+		}
+		return applyFilter(e.name, target, args, kwargs)
+	case *testExpr:
+		target, err := r.eval(e.target)
+		if err != nil {
+			return nil, err
+		}
+		args, err := r.evalArgs(e.args)
+		if err != nil {
+			return nil, err
+		}
+		result, err := applyTest(e.name, target, args)
+		if err != nil {
+			return nil, err
+		}
+		return result != e.negate, nil
+	case *unaryExpr:
+		operand, err := r.eval(e.operand)
+		if err != nil {
+			return nil, err
+		}
+		switch e.op {
+		case "not":
+			return !truthy(operand), nil
+		case "-":
+			return -toNumber(operand), nil
+		default:
+			return toNumber(operand), nil
+		}
+	case *binaryExpr:
+		return r.evalBinary(e)
+	case *condExpr:
+		cond, err := r.eval(e.cond)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(cond) {
+			return r.eval(e.then)
+		}
+		return r.eval(e.otherwise)
+	case *listExpr:
+		items, err := r.evalArgs(e.items)
+		if err != nil {
+			return nil, err
+		}
+		if items == nil {
+			items = []interface{}{}
+		}
+		return items, nil
+	case *dictExpr:
+		dict := map[string]interface{}{}
+		for i := range e.keys {
+			k, err := r.eval(e.keys[i])
+			if err != nil {
+				return nil, err
+			}
+			v, err := r.eval(e.values[i])
+			if err != nil {
+				return nil, err
+			}
+			dict[toString(k)] = v
+		}
+		return dict, nil
+	}
+	return nil, fmt.Errorf("unknown expression %T", e)
+}
+
+func (r *renderer) evalArgs(exprs []expr) ([]interface{}, error) {
+	var values []interface{}
+	for _, e := range exprs {
+		v, err := r.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (r *renderer) evalCall(e *callExpr) (interface{}, error) {
+	fn, err := r.eval(e.fn)
+	if err != nil {
+		return nil, err
+	}
+	args, err := r.evalArgs(e.args)
+	if err != nil {
+		return nil, err
+	}
+	switch fn := fn.(type) {
+	case function:
+		return fn(args)
+	case boundMethod:
+		return callMethod(fn.receiver, fn.name, args)
+	}
+	return nil, fmt.Errorf("unable to call %s, which is not a function", describe(e.fn))
+}
+
+func describe(e expr) string {
+	switch e := e.(type) {
+	case *nameExpr:
+		return e.name
+	case *memberExpr:
+		if key, ok := e.key.(*literalExpr); ok {
+			return describe(e.target) + "." + toString(key.value)
+		}
+		return describe(e.target) + "[...]"
+	}
+	return "expression"
+}
+
+func (r *renderer) evalBinary(e *binaryExpr) (interface{}, error) {
+	left, err := r.eval(e.left)
+	if err != nil {
+		return nil, err
+	}
+	// and/or short circuit and return the deciding operand, as in javascript
+	switch e.op {
+	case "and":
+		if !truthy(left) {
+			return left, nil
+		}
+		return r.eval(e.right)
+	case "or":
+		if truthy(left) {
+			return left, nil
+		}
+		return r.eval(e.right)
+	}
+
+	right, err := r.eval(e.right)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "==":
+		return looseEqual(left, right), nil
+	case "!=":
+		return !looseEqual(left, right), nil
+	case "===":
+		return strictEqual(left, right), nil
+	case "!==":
+		return !strictEqual(left, right), nil
+	case "<", ">", "<=", ">=":
+		return compare(e.op, left, right), nil
+	case "~":
+		return toString(left) + toString(right), nil
+	case "+":
+		if _, ok := left.(string); ok {
+			return toString(left) + toString(right), nil
+		}
+		if _, ok := right.(string); ok {
+			return toString(left) + toString(right), nil
+		}
+		return toNumber(left) + toNumber(right), nil
+	case "-":
+		return toNumber(left) - toNumber(right), nil
+	case "*":
+		return toNumber(left) * toNumber(right), nil
+	case "/":
+		return toNumber(left) / toNumber(right), nil
+	case "//":
+		return math.Floor(toNumber(left) / toNumber(right)), nil
+	case "%":
+		return math.Mod(toNumber(left), toNumber(right)), nil
+	case "**":
+		return math.Pow(toNumber(left), toNumber(right)), nil
+	case "in":
+		return contains(right, left)
+	}
+	return nil, fmt.Errorf("unknown operator %s", e.op)
+}
+
+// value helpers
+
+// convert values to the types used by the renderer: numbers are float64, arrays []interface{} and
+// objects map[string]interface{}
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, float64, undefined, function:
+		return v
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = normalize(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for k, item := range v {
+			result[k] = normalize(item)
+		}
+		return result
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for k, item := range v {
+			result[fmt.Sprintf("%v", k)] = normalize(item)
+		}
+		return result
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32:
+		return rv.Float()
+	case reflect.Slice, reflect.Array:
+		result := make([]interface{}, rv.Len())
+		for i := range result {
+			result[i] = normalize(rv.Index(i).Interface())
+		}
+		return result
+	}
+	// anything else is converted via its JSON representation
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return normalize(result)
+}
+
+// the javascript string representation of a value
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil, undefined:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return formatNumber(v)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = toString(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		return "[object Object]"
+	case function, boundMethod:
+		return "function"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func formatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func toNumber(value interface{}) float64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case float64:
+		return v
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case string:
+		s := strings.TrimSpace(v)
+		if s == "" {
+			return 0
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	}
+	return math.NaN()
+}
+
+// javascript truthiness - note empty arrays and objects are truthy
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil, undefined:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+func isNullish(value interface{}) bool {
+	switch value.(type) {
+	case nil, undefined:
+		return true
+	}
+	return false
+}
+
+func strictEqual(a, b interface{}) bool {
+	switch av := a.(type) {
+	case nil, undefined, bool, string, float64:
+		return reflect.TypeOf(a) == reflect.TypeOf(b) && av == b
+	}
+	// arrays and objects are only equal to themselves
+	return sameReference(a, b)
+}
+
+func looseEqual(a, b interface{}) bool {
+	if isNullish(a) || isNullish(b) {
+		return isNullish(a) && isNullish(b)
+	}
+	switch a.(type) {
+	case float64, bool, string:
+		switch b.(type) {
+		case float64, bool, string:
+			if _, ok := a.(string); ok {
+				if _, ok := b.(string); ok {
+					return a == b
+				}
+			}
+			return toNumber(a) == toNumber(b)
+		}
+	}
+	return sameReference(a, b)
+}
+
+func sameReference(a, b interface{}) bool {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if av.Kind() != bv.Kind() {
+		return false
+	}
+	switch av.Kind() {
+	case reflect.Map, reflect.Slice:
+		return av.Pointer() == bv.Pointer() && av.Len() == bv.Len()
+	}
+	return false
+}
+
+func compare(op string, a, b interface{}) bool {
+	as, aString := a.(string)
+	bs, bString := b.(string)
+	var c int
+	if aString && bString {
+		c = strings.Compare(as, bs)
+	} else {
+		af, bf := toNumber(a), toNumber(b)
+		if math.IsNaN(af) || math.IsNaN(bf) {
+			return false
+		}
+		switch {
+		case af < bf:
+			c = -1
+		case af > bf:
+			c = 1
+		}
+	}
+	switch op {
+	case "<":
+		return c < 0
+	case ">":
+		return c > 0
+	case "<=":
+		return c <= 0
+	default:
+		return c >= 0
+	}
+}
+
+// the "in" operator - an item of an array, a substring of a string or a key of an object
+func contains(container, item interface{}) (bool, error) {
+	switch c := container.(type) {
+	case []interface{}:
+		for _, v := range c {
+			if strictEqual(v, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case string:
+		return strings.Contains(c, toString(item)), nil
+	case map[string]interface{}:
+		_, ok := c[toString(item)]
+		return ok, nil
+	}
+	return false, fmt.Errorf("cannot use \"in\" operator to search for %q in unexpected types", toString(item))
+}
+
+func memberLookup(target, key interface{}) interface{} {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		if v, ok := t[toString(key)]; ok {
+			return v
+		}
+	case []interface{}:
+		if k, ok := key.(float64); ok {
+			i := int(k)
+			if i < 0 {
+				i += len(t)
+			}
+			if i >= 0 && i < len(t) {
+				return t[i]
+			}
+			return undefined{}
+		}
+		if key == "length" {
+			return float64(len(t))
+		}
+		if isMethod(target, toString(key)) {
+			return boundMethod{receiver: t, name: toString(key)}
+		}
+	case string:
+		if k, ok := key.(float64); ok {
+			runes := []rune(t)
+			if i := int(k); i >= 0 && i < len(runes) {
+				return string(runes[i])
+			}
+			return undefined{}
+		}
+		if key == "length" {
+			return float64(len([]rune(t)))
+		}
+		if isMethod(target, toString(key)) {
+			return boundMethod{receiver: t, name: toString(key)}
+		}
+	}
+	return undefined{}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func rangeFunction(args []interface{}) (interface{}, error) {
+	var start, stop, step float64 = 0, 0, 1
+	switch len(args) {
+	case 1:
+		stop = toNumber(args[0])
+	case 2:
+		start, stop = toNumber(args[0]), toNumber(args[1])
+	case 3:
+		start, stop, step = toNumber(args[0]), toNumber(args[1]), toNumber(args[2])
+	default:
+		return nil, fmt.Errorf("range expects 1 to 3 arguments")
+	}
+	if step == 0 {
+		return nil, fmt.Errorf("range step must not be zero")
+	}
+	result := []interface{}{}
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		result = append(result, i)
+	}
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package turbot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+
+	connection_manager "github.com/turbot/steampipe-plugin-sdk/v5/connection"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+// Clients are cached by connection name and a hash of the credentials they use, so connections with
+// different credentials never share a client, and new credentials get a new client. Resolving the credentials
+// may read the credentials file or run the credential process, so the key of the client is itself cached,
+// until the connection config or a watched credentials file changes, or the workspace rejects the credentials.
+
+// credentialsGeneration is incremented when a watched credentials file changes, which expires the cached
+// client keys of all connections
+var credentialsGeneration int64
+
+var (
+	// the credentials files being watched
+	watchedCredentialsFiles      = map[string]bool{}
+	watchedCredentialsFilesMutex sync.Mutex
+)
+
+// credentialsCacheKey returns the cache key of the client key of the connection, which changes with the
+// connection config and credentials files
+func credentialsCacheKey(connection *plugin.Connection, config apiClient.ClientConfig) string {
+	configHash := hashStrings(config.Profile, config.Credentials.Workspace, config.Credentials.AccessKey, config.Credentials.SecretKey,
+		config.CredentialProcess, config.CredentialsPath, config.RecordPath, config.ReplayPath, config.SnapshotPath, config.TracePath, strconv.FormatBool(config.DebugQueries))
+	return fmt.Sprintf("turbot_credentials_%s_%s_%d", connection.Name, configHash, atomic.LoadInt64(&credentialsGeneration))
+}
+
+// resolveClientConfig resolves the credentials of the config, returning the cache key of the client of the
+// connection and a config which uses the provider of the credentials, so they are not resolved again
+func resolveClientConfig(ctx context.Context, connection *plugin.Connection, config apiClient.ClientConfig) (string, apiClient.ClientConfig, error) {
+	// replayed and snapshot clients need no credentials
+	if config.ReplayPath != "" || config.SnapshotPath != "" {
+		return clientCacheKey(connection, config.RecordPath, config.ReplayPath, config.SnapshotPath, config.TracePath, strconv.FormatBool(config.DebugQueries)), config, nil
+	}
+	credentials, provider, err := apiClient.ResolveCredentials(config)
+	if err != nil {
+		return "", config, fmt.Errorf("Error creating Turbot client: failed to get credentials, error: %s", err.Error())
+	}
+	if file, ok := provider.(*apiClient.FileProvider); ok {
+		watchCredentialsFile(ctx, file.Path)
+	}
+	config.CredentialProviders = []apiClient.CredentialProvider{provider}
+	return clientCacheKey(connection, credentials.Workspace, credentials.AccessKey, credentials.SecretKey, config.RecordPath, config.TracePath, strconv.FormatBool(config.DebugQueries)), config, nil
+}
+
+// clientCacheKey returns the cache key of the client of the connection with the given credentials
+func clientCacheKey(connection *plugin.Connection, credentials ...string) string {
+	return fmt.Sprintf("turbot_client_%s_%s", connection.Name, hashStrings(credentials...))
+}
+
+// cacheClient caches a new client of the connection, and its key, so connect uses it until the credentials
+// change. The hooks of the client are set here, before it is shared, as setting them on a client in use would
+// race with its requests. The client removes itself from the cache if the workspace rejects its credentials, so
+// the next connect resolves them again, e.g. after the access key is rotated. Its requests are summarised in the
+// turbot_api_call_stats table of the connection, and listed in its turbot_query_explain table if the
+// debug_queries option is set.
+func cacheClient(cache *connection_manager.Cache, connection *plugin.Connection, credentialsKey, clientKey string, client *apiClient.Client) {
+	client.OnAuthFailure = func() {
+		cache.Delete(credentialsKey)
+		cache.Delete(clientKey)
+	}
+	stats, explained := connectionCallStats(connection.Name), connectionExplainedRequests(connection.Name)
+	client.OnRequest = func(request apiClient.RequestStats) {
+		stats.Record(request)
+		if request.Query != "" {
+			explained.add(request)
+		}
+	}
+	cache.Set(clientKey, client)
+	cache.Set(credentialsKey, clientKey)
+}
+
+// watchCredentialsFile expires the cached client keys when the credentials file changes, so new credentials
+// are used without restarting the plugin. Each file is watched once per plugin process.
+func watchCredentialsFile(ctx context.Context, path string) {
+	path = filepath.Clean(path)
+	watchedCredentialsFilesMutex.Lock()
+	defer watchedCredentialsFilesMutex.Unlock()
+	if watchedCredentialsFiles[path] {
+		return
+	}
+
+	logger := plugin.Logger(ctx)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Warn("watchCredentialsFile", "watch_error", err)
+		return
+	}
+	// the file may be replaced rather than written, e.g. by an editor, so its directory is watched
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		logger.Warn("watchCredentialsFile", "watch_error", err, "path", path)
+		watcher.Close()
+		return
+	}
+	watchedCredentialsFiles[path] = true
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// a removed file leaves its clients in use until the workspace rejects their credentials
+				if filepath.Clean(event.Name) == path && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					atomic.AddInt64(&credentialsGeneration, 1)
+					logger.Info("watchCredentialsFile", "changed", path)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("watchCredentialsFile", "watch_error", err, "path", path)
+			}
+		}
+	}()
+}
+
+// hashStrings returns a short hash of the strings, so secrets are not part of cache keys
+func hashStrings(values ...string) string {
+	hash := sha256.Sum256([]byte(strings.Join(values, "\x00")))
+	return hex.EncodeToString(hash[:8])
+}
@@ -10,6 +10,19 @@ type turbotConfig struct {
 	AccessKey *string `cty:"access_key"`
 	SecretKey *string `cty:"secret_key"`
 	Workspace *string `cty:"workspace"`
+
+	CredentialProcess *string `cty:"credential_process"`
+
+	ResourceTypes []string `cty:"resource_types"`
+
+	RecordCassette *string `cty:"record_cassette"`
+	ReplayCassette *string `cty:"replay_cassette"`
+	SnapshotPath   *string `cty:"snapshot_path"`
+
+	TraceFile    *string `cty:"trace_file"`
+	DebugQueries *bool   `cty:"debug_queries"`
+
+	DriftProfile *string `cty:"drift_profile"`
 }
 
 var ConfigSchema = map[string]*schema.Attribute{
@@ -25,6 +38,31 @@ var ConfigSchema = map[string]*schema.Attribute{
 	"workspace": {
 		Type: schema.TypeString,
 	},
+	"credential_process": {
+		Type: schema.TypeString,
+	},
+	"resource_types": {
+		Type: schema.TypeList,
+		Elem: &schema.Attribute{Type: schema.TypeString},
+	},
+	"record_cassette": {
+		Type: schema.TypeString,
+	},
+	"replay_cassette": {
+		Type: schema.TypeString,
+	},
+	"snapshot_path": {
+		Type: schema.TypeString,
+	},
+	"trace_file": {
+		Type: schema.TypeString,
+	},
+	"debug_queries": {
+		Type: schema.TypeBool,
+	},
+	"drift_profile": {
+		Type: schema.TypeString,
+	},
 }
 
 func ConfigInstance() interface{} {
@@ -20,21 +20,52 @@ func Plugin(ctx context.Context) *plugin.Plugin {
 			ShouldIgnoreError: errors.NotFoundError,
 		},
 		DefaultTransform: transform.FromGo(),
-		TableMap: map[string]*plugin.Table{
-			"turbot_active_grant":   tableTurbotActiveGrant(ctx),
-			"turbot_control":        tableTurbotControl(ctx),
-			"turbot_control_type":   tableTurbotControlType(ctx),
-			"turbot_grant":          tableTurbotGrant(ctx),
-			"turbot_mod_version":    tableTurbotModVersion(ctx),
-			"turbot_notification":   tableTurbotNotification(ctx),
-			"turbot_policy_setting": tableTurbotPolicySetting(ctx),
-			"turbot_policy_type":    tableTurbotPolicyType(ctx),
-			"turbot_policy_value":   tableTurbotPolicyValue(ctx),
-			"turbot_resource":       tableTurbotResource(ctx),
-			"turbot_resource_type":  tableTurbotResourceType(ctx),
-			"turbot_smart_folder":   tableTurbotSmartFolder(ctx),
-			"turbot_tag":            tableTurbotTag(ctx),
-		},
+		SchemaMode:       plugin.SchemaModeDynamic,
+		TableMapFunc:     pluginTableDefinitions,
 	}
 	return p
 }
+
+// pluginTableDefinitions returns the static tables, plus the drift table if the drift_profile connection
+// option is set, and a table for each resource type configured in the resource_types connection option.
+// Dynamic tables are skipped if their resource types can't be read.
+func pluginTableDefinitions(ctx context.Context, d *plugin.TableMapData) (map[string]*plugin.Table, error) {
+	tables := map[string]*plugin.Table{
+		"turbot_active_grant":              tableTurbotActiveGrant(ctx),
+		"turbot_api_call_stats":            tableTurbotAPICallStats(ctx),
+		"turbot_calculated_policy_preview": tableTurbotCalculatedPolicyPreview(ctx),
+		"turbot_control":                   tableTurbotControl(ctx),
+		"turbot_control_type":              tableTurbotControlType(ctx),
+		"turbot_credential_profile":        tableTurbotCredentialProfile(ctx),
+		"turbot_directory":                 tableTurbotDirectory(ctx),
+		"turbot_grant":                     tableTurbotGrant(ctx),
+		"turbot_mod_version":               tableTurbotModVersion(ctx),
+		"turbot_notification":              tableTurbotNotification(ctx),
+		"turbot_policy_setting":            tableTurbotPolicySetting(ctx),
+		"turbot_policy_type":               tableTurbotPolicyType(ctx),
+		"turbot_policy_value":              tableTurbotPolicyValue(ctx),
+		"turbot_query_explain":             tableTurbotQueryExplain(ctx),
+		"turbot_resource":                  tableTurbotResource(ctx),
+		"turbot_resource_type":             tableTurbotResourceType(ctx),
+		"turbot_smart_folder":              tableTurbotSmartFolder(ctx),
+		"turbot_smart_folder_attachment":   tableTurbotSmartFolderAttachment(ctx),
+		"turbot_tag":                       tableTurbotTag(ctx),
+		"turbot_workspace":                 tableTurbotWorkspace(ctx),
+	}
+
+	// drift is only reported when there is a workspace to compare with
+	if GetConfig(d.Connection).DriftProfile != nil {
+		tables["turbot_policy_setting_drift"] = tableTurbotPolicySettingDrift(ctx)
+	}
+
+	// the static tables are still available if the resource types can't be read, e.g. while the workspace is down
+	resourceTypeTables, err := resourceTypeTables(ctx, d, tables)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot.pluginTableDefinitions", "resource_types_error", err)
+		return tables, nil
+	}
+	for name, table := range resourceTypeTables {
+		tables[name] = table
+	}
+	return tables, nil
+}
@@ -0,0 +1,80 @@
+package turbot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+// queryVariant is a query for the workspaces supporting an API feature. Turbot Enterprise lags the SaaS release,
+// so a query using newer fields has a variant without them for older workspaces.
+type queryVariant struct {
+	// the feature used by the query, see apiClient.Features. Empty if every workspace supports it.
+	feature string
+	query   string
+	// the columns read from fields the variant does not select, which are NULL
+	unsupportedColumns []string
+}
+
+// queryRegistry holds the variants of each query by name, the variant needing the newest workspace first
+var queryRegistry = map[string][]queryVariant{
+	"activeGrantList": {
+		{feature: apiClient.FeatureActiveGrants, query: activeGrants},
+	},
+	"modVersionList": {
+		{feature: apiClient.FeatureModVersionSearch, query: queryModVersions},
+	},
+	"notificationGet": {
+		{feature: apiClient.FeatureNotificationActiveGrants, query: queryNotificationGetFields},
+		{query: queryNotificationGetFieldsLegacy, unsupportedColumns: notificationActiveGrantColumns},
+	},
+	"notificationList": {
+		{feature: apiClient.FeatureNotificationActiveGrants, query: queryNotificationList},
+		{query: queryNotificationListLegacy, unsupportedColumns: notificationActiveGrantColumns},
+	},
+}
+
+// selectQuery returns the variant of a registered query supported by the workspace of the connection. Support of
+// the feature of each variant is detected on first use and cached, see workspaceSupports. If it cannot be
+// detected, that variant is used, so the query fails only if the workspace rejects it. Requested columns which the
+// variant does not read are logged, since they are NULL rather than failing the query.
+func selectQuery(ctx context.Context, d *plugin.QueryData, table, name string) (string, error) {
+	variants := queryRegistry[name]
+	for _, variant := range variants {
+		if variant.feature != "" {
+			supported, err := workspaceSupports(ctx, d, variant.feature)
+			if err != nil {
+				plugin.Logger(ctx).Warn(table+".selectQuery", "query", name, "feature", variant.feature, "feature_error", err, "message", "using the query for the feature")
+				return variant.query, nil
+			}
+			if !supported {
+				continue
+			}
+		}
+		if unsupported := requestedColumns(d, variant.unsupportedColumns); len(unsupported) > 0 {
+			plugin.Logger(ctx).Warn(table+".selectQuery", "query", name, "message", "columns not supported by the workspace are NULL", "columns", strings.Join(unsupported, ","))
+		}
+		return variant.query, nil
+	}
+
+	feature := variants[len(variants)-1].feature
+	return "", fmt.Errorf("%s is not supported by the workspace, which has no %s field", table, apiClient.FeatureField(feature))
+}
+
+// requestedColumns returns which of the columns are requested by the query
+func requestedColumns(d *plugin.QueryData, columns []string) []string {
+	var requested []string
+	for _, column := range columns {
+		for _, c := range d.QueryContext.Columns {
+			if c == column {
+				requested = append(requested, column)
+				break
+			}
+		}
+	}
+	return requested
+}
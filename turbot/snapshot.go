@@ -0,0 +1,121 @@
+package turbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient/snapshot"
+)
+
+// A list query run to export a snapshot, and the name of its paging variable
+type snapshotQuery struct {
+	collection     string
+	query          string
+	filter         string
+	pagingVariable string
+}
+
+// snapshotQueries are the list queries of the tables served from a snapshot. Where a table's get hydrate
+// selects other fields than its list hydrate, those fields are listed too. The items of a collection read
+// by several queries are merged.
+var snapshotQueries = []snapshotQuery{
+	{collection: "activeGrants", query: activeGrants, pagingVariable: "paging"},
+	{collection: "controlTypes", query: queryControlTypeList, pagingVariable: "next_token"},
+	{collection: "controlTypes", query: snapshotListQuery("controlTypes", queryControlTypeGetFields), pagingVariable: "next_token"},
+	{collection: "controls", query: queryControlList, pagingVariable: "next_token"},
+	{collection: "grants", query: grants, pagingVariable: "paging"},
+	{collection: "policySettings", query: queryPolicySettingList, pagingVariable: "next_token"},
+	{collection: "policyTypes", query: queryPolicyTypeList, pagingVariable: "next_token"},
+	{collection: "policyTypes", query: snapshotListQuery("policyTypes", queryPolicyTypeGetFields), pagingVariable: "next_token"},
+	{collection: "policyValues", query: queryPolicyValueList, pagingVariable: "next_token"},
+	{collection: "resourceTypes", query: queryResourceTypeList, pagingVariable: "next_token"},
+	{collection: "resourceTypes", query: snapshotListQuery("resourceTypes", queryResourceTypeGetFields), pagingVariable: "next_token"},
+	{collection: "resourceTypes", query: snapshotListQuery("resourceTypes", queryResourceTypeSchemaFields), pagingVariable: "next_token"},
+	{collection: "resources", query: queryResourceList, pagingVariable: "next_token"},
+	{collection: "resources", query: querySmartFolderList, filter: "resourceTypeId:'tmod:@turbot/turbot#/resource/types/smartFolder' resourceTypeLevel:self", pagingVariable: "next_token"},
+	{collection: "tags", query: queryTagList, pagingVariable: "paging"},
+}
+
+// snapshotListQuery returns a list query of a collection selecting the given fields of each item, and
+// its id so it is merged with the items of the other queries
+func snapshotListQuery(collection string, fields string) string {
+	return fmt.Sprintf(`
+query snapshotList($filter: [String!], $next_token: String) {
+	%s(filter: $filter, paging: $next_token) {
+		items {
+%s
+			turbot {
+				id
+			}
+		}
+		paging {
+			next
+		}
+	}
+}
+`, collection, fields)
+}
+
+// ExportSnapshot pages every item of the collections served from a snapshot, see the snapshot_path
+// connection option, and writes them to w as a snapshot. Progress is called with the number of items of
+// a collection read so far, after each page.
+func ExportSnapshot(conn *apiClient.Client, w io.Writer, progress func(collection string, count int)) error {
+	store, err := snapshot.NewStore(map[string][]map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	for _, q := range snapshotQueries {
+		filters := []string{"limit:5000"}
+		if q.filter != "" {
+			filters = append([]string{q.filter}, filters...)
+		}
+		nextToken := ""
+		for {
+			variables := map[string]interface{}{"filter": filters, q.pagingVariable: nextToken}
+			result := map[string]json.RawMessage{}
+			if err := conn.DoRequest(q.query, variables, &result); err != nil {
+				return fmt.Errorf("error exporting %s: %s", q.collection, err.Error())
+			}
+
+			// decode numbers as such, so large integers in resource data are exported exactly
+			data := map[string]interface{}{}
+			for key, raw := range result {
+				decoder := json.NewDecoder(bytes.NewReader(raw))
+				decoder.UseNumber()
+				var value interface{}
+				if err := decoder.Decode(&value); err != nil {
+					return fmt.Errorf("error exporting %s: %s", q.collection, err.Error())
+				}
+				data[key] = value
+			}
+			if err := store.AddResponse(q.query, variables, data); err != nil {
+				return fmt.Errorf("error exporting %s: %s", q.collection, err.Error())
+			}
+			if progress != nil {
+				progress(q.collection, store.Count(q.collection))
+			}
+
+			next, _ := jsonPath(data, q.collection, "paging", "next").(string)
+			if next == "" {
+				break
+			}
+			nextToken = next
+		}
+	}
+	return store.WriteSnapshot(w)
+}
+
+// jsonPath returns the value at a path of nested JSON objects, or nil
+func jsonPath(value interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
@@ -161,10 +161,16 @@ func listActiveGrants(ctx context.Context, d *plugin.QueryData, h *plugin.Hydrat
 		filters = append(filters, fmt.Sprintf("limit:%s", strconv.Itoa(int(pageLimit))))
 	}
 
+	query, err := selectQuery(ctx, d, "turbot_active_grant", "activeGrantList")
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_active_grants.listActiveGrants", "query_error", err)
+		return nil, err
+	}
+
 	nextToken := ""
 	for {
 		result := &ActiveGrantInfo{}
-		err = conn.DoRequest(activeGrants, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+		err = conn.DoRequestContext(requestContext(ctx, d), query, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
 		if err != nil {
 			plugin.Logger(ctx).Error("turbot_active_grants.listActiveGrants", "query_error", err)
 		}
@@ -172,7 +178,7 @@ func listActiveGrants(ctx context.Context, d *plugin.QueryData, h *plugin.Hydrat
 
 			d.StreamListItem(ctx, ActiveGrantDetails)
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
@@ -0,0 +1,105 @@
+package turbot
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+func tableTurbotAPICallStats(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "turbot_api_call_stats",
+		Description: "A summary of the Turbot API requests made by the connection since the plugin started, by table and GraphQL operation.",
+		List: &plugin.ListConfig{
+			Hydrate: listAPICallStats,
+		},
+		Columns: []*plugin.Column{
+			// Top columns
+			{Name: "table_name", Type: proto.ColumnType_STRING, Transform: transform.FromField("Table").NullIfZero(), Description: "The table the requests were made for, or null for requests made outside a table, e.g. to connect."},
+			{Name: "operation", Type: proto.ColumnType_STRING, Description: "The GraphQL operation of the requests, e.g. resourceList."},
+			{Name: "calls", Type: proto.ColumnType_INT, Description: "The number of requests."},
+			{Name: "errors", Type: proto.ColumnType_INT, Description: "The number of requests which failed."},
+			{Name: "avg_latency_ms", Type: proto.ColumnType_DOUBLE, Description: "The average latency of the requests in milliseconds."},
+			{Name: "max_latency_ms", Type: proto.ColumnType_DOUBLE, Description: "The latency of the slowest request in milliseconds."},
+			// Other columns
+			{Name: "rows", Type: proto.ColumnType_INT, Description: "The number of items returned by the requests."},
+			{Name: "bytes_received", Type: proto.ColumnType_INT, Description: "The size of the responses in bytes."},
+			{Name: "total_latency_ms", Type: proto.ColumnType_DOUBLE, Description: "The total latency of the requests in milliseconds."},
+			{Name: "last_error_class", Type: proto.ColumnType_STRING, Transform: transform.FromField("LastErrorClass").NullIfZero(), Description: "The class of the error of the last failed request: auth, throttled, server, client, network, not_found or graphql."},
+			{Name: "first_call", Type: proto.ColumnType_TIMESTAMP, Description: "The time of the first request."},
+			{Name: "last_call", Type: proto.ColumnType_TIMESTAMP, Description: "The time of the last request."},
+		},
+	}
+}
+
+var (
+	// the summaries of the requests of each connection, by connection name
+	callStats      = map[string]*apiClient.CallStats{}
+	callStatsMutex sync.Mutex
+)
+
+// connectionCallStats returns the summaries of the requests of a connection, which outlive its clients
+func connectionCallStats(connection string) *apiClient.CallStats {
+	callStatsMutex.Lock()
+	defer callStatsMutex.Unlock()
+	stats, ok := callStats[connection]
+	if !ok {
+		stats = &apiClient.CallStats{}
+		callStats[connection] = stats
+	}
+	return stats
+}
+
+// APICallStats is a row of the turbot_api_call_stats table
+type APICallStats struct {
+	Table          string
+	Operation      string
+	Calls          int64
+	Errors         int64
+	AvgLatencyMs   float64
+	MaxLatencyMs   float64
+	Rows           int64
+	BytesReceived  int64
+	TotalLatencyMs float64
+	LastErrorClass string
+	FirstCall      time.Time
+	LastCall       time.Time
+}
+
+func listAPICallStats(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	for _, summary := range connectionCallStats(d.Connection.Name).Summaries() {
+		row := APICallStats{
+			Table:          summary.Table,
+			Operation:      summary.Operation,
+			Calls:          summary.Calls,
+			Errors:         summary.Errors,
+			MaxLatencyMs:   milliseconds(summary.MaxLatency),
+			Rows:           summary.Rows,
+			BytesReceived:  summary.BytesReceived,
+			TotalLatencyMs: milliseconds(summary.TotalLatency),
+			LastErrorClass: summary.LastError,
+			FirstCall:      summary.FirstCall,
+			LastCall:       summary.LastCall,
+		}
+		if summary.Calls > 0 {
+			row.AvgLatencyMs = row.TotalLatencyMs / float64(summary.Calls)
+		}
+		d.StreamListItem(ctx, row)
+
+		// Context can be cancelled due to manual cancellation or the limit has been hit
+		if rowsRemaining(ctx, d) == 0 {
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
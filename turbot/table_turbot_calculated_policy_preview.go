@@ -0,0 +1,319 @@
+package turbot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/helpers"
+	"github.com/turbot/steampipe-plugin-turbot/nunjucks"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+func tableTurbotCalculatedPolicyPreview(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "turbot_calculated_policy_preview",
+		Description: "Preview a calculated policy for a resource, by running its template input and rendering its template.",
+		List: &plugin.ListConfig{
+			KeyColumns: []*plugin.KeyColumn{
+				{Name: "resource_id", Require: plugin.Required},
+				{Name: "policy_setting_id", Require: plugin.Optional},
+				{Name: "policy_type_uri", Require: plugin.Optional},
+				{Name: "template_input", Require: plugin.Optional},
+				{Name: "template", Require: plugin.Optional},
+			},
+			Hydrate: listCalculatedPolicyPreview,
+		},
+		Columns: []*plugin.Column{
+			// Top columns
+			{Name: "resource_id", Type: proto.ColumnType_INT, Transform: transform.FromField("ResourceID"), Description: "ID of the resource the policy is calculated for."},
+			{Name: "policy_setting_id", Type: proto.ColumnType_INT, Transform: transform.FromField("PolicySettingID").Transform(transform.NullIfZeroValue), Description: "ID of the calculated policy setting to preview."},
+			{Name: "policy_type_uri", Type: proto.ColumnType_STRING, Transform: transform.FromField("PolicyTypeURI").Transform(transform.NullIfZeroValue), Description: "URI of the policy type. If no policy setting is given, the default template of the policy type is previewed."},
+			{Name: "rendered", Type: proto.ColumnType_STRING, Transform: transform.FromField("Rendered").Transform(transform.NullIfZeroValue), Description: "Output of the template, a YAML string."},
+			{Name: "value", Type: proto.ColumnType_JSON, Description: "Value of the policy, parsed from the rendered template."},
+			{Name: "input", Type: proto.ColumnType_JSON, Description: "Result of the template input queries, used as the input to the template."},
+			{Name: "input_error", Type: proto.ColumnType_STRING, Transform: transform.FromField("InputError").Transform(transform.NullIfZeroValue), Description: "Error running the template input queries."},
+			{Name: "render_error", Type: proto.ColumnType_STRING, Transform: transform.FromField("RenderError").Transform(transform.NullIfZeroValue), Description: "Error rendering the template, or parsing the rendered YAML."},
+			// Other columns
+			{Name: "template", Type: proto.ColumnType_STRING, Description: "Nunjucks template rendered to calculate the policy value. Set this to preview a template which has not been saved."},
+			{Name: "template_input", Type: proto.ColumnType_STRING, Transform: transform.FromField("TemplateInput").Transform(transform.NullIfZeroValue), Description: "GraphQL query, or YAML list of queries, run for the resource to get the template input. Set this to preview an input which has not been saved."},
+			{Name: "workspace", Type: proto.ColumnType_STRING, Hydrate: plugin.HydrateFunc(getTurbotWorkspace).WithCache(), Transform: transform.FromValue(), Description: "Specifies the workspace URL."},
+		},
+	}
+}
+
+const (
+	queryCalculatedPolicySetting = `
+query calculatedPolicySetting($id: ID!) {
+	policySetting(id: $id) {
+		template
+		templateInput
+		type {
+			uri
+		}
+	}
+}
+`
+
+	queryCalculatedPolicyType = `
+query calculatedPolicyType($id: ID!) {
+	policyType(id: $id) {
+		defaultTemplate
+		defaultTemplateInput
+		uri
+	}
+}
+`
+)
+
+type CalculatedPolicyPreview struct {
+	ResourceID      int64
+	PolicySettingID int64
+	PolicyTypeURI   string
+	Template        string
+	TemplateInput   string
+	Input           map[string]interface{}
+	InputError      string
+	Rendered        string
+	Value           interface{}
+	RenderError     string
+}
+
+type CalculatedPolicySettingResponse struct {
+	PolicySetting struct {
+		Template      string
+		TemplateInput interface{}
+		Type          struct {
+			URI string
+		}
+	}
+}
+
+type CalculatedPolicyTypeResponse struct {
+	PolicyType struct {
+		DefaultTemplate      string
+		DefaultTemplateInput interface{}
+		URI                  string
+	}
+}
+
+func listCalculatedPolicyPreview(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	conn, err := connect(ctx, d)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_calculated_policy_preview.listCalculatedPolicyPreview", "connection_error", err)
+		return nil, err
+	}
+	quals := d.EqualsQuals
+
+	// Start with the template and input of the policy setting or policy type, if given
+	base := CalculatedPolicyPreview{}
+	var templateInput interface{}
+	switch {
+	case quals["policy_setting_id"] != nil:
+		base.PolicySettingID = quals["policy_setting_id"].GetInt64Value()
+		result := &CalculatedPolicySettingResponse{}
+		err = conn.DoRequestContext(requestContext(ctx, d), queryCalculatedPolicySetting, map[string]interface{}{"id": strconv.FormatInt(base.PolicySettingID, 10)}, result)
+		if err != nil {
+			plugin.Logger(ctx).Error("turbot_calculated_policy_preview.listCalculatedPolicyPreview", "query_error", err)
+			return nil, err
+		}
+		base.PolicyTypeURI = result.PolicySetting.Type.URI
+		base.Template = result.PolicySetting.Template
+		templateInput = result.PolicySetting.TemplateInput
+	case quals["policy_type_uri"] != nil:
+		result := &CalculatedPolicyTypeResponse{}
+		err = conn.DoRequestContext(requestContext(ctx, d), queryCalculatedPolicyType, map[string]interface{}{"id": quals["policy_type_uri"].GetStringValue()}, result)
+		if err != nil {
+			plugin.Logger(ctx).Error("turbot_calculated_policy_preview.listCalculatedPolicyPreview", "query_error", err)
+			return nil, err
+		}
+		base.PolicyTypeURI = result.PolicyType.URI
+		base.Template = result.PolicyType.DefaultTemplate
+		templateInput = result.PolicyType.DefaultTemplateInput
+	}
+
+	// A template or input given in the query takes precedence
+	if quals["template"] != nil {
+		base.Template = quals["template"].GetStringValue()
+	}
+	if quals["template_input"] != nil {
+		templateInput = quals["template_input"].GetStringValue()
+	}
+	if base.Template == "" {
+		return nil, fmt.Errorf("turbot_calculated_policy_preview requires a policy_setting_id, policy_type_uri or template")
+	}
+	queries, err := templateInputQueries(templateInput)
+	if err != nil {
+		return nil, err
+	}
+	base.TemplateInput, _ = helpers.InterfaceToStringOrYaml(templateInput)
+
+	for _, resourceID := range qualInt64Values(quals["resource_id"]) {
+		preview := base
+		preview.ResourceID = resourceID
+		previewCalculatedPolicy(requestContext(ctx, d), conn, &preview, queries)
+		d.StreamListItem(ctx, preview)
+
+		// Context can be cancelled due to manual cancellation or the limit has been hit
+		if rowsRemaining(ctx, d) == 0 {
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// run the template input queries for the resource and render the template with the result. Errors are
+// recorded in the preview rather than returned, since finding them is the point of the preview.
+func previewCalculatedPolicy(ctx context.Context, conn *apiClient.Client, preview *CalculatedPolicyPreview, queries []string) {
+	preview.Input = map[string]interface{}{}
+	for _, q := range queries {
+		query, uses := templateInputQuery(q)
+		variables := map[string]interface{}{}
+		if uses {
+			variables["resourceId"] = strconv.FormatInt(preview.ResourceID, 10)
+		}
+		result := map[string]interface{}{}
+		if err := conn.DoRequestContext(ctx, query, variables, &result); err != nil {
+			plugin.Logger(ctx).Debug("turbot_calculated_policy_preview.previewCalculatedPolicy", "query", query, "input_error", err)
+			preview.InputError = err.Error()
+			return
+		}
+		helpers.MergeMaps(preview.Input, result)
+	}
+
+	rendered, err := nunjucks.Render(preview.Template, preview.Input)
+	if err != nil {
+		preview.RenderError = fmt.Sprintf("error rendering template: %s", err.Error())
+		return
+	}
+	preview.Rendered = rendered
+	value, err := helpers.ParseYamlString(rendered)
+	if err != nil {
+		preview.RenderError = fmt.Sprintf("error parsing rendered template: %s", err.Error())
+		return
+	}
+	preview.Value = helpers.NormalizeYamlValue(value)
+}
+
+// templateInputQueries returns the queries of a template input, which is either a single GraphQL query, or a
+// list of queries whose results are merged
+func templateInputQueries(templateInput interface{}) ([]string, error) {
+	switch v := templateInput.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		var queries []string
+		for _, q := range v {
+			queries = append(queries, helpers.InterfaceToString(q))
+		}
+		return queries, nil
+	case string:
+		s := strings.TrimSpace(v)
+		if s == "" {
+			return nil, nil
+		}
+		// a list of queries is given as a YAML list
+		if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "[") {
+			parsed, err := helpers.ParseYamlString(s)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing template input: %s", err.Error())
+			}
+			if list, ok := parsed.([]interface{}); ok {
+				return templateInputQueries(list)
+			}
+		}
+		return []string{s}, nil
+	}
+	return nil, fmt.Errorf("unexpected template input type %T", templateInput)
+}
+
+var operationHeaderRegex = regexp.MustCompile(`^\s*(query)?\s*([_A-Za-z][_0-9A-Za-z]*)?\s*(\([^)]*\))?\s*\{`)
+
+// templateInputQuery prepares a template input query to be run for a resource. Template inputs are written
+// relative to the resource the policy is calculated for, e.g. { resource { data } }, so top-level resource
+// fields with no arguments are given the $resourceId variable, which is declared if it is used. It returns
+// the query and whether it uses the variable.
+func templateInputQuery(query string) (string, bool) {
+	header := operationHeaderRegex.FindStringSubmatch(query)
+	if header == nil {
+		return query, false
+	}
+	body := query[len(header[0])-1:]
+	body = injectResourceId(body)
+	if !strings.Contains(body, "$resourceId") {
+		return query, false
+	}
+
+	variables := header[3]
+	switch {
+	case variables == "":
+		variables = "($resourceId: ID!)"
+	case !strings.Contains(variables, "$resourceId"):
+		variables = "($resourceId: ID!, " + strings.TrimPrefix(variables, "(")
+	}
+	name := header[2]
+	if name == "" {
+		name = "TemplateInput"
+	}
+	return fmt.Sprintf("query %s%s %s", name, variables, body), true
+}
+
+// add (id: $resourceId) to the top level resource fields of the selection set
+func injectResourceId(body string) string {
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '"':
+			// copy string literals as is
+			j := i + 1
+			for j < len(body) && body[j] != '"' {
+				if body[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(body) {
+				j = len(body) - 1
+			}
+			b.WriteString(body[i : j+1])
+			i = j
+			continue
+		case c == '#':
+			// copy comments as is
+			j := strings.IndexByte(body[i:], '\n')
+			if j < 0 {
+				j = len(body) - i - 1
+			}
+			b.WriteString(body[i : i+j+1])
+			i += j
+			continue
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case depth == 1 && strings.HasPrefix(body[i:], "resource") && (i == 0 || !isGraphQLNameChar(body[i-1])):
+			rest := body[i+len("resource"):]
+			trimmed := strings.TrimLeftFunc(rest, unicode.IsSpace)
+			if strings.HasPrefix(trimmed, "{") {
+				b.WriteString("resource(id: $resourceId)")
+				i += len("resource") - 1
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isGraphQLNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -155,7 +155,7 @@ func listControl(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData
 	nextToken := ""
 	for {
 		result := &ControlsResponse{}
-		err = conn.DoRequest(queryControlList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+		err = conn.DoRequestContext(requestContext(ctx, d), queryControlList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
 		if err != nil {
 			plugin.Logger(ctx).Error("turbot_control.listControl", "query_error", err)
 			return nil, err
@@ -164,7 +164,7 @@ func listControl(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData
 			d.StreamListItem(ctx, r)
 
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
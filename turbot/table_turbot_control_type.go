@@ -2,6 +2,7 @@ package turbot
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 
@@ -89,9 +90,7 @@ query controlTypeList($filter: [String!], $next_token: String) {
 }
 `
 
-	queryControlTypeGet = `
-query controlTypeGet($id: ID!) {
-	controlType(id: $id) {
+	queryControlTypeGetFields = `
 		category {
 			turbot {
 				id
@@ -117,10 +116,7 @@ query controlTypeGet($id: ID!) {
 			updateTimestamp
 			versionId
 		}
-		uri
-	}
-}
-`
+		uri`
 )
 
 func listControlType(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
@@ -162,7 +158,7 @@ func listControlType(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrate
 	nextToken := ""
 	for {
 		result := &ControlTypesResponse{}
-		err = conn.DoRequest(queryControlTypeList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+		err = conn.DoRequestContext(requestContext(ctx, d), queryControlTypeList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
 		if err != nil {
 			plugin.Logger(ctx).Error("turbot_control_type.listControlType", "query_error", err)
 			return nil, err
@@ -171,7 +167,7 @@ func listControlType(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrate
 			d.StreamListItem(ctx, r)
 
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
@@ -192,11 +188,19 @@ func getControlType(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateD
 	}
 	quals := d.EqualsQuals
 	id := quals["id"].GetInt64Value()
-	result := &ControlTypeResponse{}
-	err = conn.DoRequest(queryControlTypeGet, map[string]interface{}{"id": id}, result)
+	// Lookups made concurrently, e.g. for "id in (...)", are sent as a single aliased request
+	data, err := conn.Batcher("controlType", queryControlTypeGetFields).Get(ctx, strconv.FormatInt(id, 10))
 	if err != nil {
 		plugin.Logger(ctx).Error("turbot_control_type.getControlType", "query_error", err)
 		return nil, err
 	}
-	return result.ControlType, nil
+	if data == nil {
+		return nil, nil
+	}
+	var result ControlType
+	if err = json.Unmarshal(data, &result); err != nil {
+		plugin.Logger(ctx).Error("turbot_control_type.getControlType", "unmarshal_error", err)
+		return nil, err
+	}
+	return result, nil
 }
@@ -0,0 +1,110 @@
+package turbot
+
+import (
+	"context"
+	"os"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+func tableTurbotCredentialProfile(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "turbot_credential_profile",
+		Description: "The profiles of the Turbot CLI credentials file, and the source of the credentials the connection uses. Secrets are never returned.",
+		List: &plugin.ListConfig{
+			Hydrate: listCredentialProfile,
+		},
+		Columns: []*plugin.Column{
+			// Top columns
+			{Name: "name", Type: proto.ColumnType_STRING, Description: "Name of the profile, or null for credentials which are not from a profile."},
+			{Name: "source", Type: proto.ColumnType_STRING, Description: "Source of the credentials: profile, config, credential_process, env or custom."},
+			{Name: "in_effect", Type: proto.ColumnType_BOOL, Description: "True if the connection uses these credentials."},
+			{Name: "workspace", Type: proto.ColumnType_STRING, Description: "Workspace of the credentials."},
+			{Name: "error", Type: proto.ColumnType_STRING, Description: "Why the credentials cannot be used, e.g. a missing key or an environment variable which is not set."},
+			// Other columns
+			{Name: "access_key_set", Type: proto.ColumnType_BOOL, Description: "True if the access key is set."},
+			{Name: "secret_key_set", Type: proto.ColumnType_BOOL, Description: "True if the secret key is set."},
+			{Name: "path", Type: proto.ColumnType_STRING, Description: "Path of the credentials file of the profile."},
+			{Name: "line", Type: proto.ColumnType_INT, Transform: transform.FromField("Line").NullIfZero(), Description: "Line of the profile in the credentials file."},
+		},
+	}
+}
+
+// CredentialProfile is a source of credentials of the connection, without its secrets
+type CredentialProfile struct {
+	Name         *string
+	Source       *string
+	InEffect     bool
+	Workspace    string
+	Error        *string
+	AccessKeySet bool
+	SecretKeySet bool
+	Path         string
+	Line         int
+}
+
+func listCredentialProfile(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	config := getClientConfig(d.Connection)
+	path, err := apiClient.CredentialsPath(config)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_credential_profile.listCredentialProfile", "path_error", err)
+		return nil, err
+	}
+	credentials, provider, resolveErr := apiClient.ResolveCredentials(config)
+	// the profile in effect, if the credentials are from the credentials file
+	inEffect := ""
+	if file, ok := provider.(*apiClient.FileProvider); ok && file.Path == path {
+		inEffect = file.Profile
+		if inEffect == "" {
+			inEffect = "default"
+		}
+	}
+
+	profiles, err := apiClient.LoadCredentialsFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		// the file cannot be read, so no profile can be used
+		plugin.Logger(ctx).Warn("turbot_credential_profile.listCredentialProfile", "file_error", err)
+		d.StreamListItem(ctx, CredentialProfile{Source: stringPointer("profile"), Path: path, Error: stringPointer(err.Error())})
+	}
+	for _, profile := range profiles {
+		row := CredentialProfile{
+			Name:         stringPointer(profile.Name),
+			Source:       stringPointer("profile"),
+			InEffect:     profile.Name == inEffect,
+			Workspace:    profile.Credentials.Workspace,
+			AccessKeySet: profile.Credentials.AccessKey != "",
+			SecretKeySet: profile.Credentials.SecretKey != "",
+			Path:         path,
+			Line:         profile.Line,
+		}
+		if profile.Err != nil {
+			row.Error = stringPointer(profile.Err.Error())
+		} else if !apiClient.CredentialsSet(profile.Credentials) {
+			row.Error = stringPointer("the profile does not set all of accessKey, secretKey and workspace")
+		}
+		d.StreamListItem(ctx, row)
+	}
+
+	switch {
+	case resolveErr != nil:
+		// no source has credentials, or the source in effect failed
+		d.StreamListItem(ctx, CredentialProfile{Error: stringPointer(resolveErr.Error())})
+	case inEffect == "":
+		d.StreamListItem(ctx, CredentialProfile{
+			Source:       stringPointer(apiClient.CredentialSource(provider)),
+			InEffect:     true,
+			Workspace:    credentials.Workspace,
+			AccessKeySet: true,
+			SecretKeySet: true,
+		})
+	}
+	return nil, nil
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
@@ -0,0 +1,355 @@
+package turbot
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+func tableTurbotDirectory(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "turbot_directory",
+		Description: "Directories of the workspace, with their identity provider configuration. Secrets are never read.",
+		List: &plugin.ListConfig{
+			KeyColumns: []*plugin.KeyColumn{
+				{Name: "id", Require: plugin.Optional},
+				{Name: "directory_type", Require: plugin.Optional},
+			},
+			Hydrate: listDirectory,
+		},
+		Columns: []*plugin.Column{
+			// Top columns
+			{Name: "id", Type: proto.ColumnType_INT, Description: "Unique identifier of the directory."},
+			{Name: "title", Type: proto.ColumnType_STRING, Description: "Title of the directory."},
+			{Name: "directory_type", Type: proto.ColumnType_STRING, Description: "Type of the directory: saml, ldap, google, local or turbot."},
+			{Name: "status", Type: proto.ColumnType_STRING, Description: "Status of the directory, e.g. Active."},
+			{Name: "insecure_settings", Type: proto.ColumnType_JSON, Transform: transform.FromField("InsecureSettings").Transform(emptyListIfNil), Description: "Findings for the settings of the directory which weaken its security, e.g. sign_requests_disabled."},
+			{Name: "certificate_expiry", Type: proto.ColumnType_TIMESTAMP, Description: "When the certificate of the identity provider, or LDAP server, expires."},
+			// Other columns
+			{Name: "akas", Type: proto.ColumnType_JSON, Transform: transform.FromField("Akas").Transform(emptyListIfNil), Description: "AKA (also known as) identifiers for the directory."},
+			{Name: "parent_id", Type: proto.ColumnType_INT, Description: "ID for the parent of the directory."},
+			{Name: "trunk_title", Type: proto.ColumnType_STRING, Description: "Title with full path of the directory."},
+			{Name: "description", Type: proto.ColumnType_STRING, Description: "Description of the directory."},
+			{Name: "profile_id_template", Type: proto.ColumnType_STRING, Description: "Template of the profile id of the users of the directory."},
+			{Name: "entry_point", Type: proto.ColumnType_STRING, Description: "SAML: URL of the single sign-on endpoint of the identity provider."},
+			{Name: "issuer", Type: proto.ColumnType_STRING, Description: "SAML: issuer of the requests to the identity provider."},
+			{Name: "name_id_format", Type: proto.ColumnType_STRING, Description: "SAML: format of the name id requested from the identity provider."},
+			{Name: "sign_requests", Type: proto.ColumnType_STRING, Description: "SAML: whether requests to the identity provider are signed, Enabled or Disabled."},
+			{Name: "signature_algorithm", Type: proto.ColumnType_STRING, Description: "SAML: algorithm of the signature of requests, e.g. sha256."},
+			{Name: "allow_idp_initiated_sso", Type: proto.ColumnType_BOOL, Description: "SAML: whether logins started by the identity provider are allowed."},
+			{Name: "allow_group_syncing", Type: proto.ColumnType_BOOL, Description: "SAML: whether the groups of users are synced from the identity provider."},
+			{Name: "url", Type: proto.ColumnType_STRING, Description: "LDAP: URL of the LDAP server."},
+			{Name: "base", Type: proto.ColumnType_STRING, Description: "LDAP: base distinguished name of searches."},
+			{Name: "distinguished_name", Type: proto.ColumnType_STRING, Description: "LDAP: distinguished name Turbot binds to the server as."},
+			{Name: "tls_enabled", Type: proto.ColumnType_BOOL, Description: "LDAP: whether connections to the server use TLS."},
+			{Name: "reject_unauthorized", Type: proto.ColumnType_BOOL, Description: "LDAP: whether connections to servers with an untrusted certificate are rejected."},
+			{Name: "client_id", Type: proto.ColumnType_STRING, Description: "Google: OAuth client id."},
+			{Name: "hosted_domain", Type: proto.ColumnType_STRING, Description: "Google: G Suite domain users must belong to."},
+			{Name: "server", Type: proto.ColumnType_STRING, Description: "Turbot: server of the directory."},
+			{Name: "certificate", Type: proto.ColumnType_STRING, Description: "Certificate of the identity provider (SAML), or of the LDAP server."},
+			{Name: "certificate_subject", Type: proto.ColumnType_STRING, Description: "Subject of the certificate."},
+			{Name: "workspace", Type: proto.ColumnType_STRING, Hydrate: plugin.HydrateFunc(getTurbotWorkspace).WithCache(), Transform: transform.FromValue(), Description: "Specifies the workspace URL."},
+		},
+	}
+}
+
+// the resource types of directories, by directory type
+var directoryResourceTypes = map[string]string{
+	"google": "tmod:@turbot/turbot-iam#/resource/types/googleDirectory",
+	"ldap":   "tmod:@turbot/turbot-iam#/resource/types/ldapDirectory",
+	"local":  "tmod:@turbot/turbot-iam#/resource/types/localDirectory",
+	"saml":   "tmod:@turbot/turbot-iam#/resource/types/samlDirectory",
+	"turbot": "tmod:@turbot/turbot-iam#/resource/types/turbotDirectory",
+}
+
+// certificates expiring within this time are reported as a finding
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+const queryDirectoryList = `
+query directoryList($filter: [String!], $next_token: String) {
+	resources(filter: $filter, paging: $next_token) {
+		items {
+			type {
+				uri
+			}
+			trunk {
+				title
+			}
+			turbot {
+				id
+				parentId
+				akas
+			}
+			title: get(path: "title")
+			description: get(path: "description")
+			status: get(path: "status")
+			profileIdTemplate: get(path: "profileIdTemplate")
+			entryPoint: get(path: "entryPoint")
+			certificate: get(path: "certificate")
+			issuer: get(path: "issuer")
+			nameIdFormat: get(path: "nameIdFormat")
+			signRequests: get(path: "signRequests")
+			signatureAlgorithm: get(path: "signatureAlgorithm")
+			allowIdpInitiatedSso: get(path: "allowIdpInitiatedSso")
+			allowGroupSyncing: get(path: "allowGroupSyncing")
+			url: get(path: "url")
+			base: get(path: "base")
+			distinguishedName: get(path: "distinguishedName")
+			tlsEnabled: get(path: "tlsEnabled")
+			tlsServerCertificate: get(path: "tlsServerCertificate")
+			rejectUnauthorized: get(path: "rejectUnauthorized")
+			clientId: get(path: "clientId")
+			hostedDomain: get(path: "hostedDomain")
+			server: get(path: "server")
+		}
+		paging {
+			next
+		}
+	}
+}
+`
+
+// directoryItem is a directory as returned by the API. Its secrets are not selected, so they are never sent
+// to the plugin, or recorded in cassettes and snapshots.
+type directoryItem struct {
+	Type struct {
+		URI string
+	}
+	Trunk struct {
+		Title string
+	}
+	Turbot struct {
+		ID       string
+		ParentID string
+		Akas     []string
+	}
+	Title                string
+	Description          string
+	Status               string
+	ProfileIdTemplate    string
+	EntryPoint           string
+	Certificate          string
+	Issuer               string
+	NameIdFormat         string
+	SignRequests         string
+	SignatureAlgorithm   string
+	AllowIdpInitiatedSso *bool
+	AllowGroupSyncing    *bool
+	Url                  string
+	Base                 string
+	DistinguishedName    string
+	TlsEnabled           *bool
+	TlsServerCertificate string
+	RejectUnauthorized   *bool
+	ClientId             string
+	HostedDomain         string
+	Server               string
+}
+
+type directoryListResponse struct {
+	Resources struct {
+		Items  []directoryItem
+		Paging struct {
+			Next string
+		}
+	}
+}
+
+// Directory is a row of turbot_directory
+type Directory struct {
+	ID                   string
+	ParentID             string
+	Title                string
+	TrunkTitle           string
+	Akas                 []string
+	DirectoryType        string
+	Description          string
+	Status               string
+	ProfileIdTemplate    string
+	EntryPoint           string
+	Issuer               string
+	NameIdFormat         string
+	SignRequests         string
+	SignatureAlgorithm   string
+	AllowIdpInitiatedSso *bool
+	AllowGroupSyncing    *bool
+	Url                  string
+	Base                 string
+	DistinguishedName    string
+	TlsEnabled           *bool
+	RejectUnauthorized   *bool
+	ClientId             string
+	HostedDomain         string
+	Server               string
+	Certificate          string
+	CertificateSubject   string
+	CertificateExpiry    *time.Time
+	InsecureSettings     []string
+}
+
+func listDirectory(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	conn, err := connect(ctx, d)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_directory.listDirectory", "connection_error", err)
+		return nil, err
+	}
+
+	quals := d.EqualsQuals
+	var types []string
+	if quals["directory_type"] != nil {
+		var names []string
+		if list := quals["directory_type"].GetListValue(); list != nil {
+			for _, value := range list.Values {
+				names = append(names, value.GetStringValue())
+			}
+		} else {
+			names = append(names, quals["directory_type"].GetStringValue())
+		}
+		for _, name := range names {
+			if directoryType := directoryResourceTypes[strings.ToLower(name)]; directoryType != "" {
+				types = append(types, "'"+directoryType+"'")
+			}
+		}
+		// none of the directory types are known, so no directory has them
+		if len(types) == 0 {
+			return nil, nil
+		}
+	} else {
+		for _, directoryType := range []string{"google", "ldap", "local", "saml", "turbot"} {
+			types = append(types, "'"+directoryResourceTypes[directoryType]+"'")
+		}
+	}
+	filter := fmt.Sprintf("resourceTypeId:%s resourceTypeLevel:self limit:5000", strings.Join(types, ","))
+	if quals["id"] != nil {
+		filter += fmt.Sprintf(" resourceId:%s level:self", getQualListValues(ctx, quals, "id", "int64"))
+	}
+
+	now := time.Now()
+	nextToken := ""
+	for {
+		result := &directoryListResponse{}
+		err = conn.DoRequestContext(requestContext(ctx, d), queryDirectoryList, map[string]interface{}{"filter": filter, "next_token": nextToken}, result)
+		if err != nil {
+			plugin.Logger(ctx).Error("turbot_directory.listDirectory", "query_error", err)
+			return nil, err
+		}
+		for _, item := range result.Resources.Items {
+			d.StreamListItem(ctx, newDirectory(item, now))
+
+			// Context can be cancelled due to manual cancellation or the limit has been hit
+			if rowsRemaining(ctx, d) == 0 {
+				return nil, nil
+			}
+		}
+		if result.Resources.Paging.Next == "" {
+			break
+		}
+		nextToken = result.Resources.Paging.Next
+	}
+
+	return nil, nil
+}
+
+// newDirectory returns the row of a directory, checking its settings at the given time
+func newDirectory(item directoryItem, now time.Time) Directory {
+	directory := Directory{
+		ID:                   item.Turbot.ID,
+		ParentID:             item.Turbot.ParentID,
+		Title:                item.Title,
+		TrunkTitle:           item.Trunk.Title,
+		Akas:                 item.Turbot.Akas,
+		Description:          item.Description,
+		Status:               item.Status,
+		ProfileIdTemplate:    item.ProfileIdTemplate,
+		EntryPoint:           item.EntryPoint,
+		Issuer:               item.Issuer,
+		NameIdFormat:         item.NameIdFormat,
+		SignRequests:         item.SignRequests,
+		SignatureAlgorithm:   item.SignatureAlgorithm,
+		AllowIdpInitiatedSso: item.AllowIdpInitiatedSso,
+		AllowGroupSyncing:    item.AllowGroupSyncing,
+		Url:                  item.Url,
+		Base:                 item.Base,
+		DistinguishedName:    item.DistinguishedName,
+		TlsEnabled:           item.TlsEnabled,
+		RejectUnauthorized:   item.RejectUnauthorized,
+		ClientId:             item.ClientId,
+		HostedDomain:         item.HostedDomain,
+		Server:               item.Server,
+	}
+	for directoryType, uri := range directoryResourceTypes {
+		if item.Type.URI == uri {
+			directory.DirectoryType = directoryType
+		}
+	}
+
+	var findings []string
+	switch directory.DirectoryType {
+	case "saml":
+		directory.Certificate = item.Certificate
+		if !strings.EqualFold(item.SignRequests, "Enabled") {
+			findings = append(findings, "sign_requests_disabled")
+		}
+		if item.AllowIdpInitiatedSso != nil && *item.AllowIdpInitiatedSso {
+			findings = append(findings, "idp_initiated_sso_allowed")
+		}
+		if strings.Contains(strings.ToLower(item.SignatureAlgorithm), "sha1") {
+			findings = append(findings, "weak_signature_algorithm")
+		}
+		if item.EntryPoint != "" && !strings.HasPrefix(strings.ToLower(item.EntryPoint), "https://") {
+			findings = append(findings, "entry_point_not_https")
+		}
+	case "ldap":
+		directory.Certificate = item.TlsServerCertificate
+		if (item.TlsEnabled == nil || !*item.TlsEnabled) && !strings.HasPrefix(strings.ToLower(item.Url), "ldaps://") {
+			findings = append(findings, "tls_disabled")
+		}
+		if item.RejectUnauthorized != nil && !*item.RejectUnauthorized {
+			findings = append(findings, "server_certificate_not_verified")
+		}
+	case "google":
+		if item.HostedDomain == "" {
+			findings = append(findings, "hosted_domain_not_set")
+		}
+	}
+
+	if directory.Certificate != "" {
+		certificate, err := parseCertificate(directory.Certificate)
+		if err != nil {
+			findings = append(findings, "certificate_invalid")
+		} else {
+			expiry := certificate.NotAfter
+			directory.CertificateExpiry = &expiry
+			directory.CertificateSubject = certificate.Subject.String()
+			if now.After(expiry) {
+				findings = append(findings, "certificate_expired")
+			} else if now.Add(certificateExpiryWarning).After(expiry) {
+				findings = append(findings, "certificate_expires_soon")
+			}
+		}
+	}
+	directory.InsecureSettings = findings
+	return directory
+}
+
+// parseCertificate parses a PEM certificate, or the base64 DER certificate identity providers often give
+// without the PEM header
+func parseCertificate(s string) (*x509.Certificate, error) {
+	s = strings.TrimSpace(s)
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		return x509.ParseCertificate(block.Bytes)
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
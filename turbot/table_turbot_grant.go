@@ -162,7 +162,7 @@ func listGrants(ctx context.Context, d *plugin.QueryData, h *plugin.HydrateData)
 	nextToken := ""
 	for {
 		result := &GrantInfo{}
-		err = conn.DoRequest(grants, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+		err = conn.DoRequestContext(requestContext(ctx, d), grants, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
 		if err != nil {
 			plugin.Logger(ctx).Error("turbot_grants.listGrants", "query_error", err)
 		}
@@ -170,7 +170,7 @@ func listGrants(ctx context.Context, d *plugin.QueryData, h *plugin.HydrateData)
 
 			d.StreamListItem(ctx, grantDetails)
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
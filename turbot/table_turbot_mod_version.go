@@ -93,14 +93,19 @@ func listModVersion(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateD
 	}
 
 	plugin.Logger(ctx).Trace("turbot_mod_version.listModVersion", "quals", quals)
+	query, err := selectQuery(ctx, d, "turbot_mod_version", "modVersionList")
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_mod_version.listModVersion", "query_error", err)
+		return nil, err
+	}
 	nextToken := ""
 
 	for {
 		result := &ModVersionResponse{}
 		if status != nil {
-			err = conn.DoRequest(queryModVersions, map[string]interface{}{"search": searchText, "orgName": orgName, "modName": modName, "status": status, "next_token": nextToken}, result)
+			err = conn.DoRequestContext(requestContext(ctx, d), query, map[string]interface{}{"search": searchText, "orgName": orgName, "modName": modName, "status": status, "next_token": nextToken}, result)
 		} else {
-			err = conn.DoRequest(queryModVersions, map[string]interface{}{"search": searchText, "orgName": orgName, "modName": modName, "next_token": nextToken}, result)
+			err = conn.DoRequestContext(requestContext(ctx, d), query, map[string]interface{}{"search": searchText, "orgName": orgName, "modName": modName, "next_token": nextToken}, result)
 		}
 
 		if err != nil {
@@ -120,7 +125,7 @@ func listModVersion(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateD
 			}
 
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
@@ -123,158 +123,8 @@ func tableTurbotNotification(ctx context.Context) *plugin.Table {
 	}
 }
 
-const (
-	queryNotificationList = `
-		query notificationList($filter: [String!], $next_token: String) {
-			notifications(filter: $filter, paging: $next_token) {
-				items {
-
-					icon
-					message
-					notificationType
-					data
-
-					actor {
-						identity {
-							trunk { title }
-							turbot {
-								title
-								id
-								actorIdentityId
-							}
-						}
-					}
-
-					control {
-						state
-						reason
-						details
-						type {
-							uri
-							trunk {
-								title
-							}
-							turbot {
-								id
-							}
-						}
-					}
-
-					resource {
-						data
-						metadata
-						trunk {
-							title
-						}
-						turbot {
-							akas
-							parentId
-							path
-							tags
-							title
-						}
-						type {
-							uri
-							trunk {
-								title
-							}
-							turbot {
-								id
-							}
-						}
-					}
-
-					policySetting {
-						isCalculated
-						type {
-							uri
-							readOnly
-							defaultTemplate
-							defaultTemplateInput
-							secret
-							trunk {
-								title
-							}
-							turbot {
-								id
-							}
-						}
-						value
-					}
-
-					grant {
-						roleName
-						permissionTypeId
-						permissionLevelId
-						validToTimestamp
-						validFromTimestamp
-						level {
-							title
-						}
-						type {
-							title
-						}
-						identity {
-							trunk { title }
-							profileId: get(path: "profileId")
-						}
-					}
-
-					activeGrant {
-						grant {
-							roleName
-							permissionTypeId
-							permissionLevelId
-							validToTimestamp
-							validFromTimestamp
-							level {
-								title
-							}
-							type {
-								title
-							}
-							identity {
-								trunk { title }
-								profileId: get(path: "profileId")
-							}
-						}
-					}
-
-					turbot {
-						controlId
-						controlNewVersionId
-						controlOldVersionId
-						createTimestamp
-						grantId
-						grantNewVersionId
-						grantOldVersionId
-						id
-						policySettingId
-						policySettingNewVersionId
-						policySettingOldVersionId
-						processId
-						resourceId
-						resourceNewVersionId
-						resourceOldVersionId
-						grantId
-						grantNewVersionId
-						grantOldVersionId
-						activeGrantsId
-						activeGrantsNewVersionId
-						activeGrantsOldVersionId
-						type
-					}
-
-				}
-				paging {
-					next
-				}
-			}
-		}`
-
-	queryNotificationGet = `
-		query notificationGet($id: ID!) {
-			notification(id: $id) {
+// the fields of a notification, except those of active grants
+const notificationFields = `
 				icon
 				message
 				notificationType
@@ -363,7 +213,10 @@ const (
 						}
 						profileId: get(path: "profileId")
 					}
-				}
+				}`
+
+// the fields of the active grant of a notification, which older workspaces do not support
+const notificationActiveGrantFields = `
 				activeGrant {
 					grant {
 						roleName
@@ -384,7 +237,12 @@ const (
 							profileId: get(path: "profileId")
 						}
 					}
-				}
+				}`
+
+// notificationTurbotFields returns the turbot metadata fields of a notification, with the ids of its active
+// grant if supported
+func notificationTurbotFields(activeGrants bool) string {
+	fields := `
 				turbot {
 					controlId
 					controlNewVersionId
@@ -400,18 +258,54 @@ const (
 					processId
 					resourceId
 					resourceNewVersionId
-					resourceOldVersionId
-					grantId
-					grantNewVersionId
-					grantOldVersionId
+					resourceOldVersionId`
+	if activeGrants {
+		fields += `
 					activeGrantsId
 					activeGrantsNewVersionId
-					activeGrantsOldVersionId
+					activeGrantsOldVersionId`
+	}
+	return fields + `
 					type
+				}`
+}
+
+var (
+	queryNotificationGetFields       = notificationFields + notificationActiveGrantFields + notificationTurbotFields(true)
+	queryNotificationGetFieldsLegacy = notificationFields + notificationTurbotFields(false)
+
+	queryNotificationList       = notificationListQuery(queryNotificationGetFields)
+	queryNotificationListLegacy = notificationListQuery(queryNotificationGetFieldsLegacy)
+)
+
+// the columns read from the active grant fields of a notification
+var notificationActiveGrantColumns = []string{
+	"active_grant_id",
+	"active_grant_new_version_id",
+	"active_grant_old_version_id",
+	"active_grant_valid_to_timestamp",
+	"active_grant_identity_profile_id",
+	"active_grant_identity_trunk_title",
+	"active_grant_level_title",
+	"active_grant_permission_level_id",
+	"active_grant_permission_type_id",
+	"active_grant_role_name",
+	"active_grant_type_title",
+}
+
+// notificationListQuery returns the list query of notifications selecting the given fields of each item
+func notificationListQuery(fields string) string {
+	return `
+		query notificationList($filter: [String!], $next_token: String) {
+			notifications(filter: $filter, paging: $next_token) {
+				items {` + fields + `
+				}
+				paging {
+					next
 				}
 			}
 		}`
-)
+}
 
 func listNotification(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
 	conn, err := connect(ctx, d)
@@ -505,10 +399,16 @@ func listNotification(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrat
 
 	plugin.Logger(ctx).Warn("turbot_notification.listNotification", "filters", filters)
 
+	query, err := selectQuery(ctx, d, "turbot_notification", "notificationList")
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_notification.listNotification", "query_error", err)
+		return nil, err
+	}
+
 	nextToken := ""
 	for {
 		result := &NotificationsResponse{}
-		err = conn.DoRequest(queryNotificationList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+		err = conn.DoRequestContext(requestContext(ctx, d), query, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
 		if err != nil {
 			plugin.Logger(ctx).Error("turbot_notification.listNotification", "query_error", err)
 			// Not returning for function in case of errors because of resources/policies/controls referred might be deleted and
@@ -519,7 +419,7 @@ func listNotification(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrat
 			d.StreamListItem(ctx, r)
 
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
@@ -538,14 +438,27 @@ func getNotification(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrate
 		plugin.Logger(ctx).Error("turbot_notification.getNotification", "connection_error", err)
 		return nil, err
 	}
+	fields, err := selectQuery(ctx, d, "turbot_notification", "notificationGet")
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_notification.getNotification", "query_error", err)
+		return nil, err
+	}
 	id := d.EqualsQuals["id"].GetInt64Value()
-	result := &NotificationsGetResponse{}
-	err = conn.DoRequest(queryNotificationGet, map[string]interface{}{"id": id}, result)
+	// Lookups made concurrently, e.g. for "id in (...)", are sent as a single aliased request
+	data, err := conn.Batcher("notification", fields).Get(ctx, strconv.FormatInt(id, 10))
 	if err != nil {
 		plugin.Logger(ctx).Error("turbot_notification.getNotification", "query_error", err)
 		return nil, err
 	}
-	return result.Notification, nil
+	if data == nil {
+		return nil, nil
+	}
+	var result Notification
+	if err = json.Unmarshal(data, &result); err != nil {
+		plugin.Logger(ctx).Error("turbot_notification.getNotification", "unmarshal_error", err)
+		return nil, err
+	}
+	return result, nil
 }
 
 //// TRANFORM FUNCTION
@@ -36,6 +36,9 @@ func tableTurbotPolicySetting(ctx context.Context) *plugin.Table {
 			{Name: "policy_type_uri", Type: proto.ColumnType_STRING, Transform: transform.FromField("Type.URI"), Description: "URI of the policy type for this policy setting."},
 			{Name: "policy_type_trunk_title", Type: proto.ColumnType_STRING, Transform: transform.FromField("Type.Trunk.Title"), Description: "Full title (including ancestor trunk) of the policy type."},
 			{Name: "value", Type: proto.ColumnType_STRING, Description: "Value of the policy setting (for non-calculated policy settings)."},
+			{Name: "value_json", Type: proto.ColumnType_JSON, Hydrate: getPolicySettingParse, Transform: transform.FromValue().Transform(parsedPolicyValue), Description: "Value of the policy setting, parsed from YAML or JSON according to the schema of the policy type."},
+			{Name: "value_type", Type: proto.ColumnType_STRING, Hydrate: getPolicySettingParse, Transform: transform.FromValue().Transform(parsedPolicyValueType), Description: "JSON type of the parsed value: string, integer, number, boolean, array, object or null."},
+			{Name: "value_parse_error", Type: proto.ColumnType_STRING, Hydrate: getPolicySettingParse, Transform: transform.FromValue().Transform(parsedPolicyValueError), Description: "Error parsing the value, or validating it against the schema of the policy type."},
 			{Name: "is_calculated", Type: proto.ColumnType_BOOL, Description: "True if this is a policy setting will be calculated for each value."},
 			{Name: "exception", Type: proto.ColumnType_BOOL, Transform: transform.FromField("Exception").Transform(intToBool), Description: "True if this setting is an exception to a higher level setting."},
 			{Name: "orphan", Type: proto.ColumnType_BOOL, Transform: transform.FromField("Orphan").Transform(intToBool), Description: "True if this setting is orphaned by a higher level setting."},
@@ -81,6 +84,7 @@ query policySettingList($filter: [String!], $next_token: String) {
 			template
 			templateInput
 			type {
+				schema
 				uri
 				trunk {
 					title
@@ -185,7 +189,7 @@ func listPolicySetting(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydra
 	nextToken := ""
 	for {
 		result := &PolicySettingsResponse{}
-		err = conn.DoRequest(queryPolicySettingList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+		err = conn.DoRequestContext(requestContext(ctx, d), queryPolicySettingList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
 		if err != nil {
 			plugin.Logger(ctx).Error("turbot_policy_setting.listPolicySetting", "query_error", err)
 			return nil, err
@@ -194,7 +198,7 @@ func listPolicySetting(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydra
 			d.StreamListItem(ctx, r)
 
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
@@ -0,0 +1,127 @@
+package turbot
+
+import (
+	"context"
+
+	"github.com/turbot/steampipe-plugin-turbot/drift"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+func tableTurbotPolicySettingDrift(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "turbot_policy_setting_drift",
+		Description: "Differences between the policy settings of the workspace and the workspace of the drift_profile connection option.",
+		List: &plugin.ListConfig{
+			KeyColumns: []*plugin.KeyColumn{
+				{Name: "filter", Require: plugin.Optional},
+			},
+			Hydrate: listPolicySettingDrift,
+		},
+		Columns: []*plugin.Column{
+			// Top columns
+			{Name: "status", Type: proto.ColumnType_STRING, Description: "How the setting differs: added (only in the drift workspace), removed (only in this workspace) or changed."},
+			{Name: "policy_type_uri", Type: proto.ColumnType_STRING, Transform: transform.FromField("PolicyTypeURI"), Description: "URI of the policy type of the setting."},
+			{Name: "resource_aka", Type: proto.ColumnType_STRING, Transform: transform.FromField("ResourceAka"), Description: "AKA of the resource of the setting, which is used to match the settings of the workspaces."},
+			{Name: "changed_fields", Type: proto.ColumnType_JSON, Transform: transform.FromField("ChangedFields"), Description: "Fields which differ, for a changed setting: value, template, template_input, precedence or note."},
+			{Name: "policy_setting_id", Type: proto.ColumnType_INT, Transform: transform.FromField("PolicySettingID").Transform(transform.NullIfZeroValue), Description: "ID of the setting in this workspace."},
+			{Name: "drift_policy_setting_id", Type: proto.ColumnType_INT, Transform: transform.FromField("DriftPolicySettingID").Transform(transform.NullIfZeroValue), Description: "ID of the setting in the drift workspace."},
+			// Other columns
+			{Name: "value", Type: proto.ColumnType_JSON, Description: "Value of the setting in this workspace, for non-calculated settings of policy types which are not secret."},
+			{Name: "drift_value", Type: proto.ColumnType_JSON, Description: "Value of the setting in the drift workspace, for non-calculated settings of policy types which are not secret."},
+			{Name: "template", Type: proto.ColumnType_STRING, Transform: transform.FromField("Template").Transform(transform.NullIfZeroValue), Description: "Template of the calculated setting in this workspace."},
+			{Name: "drift_template", Type: proto.ColumnType_STRING, Transform: transform.FromField("DriftTemplate").Transform(transform.NullIfZeroValue), Description: "Template of the calculated setting in the drift workspace."},
+			{Name: "template_input", Type: proto.ColumnType_STRING, Transform: transform.FromField("TemplateInput").Transform(transform.NullIfZeroValue), Description: "Template input of the calculated setting in this workspace."},
+			{Name: "drift_template_input", Type: proto.ColumnType_STRING, Transform: transform.FromField("DriftTemplateInput").Transform(transform.NullIfZeroValue), Description: "Template input of the calculated setting in the drift workspace."},
+			{Name: "precedence", Type: proto.ColumnType_STRING, Transform: transform.FromField("Precedence").Transform(transform.NullIfZeroValue), Description: "Precedence of the setting in this workspace."},
+			{Name: "drift_precedence", Type: proto.ColumnType_STRING, Transform: transform.FromField("DriftPrecedence").Transform(transform.NullIfZeroValue), Description: "Precedence of the setting in the drift workspace."},
+			{Name: "note", Type: proto.ColumnType_STRING, Transform: transform.FromField("Note").Transform(transform.NullIfZeroValue), Description: "Note of the setting in this workspace."},
+			{Name: "drift_note", Type: proto.ColumnType_STRING, Transform: transform.FromField("DriftNote").Transform(transform.NullIfZeroValue), Description: "Note of the setting in the drift workspace."},
+			{Name: "filter", Type: proto.ColumnType_STRING, Transform: transform.FromQual("filter"), Description: "Filter of the policy settings compared in both workspaces."},
+			{Name: "workspace", Type: proto.ColumnType_STRING, Hydrate: plugin.HydrateFunc(getTurbotWorkspace).WithCache(), Transform: transform.FromValue(), Description: "Specifies the workspace URL."},
+		},
+	}
+}
+
+// PolicySettingDrift is a row of the drift table - a difference between the settings of the workspaces
+type PolicySettingDrift struct {
+	Status               string
+	PolicyTypeURI        string
+	ResourceAka          string
+	ChangedFields        []string
+	PolicySettingID      string
+	DriftPolicySettingID string
+	Value                interface{}
+	DriftValue           interface{}
+	Template             string
+	DriftTemplate        string
+	TemplateInput        string
+	DriftTemplateInput   string
+	Precedence           string
+	DriftPrecedence      string
+	Note                 string
+	DriftNote            string
+}
+
+func listPolicySettingDrift(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	conn, err := connect(ctx, d)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_policy_setting_drift.listPolicySettingDrift", "connection_error", err)
+		return nil, err
+	}
+	driftConn, err := connectDrift(ctx, d)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_policy_setting_drift.listPolicySettingDrift", "connection_error", err)
+		return nil, err
+	}
+
+	filter := ""
+	if d.EqualsQuals["filter"] != nil {
+		filter = d.EqualsQuals["filter"].GetStringValue()
+	}
+
+	report, err := drift.Compare(conn, driftConn, filter)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_policy_setting_drift.listPolicySettingDrift", "query_error", err)
+		return nil, err
+	}
+
+	for _, difference := range report.Differences {
+		d.StreamListItem(ctx, policySettingDriftRow(difference))
+
+		// Context can be cancelled due to manual cancellation or the limit has been hit
+		if rowsRemaining(ctx, d) == 0 {
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// policySettingDriftRow flattens a difference, whose settings in either workspace may be missing
+func policySettingDriftRow(difference drift.Difference) PolicySettingDrift {
+	row := PolicySettingDrift{
+		Status:        string(difference.Status),
+		PolicyTypeURI: difference.PolicyType,
+		ResourceAka:   difference.Resource,
+		ChangedFields: difference.Fields,
+	}
+	if setting := difference.From; setting != nil {
+		row.PolicySettingID = setting.ID
+		row.Value = setting.Value
+		row.Template = setting.Template
+		row.TemplateInput = setting.TemplateInput
+		row.Precedence = setting.Precedence
+		row.Note = setting.Note
+	}
+	if setting := difference.To; setting != nil {
+		row.DriftPolicySettingID = setting.ID
+		row.DriftValue = setting.Value
+		row.DriftTemplate = setting.Template
+		row.DriftTemplateInput = setting.TemplateInput
+		row.DriftPrecedence = setting.Precedence
+		row.DriftNote = setting.Note
+	}
+	return row
+}
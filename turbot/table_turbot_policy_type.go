@@ -2,6 +2,7 @@ package turbot
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 
@@ -104,9 +105,7 @@ query policyTypeList($filter: [String!], $next_token: String) {
 }
 `
 
-	queryPolicyTypeGet = `
-query policyTypeGet($id: ID!) {
-	policyType(id: $id) {
+	queryPolicyTypeGetFields = `
 		category {
 			turbot {
 				id
@@ -141,10 +140,7 @@ query policyTypeGet($id: ID!) {
 			updateTimestamp
 			versionId
 		}
-		uri
-	}
-}
-`
+		uri`
 )
 
 func listPolicyType(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
@@ -182,7 +178,7 @@ func listPolicyType(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateD
 	nextToken := ""
 	for {
 		result := &PolicyTypesResponse{}
-		err = conn.DoRequest(queryPolicyTypeList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+		err = conn.DoRequestContext(requestContext(ctx, d), queryPolicyTypeList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
 		if err != nil {
 			plugin.Logger(ctx).Error("turbot_policy_type.listPolicyType", "query_error", err)
 			return nil, err
@@ -191,7 +187,7 @@ func listPolicyType(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateD
 			d.StreamListItem(ctx, r)
 
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
@@ -211,11 +207,19 @@ func getPolicyType(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateDa
 	}
 	quals := d.EqualsQuals
 	id := quals["id"].GetInt64Value()
-	result := &PolicyTypeResponse{}
-	err = conn.DoRequest(queryPolicyTypeGet, map[string]interface{}{"id": id}, result)
+	// Lookups made concurrently, e.g. for "id in (...)", are sent as a single aliased request
+	data, err := conn.Batcher("policyType", queryPolicyTypeGetFields).Get(ctx, strconv.FormatInt(id, 10))
 	if err != nil {
 		plugin.Logger(ctx).Error("turbot_policy_type.getPolicyType", "query_error", err)
 		return nil, err
 	}
-	return result.PolicyType, nil
+	if data == nil {
+		return nil, nil
+	}
+	var result PolicyType
+	if err = json.Unmarshal(data, &result); err != nil {
+		plugin.Logger(ctx).Error("turbot_policy_type.getPolicyType", "unmarshal_error", err)
+		return nil, err
+	}
+	return result, nil
 }
@@ -38,6 +38,9 @@ func tableTurbotPolicyValue(ctx context.Context) *plugin.Table {
 			{Name: "state", Type: proto.ColumnType_STRING, Description: "State of the policy value."},
 			{Name: "secret_value", Type: proto.ColumnType_STRING, Transform: transform.FromField("SecretValue").Transform(convToString), Description: "Secrect value of the policy value."},
 			{Name: "value", Type: proto.ColumnType_STRING, Transform: transform.FromField("Value").Transform(convToString), Description: "Value of the policy value."},
+			{Name: "value_json", Type: proto.ColumnType_JSON, Hydrate: getPolicyValueParse, Transform: transform.FromValue().Transform(parsedPolicyValue), Description: "Value of the policy value, parsed from YAML or JSON according to the schema of the policy type."},
+			{Name: "value_type", Type: proto.ColumnType_STRING, Hydrate: getPolicyValueParse, Transform: transform.FromValue().Transform(parsedPolicyValueType), Description: "JSON type of the parsed value: string, integer, number, boolean, array, object or null."},
+			{Name: "value_parse_error", Type: proto.ColumnType_STRING, Hydrate: getPolicyValueParse, Transform: transform.FromValue().Transform(parsedPolicyValueError), Description: "Error parsing the value, or validating it against the schema of the policy type."},
 			{Name: "type_mod_uri", Type: proto.ColumnType_STRING, Transform: transform.FromField("Type.ModURI"), Description: "URI of the mod that contains the policy value."},
 
 			// Other columns
@@ -72,6 +75,7 @@ query MyQuery($filter: [String!], $next_token: String) {
 			type {
 				modUri
 				defaultTemplate
+				schema
 				title
 				trunk {
 				  title
@@ -189,7 +193,7 @@ func listPolicyValue(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrate
 	nextToken := ""
 	for {
 		result := &PolicyValuesResponse{}
-		err = conn.DoRequest(queryPolicyValueList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+		err = conn.DoRequestContext(requestContext(ctx, d), queryPolicyValueList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
 		if err != nil {
 			plugin.Logger(ctx).Error("turbot_policy_value.listPolicyValue", "query_error", err)
 			return nil, err
@@ -198,7 +202,7 @@ func listPolicyValue(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrate
 			d.StreamListItem(ctx, r)
 
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
@@ -0,0 +1,120 @@
+package turbot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+func tableTurbotQueryExplain(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "turbot_query_explain",
+		Description: "The Turbot API requests recently made by the connection, with their filter, GraphQL document and variables, to check which qualifiers of a query were pushed down to the API. Requires the debug_queries connection option.",
+		List: &plugin.ListConfig{
+			Hydrate: listQueryExplain,
+		},
+		Columns: []*plugin.Column{
+			// Top columns
+			{Name: "time", Type: proto.ColumnType_TIMESTAMP, Description: "The time of the request."},
+			{Name: "table_name", Type: proto.ColumnType_STRING, Transform: transform.FromField("Table").NullIfZero(), Description: "The table the request was made for, or null for a request made outside a table, e.g. to connect."},
+			{Name: "operation", Type: proto.ColumnType_STRING, Description: "The GraphQL operation of the request, e.g. resourceList."},
+			{Name: "filter", Type: proto.ColumnType_STRING, Transform: transform.FromField("Filter").NullIfZero(), Description: "The Turbot filter of the request, built from the qualifiers of the query."},
+			{Name: "page", Type: proto.ColumnType_INT, Transform: transform.FromField("Page").NullIfZero(), Description: "The page of the results of the filter, counting from 1."},
+			{Name: "rows", Type: proto.ColumnType_INT, Description: "The number of items returned by the request."},
+			// Other columns
+			{Name: "latency_ms", Type: proto.ColumnType_DOUBLE, Description: "The latency of the request in milliseconds."},
+			{Name: "error_class", Type: proto.ColumnType_STRING, Transform: transform.FromField("ErrorClass").NullIfZero(), Description: "The class of the error of the request, if it failed: auth, throttled, server, client, network, not_found or graphql."},
+			{Name: "query", Type: proto.ColumnType_STRING, Description: "The GraphQL document of the request."},
+			{Name: "variables", Type: proto.ColumnType_JSON, Description: "The variables of the request."},
+		},
+	}
+}
+
+// maxExplainedRequests is the number of requests listed by the turbot_query_explain table of each connection
+const maxExplainedRequests = 1000
+
+// explainedRequests are the latest requests of a connection, oldest first
+type explainedRequests struct {
+	mutex    sync.Mutex
+	requests []apiClient.RequestStats
+}
+
+func (e *explainedRequests) add(request apiClient.RequestStats) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if len(e.requests) == maxExplainedRequests {
+		e.requests = append(e.requests[:0], e.requests[1:]...)
+	}
+	e.requests = append(e.requests, request)
+}
+
+func (e *explainedRequests) list() []apiClient.RequestStats {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]apiClient.RequestStats{}, e.requests...)
+}
+
+var (
+	// the latest requests of each connection, by connection name
+	explained      = map[string]*explainedRequests{}
+	explainedMutex sync.Mutex
+)
+
+// connectionExplainedRequests returns the latest requests of a connection, which outlive its clients
+func connectionExplainedRequests(connection string) *explainedRequests {
+	explainedMutex.Lock()
+	defer explainedMutex.Unlock()
+	requests, ok := explained[connection]
+	if !ok {
+		requests = &explainedRequests{}
+		explained[connection] = requests
+	}
+	return requests
+}
+
+// QueryExplain is a row of the turbot_query_explain table
+type QueryExplain struct {
+	Time       time.Time
+	Table      string
+	Operation  string
+	Filter     string
+	Page       int
+	Rows       int
+	LatencyMs  float64
+	ErrorClass string
+	Query      string
+	Variables  map[string]interface{}
+}
+
+func listQueryExplain(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	if debug := GetConfig(d.Connection).DebugQueries; debug == nil || !*debug {
+		return nil, fmt.Errorf("the debug_queries connection option is not set")
+	}
+	for _, request := range connectionExplainedRequests(d.Connection.Name).list() {
+		d.StreamListItem(ctx, QueryExplain{
+			Time:       request.Start,
+			Table:      request.Table,
+			Operation:  request.Operation,
+			Filter:     request.Filter,
+			Page:       request.Page,
+			Rows:       request.Rows,
+			LatencyMs:  milliseconds(request.Latency),
+			ErrorClass: request.ErrorClass,
+			Query:      request.Query,
+			Variables:  request.Variables,
+		})
+
+		// Context can be cancelled due to manual cancellation or the limit has been hit
+		if rowsRemaining(ctx, d) == 0 {
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
@@ -2,9 +2,13 @@ package turbot
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
 	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
@@ -24,6 +28,10 @@ func tableTurbotResource(ctx context.Context) *plugin.Table {
 			},
 			Hydrate: listResource,
 		},
+		Get: &plugin.GetConfig{
+			KeyColumns: plugin.SingleColumn("aka"),
+			Hydrate:    getResource,
+		},
 		Columns: []*plugin.Column{
 			// Top columns
 			{Name: "id", Type: proto.ColumnType_INT, Transform: transform.FromField("Turbot.ID"), Description: "Unique identifier of the resource."},
@@ -31,6 +39,7 @@ func tableTurbotResource(ctx context.Context) *plugin.Table {
 			{Name: "trunk_title", Type: proto.ColumnType_STRING, Transform: transform.FromField("Trunk.Title"), Description: "Title with full path of the resource."},
 			{Name: "tags", Type: proto.ColumnType_JSON, Transform: transform.FromField("Turbot.Tags"), Description: "Tags for the resource."},
 			{Name: "akas", Type: proto.ColumnType_JSON, Transform: transform.FromField("Turbot.Akas"), Description: "AKA (also known as) identifiers for the resource."},
+			{Name: "aka", Type: proto.ColumnType_STRING, Transform: transform.FromQual("aka"), Description: "AKA (also known as) identifier used to look up the resource, e.g. an AWS ARN, GCP self link or Azure ID."},
 			// Other columns
 			{Name: "create_timestamp", Type: proto.ColumnType_TIMESTAMP, Transform: transform.FromField("Turbot.CreateTimestamp"), Description: "When the resource was first discovered by Turbot. (It may have been created earlier.)"},
 			{Name: "data", Type: proto.ColumnType_JSON, Description: "Resource data."},
@@ -81,8 +90,42 @@ query resourceList($filter: [String!], $next_token: String) {
 	}
 }
 `
+
+	// Fields requested for each resource in a batch lookup by aka
+	queryResourceBatchFields = `
+		data
+		metadata
+		trunk {
+			title
+		}
+		turbot {
+			id
+			title
+			tags
+			akas
+			timestamp
+			createTimestamp
+			updateTimestamp
+			versionId
+			parentId
+			path
+			resourceTypeId
+		}
+		type {
+			uri
+		}`
+
+	// Upper limit on the number of resources read in a single aliased request
+	resourceBatchSize = 500
 )
 
+// The SDK calls the get hydrate once for each value of an "aka in (...)" list. The first call reads
+// every resource in the list with aliased requests, and the other calls wait for and share that result.
+var getResourcesForAkaQual = plugin.HydrateFunc(listResourcesForAkaQual).Memoize(func(o *plugin.MemoizeConfiguration) {
+	o.GetCacheKeyFunc = resourceAkaQualCacheKey
+	o.Ttl = time.Minute
+})
+
 func listResource(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
 	conn, err := connect(ctx, d)
 	if err != nil {
@@ -137,7 +180,7 @@ func listResource(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateDat
 	nextToken := ""
 	for {
 		result := &ResourcesResponse{}
-		err = conn.DoRequest(queryResourceList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+		err = conn.DoRequestContext(requestContext(ctx, d), queryResourceList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
 		if err != nil {
 			plugin.Logger(ctx).Error("turbot_resource.listResource", "query_error", err)
 			return nil, err
@@ -146,7 +189,7 @@ func listResource(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateDat
 			d.StreamListItem(ctx, r)
 
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
@@ -158,3 +201,87 @@ func listResource(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateDat
 
 	return nil, nil
 }
+
+func getResource(ctx context.Context, d *plugin.QueryData, h *plugin.HydrateData) (interface{}, error) {
+	aka := d.EqualsQuals["aka"].GetStringValue()
+	if aka == "" {
+		return nil, nil
+	}
+
+	result, err := getResourcesForAkaQual(ctx, d, h)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_resource.getResource", "query_error", err)
+		return nil, err
+	}
+	if resource, ok := result.(map[string]Resource)[aka]; ok {
+		return resource, nil
+	}
+	return nil, nil
+}
+
+func listResourcesForAkaQual(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	conn, err := connect(ctx, d)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_resource.listResourcesForAkaQual", "connection_error", err)
+		return nil, err
+	}
+
+	akas := akaQualValues(d)
+	plugin.Logger(ctx).Trace("turbot_resource.listResourcesForAkaQual", "akas", akas)
+
+	resources := map[string]Resource{}
+	for start := 0; start < len(akas); start += resourceBatchSize {
+		end := start + resourceBatchSize
+		if end > len(akas) {
+			end = len(akas)
+		}
+		items, err := conn.ReadResourceBatch(akas[start:end], queryResourceBatchFields)
+		if err != nil {
+			plugin.Logger(ctx).Error("turbot_resource.listResourcesForAkaQual", "query_error", err)
+			return nil, err
+		}
+		for aka, item := range items {
+			var r Resource
+			if err := json.Unmarshal(item, &r); err != nil {
+				return nil, err
+			}
+			resources[aka] = r
+		}
+	}
+	return resources, nil
+}
+
+// akaQualValues returns the sorted, distinct values of the aka qual for the whole query, rather than the
+// single value the SDK passes to each get call
+func akaQualValues(d *plugin.QueryData) []string {
+	values := map[string]bool{}
+	if quals, ok := d.QueryContext.UnsafeQuals["aka"]; ok {
+		for _, q := range quals.GetQuals() {
+			if q.GetStringValue() != "=" {
+				continue
+			}
+			if list := q.GetValue().GetListValue(); list != nil {
+				for _, v := range list.Values {
+					values[v.GetStringValue()] = true
+				}
+			} else {
+				values[q.GetValue().GetStringValue()] = true
+			}
+		}
+	}
+	// always include the value for this get call
+	values[d.EqualsQuals["aka"].GetStringValue()] = true
+
+	akas := []string{}
+	for v := range values {
+		if v != "" {
+			akas = append(akas, v)
+		}
+	}
+	sort.Strings(akas)
+	return akas
+}
+
+func resourceAkaQualCacheKey(_ context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	return fmt.Sprintf("turbot_resource.aka.%s", strings.Join(akaQualValues(d), ",")), nil
+}
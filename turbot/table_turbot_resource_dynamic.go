@@ -0,0 +1,432 @@
+package turbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+
+	connection_manager "github.com/turbot/steampipe-plugin-sdk/v5/connection"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+const (
+	queryResourceTypeUriList = `
+query resourceTypeUriList($filter: [String!], $next_token: String) {
+	resourceTypes(filter: $filter, paging: $next_token) {
+		items {
+			uri
+		}
+		paging {
+			next
+		}
+	}
+}
+`
+
+	// Fields requested for each resource type a dynamic table is generated for
+	queryResourceTypeSchemaFields = `
+		description
+		schema
+		title
+		uri`
+)
+
+// ResourceTypeSchema is a resource type with the JSON schema of its resources' data
+type ResourceTypeSchema struct {
+	Description string
+	Schema      map[string]interface{}
+	Title       string
+	URI         string
+}
+
+// A typed column generated from a top-level property of the resource type schema
+type resourcePropertyColumn struct {
+	Name        string
+	Property    string
+	Type        proto.ColumnType
+	Description string
+}
+
+var resourceTypeUriRegex = regexp.MustCompile(`^tmod:@([^/]+)/([^#]+)#/resource/types/([^/]+)$`)
+
+// resourceTypeTables returns a table for each resource type matching the resource_types configured for
+// the connection. Each entry is a resource type URI, or a glob such as tmod:@turbot/aws-s3#/resource/types/*
+func resourceTypeTables(ctx context.Context, d *plugin.TableMapData, existing map[string]*plugin.Table) (map[string]*plugin.Table, error) {
+	tables := map[string]*plugin.Table{}
+	patterns := GetConfig(d.Connection).ResourceTypes
+	if len(patterns) == 0 {
+		return tables, nil
+	}
+
+	conn, err := connectCached(ctx, d.Connection, connection_manager.NewCache(d.ConnectionCache))
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot.resourceTypeTables", "connection_error", err)
+		return nil, err
+	}
+
+	uris, err := matchResourceTypeUris(conn, patterns)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot.resourceTypeTables", "query_error", err)
+		return nil, err
+	}
+
+	items, err := conn.Batcher("resourceType", queryResourceTypeSchemaFields).GetAll(uris)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot.resourceTypeTables", "query_error", err)
+		return nil, err
+	}
+
+	for _, uri := range uris {
+		item, ok := items[uri]
+		if !ok {
+			plugin.Logger(ctx).Warn("turbot.resourceTypeTables", "resource_type_not_found", uri)
+			continue
+		}
+		var resourceType ResourceTypeSchema
+		if err := json.Unmarshal(item, &resourceType); err != nil {
+			plugin.Logger(ctx).Error("turbot.resourceTypeTables", "unmarshal_error", err, "uri", uri)
+			continue
+		}
+		name := resourceTypeTableName(resourceType.URI)
+		if name == "" {
+			plugin.Logger(ctx).Warn("turbot.resourceTypeTables", "invalid_resource_type_uri", resourceType.URI)
+			continue
+		}
+		if _, ok := existing[name]; ok {
+			plugin.Logger(ctx).Warn("turbot.resourceTypeTables", "table_name_conflict", name)
+			continue
+		}
+		if _, ok := tables[name]; ok {
+			plugin.Logger(ctx).Warn("turbot.resourceTypeTables", "table_name_conflict", name)
+			continue
+		}
+		tables[name] = tableTurbotResourceOfType(name, resourceType)
+	}
+	return tables, nil
+}
+
+// matchResourceTypeUris returns the sorted, distinct resource type URIs matching the given patterns. The
+// resource types are only listed if a pattern is a glob, otherwise the URIs are used as given.
+func matchResourceTypeUris(conn *apiClient.Client, patterns []string) ([]string, error) {
+	matched := map[string]bool{}
+	var globs []string
+	for _, p := range patterns {
+		if strings.ContainsAny(p, "*?[") {
+			globs = append(globs, p)
+		} else {
+			matched[p] = true
+		}
+	}
+
+	if len(globs) > 0 {
+		filters := []string{"limit:5000"}
+		nextToken := ""
+		for {
+			result := &ResourceTypesResponse{}
+			err := conn.DoRequest(queryResourceTypeUriList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range result.ResourceTypes.Items {
+				for _, glob := range globs {
+					if ok, _ := path.Match(glob, r.URI); ok {
+						matched[r.URI] = true
+					}
+				}
+			}
+			if result.ResourceTypes.Paging.Next == "" {
+				break
+			}
+			nextToken = result.ResourceTypes.Paging.Next
+		}
+	}
+
+	uris := make([]string, 0, len(matched))
+	for uri := range matched {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	return uris, nil
+}
+
+// resourceTypeTableName returns the table name for a resource type URI, e.g. turbot_aws_s3_bucket for
+// tmod:@turbot/aws-s3#/resource/types/bucket. Mods from other orgs include the org in the name.
+func resourceTypeTableName(uri string) string {
+	match := resourceTypeUriRegex.FindStringSubmatch(uri)
+	if match == nil {
+		return ""
+	}
+	parts := []string{"turbot"}
+	if match[1] != "turbot" {
+		parts = append(parts, match[1])
+	}
+	parts = append(parts, match[2], strcase.ToSnake(match[3]))
+	name := strings.Join(parts, "_")
+	return strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToLower(name))
+}
+
+func tableTurbotResourceOfType(name string, resourceType ResourceTypeSchema) *plugin.Table {
+	columns := []*plugin.Column{
+		// Top columns
+		{Name: "id", Type: proto.ColumnType_INT, Transform: transform.FromField("Turbot.ID"), Description: "Unique identifier of the resource."},
+		{Name: "title", Type: proto.ColumnType_STRING, Transform: transform.FromField("Turbot.Title"), Description: "Title of the resource."},
+		{Name: "trunk_title", Type: proto.ColumnType_STRING, Transform: transform.FromField("Trunk.Title"), Description: "Title with full path of the resource."},
+		{Name: "tags", Type: proto.ColumnType_JSON, Transform: transform.FromField("Turbot.Tags"), Description: "Tags for the resource."},
+		{Name: "akas", Type: proto.ColumnType_JSON, Transform: transform.FromField("Turbot.Akas"), Description: "AKA (also known as) identifiers for the resource."},
+		// Other columns
+		{Name: "create_timestamp", Type: proto.ColumnType_TIMESTAMP, Transform: transform.FromField("Turbot.CreateTimestamp"), Description: "When the resource was first discovered by Turbot. (It may have been created earlier.)"},
+		{Name: "data", Type: proto.ColumnType_JSON, Description: "Resource data."},
+		{Name: "filter", Type: proto.ColumnType_STRING, Transform: transform.FromQual("filter"), Description: "Filter used for this resource list."},
+		{Name: "metadata", Type: proto.ColumnType_JSON, Description: "Resource custom metadata."},
+		{Name: "parent_id", Type: proto.ColumnType_INT, Transform: transform.FromField("Turbot.ParentID"), Description: "ID for the parent of this resource."},
+		{Name: "path", Type: proto.ColumnType_JSON, Transform: transform.FromField("Turbot.Path").Transform(pathToArray), Description: "Hierarchy path with all identifiers of ancestors of the resource."},
+		{Name: "resource_type_uri", Type: proto.ColumnType_STRING, Transform: transform.FromField("Type.URI"), Description: "URI of the resource type for this resource."},
+		{Name: "timestamp", Type: proto.ColumnType_TIMESTAMP, Transform: transform.FromField("Turbot.Timestamp"), Description: "Timestamp when the resource was last modified (created, updated or deleted)."},
+		{Name: "update_timestamp", Type: proto.ColumnType_TIMESTAMP, Transform: transform.FromField("Turbot.UpdateTimestamp"), Description: "When the resource was last updated in Turbot."},
+		{Name: "version_id", Type: proto.ColumnType_INT, Transform: transform.FromField("Turbot.VersionID"), Description: "Unique identifier for this version of the resource."},
+		{Name: "workspace", Type: proto.ColumnType_STRING, Hydrate: plugin.HydrateFunc(getTurbotWorkspace).WithCache(), Transform: transform.FromValue(), Description: "Specifies the workspace URL."},
+	}
+	keyColumns := []*plugin.KeyColumn{
+		{Name: "id", Require: plugin.Optional},
+		{Name: "filter", Require: plugin.Optional},
+	}
+
+	existing := map[string]bool{}
+	for _, c := range columns {
+		existing[c.Name] = true
+	}
+	properties := resourcePropertyColumns(resourceType.Schema, existing)
+	for _, p := range properties {
+		columns = append(columns, &plugin.Column{Name: p.Name, Type: p.Type, Transform: transform.FromP(resourceDataProperty, p.Property), Description: p.Description})
+		// Only scalar properties can be pushed down as resource filters
+		if p.Type != proto.ColumnType_JSON && p.Type != proto.ColumnType_TIMESTAMP {
+			keyColumns = append(keyColumns, &plugin.KeyColumn{Name: p.Name, Require: plugin.Optional})
+		}
+	}
+
+	description := fmt.Sprintf("%s resources from the Turbot CMDB.", resourceType.Title)
+	if resourceType.Description != "" {
+		description = fmt.Sprintf("%s resources from the Turbot CMDB. %s", resourceType.Title, resourceType.Description)
+	}
+
+	return &plugin.Table{
+		Name:        name,
+		Description: description,
+		List: &plugin.ListConfig{
+			KeyColumns: keyColumns,
+			Hydrate:    listResourceOfType(name, resourceType.URI, properties),
+		},
+		Columns: columns,
+	}
+}
+
+// resourcePropertyColumns returns a typed column for each top-level property of the schema, including those
+// of any allOf subschemas, sorted by name. Names already used by the standard columns are prefixed with data_.
+func resourcePropertyColumns(schema map[string]interface{}, existing map[string]bool) []resourcePropertyColumn {
+	properties := map[string]map[string]interface{}{}
+	collectSchemaProperties(schema, properties)
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var columns []resourcePropertyColumn
+	for _, property := range names {
+		name := strcase.ToSnake(property)
+		if name == "" {
+			continue
+		}
+		if existing[name] {
+			name = "data_" + name
+		}
+		if existing[name] {
+			continue
+		}
+		existing[name] = true
+
+		description, _ := properties[property]["description"].(string)
+		if description == "" {
+			description = fmt.Sprintf("The %s property of the resource data.", property)
+		}
+		columns = append(columns, resourcePropertyColumn{
+			Name:        name,
+			Property:    property,
+			Type:        schemaColumnType(properties[property]),
+			Description: description,
+		})
+	}
+	return columns
+}
+
+func collectSchemaProperties(schema map[string]interface{}, properties map[string]map[string]interface{}) {
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		for name, p := range props {
+			if propertySchema, ok := p.(map[string]interface{}); ok {
+				properties[name] = propertySchema
+			} else {
+				properties[name] = map[string]interface{}{}
+			}
+		}
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, s := range allOf {
+			if subschema, ok := s.(map[string]interface{}); ok {
+				collectSchemaProperties(subschema, properties)
+			}
+		}
+	}
+}
+
+// schemaColumnType maps the JSON schema type of a property to a column type. Properties with more than
+// one type (other than null), or no type, are returned as JSON.
+func schemaColumnType(schema map[string]interface{}) proto.ColumnType {
+	var schemaType string
+	switch t := schema["type"].(type) {
+	case string:
+		schemaType = t
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				if schemaType != "" {
+					return proto.ColumnType_JSON
+				}
+				schemaType = s
+			}
+		}
+	}
+
+	switch schemaType {
+	case "string":
+		if schema["format"] == "date-time" {
+			return proto.ColumnType_TIMESTAMP
+		}
+		return proto.ColumnType_STRING
+	case "integer":
+		return proto.ColumnType_INT
+	case "number":
+		return proto.ColumnType_DOUBLE
+	case "boolean":
+		return proto.ColumnType_BOOL
+	}
+	return proto.ColumnType_JSON
+}
+
+func resourceDataProperty(_ context.Context, d *transform.TransformData) (interface{}, error) {
+	r, ok := d.HydrateItem.(Resource)
+	if !ok || r.Data == nil {
+		return nil, nil
+	}
+	return r.Data[d.Param.(string)], nil
+}
+
+func listResourceOfType(tableName string, resourceTypeUri string, properties []resourcePropertyColumn) plugin.HydrateFunc {
+	return func(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+		conn, err := connect(ctx, d)
+		if err != nil {
+			plugin.Logger(ctx).Error(tableName+".listResourceOfType", "connection_error", err)
+			return nil, err
+		}
+
+		filters := []string{}
+		quals := d.EqualsQuals
+
+		filter := ""
+		if quals["filter"] != nil {
+			filter = quals["filter"].GetStringValue()
+			filters = append(filters, filter)
+		}
+
+		filters = append(filters, fmt.Sprintf("resourceTypeId:'%s' resourceTypeLevel:self", apiClient.EscapeFilterString(resourceTypeUri)))
+		if quals["id"] != nil {
+			filters = append(filters, fmt.Sprintf("resourceId:%s level:self", getQualListValues(ctx, quals, "id", "int64")))
+		}
+		for _, p := range properties {
+			if quals[p.Name] == nil {
+				continue
+			}
+			if value, ok := propertyFilterValue(quals[p.Name], p.Type); ok {
+				filters = append(filters, fmt.Sprintf("$.%s:%s", p.Property, value))
+			}
+		}
+
+		// Add a limit if they haven't given one in the filter field
+		pageResults := false
+		re := regexp.MustCompile(`(^|\s)limit:[0-9]+($|\s)`)
+		if !re.MatchString(filter) {
+			pageResults = true
+			var pageLimit int64 = 5000
+
+			// Adjust page limit, if less than default value
+			limit := d.QueryContext.Limit
+			if d.QueryContext.Limit != nil {
+				if *limit < pageLimit {
+					pageLimit = *limit
+				}
+			}
+			filters = append(filters, fmt.Sprintf("limit:%s", strconv.Itoa(int(pageLimit))))
+		}
+
+		plugin.Logger(ctx).Trace(tableName+".listResourceOfType", "quals", quals)
+		plugin.Logger(ctx).Trace(tableName+".listResourceOfType", "filters", filters)
+
+		nextToken := ""
+		for {
+			result := &ResourcesResponse{}
+			err = conn.DoRequestContext(requestContext(ctx, d), queryResourceList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+			if err != nil {
+				plugin.Logger(ctx).Error(tableName+".listResourceOfType", "query_error", err)
+				return nil, err
+			}
+			for _, r := range result.Resources.Items {
+				d.StreamListItem(ctx, r)
+
+				// Context can be cancelled due to manual cancellation or the limit has been hit
+				if rowsRemaining(ctx, d) == 0 {
+					return nil, nil
+				}
+			}
+			if !pageResults || result.Resources.Paging.Next == "" {
+				break
+			}
+			nextToken = result.Resources.Paging.Next
+		}
+
+		return nil, nil
+	}
+}
+
+// propertyFilterValue formats a qual value for a resource data filter, e.g. $.versioning:'Enabled'. Lists
+// of values are not supported by data filters, so they are left for Steampipe to filter.
+func propertyFilterValue(qual *proto.QualValue, columnType proto.ColumnType) (string, bool) {
+	switch columnType {
+	case proto.ColumnType_STRING:
+		if v, ok := qual.Value.(*proto.QualValue_StringValue); ok {
+			return fmt.Sprintf("'%s'", apiClient.EscapeFilterString(v.StringValue)), true
+		}
+	case proto.ColumnType_INT:
+		if v, ok := qual.Value.(*proto.QualValue_Int64Value); ok {
+			return strconv.FormatInt(v.Int64Value, 10), true
+		}
+	case proto.ColumnType_DOUBLE:
+		if v, ok := qual.Value.(*proto.QualValue_DoubleValue); ok {
+			return strconv.FormatFloat(v.DoubleValue, 'f', -1, 64), true
+		}
+	case proto.ColumnType_BOOL:
+		if v, ok := qual.Value.(*proto.QualValue_BoolValue); ok {
+			return strconv.FormatBool(v.BoolValue), true
+		}
+	}
+	return "", false
+}
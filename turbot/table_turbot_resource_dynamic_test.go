@@ -0,0 +1,168 @@
+package turbot
+
+import (
+	"testing"
+
+	"github.com/machinebox/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+func TestResourceTypeTableName(t *testing.T) {
+	for uri, name := range map[string]string{
+		"tmod:@turbot/aws-s3#/resource/types/bucket":             "turbot_aws_s3_bucket",
+		"tmod:@turbot/turbot#/resource/types/smartFolder":        "turbot_turbot_smart_folder",
+		"tmod:@acme/acme-app.v2#/resource/types/ServiceInstance": "turbot_acme_acme_app_v2_service_instance",
+		"tmod:@turbot/aws#/resource/categories/account":          "",
+		"tmod:@turbot/aws-s3#/resource/types/bucket/extra":       "",
+		"arn:aws:s3:::bucket":                                    "",
+	} {
+		assert.Equal(t, name, resourceTypeTableName(uri), uri)
+	}
+}
+
+func TestMatchResourceTypeUris(t *testing.T) {
+	conn := &apiClient.Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(newTestServer(t).URL)}
+
+	// URIs are used as given, and globs are matched against the resource types of the workspace
+	uris, err := matchResourceTypeUris(conn, []string{
+		"tmod:@turbot/aws#/resource/types/*",
+		"tmod:@turbot/aws-s3#/resource/types/bucket",
+		"tmod:@turbot/aws-?3#/resource/types/[b]ucket",
+		"tmod:@turbot/custom#/resource/types/thing",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"tmod:@turbot/aws#/resource/types/account",
+		"tmod:@turbot/aws-s3#/resource/types/bucket",
+		"tmod:@turbot/custom#/resource/types/thing",
+	}, uris)
+
+	// the resource types are not listed when there is no glob
+	offline := &apiClient.Client{Graphql: graphql.NewClient("http://127.0.0.1:1")}
+	uris, err = matchResourceTypeUris(offline, []string{"tmod:@turbot/aws-s3#/resource/types/bucket"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tmod:@turbot/aws-s3#/resource/types/bucket"}, uris)
+}
+
+func TestResourcePropertyColumns(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"Name":            map[string]interface{}{"type": "string", "description": "The name of the bucket."},
+			"title":           map[string]interface{}{"type": "string"},
+			"CreationDate":    map[string]interface{}{"type": "string", "format": "date-time"},
+			"ObjectCount":     map[string]interface{}{"type": []interface{}{"integer", "null"}},
+			"Size":            map[string]interface{}{"type": "number"},
+			"Versioned":       map[string]interface{}{"type": "boolean"},
+			"Policy":          map[string]interface{}{"type": []interface{}{"object", "string"}},
+			"Untyped":         true,
+			"LoggingSettings": map[string]interface{}{"type": "object"},
+		},
+		"allOf": []interface{}{
+			map[string]interface{}{"properties": map[string]interface{}{"Region": map[string]interface{}{"type": "string"}}},
+		},
+	}
+	columns := resourcePropertyColumns(schema, map[string]bool{"id": true, "title": true})
+
+	types := map[string]proto.ColumnType{}
+	properties, descriptions := map[string]string{}, map[string]string{}
+	for _, c := range columns {
+		types[c.Name] = c.Type
+		properties[c.Name] = c.Property
+		descriptions[c.Name] = c.Description
+	}
+	assert.Equal(t, map[string]proto.ColumnType{
+		"creation_date":    proto.ColumnType_TIMESTAMP,
+		"data_title":       proto.ColumnType_STRING,
+		"logging_settings": proto.ColumnType_JSON,
+		"name":             proto.ColumnType_STRING,
+		"object_count":     proto.ColumnType_INT,
+		"policy":           proto.ColumnType_JSON,
+		"region":           proto.ColumnType_STRING,
+		"size":             proto.ColumnType_DOUBLE,
+		"untyped":          proto.ColumnType_JSON,
+		"versioned":        proto.ColumnType_BOOL,
+	}, types)
+
+	// columns are sorted by property, renamed when they clash with a standard column, and keep the property
+	// they are read from and filtered on
+	assert.Equal(t, "CreationDate", columns[0].Property)
+	assert.Equal(t, "title", properties["data_title"])
+	assert.Equal(t, "ObjectCount", properties["object_count"])
+	assert.Equal(t, "The name of the bucket.", descriptions["name"])
+	assert.Equal(t, "The Region property of the resource data.", descriptions["region"])
+}
+
+func TestPropertyFilterValue(t *testing.T) {
+	tests := []struct {
+		qual       *proto.QualValue
+		columnType proto.ColumnType
+		value      string
+		ok         bool
+	}{
+		{stringQual("Enabled"), proto.ColumnType_STRING, "'Enabled'", true},
+		{stringQual(`it's a \ path`), proto.ColumnType_STRING, `'it\'s a \\ path'`, true},
+		{intQual(42), proto.ColumnType_INT, "42", true},
+		{&proto.QualValue{Value: &proto.QualValue_DoubleValue{DoubleValue: 1.5}}, proto.ColumnType_DOUBLE, "1.5", true},
+		{boolQual(false), proto.ColumnType_BOOL, "false", true},
+		// lists are left for Steampipe to filter
+		{intListQual(1, 2), proto.ColumnType_INT, "", false},
+		// as are quals of another type than the column
+		{stringQual("42"), proto.ColumnType_INT, "", false},
+	}
+	for _, test := range tests {
+		value, ok := propertyFilterValue(test.qual, test.columnType)
+		assert.Equal(t, test.ok, ok, test.value)
+		assert.Equal(t, test.value, value)
+	}
+}
+
+func TestListResourceOfTypeFilters(t *testing.T) {
+	var filters []string
+	server := newTestServer(t)
+	q := newTestQuery(t, server, map[string]*proto.QualValue{
+		"versioned": boolQual(true),
+		"name":      stringQual("my-bucket"),
+		"tags":      stringQual("{}"),
+	}, nil)
+	conn, err := connect(testContext(), q.d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.OnRequest = func(request apiClient.RequestStats) {
+		filters = append(filters, request.Filter)
+	}
+
+	properties := []resourcePropertyColumn{
+		{Name: "name", Property: "Name", Type: proto.ColumnType_STRING},
+		{Name: "versioned", Property: "Versioned", Type: proto.ColumnType_BOOL},
+	}
+	_, err = listResourceOfType("turbot_aws_s3_bucket", "tmod:@turbot/aws-s3#/resource/types/bucket", properties)(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, filters, 1) {
+		assert.Equal(t, "resourceTypeId:'tmod:@turbot/aws-s3#/resource/types/bucket' resourceTypeLevel:self $.Name:'my-bucket' $.Versioned:true limit:5000", filters[0])
+	}
+}
+
+func TestPluginTableDefinitionsSkipsResourceTypes(t *testing.T) {
+	workspace, accessKey, secretKey := "http://127.0.0.1:1", "access", "secret"
+	q := newTestQuery(t, newTestServer(t), nil, nil)
+	d := &plugin.TableMapData{
+		Connection: &plugin.Connection{Name: "unreachable", Config: turbotConfig{
+			Workspace:     &workspace,
+			AccessKey:     &accessKey,
+			SecretKey:     &secretKey,
+			ResourceTypes: []string{"tmod:@turbot/aws-s3#/resource/types/*"},
+		}},
+		ConnectionCache: q.d.ConnectionCache,
+	}
+
+	// the workspace can't be reached, so the resource types can't be read, but the static tables are returned
+	tables, err := pluginTableDefinitions(testContext(), d)
+	assert.NoError(t, err)
+	assert.Contains(t, tables, "turbot_resource")
+	assert.NotContains(t, tables, "turbot_aws_s3_bucket")
+}
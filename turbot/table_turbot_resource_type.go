@@ -2,6 +2,7 @@ package turbot
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 
@@ -85,9 +86,7 @@ query resourceTypeList($filter: [String!], $next_token: String) {
 }
 `
 
-	queryResourceTypeGet = `
-query resourceGet($id: ID!) {
-	resourceType(id: $id) {
+	queryResourceTypeGetFields = `
 		category {
 			turbot {
 				id
@@ -111,10 +110,7 @@ query resourceGet($id: ID!) {
 			updateTimestamp
 			versionId
 		}
-		uri
-	}
-}
-`
+		uri`
 )
 
 func listResourceType(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
@@ -156,7 +152,7 @@ func listResourceType(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrat
 	nextToken := ""
 	for {
 		result := &ResourceTypesResponse{}
-		err = conn.DoRequest(queryResourceTypeList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
+		err = conn.DoRequestContext(requestContext(ctx, d), queryResourceTypeList, map[string]interface{}{"filter": filters, "next_token": nextToken}, result)
 		if err != nil {
 			plugin.Logger(ctx).Error("turbot_resource_type.listResourceType", "query_error", err)
 			return nil, err
@@ -165,7 +161,7 @@ func listResourceType(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrat
 			d.StreamListItem(ctx, r)
 
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
@@ -186,11 +182,19 @@ func getResourceType(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrate
 	}
 	quals := d.EqualsQuals
 	id := quals["id"].GetInt64Value()
-	result := &ResourceTypeResponse{}
-	err = conn.DoRequest(queryResourceTypeGet, map[string]interface{}{"id": id}, result)
+	// Lookups made concurrently, e.g. for "id in (...)", are sent as a single aliased request
+	data, err := conn.Batcher("resourceType", queryResourceTypeGetFields).Get(ctx, strconv.FormatInt(id, 10))
 	if err != nil {
 		plugin.Logger(ctx).Error("turbot_resource_type.getResourceType", "query_error", err)
 		return nil, err
 	}
-	return result.ResourceType, nil
+	if data == nil {
+		return nil, nil
+	}
+	var result ResourceType
+	if err = json.Unmarshal(data, &result); err != nil {
+		plugin.Logger(ctx).Error("turbot_resource_type.getResourceType", "unmarshal_error", err)
+		return nil, err
+	}
+	return result, nil
 }
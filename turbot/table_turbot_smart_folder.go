@@ -2,6 +2,7 @@ package turbot
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 
@@ -88,9 +89,7 @@ query smartFolderList($filter: [String!], $next_token: String) {
 }
 `
 
-	querySmartFolderGet = `
-query smartFolderGet($id: ID!) {
-	resource(id: $id) {
+	querySmartFolderGetFields = `
 		attachedResources {
 			items {
 				turbot {
@@ -118,10 +117,7 @@ query smartFolderGet($id: ID!) {
 		}
 		type {
 			uri
-		}
-	}
-}
-`
+		}`
 )
 
 func listSmartFolder(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
@@ -145,7 +141,7 @@ func listSmartFolder(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrate
 	nextToken := ""
 	for {
 		result := &ResourcesResponse{}
-		err = conn.DoRequest(querySmartFolderList, map[string]interface{}{"filter": filter, "next_token": nextToken}, result)
+		err = conn.DoRequestContext(requestContext(ctx, d), querySmartFolderList, map[string]interface{}{"filter": filter, "next_token": nextToken}, result)
 		if err != nil {
 			plugin.Logger(ctx).Error("turbot_smart_folder.listSmartFolder", "query_error", err)
 			return nil, err
@@ -154,7 +150,7 @@ func listSmartFolder(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydrate
 			d.StreamListItem(ctx, r)
 
 			// Context can be cancelled due to manual cancellation or the limit has been hit
-			if d.RowsRemaining(ctx) == 0 {
+			if rowsRemaining(ctx, d) == 0 {
 				return nil, nil
 			}
 		}
@@ -175,11 +171,19 @@ func getSmartFolder(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateD
 	}
 	quals := d.EqualsQuals
 	id := quals["id"].GetInt64Value()
-	result := &ResourceResponse{}
-	err = conn.DoRequest(querySmartFolderGet, map[string]interface{}{"id": id}, result)
+	// Lookups made concurrently, e.g. for "id in (...)", are sent as a single aliased request
+	data, err := conn.Batcher("resource", querySmartFolderGetFields).Get(ctx, strconv.FormatInt(id, 10))
 	if err != nil {
 		plugin.Logger(ctx).Error("turbot_smart_folder.getSmartFolder", "query_error", err)
 		return nil, err
 	}
-	return result.Resource, nil
+	if data == nil {
+		return nil, nil
+	}
+	var result Resource
+	if err = json.Unmarshal(data, &result); err != nil {
+		plugin.Logger(ctx).Error("turbot_smart_folder.getSmartFolder", "unmarshal_error", err)
+		return nil, err
+	}
+	return result, nil
 }
@@ -0,0 +1,280 @@
+package turbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+func tableTurbotSmartFolderAttachment(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "turbot_smart_folder_attachment",
+		Description: "Attachments of smart folders to resources, one row per smart folder and resource.",
+		List: &plugin.ListConfig{
+			KeyColumns: []*plugin.KeyColumn{
+				{Name: "smart_folder_id", Require: plugin.Optional},
+				{Name: "resource_id", Require: plugin.Optional},
+			},
+			Hydrate: listSmartFolderAttachment,
+		},
+		Columns: []*plugin.Column{
+			// Top columns
+			{Name: "smart_folder_id", Type: proto.ColumnType_INT, Transform: transform.FromField("SmartFolder.Turbot.ID"), Description: "Unique identifier of the smart folder."},
+			{Name: "resource_id", Type: proto.ColumnType_INT, Transform: transform.FromField("Resource.Turbot.ID"), Description: "Unique identifier of the resource the smart folder is attached to."},
+			{Name: "smart_folder_title", Type: proto.ColumnType_STRING, Transform: transform.FromField("SmartFolder.Turbot.Title"), Description: "Title of the smart folder."},
+			{Name: "resource_title", Type: proto.ColumnType_STRING, Transform: transform.FromField("Resource.Turbot.Title"), Description: "Title of the resource."},
+			{Name: "resource_type_uri", Type: proto.ColumnType_STRING, Transform: transform.FromField("Resource.Type.URI"), Description: "URI of the resource type of the resource."},
+			// Other columns
+			{Name: "smart_folder_akas", Type: proto.ColumnType_JSON, Transform: transform.FromField("SmartFolder.Turbot.Akas").Transform(emptyListIfNil), Description: "AKA (also known as) identifiers for the smart folder."},
+			{Name: "smart_folder_trunk_title", Type: proto.ColumnType_STRING, Transform: transform.FromField("SmartFolder.Trunk.Title"), Description: "Title with full path of the smart folder."},
+			{Name: "resource_akas", Type: proto.ColumnType_JSON, Transform: transform.FromField("Resource.Turbot.Akas").Transform(emptyListIfNil), Description: "AKA (also known as) identifiers for the resource."},
+			{Name: "resource_trunk_title", Type: proto.ColumnType_STRING, Transform: transform.FromField("Resource.Trunk.Title"), Description: "Title with full path of the resource."},
+			{Name: "workspace", Type: proto.ColumnType_STRING, Hydrate: plugin.HydrateFunc(getTurbotWorkspace).WithCache(), Transform: transform.FromValue(), Description: "Specifies the workspace URL."},
+		},
+	}
+}
+
+// SmartFolderAttachment is the attachment of a smart folder to a resource
+type SmartFolderAttachment struct {
+	SmartFolder AttachmentEnd
+	Resource    AttachmentEnd
+}
+
+// AttachmentEnd is a smart folder or resource of an attachment
+type AttachmentEnd struct {
+	Trunk struct {
+		Title string
+	}
+	Turbot struct {
+		ID    string
+		Title string
+		Akas  []string
+	}
+	Type struct {
+		URI string
+	}
+}
+
+// the fields of each end of an attachment
+const attachmentEndFields = `
+			trunk {
+				title
+			}
+			turbot {
+				id
+				title
+				akas
+			}
+			type {
+				uri
+			}`
+
+var (
+	querySmartFolderAttachmentList = `
+query smartFolderAttachmentList($filter: [String!], $next_token: String) {
+	resources(filter: $filter, paging: $next_token) {
+		items {` + attachmentEndFields + `
+			attachedResources {
+				items {` + attachmentEndFields + `
+				}
+				paging {
+					next
+				}
+			}
+		}
+		paging {
+			next
+		}
+	}
+}
+`
+
+	// the smart folders of a resource are read from the resource, rather than by listing every smart folder
+	queryResourceSmartFoldersGetFields = attachmentEndFields + `
+			attachedSmartFolders {
+				items {` + attachmentEndFields + `
+				}
+				paging {
+					next
+				}
+			}`
+
+	// the pages of the attachedResources of a smart folder, or attachedSmartFolders of a resource, after the first
+	queryAttachmentsPage = `
+query attachmentsPage($id: ID!, $next_token: String) {
+	resource(id: $id) {
+		%s(paging: $next_token) {
+			items {` + attachmentEndFields + `
+			}
+			paging {
+				next
+			}
+		}
+	}
+}
+`
+)
+
+type smartFolderAttachmentListResponse struct {
+	Resources struct {
+		Items []struct {
+			AttachmentEnd
+			AttachedResources attachmentsPage
+		}
+		Paging struct {
+			Next string
+		}
+	}
+}
+
+type resourceSmartFolders struct {
+	AttachmentEnd
+	AttachedSmartFolders attachmentsPage
+}
+
+// attachmentsPage is a page of the smart folders attached to a resource, or the resources attached to a smart folder
+type attachmentsPage struct {
+	Items  []AttachmentEnd
+	Paging struct {
+		Next string
+	}
+}
+
+func listSmartFolderAttachment(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	quals := d.EqualsQuals
+	if quals["resource_id"] != nil {
+		return listResourceSmartFolderAttachments(ctx, d)
+	}
+
+	conn, err := connect(ctx, d)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_smart_folder_attachment.listSmartFolderAttachment", "connection_error", err)
+		return nil, err
+	}
+
+	filter := "resourceTypeId:'tmod:@turbot/turbot#/resource/types/smartFolder' resourceTypeLevel:self limit:5000"
+	if quals["smart_folder_id"] != nil {
+		filter += fmt.Sprintf(" resourceId:%s level:self", getQualListValues(ctx, quals, "smart_folder_id", "int64"))
+	}
+
+	nextToken := ""
+	for {
+		result := &smartFolderAttachmentListResponse{}
+		err = conn.DoRequestContext(requestContext(ctx, d), querySmartFolderAttachmentList, map[string]interface{}{"filter": filter, "next_token": nextToken}, result)
+		if err != nil {
+			plugin.Logger(ctx).Error("turbot_smart_folder_attachment.listSmartFolderAttachment", "query_error", err)
+			return nil, err
+		}
+		for _, folder := range result.Resources.Items {
+			resources, err := listAttachments(ctx, d, folder.Turbot.ID, "attachedResources", folder.AttachedResources)
+			if err != nil {
+				plugin.Logger(ctx).Error("turbot_smart_folder_attachment.listSmartFolderAttachment", "query_error", err)
+				return nil, err
+			}
+			for _, resource := range resources {
+				d.StreamListItem(ctx, SmartFolderAttachment{SmartFolder: folder.AttachmentEnd, Resource: resource})
+
+				// Context can be cancelled due to manual cancellation or the limit has been hit
+				if rowsRemaining(ctx, d) == 0 {
+					return nil, nil
+				}
+			}
+		}
+		if result.Resources.Paging.Next == "" {
+			break
+		}
+		nextToken = result.Resources.Paging.Next
+	}
+
+	return nil, nil
+}
+
+// listResourceSmartFolderAttachments lists the attachments of the resources of the resource_id qual, from the
+// smart folders attached to each resource
+func listResourceSmartFolderAttachments(ctx context.Context, d *plugin.QueryData) (interface{}, error) {
+	conn, err := connect(ctx, d)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_smart_folder_attachment.listResourceSmartFolderAttachments", "connection_error", err)
+		return nil, err
+	}
+
+	quals := d.EqualsQuals
+	var ids []string
+	for _, id := range qualInt64Values(quals["resource_id"]) {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	var smartFolderIDs map[string]bool
+	if quals["smart_folder_id"] != nil {
+		smartFolderIDs = map[string]bool{}
+		for _, id := range qualInt64Values(quals["smart_folder_id"]) {
+			smartFolderIDs[strconv.FormatInt(id, 10)] = true
+		}
+	}
+
+	items, err := conn.Batcher("resource", queryResourceSmartFoldersGetFields).GetAll(ids)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_smart_folder_attachment.listResourceSmartFolderAttachments", "query_error", err)
+		return nil, err
+	}
+	for _, id := range ids {
+		data, ok := items[id]
+		if !ok {
+			continue
+		}
+		var resource resourceSmartFolders
+		if err = json.Unmarshal(data, &resource); err != nil {
+			plugin.Logger(ctx).Error("turbot_smart_folder_attachment.listResourceSmartFolderAttachments", "unmarshal_error", err)
+			return nil, err
+		}
+		folders, err := listAttachments(ctx, d, resource.Turbot.ID, "attachedSmartFolders", resource.AttachedSmartFolders)
+		if err != nil {
+			plugin.Logger(ctx).Error("turbot_smart_folder_attachment.listResourceSmartFolderAttachments", "query_error", err)
+			return nil, err
+		}
+		for _, folder := range folders {
+			if smartFolderIDs != nil && !smartFolderIDs[folder.Turbot.ID] {
+				continue
+			}
+			d.StreamListItem(ctx, SmartFolderAttachment{SmartFolder: folder, Resource: resource.AttachmentEnd})
+
+			// Context can be cancelled due to manual cancellation or the limit has been hit
+			if rowsRemaining(ctx, d) == 0 {
+				return nil, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// listAttachments returns the items of the first page of the attachments of a smart folder or resource, given by
+// its id, followed by the items of the pages after it
+func listAttachments(ctx context.Context, d *plugin.QueryData, id string, connection string, first attachmentsPage) ([]AttachmentEnd, error) {
+	items := first.Items
+	nextToken := first.Paging.Next
+	if nextToken == "" {
+		return items, nil
+	}
+
+	conn, err := connect(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(queryAttachmentsPage, connection)
+	for nextToken != "" {
+		result := map[string]map[string]attachmentsPage{}
+		err = conn.DoRequestContext(requestContext(ctx, d), query, map[string]interface{}{"id": id, "next_token": nextToken}, &result)
+		if err != nil {
+			return nil, err
+		}
+		page := result["resource"][connection]
+		items = append(items, page.Items...)
+		nextToken = page.Paging.Next
+	}
+	return items, nil
+}
@@ -0,0 +1,135 @@
+package turbot
+
+import (
+	"context"
+
+	"github.com/blang/semver"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+func tableTurbotWorkspace(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "turbot_workspace",
+		Description: "The workspace of the connection, with its version, the identity the connection is authenticated as and the API features it supports.",
+		List: &plugin.ListConfig{
+			Hydrate: listWorkspace,
+		},
+		Columns: []*plugin.Column{
+			// Top columns
+			{Name: "workspace", Type: proto.ColumnType_STRING, Hydrate: plugin.HydrateFunc(getTurbotWorkspace).WithCache(), Transform: transform.FromValue(), Description: "Specifies the workspace URL."},
+			{Name: "workspace_version", Type: proto.ColumnType_STRING, Description: "Version of the workspace, from the Turbot > Workspace > Version policy."},
+			{Name: "turbot_mod_version", Type: proto.ColumnType_STRING, Description: "Version of the @turbot/turbot mod installed in the workspace."},
+			{Name: "identity_id", Type: proto.ColumnType_INT, Transform: transform.FromField("Identity.Turbot.Id"), Description: "Unique identifier of the identity the connection is authenticated as."},
+			{Name: "identity_title", Type: proto.ColumnType_STRING, Transform: transform.FromField("Identity.Turbot.Title"), Description: "Title of the identity the connection is authenticated as."},
+			// Other columns
+			{Name: "identity_akas", Type: proto.ColumnType_JSON, Transform: transform.FromField("Identity.Turbot.Akas"), Description: "AKA (also known as) identifiers for the identity."},
+			{Name: "identity_trunk_title", Type: proto.ColumnType_STRING, Transform: transform.FromField("Identity.Trunk.Title"), Description: "Title with full path of the identity."},
+			{Name: "identity_type_uri", Type: proto.ColumnType_STRING, Transform: transform.FromField("Identity.Type.Uri"), Description: "URI of the resource type of the identity, e.g. a profile."},
+			{Name: "features", Type: proto.ColumnType_JSON, Description: "Whether each API feature used by the plugin is supported by the workspace, by feature name."},
+		},
+	}
+}
+
+// Workspace is the workspace of a connection
+type Workspace struct {
+	WorkspaceVersion string
+	TurbotModVersion string
+	Identity         *apiClient.Identity
+	Features         map[string]bool
+}
+
+func listWorkspace(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	conn, err := connect(ctx, d)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_workspace.listWorkspace", "connection_error", err)
+		return nil, err
+	}
+
+	version, err := getWorkspaceVersion(ctx, d)
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_workspace.listWorkspace", "query_error", err)
+		return nil, err
+	}
+	modVersion, err := conn.GetTurbotModVersion()
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_workspace.listWorkspace", "query_error", err)
+		return nil, err
+	}
+	identity, err := conn.GetIdentity()
+	if err != nil {
+		plugin.Logger(ctx).Error("turbot_workspace.listWorkspace", "query_error", err)
+		return nil, err
+	}
+
+	features := map[string]bool{}
+	for _, feature := range apiClient.Features {
+		if features[feature], err = workspaceSupports(ctx, d, feature); err != nil {
+			plugin.Logger(ctx).Error("turbot_workspace.listWorkspace", "query_error", err)
+			return nil, err
+		}
+	}
+
+	d.StreamListItem(ctx, Workspace{
+		WorkspaceVersion: version.String(),
+		TurbotModVersion: modVersion.String(),
+		Identity:         identity,
+		Features:         features,
+	})
+	return nil, nil
+}
+
+// workspaceVersionCacheKey returns the connection cache key of the workspace version of a client, which changes
+// with the workspace and credentials of the connection
+func workspaceVersionCacheKey(clientKey string) string {
+	return clientKey + "_workspace_version"
+}
+
+// getWorkspaceVersion returns the version of the workspace of the connection, read once per client
+func getWorkspaceVersion(ctx context.Context, d *plugin.QueryData) (*semver.Version, error) {
+	conn, clientKey, err := connectWithKey(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := workspaceVersionCacheKey(clientKey)
+	if cachedData, ok := d.ConnectionManager.Cache.Get(cacheKey); ok {
+		return cachedData.(*semver.Version), nil
+	}
+
+	version, err := conn.GetTurbotWorkspaceVersion()
+	if err != nil {
+		return nil, err
+	}
+	d.ConnectionManager.Cache.Set(cacheKey, version)
+	return version, nil
+}
+
+// workspaceFeatureCacheKey returns the connection cache key of whether the workspace of a client supports a
+// feature
+func workspaceFeatureCacheKey(clientKey, feature string) string {
+	return clientKey + "_feature_" + feature
+}
+
+// workspaceSupports returns whether the workspace of the connection supports an API feature, e.g.
+// apiClient.FeatureActiveGrants, so tables can fall back to a query older workspaces support. Support is
+// detected once per client, by querying the field of the feature, see apiClient.SupportsFeature.
+func workspaceSupports(ctx context.Context, d *plugin.QueryData, feature string) (bool, error) {
+	conn, clientKey, err := connectWithKey(ctx, d)
+	if err != nil {
+		return false, err
+	}
+	cacheKey := workspaceFeatureCacheKey(clientKey, feature)
+	if cachedData, ok := d.ConnectionManager.Cache.Get(cacheKey); ok {
+		return cachedData.(bool), nil
+	}
+
+	supported, err := conn.SupportsFeature(feature)
+	if err != nil {
+		return false, err
+	}
+	d.ConnectionManager.Cache.Set(cacheKey, supported)
+	return supported, nil
+}
@@ -0,0 +1,883 @@
+package turbot
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/eko/gocache/v3/cache"
+	"github.com/eko/gocache/v3/store"
+	"github.com/hashicorp/go-hclog"
+	"github.com/machinebox/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/errors"
+	"github.com/turbot/steampipe-plugin-turbot/mockapi"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/connection"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/context_key"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/quals"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// start the mock Turbot API, serving the fixtures in testdata
+func newTestServer(t *testing.T) *httptest.Server {
+	s, err := mockapi.LoadStore("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mockapi.NewServer(s))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testQuery is the query data for a hydrate call against the mock API, collecting the items it streams
+type testQuery struct {
+	d     *plugin.QueryData
+	items []interface{}
+
+	// the rows required by the limit of the query, and streamed so far, see rowsRemaining
+	rowsRequired int64
+	rowsStreamed int64
+}
+
+// the test queries, by their query data
+var testQueries sync.Map
+
+func init() {
+	// the SDK only tracks the rows streamed by the queries it executes, so the hydrates are given the rows
+	// remaining of the test query
+	rowsRemaining = func(ctx context.Context, d *plugin.QueryData) int64 {
+		if plugin.IsCancelled(ctx) {
+			return 0
+		}
+		if q, ok := testQueries.Load(d); ok {
+			return q.(*testQuery).rowsRequired - q.(*testQuery).rowsStreamed
+		}
+		return d.RowsRemaining(ctx)
+	}
+}
+
+// newTestQuery builds the query data for a hydrate call with the given equals quals and limit. The client is
+// put in the connection cache, so connect uses it rather than creating one from the connection config.
+func newTestQuery(t *testing.T, server *httptest.Server, equalsQuals map[string]*proto.QualValue, limit *int64) *testQuery {
+	return newTestClientQuery(t, &apiClient.Client{
+		AccessKey: "access",
+		SecretKey: "secret",
+		Graphql:   graphql.NewClient(server.URL),
+	}, equalsQuals, limit)
+}
+
+// newTestClientQuery builds the query data for a hydrate call using the given client
+func newTestClientQuery(t *testing.T, client *apiClient.Client, equalsQuals map[string]*proto.QualValue, limit *int64) *testQuery {
+	ristrettoCache, err := ristretto.NewCache(&ristretto.Config{NumCounters: 1000, MaxCost: 1 << 20, BufferItems: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	connectionCache := connection.NewConnectionCache("turbot", cache.New[any](store.NewRistretto(ristrettoCache)))
+	q := &testQuery{}
+	q.d = &plugin.QueryData{
+		EqualsQuals:       equalsQuals,
+		Quals:             plugin.KeyColumnQualMap{},
+		QueryContext:      &plugin.QueryContext{UnsafeQuals: map[string]*proto.Quals{}, Limit: limit},
+		Connection:        &plugin.Connection{Name: "turbot", Config: turbotConfig{}},
+		ConnectionManager: connection.NewManager(connectionCache),
+		ConnectionCache:   connectionCache,
+	}
+	cacheClient(q.d.ConnectionManager.Cache, q.d.Connection, credentialsCacheKey(q.d.Connection, getClientConfig(q.d.Connection)), "turbot_client_test", client)
+	ristrettoCache.Wait()
+
+	q.rowsRequired = math.MaxInt32
+	if limit != nil {
+		q.rowsRequired = *limit
+	}
+	q.d.StreamListItem = func(_ context.Context, items ...interface{}) {
+		q.items = append(q.items, items...)
+		q.rowsStreamed += int64(len(items))
+	}
+	testQueries.Store(q.d, q)
+	t.Cleanup(func() { testQueries.Delete(q.d) })
+	return q
+}
+
+func stringQual(value string) *proto.QualValue {
+	return &proto.QualValue{Value: &proto.QualValue_StringValue{StringValue: value}}
+}
+
+func intQual(value int64) *proto.QualValue {
+	return &proto.QualValue{Value: &proto.QualValue_Int64Value{Int64Value: value}}
+}
+
+func boolQual(value bool) *proto.QualValue {
+	return &proto.QualValue{Value: &proto.QualValue_BoolValue{BoolValue: value}}
+}
+
+func intListQual(values ...int64) *proto.QualValue {
+	list := &proto.QualValueList{}
+	for _, v := range values {
+		list.Values = append(list.Values, intQual(v))
+	}
+	return &proto.QualValue{Value: &proto.QualValue_ListValue{ListValue: list}}
+}
+
+func stringListQual(values ...string) *proto.QualValue {
+	list := &proto.QualValueList{}
+	for _, v := range values {
+		list.Values = append(list.Values, stringQual(v))
+	}
+	return &proto.QualValue{Value: &proto.QualValue_ListValue{ListValue: list}}
+}
+
+// itemField returns the value of a dot separated path of struct fields, e.g. Turbot.ID
+func itemField(item interface{}, path string) interface{} {
+	v := reflect.ValueOf(item)
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		v = v.FieldByName(name)
+		if !v.IsValid() {
+			return nil
+		}
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+// testContext returns a context with the logger the SDK adds for hydrate calls
+func testContext() context.Context {
+	return context.WithValue(context.Background(), context_key.Logger, hclog.NewNullLogger())
+}
+
+func TestListHydrates(t *testing.T) {
+	server := newTestServer(t)
+
+	type test struct {
+		name     string
+		hydrate  plugin.HydrateFunc
+		quals    map[string]*proto.QualValue
+		field    string
+		expected []interface{}
+	}
+	bucketType := "tmod:@turbot/aws-s3#/resource/types/bucket"
+	versioningPolicy := "tmod:@turbot/aws-s3#/policy/types/bucketVersioning"
+	tests := []test{
+		{"turbot_active_grant", listActiveGrants, nil, "Grant.Turbot.ID", []interface{}{"14001"}},
+		{"turbot_active_grant grant_id", listActiveGrants, map[string]*proto.QualValue{"grant_id": intQual(14002)}, "Grant.Turbot.ID", nil},
+		{"turbot_control", listControl, nil, "Turbot.ID", []interface{}{"6001", "6002", "6003"}},
+		{"turbot_control state", listControl, map[string]*proto.QualValue{"state": stringQual("alarm")}, "Turbot.ID", []interface{}{"6002"}},
+		{"turbot_control control_type_uri", listControl, map[string]*proto.QualValue{"control_type_uri": stringQual("tmod:@turbot/aws-s3#/control/types/bucketVersioning")}, "Turbot.ID", []interface{}{"6001", "6002"}},
+		{"turbot_control id list", listControl, map[string]*proto.QualValue{"id": intListQual(6001, 6003)}, "Turbot.ID", []interface{}{"6001", "6003"}},
+		{"turbot_control_type", listControlType, nil, "Turbot.ID", []interface{}{"5001", "5002"}},
+		{"turbot_control_type category_uri", listControlType, map[string]*proto.QualValue{"category_uri": stringQual("tmod:@turbot/turbot#/control/categories/cmdb")}, "Turbot.ID", []interface{}{"5001"}},
+		{"turbot_grant", listGrants, nil, "Turbot.ID", []interface{}{"14001", "14002", "14003"}},
+		{"turbot_grant id", listGrants, map[string]*proto.QualValue{"id": intQual(14002)}, "Identity.Email", []interface{}{"john@example.com"}},
+		{"turbot_mod_version", listModVersion, nil, "Version", []interface{}{"5.10.0", "5.9.0", "5.11.0-beta.1", "5.20.0"}},
+		{"turbot_mod_version name and status", listModVersion, map[string]*proto.QualValue{"name": stringQual("aws-s3"), "status": stringQual("rc")}, "Version", []interface{}{"5.11.0-beta.1"}},
+		{"turbot_notification", listNotification, nil, "Turbot.ID", []interface{}{"12001", "12002", "12003"}},
+		{"turbot_notification notification_type", listNotification, map[string]*proto.QualValue{"notification_type": stringQual("control_updated")}, "Turbot.ID", []interface{}{"12002"}},
+		{"turbot_notification actor_identity_id", listNotification, map[string]*proto.QualValue{"actor_identity_id": intQual(11001)}, "Turbot.ID", []interface{}{"12001", "12003"}},
+		{"turbot_notification resource_type_uri", listNotification, map[string]*proto.QualValue{"resource_type_uri": stringQual(bucketType)}, "Turbot.ID", []interface{}{"12001", "12002"}},
+		{"turbot_notification policy_type_uri", listNotification, map[string]*proto.QualValue{"policy_type_uri": stringQual(versioningPolicy)}, "Turbot.ID", []interface{}{"12003"}},
+		{"turbot_policy_setting", listPolicySetting, nil, "Turbot.ID", []interface{}{"9001", "9002", "9003"}},
+		{"turbot_policy_setting orphan", listPolicySetting, map[string]*proto.QualValue{"orphan": boolQual(true)}, "Turbot.ID", []interface{}{"9003"}},
+		{"turbot_policy_setting exception", listPolicySetting, map[string]*proto.QualValue{"exception": boolQual(false)}, "Turbot.ID", []interface{}{"9001", "9003"}},
+		{"turbot_policy_setting policy_type_uri and resource_id", listPolicySetting, map[string]*proto.QualValue{"policy_type_uri": stringQual(versioningPolicy), "resource_id": intQual(1001)}, "Turbot.ID", []interface{}{"9001"}},
+		{"turbot_policy_type", listPolicyType, nil, "Turbot.ID", []interface{}{"8001", "8002"}},
+		{"turbot_policy_type uri", listPolicyType, map[string]*proto.QualValue{"uri": stringQual(versioningPolicy)}, "Turbot.ID", []interface{}{"8001"}},
+		{"turbot_policy_value", listPolicyValue, nil, "Turbot.ID", []interface{}{"10001", "10002", "10003"}},
+		{"turbot_policy_value state", listPolicyValue, map[string]*proto.QualValue{"state": stringQual("invalid")}, "Turbot.ID", []interface{}{"10003"}},
+		{"turbot_policy_value resource_id and policy_type_id", listPolicyValue, map[string]*proto.QualValue{"resource_id": intQual(1002), "policy_type_id": intQual(8001)}, "Turbot.ID", []interface{}{"10001"}},
+		{"turbot_resource", listResource, nil, "Turbot.ID", []interface{}{"1001", "1002", "1003", "1004"}},
+		{"turbot_resource resource_type_uri", listResource, map[string]*proto.QualValue{"resource_type_uri": stringQual(bucketType)}, "Turbot.ID", []interface{}{"1002", "1003"}},
+		{"turbot_resource resource_type_id", listResource, map[string]*proto.QualValue{"resource_type_id": intQual(2001)}, "Turbot.ID", []interface{}{"1001"}},
+		{"turbot_resource id", listResource, map[string]*proto.QualValue{"id": intQual(1003)}, "Turbot.ID", []interface{}{"1003"}},
+		{"turbot_resource filter", listResource, map[string]*proto.QualValue{"filter": stringQual("$.Versioning.Status:Enabled")}, "Turbot.ID", []interface{}{"1002"}},
+		{"turbot_resource filter limit", listResource, map[string]*proto.QualValue{"filter": stringQual("limit:1")}, "Turbot.ID", []interface{}{"1001"}},
+		{"turbot_resource_type", listResourceType, nil, "Turbot.ID", []interface{}{"2001", "2002", "2003"}},
+		{"turbot_resource_type category_uri", listResourceType, map[string]*proto.QualValue{"category_uri": stringQual("tmod:@turbot/turbot#/resource/categories/storage")}, "Turbot.ID", []interface{}{"2002"}},
+		{"turbot_smart_folder", listSmartFolder, nil, "Turbot.ID", []interface{}{"1004"}},
+		{"turbot_smart_folder_attachment", listSmartFolderAttachment, nil, "Resource.Turbot.ID", []interface{}{"1001"}},
+		{"turbot_smart_folder_attachment smart_folder_id", listSmartFolderAttachment, map[string]*proto.QualValue{"smart_folder_id": intQual(1002)}, "Resource.Turbot.ID", nil},
+		{"turbot_smart_folder_attachment resource_id", listSmartFolderAttachment, map[string]*proto.QualValue{"resource_id": intQual(1001)}, "SmartFolder.Turbot.Title", []interface{}{"AWS Baseline"}},
+		{"turbot_smart_folder_attachment resource_id and smart_folder_id", listSmartFolderAttachment, map[string]*proto.QualValue{"resource_id": intQual(1001), "smart_folder_id": intQual(1005)}, "SmartFolder.Turbot.ID", nil},
+		{"turbot_smart_folder_attachment resource_id and smart_folder_id list", listSmartFolderAttachment, map[string]*proto.QualValue{"resource_id": intQual(1001), "smart_folder_id": intListQual(1004, 1005)}, "SmartFolder.Turbot.ID", []interface{}{"1004"}},
+		{"turbot_smart_folder_attachment missing resource_id", listSmartFolderAttachment, map[string]*proto.QualValue{"resource_id": intQual(99)}, "SmartFolder.Turbot.ID", nil},
+		{"turbot_tag", listTag, nil, "Turbot.ID", []interface{}{"16001", "16002"}},
+		{"turbot_tag key and value", listTag, map[string]*proto.QualValue{"key": stringQual("env"), "value": stringQual("dev")}, "Turbot.ID", []interface{}{"16002"}},
+		{"turbot_aws_s3_bucket", listResourceOfType("turbot_aws_s3_bucket", bucketType, nil), nil, "Turbot.ID", []interface{}{"1002", "1003"}},
+		{"turbot_aws_s3_bucket property", listResourceOfType("turbot_aws_s3_bucket", bucketType, []resourcePropertyColumn{{Name: "name", Property: "Name", Type: proto.ColumnType_STRING}}), map[string]*proto.QualValue{"name": stringQual("other-bucket")}, "Turbot.ID", []interface{}{"1003"}},
+	}
+	for _, test := range tests {
+		quals := test.quals
+		if quals == nil {
+			quals = map[string]*proto.QualValue{}
+		}
+		q := newTestQuery(t, server, quals, nil)
+		_, err := test.hydrate(testContext(), q.d, nil)
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+		var values []interface{}
+		for _, item := range q.items {
+			values = append(values, itemField(item, test.field))
+		}
+		assert.Equal(t, test.expected, values, test.name)
+	}
+}
+
+func TestListHydrateLimit(t *testing.T) {
+	server := newTestServer(t)
+	var limit int64 = 2
+	q := newTestQuery(t, server, map[string]*proto.QualValue{}, &limit)
+	_, err := listResource(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	assert.Len(t, q.items, 2)
+}
+
+func TestListNotificationCreateTimestamp(t *testing.T) {
+	server := newTestServer(t)
+	q := newTestQuery(t, server, map[string]*proto.QualValue{}, nil)
+	from := time.Date(2023, 1, 11, 12, 0, 0, 0, time.UTC)
+	q.d.Quals["create_timestamp"] = &plugin.KeyColumnQuals{
+		Name: "create_timestamp",
+		Quals: quals.QualSlice{
+			{Column: "create_timestamp", Operator: ">", Value: &proto.QualValue{Value: &proto.QualValue_TimestampValue{TimestampValue: timestamppb.New(from)}}},
+		},
+	}
+	_, err := listNotification(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, q.items, 1) {
+		assert.Equal(t, "12002", itemField(q.items[0], "Turbot.ID"))
+	}
+}
+
+func TestGetHydrates(t *testing.T) {
+	server := newTestServer(t)
+
+	type test struct {
+		name     string
+		hydrate  plugin.HydrateFunc
+		quals    map[string]*proto.QualValue
+		field    string
+		expected interface{}
+	}
+	tests := []test{
+		{"turbot_control_type", getControlType, map[string]*proto.QualValue{"id": intQual(5002)}, "URI", "tmod:@turbot/aws-s3#/control/types/bucketVersioning"},
+		{"turbot_notification", getNotification, map[string]*proto.QualValue{"id": intQual(12002)}, "Control.State", "alarm"},
+		{"turbot_policy_type", getPolicyType, map[string]*proto.QualValue{"id": intQual(8002)}, "Title", "Tags Template"},
+		{"turbot_resource", getResource, map[string]*proto.QualValue{"aka": stringQual("arn:aws:s3:::my-bucket")}, "Turbot.ID", "1002"},
+		{"turbot_resource_type", getResourceType, map[string]*proto.QualValue{"id": intQual(2001)}, "URI", "tmod:@turbot/aws#/resource/types/account"},
+		{"turbot_smart_folder", getSmartFolder, map[string]*proto.QualValue{"id": intQual(1004)}, "Trunk.Title", "Turbot > AWS Baseline"},
+	}
+	for _, test := range tests {
+		q := newTestQuery(t, server, test.quals, nil)
+		item, err := test.hydrate(testContext(), q.d, nil)
+		if assert.NoError(t, err, test.name) {
+			assert.Equal(t, test.expected, itemField(item, test.field), test.name)
+		}
+	}
+
+	// items which do not exist are not returned - not found errors are ignored by the plugin default get config
+	notFound := []test{
+		{"turbot_control_type", getControlType, map[string]*proto.QualValue{"id": intQual(99)}, "", nil},
+		{"turbot_notification", getNotification, map[string]*proto.QualValue{"id": intQual(99)}, "", nil},
+		{"turbot_resource", getResource, map[string]*proto.QualValue{"aka": stringQual("arn:aws:s3:::missing")}, "", nil},
+		{"turbot_smart_folder", getSmartFolder, map[string]*proto.QualValue{"id": intQual(99)}, "", nil},
+	}
+	for _, test := range notFound {
+		q := newTestQuery(t, server, test.quals, nil)
+		item, err := test.hydrate(testContext(), q.d, nil)
+		if err != nil {
+			assert.True(t, errors.NotFoundError(err), test.name)
+		}
+		assert.Nil(t, item, test.name)
+	}
+}
+
+func TestListCalculatedPolicyPreview(t *testing.T) {
+	server := newTestServer(t)
+	q := newTestQuery(t, server, map[string]*proto.QualValue{
+		"resource_id":       intListQual(1002, 1003),
+		"policy_setting_id": intQual(9003),
+	}, nil)
+	_, err := listCalculatedPolicyPreview(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if !assert.Len(t, q.items, 2) {
+		return
+	}
+	for i, expected := range []string{"prod", "dev"} {
+		preview := q.items[i].(CalculatedPolicyPreview)
+		assert.Empty(t, preview.InputError)
+		assert.Empty(t, preview.RenderError)
+		assert.Equal(t, "tmod:@turbot/aws-s3#/policy/types/bucketTagsTemplate", preview.PolicyTypeURI)
+		assert.Equal(t, map[string]interface{}{"env": expected}, preview.Value)
+	}
+}
+
+func TestGetPolicySettingParse(t *testing.T) {
+	setting := PolicySetting{Value: "a: [1, 2]"}
+	setting.Type.Schema = "type: object"
+	parsed, err := getPolicySettingParse(testContext(), nil, &plugin.HydrateData{Item: setting})
+	assert.NoError(t, err)
+
+	// the columns read the one parse of the row
+	d := &transform.TransformData{Value: parsed}
+	value, _ := parsedPolicyValue(testContext(), d)
+	assert.Equal(t, map[string]interface{}{"a": []interface{}{1, 2}}, value)
+	valueType, _ := parsedPolicyValueType(testContext(), d)
+	assert.Equal(t, "object", valueType)
+	parseError, _ := parsedPolicyValueError(testContext(), d)
+	assert.Nil(t, parseError)
+}
+
+func TestListPolicySettingDrift(t *testing.T) {
+	q := newTestQuery(t, newTestServer(t), map[string]*proto.QualValue{}, nil)
+	driftProfile := "drift"
+	q.d.Connection.Config = turbotConfig{DriftProfile: &driftProfile}
+	drifted := &apiClient.Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(newTestServer(t).URL)}
+	_, err := drifted.UpdatePolicySetting(map[string]interface{}{"id": "9001", "value": "Enforce: Enabled"})
+	assert.NoError(t, err)
+	config, err := getDriftClientConfig(q.d.Connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	driftKey := credentialsCacheKey(q.d.Connection, config)
+	cacheClient(q.d.ConnectionManager.Cache, q.d.Connection, driftKey, "turbot_client_drift_test", drifted)
+	waitForCache(q, driftKey)
+	waitForCache(q, "turbot_client_drift_test")
+
+	_, err = listPolicySettingDrift(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if !assert.Len(t, q.items, 1) {
+		return
+	}
+	row := q.items[0].(PolicySettingDrift)
+	assert.Equal(t, "changed", row.Status)
+	assert.Equal(t, "arn:aws:::123456789012", row.ResourceAka)
+	assert.Equal(t, []string{"value"}, row.ChangedFields)
+	assert.Equal(t, "Check: Enabled", row.Value)
+	assert.Equal(t, "Enforce: Enabled", row.DriftValue)
+}
+
+func TestListWorkspace(t *testing.T) {
+	var resources, policyValues []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"turbot": {"id": "178806", "akas": ["tmod:@turbot/turbot#/"], "path": "178806"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/turbot"}},
+		{"data": {"version": "5.40.2"}, "turbot": {"id": "2101", "parentId": "178806", "akas": ["tmod:@turbot/turbot"], "path": "178806.2101"}, "type": {"uri": "tmod:@turbot/turbot#/resource/types/mod"}},
+		{"trunk": {"title": "Turbot > Local > Jane Doe"}, "data": {"email": "jane@example.com"}, "turbot": {"id": "3201", "title": "Jane Doe", "akas": ["jane@example.com"], "path": "178806.3001.3201"}, "type": {"uri": "tmod:@turbot/turbot-iam#/resource/types/profile"}}
+	]`), &resources))
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"value": "5.18.2", "secretValue": "5.18.2", "type": {"uri": "tmod:@turbot/turbot#/policy/types/workspaceVersion"}, "turbot": {"id": "10101", "resourceId": "178806"}}
+	]`), &policyValues))
+	store, err := mockapi.NewStore(map[string][]map[string]interface{}{"resources": resources, "policyValues": policyValues})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mockapi.NewServer(store))
+	t.Cleanup(server.Close)
+
+	q := newTestQuery(t, server, nil, nil)
+	_, err = listWorkspace(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if !assert.Len(t, q.items, 1) {
+		return
+	}
+	workspace := q.items[0].(Workspace)
+	assert.Equal(t, "5.18.2", workspace.WorkspaceVersion)
+	assert.Equal(t, "5.40.2", workspace.TurbotModVersion)
+	if assert.NotNil(t, workspace.Identity) {
+		assert.Equal(t, "3201", workspace.Identity.Turbot.Id)
+		assert.Equal(t, "Turbot > Local > Jane Doe", workspace.Identity.Trunk.Title)
+	}
+	assert.Equal(t, map[string]bool{
+		apiClient.FeatureActiveGrants:             true,
+		apiClient.FeatureNotificationActiveGrants: true,
+		apiClient.FeatureModVersionSearch:         true,
+	}, workspace.Features)
+
+	// the version is read again for the client of new credentials, e.g. of another workspace
+	waitForCache(q, workspaceVersionCacheKey("turbot_client_test"))
+	policyValues[0]["value"], policyValues[0]["secretValue"] = "5.30.0", "5.30.0"
+	store, err = mockapi.NewStore(map[string][]map[string]interface{}{"resources": resources, "policyValues": policyValues})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upgraded := httptest.NewServer(mockapi.NewServer(store))
+	t.Cleanup(upgraded.Close)
+	client := &apiClient.Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(upgraded.URL)}
+	credentialsKey := credentialsCacheKey(q.d.Connection, getClientConfig(q.d.Connection))
+	cacheClient(q.d.ConnectionManager.Cache, q.d.Connection, credentialsKey, "turbot_client_upgraded", client)
+	for i := 0; i < 100; i++ {
+		if clientKey, _ := q.d.ConnectionManager.Cache.Get(credentialsKey); clientKey == "turbot_client_upgraded" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	version, err := getWorkspaceVersion(testContext(), q.d)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "5.30.0", version.String())
+	}
+}
+
+// newOlderTestServer starts the mock Turbot API of an older workspace, which rejects queries of the given fields
+func newOlderTestServer(t *testing.T, fields ...string) *httptest.Server {
+	s, err := mockapi.LoadStore("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := mockapi.NewServer(s)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, field := range fields {
+			if strings.Contains(string(body), field) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"errors": [{"message": "Cannot query field \"%s\""}]}`, field)
+				return
+			}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSelectQuery(t *testing.T) {
+	var probes int
+	q := newTestQuery(t, newOlderTestServer(t, "activeGrantsId", "modVersionSearches"), map[string]*proto.QualValue{}, nil)
+	conn, err := connect(testContext(), q.d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	onRequest := conn.OnRequest
+	conn.OnRequest = func(request apiClient.RequestStats) {
+		if request.Operation == "featureProbe" {
+			probes++
+		}
+		onRequest(request)
+	}
+	q.d.QueryContext.Columns = []string{"id", "active_grant_id"}
+
+	query, err := selectQuery(testContext(), q.d, "turbot_notification", "notificationList")
+	assert.NoError(t, err)
+	assert.Equal(t, queryNotificationListLegacy, query)
+	query, err = selectQuery(testContext(), q.d, "turbot_active_grant", "activeGrantList")
+	assert.NoError(t, err)
+	assert.Equal(t, activeGrants, query)
+	_, err = selectQuery(testContext(), q.d, "turbot_mod_version", "modVersionList")
+	assert.EqualError(t, err, "turbot_mod_version is not supported by the workspace, which has no modVersionSearches field")
+
+	// support is detected once per client
+	waitForCache(q, workspaceFeatureCacheKey("turbot_client_test", apiClient.FeatureModVersionSearch))
+	_, err = selectQuery(testContext(), q.d, "turbot_mod_version", "modVersionList")
+	assert.Error(t, err)
+	assert.Equal(t, 3, probes)
+
+	// the active grant columns of notifications are NULL for older workspaces
+	_, err = listNotification(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, q.items) {
+		for _, item := range q.items {
+			assert.Nil(t, item.(Notification).Turbot.ActiveGrantsID)
+		}
+	}
+	assert.NotContains(t, queryNotificationListLegacy, "activeGrant")
+
+	// the newest variant is used if support can't be detected
+	offline := newTestClientQuery(t, &apiClient.Client{Graphql: graphql.NewClient("http://127.0.0.1:1")}, map[string]*proto.QualValue{}, nil)
+	query, err = selectQuery(testContext(), offline.d, "turbot_mod_version", "modVersionList")
+	assert.NoError(t, err)
+	assert.Equal(t, queryModVersions, query)
+}
+
+func TestListSmartFolderAttachmentPaging(t *testing.T) {
+	// a smart folder attached to more resources, and a resource attached to more smart folders, than fit in a page
+	end := func(id int, uri string) map[string]interface{} {
+		return map[string]interface{}{"turbot": map[string]interface{}{"id": strconv.Itoa(id)}, "type": map[string]interface{}{"uri": uri}}
+	}
+	const smartFolderType = "tmod:@turbot/turbot#/resource/types/smartFolder"
+	var attachedResources, attachedSmartFolders []interface{}
+	for i := 0; i < 150; i++ {
+		attachedResources = append(attachedResources, end(5000+i, "tmod:@turbot/aws#/resource/types/account"))
+		attachedSmartFolders = append(attachedSmartFolders, end(6000+i, smartFolderType))
+	}
+	folder := end(2001, smartFolderType)
+	folder["attachedResources"] = map[string]interface{}{"items": attachedResources}
+	resource := end(5000, "tmod:@turbot/aws#/resource/types/account")
+	resource["attachedSmartFolders"] = map[string]interface{}{"items": attachedSmartFolders}
+	store, err := mockapi.NewStore(map[string][]map[string]interface{}{"resources": {folder, resource}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mockapi.NewServer(store))
+	t.Cleanup(server.Close)
+
+	q := newTestQuery(t, server, map[string]*proto.QualValue{}, nil)
+	_, err = listSmartFolderAttachment(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, q.items, 150) {
+		assert.Equal(t, "5149", q.items[149].(SmartFolderAttachment).Resource.Turbot.ID)
+	}
+
+	q = newTestQuery(t, server, map[string]*proto.QualValue{"resource_id": intQual(5000)}, nil)
+	_, err = listSmartFolderAttachment(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, q.items, 150) {
+		assert.Equal(t, "6149", q.items[149].(SmartFolderAttachment).SmartFolder.Turbot.ID)
+	}
+
+	q = newTestQuery(t, server, map[string]*proto.QualValue{"resource_id": intQual(5000), "smart_folder_id": intListQual(6000, 6120)}, nil)
+	_, err = listSmartFolderAttachment(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	var ids []interface{}
+	for _, item := range q.items {
+		ids = append(ids, item.(SmartFolderAttachment).SmartFolder.Turbot.ID)
+	}
+	assert.Equal(t, []interface{}{"6000", "6120"}, ids)
+}
+
+func TestListDirectory(t *testing.T) {
+	// a certificate expiring soon, in the base64 DER form identity providers give
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notAfter := time.Now().Add(10 * 24 * time.Hour).UTC().Truncate(time.Second)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "idp.example.com"}, NotBefore: time.Now().Add(-time.Hour), NotAfter: notAfter}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resources []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"data": {"title": "Okta", "status": "Active", "entryPoint": "https://idp.example.com/sso", "certificate": "`+base64.StdEncoding.EncodeToString(der)+`", "signRequests": "Disabled", "signaturePrivateKey": "saml-private-key", "signatureAlgorithm": "sha256", "allowIdpInitiatedSso": true}, "turbot": {"id": "3001", "parentId": "178806", "akas": ["saml"], "path": "178806.3001"}, "type": {"uri": "tmod:@turbot/turbot-iam#/resource/types/samlDirectory"}},
+		{"data": {"title": "AD", "status": "Active", "url": "ldap://ad.example.com", "password": "ldap-password", "tlsEnabled": false, "rejectUnauthorized": false}, "turbot": {"id": "3002", "parentId": "178806", "path": "178806.3002"}, "type": {"uri": "tmod:@turbot/turbot-iam#/resource/types/ldapDirectory"}},
+		{"data": {"title": "Google", "status": "Active", "clientId": "client", "clientSecret": "google-client-secret", "hostedDomain": "example.com"}, "turbot": {"id": "3003", "parentId": "178806", "path": "178806.3003"}, "type": {"uri": "tmod:@turbot/turbot-iam#/resource/types/googleDirectory"}},
+		{"data": {"title": "123456789012"}, "turbot": {"id": "1001", "path": "1001"}, "type": {"uri": "tmod:@turbot/aws#/resource/types/account"}}
+	]`), &resources))
+	store, err := mockapi.NewStore(map[string][]map[string]interface{}{"resources": resources})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(mockapi.NewServer(store))
+	t.Cleanup(server.Close)
+
+	q := newTestQuery(t, server, nil, nil)
+	_, err = listDirectory(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if !assert.Len(t, q.items, 3) {
+		return
+	}
+	saml, ldap, google := q.items[0].(Directory), q.items[1].(Directory), q.items[2].(Directory)
+	assert.Equal(t, "saml", saml.DirectoryType)
+	assert.Equal(t, []string{"sign_requests_disabled", "idp_initiated_sso_allowed", "certificate_expires_soon"}, saml.InsecureSettings)
+	if assert.NotNil(t, saml.CertificateExpiry) {
+		assert.True(t, notAfter.Equal(*saml.CertificateExpiry))
+	}
+	assert.Equal(t, "CN=idp.example.com", saml.CertificateSubject)
+	assert.Equal(t, []string{"tls_disabled", "server_certificate_not_verified"}, ldap.InsecureSettings)
+	assert.Empty(t, google.InsecureSettings)
+
+	// secrets are never read or returned
+	for _, field := range []string{"signaturePrivateKey", "password", "clientSecret"} {
+		assert.NotContains(t, queryDirectoryList, field)
+	}
+	rows, err := json.Marshal(q.items)
+	assert.NoError(t, err)
+	for _, secret := range []string{"saml-private-key", "ldap-password", "google-client-secret"} {
+		assert.NotContains(t, string(rows), secret)
+	}
+
+	q = newTestQuery(t, server, map[string]*proto.QualValue{"directory_type": stringQual("ldap")}, nil)
+	_, err = listDirectory(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, q.items, 1) {
+		assert.Equal(t, "3002", q.items[0].(Directory).ID)
+	}
+
+	for name, quals := range map[string]map[string]*proto.QualValue{
+		"directory_type list": {"directory_type": stringListQual("ldap", "google", "unknown")},
+		"id list":             {"id": intListQual(3002, 3003)},
+	} {
+		q = newTestQuery(t, server, quals, nil)
+		_, err = listDirectory(testContext(), q.d, nil)
+		assert.NoError(t, err, name)
+		var ids []string
+		for _, item := range q.items {
+			ids = append(ids, item.(Directory).ID)
+		}
+		assert.Equal(t, []string{"3002", "3003"}, ids, name)
+	}
+}
+
+func TestListCredentialProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.yml")
+	if err := os.WriteFile(path, []byte(`default:
+  accessKey: access
+  secretKey: secret
+  workspace: acme.cloud.turbot.com
+staging:
+  accessKey: access
+  workspace: staging.cloud.turbot.com
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TURBOT_SHARED_CREDENTIALS_FILE", path)
+	t.Setenv("TURBOT_ACCESS_KEY", "")
+	t.Setenv("TURBOT_PROFILE", "")
+
+	// the profiles of the file, with the default profile in effect
+	q := newTestQuery(t, newTestServer(t), nil, nil)
+	_, err := listCredentialProfile(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, q.items, 2) {
+		profile := q.items[0].(CredentialProfile)
+		assert.Equal(t, "default", *profile.Name)
+		assert.True(t, profile.InEffect)
+		assert.True(t, profile.SecretKeySet)
+		assert.Nil(t, profile.Error)
+		assert.Equal(t, 1, profile.Line)
+		profile = q.items[1].(CredentialProfile)
+		assert.False(t, profile.InEffect)
+		assert.False(t, profile.SecretKeySet)
+		assert.Equal(t, "the profile does not set all of accessKey, secretKey and workspace", *profile.Error)
+	}
+
+	// credentials set in the config are in effect instead, and a missing profile is listed with the others
+	for _, config := range []turbotConfig{
+		{AccessKey: stringPointer("key"), SecretKey: stringPointer("secret"), Workspace: stringPointer("acme.cloud.turbot.com")},
+		{Profile: stringPointer("production")},
+	} {
+		q = newTestQuery(t, newTestServer(t), nil, nil)
+		q.d.Connection.Config = config
+		_, err = listCredentialProfile(testContext(), q.d, nil)
+		assert.NoError(t, err)
+		if assert.Len(t, q.items, 3) {
+			assert.False(t, q.items[0].(CredentialProfile).InEffect)
+			row := q.items[2].(CredentialProfile)
+			assert.Nil(t, row.Name)
+			if config.Profile == nil {
+				assert.Equal(t, "config", *row.Source)
+				assert.True(t, row.InEffect)
+				assert.Nil(t, row.Error)
+			} else {
+				assert.False(t, row.InEffect)
+				assert.Contains(t, *row.Error, "profile production not found in credentials file "+path+", available profiles are default, staging")
+			}
+		}
+	}
+}
+
+func TestConnectReloadsCredentials(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials.yml")
+	// the file is replaced rather than written, as by an editor
+	write := func(accessKey string) {
+		content := "default:\n  accessKey: " + accessKey + "\n  secretKey: secret\n  workspace: acme.cloud.turbot.com\n"
+		if err := os.WriteFile(filepath.Join(dir, "new.yml"), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(filepath.Join(dir, "new.yml"), path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("first")
+	t.Setenv("TURBOT_SHARED_CREDENTIALS_FILE", path)
+	t.Setenv("TURBOT_ACCESS_KEY", "")
+	t.Setenv("TURBOT_PROFILE", "")
+
+	// a server which rejects all credentials
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	}))
+	t.Cleanup(server.Close)
+
+	q := newTestQuery(t, newTestServer(t), nil, nil)
+	q.d.Connection.Config = turbotConfig{Profile: stringPointer("default")}
+	config := getClientConfig(q.d.Connection)
+	// the clients are cached with the keys of their credentials, so connect uses them rather than creating them.
+	// Only the clients stay cached, so connect resolves the credentials to find them.
+	cached := func(client *apiClient.Client) string {
+		clientKey, _, err := resolveClientConfig(testContext(), q.d.Connection, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		credentialsKey := credentialsCacheKey(q.d.Connection, config)
+		cacheClient(q.d.ConnectionManager.Cache, q.d.Connection, credentialsKey, clientKey, client)
+		waitForCache(q, clientKey)
+		waitForCache(q, credentialsKey)
+		q.d.ConnectionManager.Cache.Delete(credentialsKey)
+		return clientKey
+	}
+	first := &apiClient.Client{AccessKey: "first", SecretKey: "secret", Graphql: graphql.NewClient(server.URL)}
+	firstKey := cached(first)
+	assert.NotEqual(t, firstKey, clientCacheKey(&plugin.Connection{Name: "other"}, "https://acme.cloud.turbot.com/api/latest/graphql", "first", "secret", "", "", "false"))
+	client, err := connect(testContext(), q.d)
+	assert.NoError(t, err)
+	assert.Same(t, first, client)
+
+	// changing the file expires the credentials of the client
+	generation := atomic.LoadInt64(&credentialsGeneration)
+	write("second")
+	for i := 0; i < 100 && atomic.LoadInt64(&credentialsGeneration) == generation; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NotEqual(t, generation, atomic.LoadInt64(&credentialsGeneration))
+	second := &apiClient.Client{AccessKey: "second", SecretKey: "secret", Graphql: graphql.NewClient(server.URL)}
+	assert.NotEqual(t, firstKey, cached(second))
+	client, err = connect(testContext(), q.d)
+	assert.NoError(t, err)
+	assert.Same(t, second, client)
+
+	// as does the workspace rejecting them
+	credentialsKey := credentialsCacheKey(q.d.Connection, config)
+	waitForCache(q, credentialsKey)
+	assert.Error(t, client.DoRequest(`{ actor { identity { turbot { id } } } }`, nil, &map[string]interface{}{}))
+	_, ok := q.d.ConnectionManager.Cache.Get(credentialsKey)
+	assert.False(t, ok)
+}
+
+func TestConnectSharedClient(t *testing.T) {
+	server := newTestServer(t)
+	q := newTestQuery(t, server, nil, nil)
+	cache := q.d.ConnectionManager.Cache
+
+	// two configs which differ, but resolve to the same client
+	config := apiClient.ClientConfig{SnapshotPath: "snapshot.json"}
+	other := apiClient.ClientConfig{SnapshotPath: "snapshot.json", Profile: "other"}
+	clientKey, _, err := resolveClientConfig(testContext(), q.d.Connection, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &apiClient.Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(server.URL)}
+	cacheClient(cache, q.d.Connection, credentialsCacheKey(q.d.Connection, config), clientKey, client)
+	waitForCache(q, clientKey)
+
+	// connecting with the other config while the client is in use shares it, and leaves its hooks alone, which
+	// go test -race checks
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, client.DoRequest(`{ actor { identity { turbot { id } } } }`, nil, &map[string]interface{}{}))
+		}
+	}()
+	shared, key, err := connectConfig(testContext(), q.d.Connection, cache, other)
+	wg.Wait()
+	assert.NoError(t, err)
+	assert.Same(t, client, shared)
+	assert.Equal(t, clientKey, key)
+}
+
+func TestListAPICallStats(t *testing.T) {
+	// the summaries of the connection of earlier tests are discarded
+	callStatsMutex.Lock()
+	delete(callStats, "turbot")
+	callStatsMutex.Unlock()
+	q := newTestQuery(t, newTestServer(t), map[string]*proto.QualValue{}, nil)
+
+	// the requests of the hydrates are tagged with their table
+	q.d.Table = tableTurbotResource(testContext())
+	_, err := listResource(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	resources := len(q.items)
+	q.items = nil
+	_, err = listAPICallStats(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, q.items, 1) {
+		stats := q.items[0].(APICallStats)
+		assert.Equal(t, "turbot_resource", stats.Table)
+		assert.Equal(t, "resourceList", stats.Operation)
+		assert.Equal(t, int64(1), stats.Calls)
+		assert.Equal(t, int64(resources), stats.Rows)
+		assert.Equal(t, int64(0), stats.Errors)
+		assert.False(t, stats.LastCall.IsZero())
+	}
+}
+
+func TestListQueryExplain(t *testing.T) {
+	explainedMutex.Lock()
+	delete(explained, "turbot")
+	explainedMutex.Unlock()
+	server := newTestServer(t)
+	q := newTestQuery(t, server, map[string]*proto.QualValue{"resource_type_id": {Value: &proto.QualValue_Int64Value{Int64Value: 2001}}}, nil)
+	_, err := listQueryExplain(testContext(), q.d, nil)
+	assert.EqualError(t, err, "the debug_queries connection option is not set")
+
+	// the client of the connection debugs its queries once the option is set
+	debug := true
+	q.d.Connection.Config = turbotConfig{DebugQueries: &debug}
+	client := &apiClient.Client{AccessKey: "access", SecretKey: "secret", Graphql: graphql.NewClient(server.URL), DebugQueries: true}
+	credentialsKey := credentialsCacheKey(q.d.Connection, getClientConfig(q.d.Connection))
+	cacheClient(q.d.ConnectionManager.Cache, q.d.Connection, credentialsKey, "turbot_client_debug", client)
+	waitForCache(q, credentialsKey)
+	waitForCache(q, "turbot_client_debug")
+
+	q.d.Table = tableTurbotResource(testContext())
+	_, err = listResource(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	resources := len(q.items)
+	q.items = nil
+	_, err = listQueryExplain(testContext(), q.d, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, q.items, 1) {
+		request := q.items[0].(QueryExplain)
+		assert.Equal(t, "turbot_resource", request.Table)
+		assert.Equal(t, "resourceList", request.Operation)
+		assert.Equal(t, "resourceTypeId:2001 resourceTypeLevel:self limit:5000", request.Filter)
+		assert.Equal(t, 1, request.Page)
+		assert.Equal(t, resources, request.Rows)
+		assert.Equal(t, queryResourceList, request.Query)
+		assert.Equal(t, []string{"resourceTypeId:2001 resourceTypeLevel:self", "limit:5000"}, request.Variables["filter"])
+	}
+}
+
+// waitForCache waits for a key to be set in the connection cache, as cache sets are applied asynchronously
+func waitForCache(q *testQuery, key string) {
+	for i := 0; i < 100; i++ {
+		if _, ok := q.d.ConnectionManager.Cache.Get(key); ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -17,10 +17,6 @@ type TurbotIDObject struct {
 	}
 }
 
-type ResourceResponse struct {
-	Resource Resource
-}
-
 type Resource struct {
 	AttachedResources struct {
 		Items []TurbotIDObject
@@ -45,10 +41,6 @@ type ResourceTypesResponse struct {
 	}
 }
 
-type ResourceTypeResponse struct {
-	ResourceType ResourceType
-}
-
 type ResourceType struct {
 	Category struct {
 		Turbot struct {
@@ -106,10 +98,6 @@ type ControlTypesResponse struct {
 	}
 }
 
-type ControlTypeResponse struct {
-	ControlType ControlType
-}
-
 type ControlType struct {
 	Category struct {
 		Turbot struct {
@@ -194,10 +182,6 @@ type PolicyTypesResponse struct {
 	}
 }
 
-type PolicyTypeResponse struct {
-	PolicyType PolicyType
-}
-
 type PolicyType struct {
 	Category struct {
 		Turbot struct {
@@ -259,6 +243,7 @@ type PolicyValueResourceDetails struct {
 type PolicyValueType struct {
 	ModURI          string
 	DefaultTemplate string
+	Schema          interface{}
 	Title           string
 	Trunk           struct {
 		Title string
@@ -374,7 +359,8 @@ type PolicySetting struct {
 	Template      string
 	TemplateInput interface{}
 	Type          struct {
-		Trunk struct {
+		Schema interface{}
+		Trunk  struct {
 			Title string
 		}
 		URI string
@@ -406,10 +392,6 @@ type NotificationsResponse struct {
 	}
 }
 
-type NotificationsGetResponse struct {
-	Notification Notification
-}
-
 type Notification struct {
 	Icon             string
 	Message          string
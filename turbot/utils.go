@@ -8,7 +8,9 @@ import (
 
 	"github.com/turbot/go-kit/types"
 	"github.com/turbot/steampipe-plugin-turbot/apiClient"
+	"github.com/turbot/steampipe-plugin-turbot/helpers"
 
+	connection_manager "github.com/turbot/steampipe-plugin-sdk/v5/connection"
 	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
 	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
 	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
@@ -19,18 +21,118 @@ const (
 )
 
 func connect(ctx context.Context, d *plugin.QueryData) (*apiClient.Client, error) {
+	client, _, err := connectWithKey(ctx, d)
+	return client, err
+}
 
-	// Load connection from cache, which preserves throttling protection etc
-	cacheKey := "turbot"
-	if cachedData, ok := d.ConnectionManager.Cache.Get(cacheKey); ok {
-		return cachedData.(*apiClient.Client), nil
+// connectWithKey returns the client of the connection and its cache key, which changes with the workspace and
+// credentials of the client, so data read from the workspace can be cached until they change
+func connectWithKey(ctx context.Context, d *plugin.QueryData) (*apiClient.Client, string, error) {
+	return connectConfig(ctx, d.Connection, d.ConnectionManager.Cache, getClientConfig(d.Connection))
+}
+
+// connectCached returns the client of the connection from the connection cache, creating it if the credentials
+// have changed or were never resolved. It is used outside of hydrate calls, e.g. to build the table map.
+func connectCached(ctx context.Context, connection *plugin.Connection, cache *connection_manager.Cache) (*apiClient.Client, error) {
+	client, _, err := connectConfig(ctx, connection, cache, getClientConfig(connection))
+	return client, err
+}
+
+// connectConfig returns the client of the connection for the config, and its cache key, from the connection
+// cache, creating it if the credentials have changed or were never resolved
+func connectConfig(ctx context.Context, connection *plugin.Connection, cache *connection_manager.Cache, config apiClient.ClientConfig) (*apiClient.Client, string, error) {
+
+	// Load connection from cache, which preserves throttling protection etc, see cacheClient
+	credentialsKey := credentialsCacheKey(connection, config)
+	if clientKey, ok := cache.Get(credentialsKey); ok {
+		if cachedData, ok := cache.Get(clientKey.(string)); ok {
+			return cachedData.(*apiClient.Client), clientKey.(string), nil
+		}
+	}
+
+	// The credentials have changed, or were never resolved
+	clientKey, config, err := resolveClientConfig(ctx, connection, config)
+	if err != nil {
+		return nil, "", err
+	}
+	if cachedData, ok := cache.Get(clientKey); ok {
+		// the client is already in use, so its hooks are not set again, which would race with its requests
+		cache.Set(credentialsKey, clientKey)
+		return cachedData.(*apiClient.Client), clientKey, nil
+	}
+
+	client, err := createClientFromConfig(config)
+	if err != nil {
+		return nil, "", err
 	}
 
+	// Save to cache
+	cacheClient(cache, connection, credentialsKey, clientKey, client)
+
+	// Done
+	return client, clientKey, nil
+}
+
+// createClientFromConfig creates and validates a Turbot client
+func createClientFromConfig(config apiClient.ClientConfig) (*apiClient.Client, error) {
+	client, err := apiClient.CreateClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating Turbot client: %s", err.Error())
+	}
+	if err = client.Validate(); err != nil {
+		return nil, fmt.Errorf("Error validating Turbot client: %s", err.Error())
+	}
+	return client, nil
+}
+
+// connectDrift returns the client of the workspace set by the drift_profile connection option, which the
+// workspace of the connection is compared with. It is cached like the client of the connection, see connect.
+func connectDrift(ctx context.Context, d *plugin.QueryData) (*apiClient.Client, error) {
+	config, err := getDriftClientConfig(d.Connection)
+	if err != nil {
+		return nil, err
+	}
+	client, _, err := connectConfig(ctx, d.Connection, d.ConnectionManager.Cache, config)
+	if err != nil {
+		return nil, fmt.Errorf("drift_profile: %s", err.Error())
+	}
+	return client, nil
+}
+
+// getDriftClientConfig builds the Turbot client config of the drift_profile connection option
+func getDriftClientConfig(connection *plugin.Connection) (apiClient.ClientConfig, error) {
+	profile := GetConfig(connection).DriftProfile
+	if profile == nil {
+		return apiClient.ClientConfig{}, fmt.Errorf("the drift_profile connection option is not set")
+	}
+	config := apiClient.ClientConfig{Credentials: apiClient.ClientCredentials{}, Profile: *profile}
+	if debugQueries := GetConfig(connection).DebugQueries; debugQueries != nil {
+		config.DebugQueries = *debugQueries
+	}
+	return config, nil
+}
+
+// rowsRemaining returns the number of rows the query still requires, see plugin.QueryData.RowsRemaining. The
+// SDK only tracks the rows of queries it executes, so tests of the hydrates replace it.
+var rowsRemaining = func(ctx context.Context, d *plugin.QueryData) int64 {
+	return d.RowsRemaining(ctx)
+}
+
+// requestContext tags the API requests of a hydrate call with its table, see apiClient.RequestStats
+func requestContext(ctx context.Context, d *plugin.QueryData) context.Context {
+	if d.Table == nil {
+		return ctx
+	}
+	return apiClient.WithTable(ctx, d.Table.Name)
+}
+
+// getClientConfig builds the Turbot client config from the connection config
+func getClientConfig(connection *plugin.Connection) apiClient.ClientConfig {
 	// Start with an empty Turbot config
 	config := apiClient.ClientConfig{Credentials: apiClient.ClientCredentials{}}
 
 	// Prefer config options given in Steampipe
-	turbotConfig := GetConfig(d.Connection)
+	turbotConfig := GetConfig(connection)
 	if turbotConfig.Profile != nil {
 		config.Profile = *turbotConfig.Profile
 	}
@@ -43,21 +145,26 @@ func connect(ctx context.Context, d *plugin.QueryData) (*apiClient.Client, error
 	if turbotConfig.SecretKey != nil {
 		config.Credentials.SecretKey = *turbotConfig.SecretKey
 	}
-
-	// Create the client
-	client, err := apiClient.CreateClient(config)
-	if err != nil {
-		return nil, fmt.Errorf("Error creating Turbot client: %s", err.Error())
+	if turbotConfig.CredentialProcess != nil {
+		config.CredentialProcess = *turbotConfig.CredentialProcess
 	}
-	if err = client.Validate(); err != nil {
-		return nil, fmt.Errorf("Error validating Turbot client: %s", err.Error())
+	if turbotConfig.RecordCassette != nil {
+		config.RecordPath = *turbotConfig.RecordCassette
+	}
+	if turbotConfig.ReplayCassette != nil {
+		config.ReplayPath = *turbotConfig.ReplayCassette
+	}
+	if turbotConfig.SnapshotPath != nil {
+		config.SnapshotPath = *turbotConfig.SnapshotPath
+	}
+	if turbotConfig.TraceFile != nil {
+		config.TracePath = *turbotConfig.TraceFile
+	}
+	if turbotConfig.DebugQueries != nil {
+		config.DebugQueries = *turbotConfig.DebugQueries
 	}
 
-	// Save to cache
-	d.ConnectionManager.Cache.Set(cacheKey, client)
-
-	// Done
-	return client, nil
+	return config
 }
 
 func getMapValue(_ context.Context, d *transform.TransformData) (interface{}, error) {
@@ -119,6 +226,57 @@ func pathToArray(_ context.Context, d *transform.TransformData) (interface{}, er
 	return pathInts, nil
 }
 
+// policyValueParse is the result of parsing a policy value according to the schema of its policy type
+type policyValueParse struct {
+	Value interface{}
+	Type  string
+	Error error
+}
+
+// getPolicyValueParse parses the value of a policy value row once, for the columns reading the result
+func getPolicyValueParse(_ context.Context, _ *plugin.QueryData, h *plugin.HydrateData) (interface{}, error) {
+	v := h.Item.(PolicyValue)
+	return parsePolicyValue(v.Value, v.Type.Schema), nil
+}
+
+// getPolicySettingParse parses the value of a policy setting row once, for the columns reading the result
+func getPolicySettingParse(_ context.Context, _ *plugin.QueryData, h *plugin.HydrateData) (interface{}, error) {
+	s := h.Item.(PolicySetting)
+	return parsePolicyValue(s.Value, s.Type.Schema), nil
+}
+
+func parsePolicyValue(value interface{}, schema interface{}) policyValueParse {
+	// the schema may be returned as a YAML or JSON string rather than an object
+	if s, ok := schema.(string); ok {
+		parsed, err := helpers.ParseYamlString(s)
+		if err != nil {
+			return policyValueParse{Error: fmt.Errorf("error parsing policy type schema: %s", err.Error())}
+		}
+		schema = helpers.NormalizeYamlValue(parsed)
+	}
+	parsed, valueType, err := helpers.ParsePolicyValue(value, schema)
+	return policyValueParse{Value: parsed, Type: valueType, Error: err}
+}
+
+func parsedPolicyValue(_ context.Context, d *transform.TransformData) (interface{}, error) {
+	return d.Value.(policyValueParse).Value, nil
+}
+
+func parsedPolicyValueType(_ context.Context, d *transform.TransformData) (interface{}, error) {
+	t := d.Value.(policyValueParse).Type
+	if t == "" {
+		return nil, nil
+	}
+	return t, nil
+}
+
+func parsedPolicyValueError(_ context.Context, d *transform.TransformData) (interface{}, error) {
+	if err := d.Value.(policyValueParse).Error; err != nil {
+		return err.Error(), nil
+	}
+	return nil, nil
+}
+
 func escapeQualString(_ context.Context, quals map[string]*proto.QualValue, qualName string) string {
 	s := quals[qualName].GetStringValue()
 	s = strings.Replace(s, "\\", "\\\\", -1)
@@ -127,30 +285,13 @@ func escapeQualString(_ context.Context, quals map[string]*proto.QualValue, qual
 }
 
 func getTurbotWorkspace(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
-	// Load workspace name from cache
-	cacheKey := "getTurbotWorkspaceInfo"
+	// Load workspace name from cache, until the credentials change
+	config := getClientConfig(d.Connection)
+	cacheKey := credentialsCacheKey(d.Connection, config) + "_workspace"
 	if cachedData, ok := d.ConnectionManager.Cache.Get(cacheKey); ok {
 		return cachedData.(string), nil
 	}
 
-	// Start with an empty Turbot config
-	config := apiClient.ClientConfig{Credentials: apiClient.ClientCredentials{}}
-
-	// Prefer config options given in Steampipe
-	turbotConfig := GetConfig(d.Connection)
-	if turbotConfig.Profile != nil {
-		config.Profile = *turbotConfig.Profile
-	}
-	if turbotConfig.Workspace != nil {
-		config.Credentials.Workspace = *turbotConfig.Workspace
-	}
-	if turbotConfig.AccessKey != nil {
-		config.Credentials.AccessKey = *turbotConfig.AccessKey
-	}
-	if turbotConfig.SecretKey != nil {
-		config.Credentials.SecretKey = *turbotConfig.SecretKey
-	}
-
 	credentials, err := apiClient.GetCredentials(config)
 	if err != nil {
 		return nil, nil
@@ -158,12 +299,26 @@ func getTurbotWorkspace(ctx context.Context, d *plugin.QueryData, _ *plugin.Hydr
 	endpoint := credentials.Workspace // https://pikachu-turbot.cloud.turbot-dev.com/api/latest/graphql
 	if endpoint != "" {
 		workspaceUrl := strings.Split(endpoint, "/api/")[0]
+		// reading the credentials may run the credential process, so only read them once
+		d.ConnectionManager.Cache.Set(cacheKey, workspaceUrl)
 		return workspaceUrl, nil
 	}
 
 	return nil, nil
 }
 
+// qualInt64Values returns the values of an int qual, which may be a single value or a list
+func qualInt64Values(qual *proto.QualValue) []int64 {
+	if list := qual.GetListValue(); list != nil {
+		values := make([]int64, 0, len(list.Values))
+		for _, value := range list.Values {
+			values = append(values, value.GetInt64Value())
+		}
+		return values
+	}
+	return []int64{qual.GetInt64Value()}
+}
+
 // Get QualValueList as an list of items
 func getQualListValues(ctx context.Context, quals map[string]*proto.QualValue, qualName string, qualType string) string {
 	switch qualType {